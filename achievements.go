@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxPlayLength caps how long one play is taken to have lasted. The
+// history only says when plays started, so a play is counted until the
+// next one started, and a stream left on overnight doesn't count as a
+// night of listening.
+const maxPlayLength = 2 * time.Hour
+
+// achievement is a badge earned from the listening history, just for fun
+type achievement struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Earned      bool   `json:"earned"`
+	Progress    int    `json:"progress"`
+	Goal        int    `json:"goal"`
+}
+
+// listeningTotals are what the achievements are counted from
+type listeningTotals struct {
+	plays, streams, bestStream         int
+	nightPlays, morningPlays, weekends int
+	hours                              float64
+	longestStreak                      int
+}
+
+// countTotals adds up the whole history for the achievements
+func countTotals(records []playRecord) listeningTotals {
+	var totals listeningTotals
+	plays := map[string]int{}
+	days := map[string]bool{}
+	for _, record := range records {
+		at := record.At.Local()
+		totals.plays++
+		days[at.Format("2006-01-02")] = true
+		switch hour := at.Hour(); {
+		case hour < 5:
+			totals.nightPlays++
+		case hour < 8:
+			totals.morningPlays++
+		}
+		if at.Weekday() == time.Saturday || at.Weekday() == time.Sunday {
+			totals.weekends++
+		}
+		key := record.URL
+		if key == "" {
+			key = record.Title
+		}
+		plays[key]++
+		totals.bestStream = max(totals.bestStream, plays[key])
+	}
+	totals.streams = len(plays)
+	totals.hours = listenedHours(records, time.Now())
+	totals.longestStreak = longestStreak(days)
+	return totals
+}
+
+// listenedHours estimates how long was spent listening: each play until
+// the next started, up to maxPlayLength
+func listenedHours(records []playRecord, now time.Time) float64 {
+	sorted := append([]playRecord(nil), records...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].At.Before(sorted[j].At) })
+	var total time.Duration
+	for i, record := range sorted {
+		end := now
+		if i+1 < len(sorted) {
+			end = sorted[i+1].At
+		}
+		total += min(max(end.Sub(record.At), 0), maxPlayLength)
+	}
+	return total.Hours()
+}
+
+// currentStreak returns how many days in a row, up to today, had a play.
+// A streak isn't broken until a whole day goes by without one.
+func currentStreak(records []playRecord, now time.Time) int {
+	days := map[string]bool{}
+	for _, record := range records {
+		days[record.At.Local().Format("2006-01-02")] = true
+	}
+	day := now.Local()
+	if !days[day.Format("2006-01-02")] {
+		day = day.AddDate(0, 0, -1)
+	}
+	streak := 0
+	for days[day.Format("2006-01-02")] {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+// buildAchievements works out which badges the history has earned, in
+// the order they're shown
+func buildAchievements(records []playRecord) []achievement {
+	totals := countTotals(records)
+	badge := func(name, description string, progress, goal int) achievement {
+		return achievement{Name: name, Description: description, Earned: progress >= goal, Progress: min(progress, goal), Goal: goal}
+	}
+	return []achievement{
+		badge("First Spin", "play your first stream", totals.plays, 1),
+		badge("Regular", "listen 7 days in a row", totals.longestStreak, 7),
+		badge("Devoted", "listen 30 days in a row", totals.longestStreak, 30),
+		badge("10 Hours In", "listen for 10 hours", int(totals.hours), 10),
+		badge("Centurion", "listen for 100 hours", int(totals.hours), 100),
+		badge("Lofi Lifer", "listen for 500 hours", int(totals.hours), 500),
+		badge("Night Owl", "start 10 streams between midnight and 5am", totals.nightPlays, 10),
+		badge("Early Bird", "start 10 streams between 5 and 8am", totals.morningPlays, 10),
+		badge("Weekend Warrior", "start 25 streams on weekends", totals.weekends, 25),
+		badge("Explorer", "play 10 different streams", totals.streams, 10),
+		badge("Loyal", "play the same stream 50 times", totals.bestStream, 50),
+	}
+}
+
+// achievementsSummary lists the badges earned and the closest one to go
+func achievementsSummary(achievements []achievement) string {
+	var earned []string
+	var next *achievement
+	for i, a := range achievements {
+		if a.Earned {
+			earned = append(earned, a.Name)
+			continue
+		}
+		// The one closest to done is next
+		if next == nil || a.Progress*next.Goal > next.Progress*a.Goal {
+			next = &achievements[i]
+		}
+	}
+	var summary string
+	if len(earned) > 0 {
+		summary = fmt.Sprintf("Achievements (%d/%d): %s\n", len(earned), len(achievements), strings.Join(earned, ", "))
+	}
+	if next != nil {
+		summary += fmt.Sprintf("Next up: %s, %s (%d/%d)\n", next.Name, next.Description, next.Progress, next.Goal)
+	}
+	return summary
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// alarmTickMsg checks whether the alarm is due
+type alarmTickMsg struct {
+	at time.Time // Identifies the alarm that scheduled the tick
+}
+
+// alarmTick schedules the next alarm check
+func alarmTick(at time.Time) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return alarmTickMsg{at: at}
+	})
+}
+
+// nextAlarmTime returns the next occurrence of an "HH:MM" clock time after now
+func nextAlarmTime(clock string, now time.Time) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid alarm time %q, use HH:MM", clock)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// alarmStatus returns the alarm line shown in the TUI
+func alarmStatus(cfg AlarmConfig) string {
+	return fmt.Sprintf("⏰ %s", cfg.Time)
+}
+
+// watchVolumeRamp raises mpv's volume from silence to target over the
+// given duration. mpv is started muted so the stream never blares.
+func watchVolumeRamp(ctx context.Context, target int, ramp time.Duration) {
+	start := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		progress := float64(time.Since(start)) / float64(ramp)
+		if progress >= 1 {
+			_ = mpvSetProperty("volume", target)
+			return
+		}
+		_ = mpvSetProperty("volume", float64(target)*progress)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestAlarmReplacesStreamPlaying(t *testing.T) {
+	for _, detached := range []bool{false, true} {
+		mpv := newFakeMPV(t)
+		m := testModel(t)
+		m.config.Alarm.Preset = m.config.Presets[0].Name
+		m.stream.set("Lofi Girl - Sleep", "https://www.youtube.com/watch?v=DWcJFNfaw9c")
+		if detached {
+			m.detached = true
+		} else {
+			m.pushView(nowPlayingView)
+		}
+		m.nextAlarm = time.Now().Add(-time.Second)
+
+		updated, _ := m.Update(alarmTickMsg{at: m.nextAlarm})
+		m = updated.(model)
+		if m.state != loadingView || !m.alarmRamp {
+			t.Fatalf("detached=%v: alarm didn't go off", detached)
+		}
+
+		// The old stream is stopped before the alarm's starts
+		updated, cmd := m.Update(streamURLMsg{id: m.loadID, url: "https://example.com/stream", title: m.loadingTitle})
+		m = updated.(model)
+		if !m.replacing {
+			t.Fatalf("detached=%v: alarm started alongside the stream playing", detached)
+		}
+		if cmd != nil {
+			cmd()
+		}
+		if !mpv.sent("quit") {
+			t.Fatalf("detached=%v: the stream playing wasn't stopped", detached)
+		}
+	}
+}
+
+func TestAlarmTakesOverNowPlaying(t *testing.T) {
+	newFakeMPV(t)
+	m := testModel(t)
+	m.config.Alarm.Preset = m.config.Presets[0].Name
+	m.stream.set("Lofi Girl - Sleep", "https://www.youtube.com/watch?v=DWcJFNfaw9c")
+	m.pushView(nowPlayingView)
+	m.zen = true
+	m.nextAlarm = time.Now().Add(-time.Second)
+
+	updated, _ := m.Update(alarmTickMsg{at: m.nextAlarm})
+	m = updated.(model)
+	if m.detached {
+		t.Error("the stream playing was taken for one left playing while browsing")
+	}
+	updated, _ = m.Update(streamURLMsg{id: m.loadID, url: "https://example.com/stream", title: m.loadingTitle})
+	m = updated.(model)
+	updated, _ = m.Update(streamEndedMsg{})
+	m = updated.(model)
+
+	if m.state != nowPlayingView || m.zen {
+		t.Errorf("state %v, zen %v; want the alarm's Now Playing", m.state, m.zen)
+	}
+	for _, view := range m.navStack {
+		if view == nowPlayingView {
+			t.Errorf("the old stream's Now Playing is still under the alarm's: %v", m.navStack)
+		}
+	}
+}
+
+func TestAlarmCancelledKeepsStreamPlaying(t *testing.T) {
+	mpv := newFakeMPV(t)
+	m := testModel(t)
+	m.config.Alarm.Preset = m.config.Presets[0].Name
+	m.stream.set("Lofi Girl - Sleep", "https://www.youtube.com/watch?v=DWcJFNfaw9c")
+	m.pushView(nowPlayingView)
+	m.nextAlarm = time.Now().Add(-time.Second)
+
+	updated, _ := m.Update(alarmTickMsg{at: m.nextAlarm})
+	m = updated.(model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+
+	if m.state != nowPlayingView || m.replaceStream {
+		t.Errorf("state %v, replaceStream %v; want back on Now Playing with nothing to replace", m.state, m.replaceStream)
+	}
+	if mpv.sent("quit") {
+		t.Error("the stream playing was stopped")
+	}
+}
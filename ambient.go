@@ -0,0 +1,462 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ebitengine/oto/v3"
+)
+
+// ambientRate is the sample rate the ambient sounds are made at
+const ambientRate = 44100
+
+// ambientSound is a sound the ambient generator can make
+type ambientSound struct {
+	id, name, icon string
+	detail         string // Shown muted after the name in the picker
+	generator      func(rng *rand.Rand) sampleGenerator
+}
+
+// sampleGenerator makes a sound one stereo sample at a time, in -1..1
+type sampleGenerator interface {
+	next() (left, right float64)
+}
+
+// ambientSounds lists the ambient sounds, in the order the picker shows them
+var ambientSounds = []ambientSound{
+	{"white", "White noise", "▒", "", func(rng *rand.Rand) sampleGenerator { return &whiteNoise{rng: rng} }},
+	{"pink", "Pink noise", "▒", "", func(rng *rand.Rand) sampleGenerator { return &pinkNoise{rng: rng} }},
+	{"brown", "Brown noise", "▒", "", func(rng *rand.Rand) sampleGenerator { return &brownNoise{rng: rng} }},
+	{"rain", "Rain", "🌧", "", func(rng *rand.Rand) sampleGenerator { return &rain{pink: pinkNoise{rng: rng}, rng: rng} }},
+	{"cafe", "Café murmur", "☕", "", func(rng *rand.Rand) sampleGenerator { return &cafe{pink: pinkNoise{rng: rng}, rng: rng} }},
+}
+
+// ambientChoices lists the ambient sounds followed by the configured tones
+func (m model) ambientChoices() []ambientSound {
+	return append(append([]ambientSound(nil), ambientSounds...), toneSounds(m.config.Ambient.Tones)...)
+}
+
+// whiteNoise has equal energy at every frequency: bright and hissy
+type whiteNoise struct {
+	rng *rand.Rand
+}
+
+func (w *whiteNoise) next() (float64, float64) {
+	return (w.rng.Float64()*2 - 1) * 0.2, (w.rng.Float64()*2 - 1) * 0.2
+}
+
+// pinkNoise falls off 3 dB an octave, like a waterfall, using Paul
+// Kellet's filter on white noise
+type pinkNoise struct {
+	rng *rand.Rand
+	b   [2][7]float64
+}
+
+func (p *pinkNoise) next() (float64, float64) {
+	var out [2]float64
+	for ch := range out {
+		white := p.rng.Float64()*2 - 1
+		b := &p.b[ch]
+		b[0] = 0.99886*b[0] + white*0.0555179
+		b[1] = 0.99332*b[1] + white*0.0750759
+		b[2] = 0.96900*b[2] + white*0.1538520
+		b[3] = 0.86650*b[3] + white*0.3104856
+		b[4] = 0.55000*b[4] + white*0.5329522
+		b[5] = -0.7616*b[5] - white*0.0168980
+		out[ch] = (b[0] + b[1] + b[2] + b[3] + b[4] + b[5] + b[6] + white*0.5362) * 0.06
+		b[6] = white * 0.115926
+	}
+	return out[0], out[1]
+}
+
+// brownNoise falls off 6 dB an octave: a deep rumble
+type brownNoise struct {
+	rng  *rand.Rand
+	last [2]float64
+}
+
+func (b *brownNoise) next() (float64, float64) {
+	var out [2]float64
+	for ch := range out {
+		white := b.rng.Float64()*2 - 1
+		b.last[ch] = (b.last[ch] + 0.02*white) / 1.02
+		out[ch] = b.last[ch] * 3.5 * 0.5
+	}
+	return out[0], out[1]
+}
+
+// rain is softened pink noise for the downpour with drops landing nearby
+type rain struct {
+	pink pinkNoise
+	rng  *rand.Rand
+	low  [2]float64 // Lowpass state, taking the edge off the noise
+	drop [2]float64 // Envelope of the drop sounding in each ear
+}
+
+func (r *rain) next() (float64, float64) {
+	left, right := r.pink.next()
+	in := [2]float64{left, right}
+	var out [2]float64
+	// About 40 drops a second, each a click of noise dying away in ~5ms
+	if r.rng.Float64() < 40.0/ambientRate {
+		amplitude := 0.15 + r.rng.Float64()*0.35
+		pan := r.rng.Float64()
+		r.drop[0] = math.Max(r.drop[0], amplitude*(1-pan))
+		r.drop[1] = math.Max(r.drop[1], amplitude*pan)
+	}
+	for ch := range out {
+		r.low[ch] += 0.25 * (in[ch] - r.low[ch])
+		out[ch] = r.low[ch]*1.4 + r.drop[ch]*(r.rng.Float64()*2-1)
+		r.drop[ch] *= 0.996
+	}
+	return out[0], out[1]
+}
+
+// cafe is the murmur of a room full of people, swelling and fading, with
+// a cup set down now and then
+type cafe struct {
+	pink       pinkNoise
+	rng        *rand.Rand
+	low, lower [2]float64 // Bandpass state, leaving the voice range
+	swell      [2]float64 // How loud the room is, wandering slowly
+	clink      float64    // Envelope of the clink sounding
+	clinkFreq  float64
+	clinkPhase float64
+	clinkPan   float64
+}
+
+func (c *cafe) next() (float64, float64) {
+	left, right := c.pink.next()
+	in := [2]float64{left, right}
+	var out [2]float64
+	for ch := range out {
+		// Keep roughly 150–800 Hz, where voices through a wall sit
+		c.low[ch] += 0.11 * (in[ch] - c.low[ch])
+		c.lower[ch] += 0.02 * (in[ch] - c.lower[ch])
+		c.swell[ch] += (c.rng.Float64()*2 - 1) * 0.0005
+		c.swell[ch] = math.Min(1, math.Max(0.4, c.swell[ch]))
+		out[ch] = (c.low[ch] - c.lower[ch]) * 5.5 * c.swell[ch]
+	}
+	// A clink every five seconds or so
+	if c.clink < 0.001 && c.rng.Float64() < 1.0/(5*ambientRate) {
+		c.clink = 0.05 + c.rng.Float64()*0.08
+		c.clinkFreq = 2000 + c.rng.Float64()*3000
+		c.clinkPan = c.rng.Float64()
+	}
+	if c.clink >= 0.001 {
+		c.clinkPhase += 2 * math.Pi * c.clinkFreq / ambientRate
+		tone := c.clink * math.Sin(c.clinkPhase)
+		out[0] += tone * (1 - c.clinkPan)
+		out[1] += tone * c.clinkPan
+		c.clink *= 0.9997
+	}
+	return out[0], out[1]
+}
+
+// pcmPlayers are the programs the ambient sound can be played through
+// when the sound card can't be opened directly, best first. Each reads
+// 16-bit little endian stereo at ambientRate from stdin.
+var pcmPlayers = []struct {
+	name string
+	args []string
+}{
+	{"pw-cat", []string{"--playback", "--format=s16", "--rate=44100", "--channels=2",
+		"--properties", `{"application.name": "` + audioClientName + `"}`, "-"}},
+	{"paplay", []string{"--raw", "--format=s16le", "--rate=44100", "--channels=2"}},
+	{"aplay", []string{"-q", "-t", "raw", "-f", "S16_LE", "-r", "44100", "-c", "2"}},
+	{"play", []string{"-q", "-t", "raw", "-r", "44100", "-e", "signed", "-b", "16", "-c", "2", "-"}},
+	{"ffplay", []string{"-nodisp", "-loglevel", "quiet", "-f", "s16le", "-ar", "44100", "-ac", "2", "-i", "-"}},
+	{"mpv", []string{"--really-quiet", "--no-video", "--audio-client-name=" + audioClientName, "--demuxer=rawaudio", "--demuxer-rawaudio-rate=44100",
+		"--demuxer-rawaudio-channels=2", "--demuxer-rawaudio-format=s16le", "-"}},
+}
+
+// findPCMPlayer returns the command to play raw audio through
+func findPCMPlayer() (*exec.Cmd, error) {
+	for _, player := range pcmPlayers {
+		// aplay bypasses PulseAudio's mixing on desktops; it's only there
+		// for bare ALSA systems, and doesn't exist on macOS anyway
+		if player.name == "aplay" && runtime.GOOS != "linux" {
+			continue
+		}
+		if _, err := exec.LookPath(player.name); err == nil {
+			return exec.Command(player.name, player.args...), nil
+		}
+	}
+	names := make([]string, len(pcmPlayers))
+	for i, player := range pcmPlayers {
+		names[i] = player.name
+	}
+	return nil, fmt.Errorf("nothing to play sound through; install one of %s", strings.Join(names, ", "))
+}
+
+// The process's one oto context, which oto only allows one of, opened the
+// first time a sound plays
+var (
+	audioContextOnce sync.Once
+	audioContextErr  error
+	sharedAudio      *oto.Context
+)
+
+// audioContext opens the sound card through oto: Core Audio on macOS,
+// WASAPI on Windows, and PulseAudio or ALSA on Linux, with nothing to
+// install and no cgo
+func audioContext() (*oto.Context, error) {
+	audioContextOnce.Do(func() {
+		ctx, ready, err := oto.NewContext(&oto.NewContextOptions{
+			SampleRate:      ambientRate,
+			ChannelCount:    2,
+			Format:          oto.FormatSignedInt16LE,
+			ApplicationName: audioClientName,
+		})
+		if err != nil {
+			audioContextErr = err
+			return
+		}
+		<-ready
+		// Opening the device itself fails only once it's ready
+		if err := ctx.Err(); err != nil {
+			audioContextErr = err
+			return
+		}
+		sharedAudio = ctx
+	})
+	return sharedAudio, audioContextErr
+}
+
+// audioOutput is somewhere an ambient sound plays: the sound card, or a
+// program from pcmPlayers
+type audioOutput struct {
+	name  string
+	w     io.Writer     // Takes 16-bit little endian stereo at ambientRate
+	stop  func()        // Silences it
+	ended chan struct{} // Closed once it's stopped, or its program exited
+}
+
+// openAudioOutput opens the sound card, or failing that, e.g. with no
+// sound server to talk to, starts the first of pcmPlayers installed
+func openAudioOutput() (*audioOutput, error) {
+	ctx, err := audioContext()
+	if err == nil {
+		r, w := io.Pipe()
+		player := ctx.NewPlayer(r)
+		player.Play()
+		out := &audioOutput{name: "the sound card", w: w, ended: make(chan struct{})}
+		var once sync.Once
+		out.stop = func() {
+			once.Do(func() {
+				// Fails the write feeding it, as well as oto's read
+				r.Close()
+				_ = player.Close()
+				close(out.ended)
+			})
+		}
+		return out, nil
+	}
+	slog.Warn("can't open the sound card, trying a player program", "err", err)
+
+	cmd, err := findPCMPlayer()
+	if err != nil {
+		return nil, err
+	}
+	// Named after lofitui in PulseAudio, so ducking leaves it be
+	cmd.Env = append(os.Environ(), "PULSE_PROP=application.name="+audioClientName)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cmd.Args[0], err)
+	}
+	out := &audioOutput{name: cmd.Args[0], w: stdin, ended: make(chan struct{})}
+	out.stop = func() {
+		stdin.Close()
+		_ = cmd.Process.Kill()
+	}
+	go func() {
+		_ = cmd.Wait()
+		close(out.ended)
+	}()
+	return out, nil
+}
+
+// checkAmbientPlayer reports what the ambient sounds would play through
+func checkAmbientPlayer() doctorCheck {
+	check := doctorCheck{name: "ambient sounds"}
+	if _, err := audioContext(); err == nil {
+		check.status, check.detail = "ok", "played straight to the sound card"
+		return check
+	}
+	cmd, err := findPCMPlayer()
+	if err != nil {
+		check.status, check.detail = "warn", "can't open the sound card, and nothing else to play them through"
+		check.fix = "start PipeWire or PulseAudio, or install sox"
+		return check
+	}
+	check.status, check.detail = "ok", "played through "+cmd.Args[0]+", since the sound card can't be opened directly"
+	return check
+}
+
+// ambientPlayer makes an ambient sound and plays it alongside any stream.
+// It's shared between the TUI and its goroutines.
+type ambientPlayer struct {
+	mu     sync.Mutex
+	sound  ambientSound // The sound playing, with no id when stopped
+	out    *audioOutput
+	volume atomic.Int32 // 0-100
+	muted  atomic.Bool
+	duckTo atomic.Int32 // Percent of the volume to play at, lowered while other apps play audio
+}
+
+// newAmbientPlayer returns a stopped player at the given volume
+func newAmbientPlayer(volume int) *ambientPlayer {
+	a := &ambientPlayer{}
+	a.volume.Store(int32(volume))
+	a.duckTo.Store(100)
+	return a
+}
+
+// playing returns the sound playing, if any
+func (a *ambientPlayer) playing() (ambientSound, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sound, a.sound.id != ""
+}
+
+// play starts making a sound, replacing the one playing
+func (a *ambientPlayer) play(sound ambientSound) error {
+	a.stop()
+
+	out, err := openAudioOutput()
+	if err != nil {
+		return err
+	}
+	slog.Info("ambient sound started", "sound", sound.id, "output", out.name)
+
+	a.mu.Lock()
+	a.sound, a.out = sound, out
+	a.mu.Unlock()
+	generator := sound.generator(rand.New(rand.NewSource(time.Now().UnixNano())))
+	go a.feed(generator, out.w)
+	go func() {
+		<-out.ended
+		a.mu.Lock()
+		if a.out == out {
+			a.sound, a.out = ambientSound{}, nil
+		}
+		a.mu.Unlock()
+	}()
+	return nil
+}
+
+// feed writes the sound to the output until the output goes away. Writes
+// block while the output's buffer is full, which keeps this in real time.
+func (a *ambientPlayer) feed(generator sampleGenerator, out io.Writer) {
+	const frames = 2048
+	buf := make([]byte, frames*4)
+	gain := float64(a.volume.Load()) / 100
+	for {
+		target := float64(a.volume.Load()) / 100 * float64(a.duckTo.Load()) / 100
+		if a.muted.Load() {
+			target = 0
+		}
+		for i := 0; i < frames; i++ {
+			// Glide to a new volume rather than jumping, which clicks
+			gain += (target - gain) * 0.001
+			left, right := generator.next()
+			binary.LittleEndian.PutUint16(buf[i*4:], uint16(toPCM(left*gain)))
+			binary.LittleEndian.PutUint16(buf[i*4+2:], uint16(toPCM(right*gain)))
+		}
+		if _, err := out.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+// toPCM converts a sample in -1..1 to 16 bits, clipping anything louder
+func toPCM(sample float64) int16 {
+	return int16(math.Max(-1, math.Min(1, sample)) * math.MaxInt16)
+}
+
+// stop ends the sound playing, if any
+func (a *ambientPlayer) stop() {
+	a.mu.Lock()
+	out := a.out
+	a.sound, a.out = ambientSound{}, nil
+	a.mu.Unlock()
+	if out == nil {
+		return
+	}
+	out.stop()
+	slog.Info("ambient sound stopped")
+}
+
+// setVolume changes the volume, 0-100, while playing or before
+func (a *ambientPlayer) setVolume(volume int) {
+	a.volume.Store(int32(max(0, min(100, volume))))
+}
+
+// status is the sound playing for the main menu title, "" when stopped
+func (a *ambientPlayer) status() string {
+	sound, ok := a.playing()
+	if !ok {
+		return ""
+	}
+	if a.muted.Load() {
+		return fmt.Sprintf("%s %s muted", sound.icon, sound.name)
+	}
+	return fmt.Sprintf("%s %s %d%%", sound.icon, sound.name, a.volume.Load())
+}
+
+// openAmbient shows the ambient sound picker, on the sound playing if any
+func (m *model) openAmbient() {
+	m.pushView(ambientView)
+	m.ambientError = ""
+	if sound, ok := m.ambient.playing(); ok {
+		for i, choice := range m.ambientChoices() {
+			if choice.id == sound.id {
+				m.ambientCursor = i
+			}
+		}
+	}
+}
+
+// ambientContent renders the ambient sound picker
+func (m model) ambientContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	playing, _ := m.ambient.playing()
+
+	var rows []string
+	for i, sound := range m.ambientChoices() {
+		row := sound.icon + " " + sound.name
+		if sound.detail != "" {
+			row += " " + muted.Render(sound.detail)
+		}
+		if sound.id == playing.id {
+			row += muted.Render("  playing")
+		}
+		if i == m.ambientCursor {
+			row = lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• ") + row
+		} else {
+			row = "  " + row
+		}
+		rows = append(rows, row)
+	}
+
+	content := fmt.Sprintf("Ambient Sounds\n\n%s\n\nVolume %d%%", strings.Join(rows, "\n"), m.ambient.volume.Load())
+	if m.ambientError != "" {
+		content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Danger).Render(m.ambientError)
+	}
+	return content + "\n\n" + muted.Render("Enter to play/stop • +/- volume • ESC to go back")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// audioDevice is one of mpv's audio outputs
+type audioDevice struct {
+	name        string // What mpv's audio-device takes, e.g. "pulse/raop_sink.Kitchen.local"
+	description string // Human readable, e.g. "Kitchen (AirPlay)"
+}
+
+// airplay reports whether the output is an AirPlay speaker: a RAOP sink
+// PulseAudio or PipeWire found on the network, or one macOS offers
+func (d audioDevice) airplay() bool {
+	text := strings.ToLower(d.name + " " + d.description)
+	return strings.Contains(text, "raop") || strings.Contains(text, "airplay")
+}
+
+// audioDeviceArgs picks the configured output when starting mpv
+func audioDeviceArgs(device string) []string {
+	if device == "" || device == "auto" {
+		return nil
+	}
+	return []string{"--audio-device=" + device}
+}
+
+// audioDevicesMsg lists mpv's outputs and the one in use
+type audioDevicesMsg struct {
+	devices []audioDevice
+	current string
+	err     error
+}
+
+// fetchAudioDevices asks mpv which outputs it can play to
+func fetchAudioDevices() tea.Cmd {
+	return func() tea.Msg {
+		data, err := mpvCommand("get_property", "audio-device-list")
+		if err != nil {
+			return audioDevicesMsg{err: err}
+		}
+		list, _ := data.([]interface{})
+		var devices []audioDevice
+		for _, entry := range list {
+			fields, _ := entry.(map[string]interface{})
+			name, _ := fields["name"].(string)
+			description, _ := fields["description"].(string)
+			if name != "" {
+				devices = append(devices, audioDevice{name: name, description: description})
+			}
+		}
+		current, _ := mpvGetString("audio-device")
+		return audioDevicesMsg{devices: devices, current: current}
+	}
+}
+
+// switchAudioDevice moves playback to another output
+func switchAudioDevice(name string) tea.Cmd {
+	return func() tea.Msg {
+		_ = mpvSetProperty("audio-device", name)
+		return nil
+	}
+}
+
+// audioDeviceContent renders the output picker
+func (m model) audioDeviceContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	var rows []string
+	switch {
+	case m.audioError != "":
+		rows = append(rows, lipgloss.NewStyle().Foreground(theme.Danger).Render(m.audioError))
+	case m.audioDevices == nil:
+		rows = append(rows, muted.Render("Asking mpv…"))
+	}
+	for i, device := range m.audioDevices {
+		label := device.description
+		if label == "" {
+			label = device.name
+		}
+		if device.name == m.audioCurrent {
+			label += " ✓"
+		}
+		if device.airplay() {
+			label += lipgloss.NewStyle().Foreground(theme.Info).Render(" AirPlay")
+		}
+		if i == m.audioCursor {
+			rows = append(rows, lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• ")+label)
+		} else {
+			rows = append(rows, "  "+label)
+		}
+	}
+	return fmt.Sprintf("Audio Output\n\n%s\n\n%s", strings.Join(rows, "\n"), muted.Render("Enter to switch • ESC to cancel"))
+}
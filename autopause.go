@@ -0,0 +1,135 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logind's bus name and the signals that say the session locked or the
+// machine is about to sleep
+const (
+	logindName    = "org.freedesktop.login1"
+	logindManager = "org.freedesktop.login1.Manager"
+	logindSession = "org.freedesktop.login1.Session"
+)
+
+// autoPauser pauses mpv while the session is locked or asleep, and knows
+// whether it was us who paused it
+type autoPauser struct {
+	cfg    AutoPauseConfig
+	locked bool // The session is locked
+	paused bool // We paused playback and haven't resumed it
+}
+
+// watchAutoPause follows logind on the system bus for as long as the
+// process runs, pausing playback on lock and sleep. Without logind, e.g.
+// on macOS, it does nothing.
+func watchAutoPause(cfg AutoPauseConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	conn, err := dbusSystemBus()
+	if err != nil {
+		slog.Debug("no system bus, not pausing on lock", "err", err)
+		return
+	}
+	defer conn.conn.Close()
+
+	// Only our own session's locking matters; if logind doesn't know which
+	// that is, any session's will do
+	session := ""
+	if body, err := conn.call(logindName, "/org/freedesktop/login1", logindManager, "GetSessionByPID", uint32(os.Getpid())); err == nil && len(body) > 0 {
+		if path, ok := body[0].(dbusObjectPath); ok {
+			session = ",path='" + string(path) + "'"
+		}
+	}
+	for _, rule := range []string{
+		"type='signal',sender='" + logindName + "',interface='" + logindManager + "',member='PrepareForSleep'",
+		"type='signal',sender='" + logindName + "',interface='" + logindSession + "'" + session,
+		"type='signal',sender='" + logindName + "',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'" + session,
+	} {
+		if _, err := conn.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", rule); err != nil {
+			slog.Warn("can't watch logind, not pausing on lock", "err", err)
+			return
+		}
+	}
+	slog.Debug("pausing on lock and sleep", "resume", cfg.Resume)
+
+	a := &autoPauser{cfg: cfg}
+	for {
+		msg, err := conn.read()
+		if err != nil {
+			slog.Debug("lost the system bus", "err", err)
+			return
+		}
+		if msg.kind != dbusSignal {
+			continue
+		}
+		switch {
+		case msg.iface == logindManager && msg.member == "PrepareForSleep" && len(msg.body) > 0:
+			// True going to sleep, false waking up
+			if sleeping, _ := msg.body[0].(bool); sleeping {
+				a.pause("sleep")
+			} else if !a.locked {
+				a.resume("wake")
+			}
+		case msg.iface == logindSession && msg.member == "Lock":
+			a.lock(true)
+		case msg.iface == logindSession && msg.member == "Unlock":
+			a.lock(false)
+		case msg.member == "PropertiesChanged" && len(msg.body) > 1:
+			// Desktops that lock the screen themselves set LockedHint
+			if iface, _ := msg.body[0].(string); !strings.HasPrefix(iface, logindSession) {
+				continue
+			}
+			changed, _ := msg.body[1].([]interface{})
+			for _, entry := range changed {
+				if pair, ok := entry.([]interface{}); ok && len(pair) == 2 && pair[0] == "LockedHint" {
+					if locked, ok := pair[1].(bool); ok {
+						a.lock(locked)
+					}
+				}
+			}
+		}
+	}
+}
+
+// lock notes the session locking or unlocking
+func (a *autoPauser) lock(locked bool) {
+	if locked == a.locked {
+		return
+	}
+	a.locked = locked
+	if locked {
+		a.pause("lock")
+	} else {
+		a.resume("unlock")
+	}
+}
+
+// pause pauses mpv if it's playing
+func (a *autoPauser) pause(reason string) {
+	if paused, err := mpvGetBool("pause"); err != nil || paused {
+		return
+	}
+	if err := mpvSetProperty("pause", true); err != nil {
+		return
+	}
+	slog.Info("paused", "reason", reason)
+	a.paused = true
+}
+
+// resume resumes mpv if we paused it and resuming is wanted
+func (a *autoPauser) resume(reason string) {
+	if !a.paused {
+		return
+	}
+	a.paused = false
+	if !a.cfg.Resume {
+		return
+	}
+	if err := mpvSetProperty("pause", false); err == nil {
+		slog.Info("resumed", "reason", reason)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// backend is a player that takes the terminal to play a stream. Only mpv
+// can be controlled while it plays; the others are just run until they
+// exit.
+type backend interface {
+	// command builds the command playing streamURL, extracted from pageURL,
+	// with video rendered if video is set. extraArgs are mpv options, which
+	// other players ignore.
+	command(streamURL, pageURL, title string, video bool, extraArgs []string) *exec.Cmd
+	// controllable reports whether lofitui can talk to it over mpv's IPC,
+	// for background playback, volume, pause and the rest
+	controllable() bool
+}
+
+// playerBackend returns the player the config asks for, mpv by default
+func playerBackend(config *Config) (backend, error) {
+	switch config.Player {
+	case "", "mpv":
+		return mpvBackend{}, nil
+	case "vlc":
+		return vlcBackend{}, nil
+	case "ffplay":
+		return ffplayBackend{}, nil
+	case "custom":
+		if len(config.PlayerCommand) == 0 {
+			return nil, fmt.Errorf(`player is "custom" but player_command is empty`)
+		}
+		return customBackend{template: config.PlayerCommand}, nil
+	}
+	return nil, fmt.Errorf("unknown player %q: use mpv, vlc, ffplay or custom", config.Player)
+}
+
+// backendBinary returns the program the config's player runs, for the
+// doctor and the dependency check
+func backendBinary(config *Config) string {
+	switch config.Player {
+	case "", "mpv":
+		return "mpv"
+	case "custom":
+		if len(config.PlayerCommand) > 0 {
+			return config.PlayerCommand[0]
+		}
+		return ""
+	}
+	return config.Player
+}
+
+type mpvBackend struct{}
+
+func (mpvBackend) command(streamURL, _, title string, video bool, extraArgs []string) *exec.Cmd {
+	vo := "--no-video"
+	if video {
+		vo = "--vo=tct"
+	}
+	return exec.Command("mpv", mpvArgs(streamURL, title, []string{vo, "--quiet", "--input-ipc-server=" + getMPVSocketPath()}, extraArgs)...)
+}
+
+func (mpvBackend) controllable() bool { return true }
+
+// vlcBackend plays with VLC's terminal interface, which q quits
+type vlcBackend struct{}
+
+func (vlcBackend) command(streamURL, _, title string, video bool, _ []string) *exec.Cmd {
+	args := []string{"--intf=ncurses", "--play-and-exit", "--meta-title=" + title}
+	if !video {
+		args = append(args, "--no-video")
+	}
+	return exec.Command("vlc", append(args, streamURL)...)
+}
+
+func (vlcBackend) controllable() bool { return false }
+
+// ffplayBackend plays with ffplay. Video opens a window, which q quits;
+// audio only has none, so Ctrl+C stops it.
+type ffplayBackend struct{}
+
+func (ffplayBackend) command(streamURL, _, title string, video bool, _ []string) *exec.Cmd {
+	args := []string{"-autoexit", "-loglevel", "warning", "-window_title", title}
+	if !video {
+		args = append(args, "-nodisp")
+	}
+	return exec.Command("ffplay", append(args, streamURL)...)
+}
+
+func (ffplayBackend) controllable() bool { return false }
+
+// customBackend runs a command from the config, filling in {url} (the
+// extracted stream), {page_url} (the preset's URL, e.g. for streamlink,
+// which does its own extraction) and {title}
+type customBackend struct {
+	template []string
+}
+
+func (b customBackend) command(streamURL, pageURL, title string, _ bool, _ []string) *exec.Cmd {
+	replacer := strings.NewReplacer("{url}", streamURL, "{page_url}", pageURL, "{title}", title)
+	args := make([]string, len(b.template))
+	for i, arg := range b.template {
+		args[i] = replacer.Replace(arg)
+	}
+	return exec.Command(args[0], args[1:]...)
+}
+
+func (customBackend) controllable() bool { return false }
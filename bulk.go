@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// toggleMark marks or unmarks a preset for a bulk action
+func (m *model) toggleMark(index int) {
+	if m.marked[index] {
+		delete(m.marked, index)
+	} else {
+		m.marked[index] = true
+	}
+}
+
+// clearMarks unmarks every preset. The map is shared with the list delegate,
+// so it's emptied rather than replaced.
+func (m *model) clearMarks() {
+	for index := range m.marked {
+		delete(m.marked, index)
+	}
+}
+
+// bulkTargets returns the config indexes a manage action applies to: the
+// marked presets if any, otherwise the one under the cursor
+func (m model) bulkTargets() []int {
+	if len(m.marked) == 0 {
+		if preset, ok := m.list.SelectedItem().(presetItem); ok {
+			return []int{preset.index}
+		}
+		return nil
+	}
+	var indexes []int
+	for index := range m.marked {
+		if index < len(m.config.Presets) {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// deletePresets removes the presets at the given config indexes
+func deletePresets(presets []Preset, indexes []int) []Preset {
+	remove := map[int]bool{}
+	for _, index := range indexes {
+		remove[index] = true
+	}
+	var kept []Preset
+	for i, preset := range presets {
+		if !remove[i] {
+			kept = append(kept, preset)
+		}
+	}
+	return kept
+}
+
+// addTags adds tags a preset doesn't already have
+func addTags(preset *Preset, tags []string) {
+	for _, tag := range tags {
+		found := false
+		for _, existing := range preset.Tags {
+			if existing == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			preset.Tags = append(preset.Tags, tag)
+		}
+	}
+}
+
+// presetFile is the export format, which matches the config's "presets"
+// section so a whole config can be imported too
+type presetFile struct {
+	Presets []Preset `json:"presets"`
+}
+
+// marshalPresets encodes presets in the export format
+func marshalPresets(presets []Preset) ([]byte, error) {
+	data, err := json.MarshalIndent(presetFile{presets}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal presets: %w", err)
+	}
+	return data, nil
+}
+
+// unmarshalPresets decodes an export, checking every preset is usable
+func unmarshalPresets(data []byte) ([]Preset, error) {
+	var file presetFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse presets: %w", err)
+	}
+	for i, preset := range file.Presets {
+		if strings.TrimSpace(preset.Name) == "" || strings.TrimSpace(preset.URL) == "" {
+			return nil, fmt.Errorf("preset %d has no name or URL", i+1)
+		}
+	}
+	return file.Presets, nil
+}
+
+// mergePresets adds the presets whose names aren't taken yet, returning
+// how many were added
+func mergePresets(config *Config, presets []Preset) int {
+	added := 0
+	for _, preset := range presets {
+		if presetIndex(config.Presets, preset.Name) < 0 {
+			config.Presets = append(config.Presets, preset)
+			added++
+		}
+	}
+	return added
+}
+
+// exportPresets writes presets to a timestamped file in the config
+// directory, in the same format as the config's "presets" section
+func exportPresets(presets []Preset) (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := marshalPresets(presets)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(configDir, "export-"+time.Now().Format("20060102-150405")+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write export: %w", err)
+	}
+	return path, nil
+}
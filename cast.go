@@ -0,0 +1,807 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Cast v2 channel namespaces
+const (
+	castConnectionNS = "urn:x-cast:com.google.cast.tp.connection"
+	castHeartbeatNS  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	castReceiverNS   = "urn:x-cast:com.google.cast.receiver"
+	castMediaNS      = "urn:x-cast:com.google.cast.media"
+)
+
+// castMediaReceiver is Google's Default Media Receiver app, which plays
+// any URL it's given
+const castMediaReceiver = "CC1AD845"
+
+// castDiscoveryTime is how long discovery listens for devices,
+// castTimeout how long a device gets to start playing, and castHeartbeat
+// how often we ping it
+const (
+	castDiscoveryTime = 3 * time.Second
+	castTimeout       = 20 * time.Second
+	castHeartbeat     = 5 * time.Second
+)
+
+// castDevice is a Chromecast, Google speaker or DLNA renderer found on
+// the LAN
+type castDevice struct {
+	name  string // Friendly name, e.g. "Living Room speaker"
+	model string // e.g. "Google Home Mini"
+	dlna  bool   // A UPnP AV renderer rather than a Cast device
+	sonos bool   // A Sonos speaker, which is controlled as a UPnP AV renderer
+	addr  string // host:port of a Cast device's Cast v2 endpoint
+
+	// Control URLs of a DLNA renderer's services
+	avTransport, renderingControl string
+}
+
+// discoverCastDevices asks the LAN over mDNS for Cast devices, listening
+// for answers for castDiscoveryTime
+func discoverCastDevices() ([]castDevice, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Asking from a port other than 5353 gets answers sent straight back
+	group := &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	query := mdnsQuery("_googlecast._tcp.local")
+	if _, err := conn.WriteToUDP(query, group); err != nil {
+		return nil, err
+	}
+
+	records := map[string]*mdnsInstance{}
+	deadline := time.Now().Add(castDiscoveryTime)
+	resent := false
+	buf := make([]byte, 9000)
+	for time.Now().Before(deadline) {
+		// Ask again halfway through, for devices that missed the first query
+		wait := deadline
+		if !resent {
+			wait = time.Now().Add(castDiscoveryTime / 2)
+		}
+		_ = conn.SetReadDeadline(wait)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if !resent {
+					resent = true
+					_, _ = conn.WriteToUDP(query, group)
+				}
+				continue
+			}
+			return nil, err
+		}
+		_ = parseMDNS(buf[:n], from.IP, records)
+	}
+
+	var devices []castDevice
+	for name, instance := range records {
+		if !strings.HasSuffix(name, "._googlecast._tcp.local") || instance.port == 0 {
+			continue
+		}
+		device := castDevice{name: instance.txt["fn"], model: instance.txt["md"]}
+		if device.name == "" {
+			device.name = strings.TrimSuffix(name, "._googlecast._tcp.local")
+		}
+		ip := instance.from
+		if target := records[instance.target]; target != nil && target.ip != nil {
+			ip = target.ip
+		}
+		device.addr = net.JoinHostPort(ip.String(), fmt.Sprint(instance.port))
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// mdnsInstance collects what the records of one mDNS name say
+type mdnsInstance struct {
+	target string // SRV target host
+	port   int    // SRV port
+	ip     net.IP // A record
+	from   net.IP // Who answered, in case there's no A record
+	txt    map[string]string
+}
+
+// mdnsQuery builds a PTR question for a service
+func mdnsQuery(service string) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:], 1) // One question
+	for _, label := range strings.Split(service, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(msg, 12), 1) // PTR, IN
+}
+
+// parseMDNS reads the records of an mDNS response into records, by name
+func parseMDNS(msg []byte, from net.IP, records map[string]*mdnsInstance) error {
+	if len(msg) < 12 {
+		return errors.New("mdns: short message")
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	off := 12
+	for i := 0; i < questions; i++ {
+		_, next, err := dnsName(msg, off)
+		if err != nil {
+			return err
+		}
+		off = next + 4
+	}
+
+	instance := func(name string) *mdnsInstance {
+		if records[name] == nil {
+			records[name] = &mdnsInstance{from: from, txt: map[string]string{}}
+		}
+		return records[name]
+	}
+	for i := 0; i < answers; i++ {
+		name, next, err := dnsName(msg, off)
+		if err != nil {
+			return err
+		}
+		if next+10 > len(msg) {
+			return errors.New("mdns: short record")
+		}
+		rtype := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start, end := next+10, next+10+length
+		if end > len(msg) {
+			return errors.New("mdns: short record")
+		}
+		data := msg[start:end]
+
+		switch rtype {
+		case 12: // PTR: the service has an instance
+			target, _, err := dnsName(msg, start)
+			if err != nil {
+				return err
+			}
+			instance(target)
+		case 33: // SRV: where the instance is
+			if length < 7 {
+				break
+			}
+			target, _, err := dnsName(msg, start+6)
+			if err != nil {
+				return err
+			}
+			record := instance(name)
+			record.port = int(binary.BigEndian.Uint16(data[4:]))
+			record.target = target
+		case 16: // TXT: key=value strings
+			record := instance(name)
+			for len(data) > 0 && int(data[0]) < len(data) {
+				key, value, _ := strings.Cut(string(data[1:1+data[0]]), "=")
+				record.txt[key] = value
+				data = data[1+data[0]:]
+			}
+		case 1: // A
+			if length == 4 {
+				instance(name).ip = net.IP(append([]byte(nil), data...))
+			}
+		}
+		off = end
+	}
+	return nil
+}
+
+// dnsName reads a possibly compressed DNS name at off, returning it and
+// the offset after it
+func dnsName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("mdns: bad name")
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xC0 == 0xC0:
+			// A pointer to a name earlier in the message
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errors.New("mdns: bad name")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+length > len(msg) {
+				return "", 0, errors.New("mdns: bad name")
+			}
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
+
+// isHLS reports whether an extracted stream URL is an HLS manifest, which
+// is how YouTube serves live streams
+func isHLS(streamURL string) bool {
+	return strings.Contains(streamURL, ".m3u8") || strings.Contains(streamURL, "/manifest/")
+}
+
+// castMessage is a Cast v2 message with a JSON payload
+type castMessage struct {
+	source, destination, namespace string
+	payload                        string
+}
+
+// castConn is a Cast v2 connection: TLS carrying length-prefixed protobuf
+// CastMessages, which are simple enough to encode by hand
+type castConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex // Serializes writes
+}
+
+// castDial connects to a Cast device. They use self-signed certificates,
+// so there's nothing to verify them against.
+func castDial(addr string) (*castConn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	return &castConn{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// protoString appends a length-delimited protobuf field
+func protoString(b []byte, field int, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// send writes a JSON payload from our sender to a destination
+func (c *castConn) send(destination, namespace string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	msg := []byte{0x08, 0x00} // protocol_version CASTV2_1_0
+	msg = protoString(msg, 2, "sender-0")
+	msg = protoString(msg, 3, destination)
+	msg = protoString(msg, 4, namespace)
+	msg = append(msg, 0x28, 0x00) // payload_type STRING
+	msg = protoString(msg, 6, string(data))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...))
+	return err
+}
+
+// read reads the next message, skipping fields we don't use
+func (c *castConn) read() (castMessage, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return castMessage{}, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length > 64<<10 {
+		return castMessage{}, fmt.Errorf("cast: message too large (%d bytes)", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return castMessage{}, err
+	}
+
+	var msg castMessage
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return msg, errors.New("cast: bad message")
+		}
+		data = data[n:]
+		switch key & 7 {
+		case 0: // varint
+			_, n = binary.Uvarint(data)
+			if n <= 0 {
+				return msg, errors.New("cast: bad message")
+			}
+			data = data[n:]
+		case 2: // length-delimited
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return msg, errors.New("cast: bad message")
+			}
+			value := string(data[n : n+int(size)])
+			data = data[n+int(size):]
+			switch key >> 3 {
+			case 2:
+				msg.source = value
+			case 3:
+				msg.destination = value
+			case 4:
+				msg.namespace = value
+			case 6:
+				msg.payload = value
+			}
+		default:
+			return msg, fmt.Errorf("cast: unexpected wire type %d", key&7)
+		}
+	}
+	return msg, nil
+}
+
+// castReply is the part of receiver and media messages we look at
+type castReply struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Status json.RawMessage
+}
+
+// castReceiverStatus is a RECEIVER_STATUS message's status
+type castReceiverStatus struct {
+	Applications []struct {
+		AppID       string `json:"appId"`
+		SessionID   string `json:"sessionId"`
+		TransportID string `json:"transportId"`
+	} `json:"applications"`
+	Volume struct {
+		Level float64 `json:"level"`
+		Muted bool    `json:"muted"`
+	} `json:"volume"`
+}
+
+// castMediaStatus is one entry of a MEDIA_STATUS message's status
+type castMediaStatus struct {
+	MediaSessionID int    `json:"mediaSessionId"`
+	PlayerState    string `json:"playerState"` // IDLE, BUFFERING, PLAYING or PAUSED
+	IdleReason     string `json:"idleReason"`  // FINISHED, ERROR, CANCELLED or INTERRUPTED
+}
+
+// castStatus is what a device reports while casting
+type castStatus struct {
+	state  string  // The media's playerState
+	volume float64 // 0-100
+	muted  bool
+}
+
+// castSession is a stream playing on a device
+type castSession struct {
+	device   castDevice
+	control  castControl
+	statuses chan castStatus
+	done     chan struct{} // Closed when the session is over
+	mu       sync.Mutex
+	status   castStatus
+	stopped  bool  // Stopped by us rather than ending or dropping
+	err      error // Why the session ended, nil if stopped or finished
+}
+
+// castControl is how a session controls its device
+type castControl interface {
+	setVolume(volume float64) error // 0-100
+	setMuted(muted bool) error
+	pause(paused bool) error
+	stop() error // Stops playback and hangs up
+}
+
+// startCast has a device play a stream URL
+func startCast(device castDevice, streamURL, title string) (*castSession, error) {
+	s := &castSession{device: device, statuses: make(chan castStatus, 1), done: make(chan struct{})}
+	start := s.startChromecast
+	if device.dlna {
+		start = s.startDLNA
+	}
+	if err := start(streamURL, title); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// chromecast controls the Default Media Receiver running on a Cast device
+type chromecast struct {
+	conn           *castConn
+	sessionID      string // The receiver app's session, to stop it
+	transportID    string // Where media commands go
+	mediaSessionID int    // The stream loaded in the app
+}
+
+// startChromecast launches the Default Media Receiver on a Cast device
+// and has it play the stream
+func (s *castSession) startChromecast(streamURL, title string) error {
+	conn, err := castDial(s.device.addr)
+	if err != nil {
+		return err
+	}
+	cc := &chromecast{conn: conn}
+	if err := s.launch(cc, streamURL, title); err != nil {
+		conn.conn.Close()
+		return err
+	}
+	s.control = cc
+	go cc.heartbeat(s.done)
+	go s.readLoop(cc)
+	return nil
+}
+
+// launch starts the receiver app and loads the stream into it, waiting
+// until the device has accepted it
+func (s *castSession) launch(cc *chromecast, streamURL, title string) error {
+	c := cc.conn
+	_ = c.conn.SetDeadline(time.Now().Add(castTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.send("receiver-0", castConnectionNS, map[string]string{"type": "CONNECT"}); err != nil {
+		return err
+	}
+	if err := c.send("receiver-0", castReceiverNS, map[string]interface{}{"type": "LAUNCH", "appId": castMediaReceiver, "requestId": 1}); err != nil {
+		return err
+	}
+
+	// HLS manifests are live streams; anything else is a plain file
+	media := map[string]interface{}{
+		"contentId":   streamURL,
+		"streamType":  "BUFFERED",
+		"contentType": "video/mp4",
+		"metadata":    map[string]interface{}{"metadataType": 0, "title": title},
+	}
+	if isHLS(streamURL) {
+		media["streamType"], media["contentType"] = "LIVE", "application/x-mpegURL"
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return err
+		}
+		var reply castReply
+		if json.Unmarshal([]byte(msg.payload), &reply) != nil {
+			continue
+		}
+		switch reply.Type {
+		case "PING":
+			_ = c.send(msg.source, castHeartbeatNS, map[string]string{"type": "PONG"})
+		case "LAUNCH_ERROR":
+			return fmt.Errorf("cast: %s couldn't start the media receiver (%s)", s.device.name, reply.Reason)
+		case "LOAD_FAILED", "LOAD_CANCELLED", "INVALID_REQUEST":
+			return fmt.Errorf("cast: %s couldn't play the stream (%s)", s.device.name, strings.ToLower(reply.Type))
+		case "RECEIVER_STATUS":
+			var status castReceiverStatus
+			if json.Unmarshal(reply.Status, &status) != nil {
+				continue
+			}
+			s.setStatus(func(cs *castStatus) { cs.volume, cs.muted = status.Volume.Level*100, status.Volume.Muted })
+			if cc.transportID != "" {
+				continue
+			}
+			for _, app := range status.Applications {
+				if app.AppID != castMediaReceiver || app.TransportID == "" {
+					continue
+				}
+				cc.transportID, cc.sessionID = app.TransportID, app.SessionID
+				if err := c.send(cc.transportID, castConnectionNS, map[string]string{"type": "CONNECT"}); err != nil {
+					return err
+				}
+				load := map[string]interface{}{"type": "LOAD", "requestId": 2, "media": media, "autoplay": true}
+				if err := c.send(cc.transportID, castMediaNS, load); err != nil {
+					return err
+				}
+			}
+		case "MEDIA_STATUS":
+			var statuses []castMediaStatus
+			if json.Unmarshal(reply.Status, &statuses) != nil || len(statuses) == 0 {
+				continue
+			}
+			if statuses[0].PlayerState == "IDLE" && statuses[0].IdleReason == "ERROR" {
+				return fmt.Errorf("cast: %s couldn't play the stream", s.device.name)
+			}
+			if statuses[0].MediaSessionID != 0 {
+				cc.mediaSessionID = statuses[0].MediaSessionID
+				s.setStatus(func(cs *castStatus) { cs.state = statuses[0].PlayerState })
+				return nil
+			}
+		}
+	}
+}
+
+// heartbeat pings the device so it keeps the connection open
+func (cc *chromecast) heartbeat(done <-chan struct{}) {
+	ticker := time.NewTicker(castHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_ = cc.conn.send("receiver-0", castHeartbeatNS, map[string]string{"type": "PING"})
+		}
+	}
+}
+
+// readLoop follows the device's status until the stream ends, it's cast
+// over by something else, or the connection goes
+func (s *castSession) readLoop(cc *chromecast) {
+	defer close(s.done)
+	defer cc.conn.conn.Close()
+	for {
+		// Heartbeats keep a live connection talking
+		_ = cc.conn.conn.SetReadDeadline(time.Now().Add(3 * castHeartbeat))
+		msg, err := cc.conn.read()
+		if err != nil {
+			s.finish(err)
+			return
+		}
+		var reply castReply
+		if json.Unmarshal([]byte(msg.payload), &reply) != nil {
+			continue
+		}
+		switch reply.Type {
+		case "PING":
+			_ = cc.conn.send(msg.source, castHeartbeatNS, map[string]string{"type": "PONG"})
+		case "CLOSE":
+			s.finish(fmt.Errorf("cast: %s closed the connection", s.device.name))
+			return
+		case "RECEIVER_STATUS":
+			var status castReceiverStatus
+			if json.Unmarshal(reply.Status, &status) != nil {
+				continue
+			}
+			running := false
+			for _, app := range status.Applications {
+				running = running || app.SessionID == cc.sessionID
+			}
+			if !running {
+				s.finish(fmt.Errorf("cast: %s is playing something else", s.device.name))
+				return
+			}
+			s.setStatus(func(cs *castStatus) { cs.volume, cs.muted = status.Volume.Level*100, status.Volume.Muted })
+		case "MEDIA_STATUS":
+			var statuses []castMediaStatus
+			if json.Unmarshal(reply.Status, &statuses) != nil || len(statuses) == 0 {
+				continue
+			}
+			if statuses[0].PlayerState == "IDLE" {
+				var err error
+				if statuses[0].IdleReason != "FINISHED" {
+					err = fmt.Errorf("cast: %s stopped playing (%s)", s.device.name, strings.ToLower(statuses[0].IdleReason))
+				}
+				s.finish(err)
+				return
+			}
+			s.setStatus(func(cs *castStatus) { cs.state = statuses[0].PlayerState })
+		}
+	}
+}
+
+// setStatus updates the status and offers it to the TUI, replacing one it
+// hasn't picked up yet
+func (s *castSession) setStatus(update func(*castStatus)) {
+	s.mu.Lock()
+	update(&s.status)
+	status := s.status
+	s.mu.Unlock()
+	select {
+	case <-s.statuses:
+	default:
+	}
+	s.statuses <- status
+}
+
+// finish records why the session ended, unless we stopped it
+func (s *castSession) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		s.err = err
+	}
+}
+
+// setVolume sets the device's volume, 0-100
+func (cc *chromecast) setVolume(volume float64) error {
+	level := min(max(volume, 0), 100) / 100
+	return cc.conn.send("receiver-0", castReceiverNS, map[string]interface{}{"type": "SET_VOLUME", "volume": map[string]float64{"level": level}, "requestId": 3})
+}
+
+// setMuted mutes or unmutes the device
+func (cc *chromecast) setMuted(muted bool) error {
+	return cc.conn.send("receiver-0", castReceiverNS, map[string]interface{}{"type": "SET_VOLUME", "volume": map[string]bool{"muted": muted}, "requestId": 4})
+}
+
+// pause pauses or resumes the stream
+func (cc *chromecast) pause(paused bool) error {
+	command := "PLAY"
+	if paused {
+		command = "PAUSE"
+	}
+	return cc.conn.send(cc.transportID, castMediaNS, map[string]interface{}{"type": command, "mediaSessionId": cc.mediaSessionID, "requestId": 6})
+}
+
+// stop closes the receiver app, silencing the device, and hangs up
+func (cc *chromecast) stop() error {
+	err := cc.conn.send("receiver-0", castReceiverNS, map[string]interface{}{"type": "STOP", "sessionId": cc.sessionID, "requestId": 5})
+	_ = cc.conn.conn.Close()
+	return err
+}
+
+// stop stops the device
+func (s *castSession) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.err = nil
+	s.mu.Unlock()
+	_ = s.control.stop()
+}
+
+// ended reports whether we stopped the session and why it ended otherwise
+func (s *castSession) ended() (stopped bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped, s.err
+}
+
+// castDevicesMsg is the result of looking for Cast devices
+type castDevicesMsg struct {
+	devices []castDevice
+	err     error
+}
+
+// castStartedMsg reports whether a device took the stream
+type castStartedMsg struct {
+	id      int // Matches model.loadID of the load it's for
+	session *castSession
+	err     error
+}
+
+// castStatusMsg is a status update from the device being cast to
+type castStatusMsg struct {
+	session *castSession
+	status  castStatus
+}
+
+// castEndedMsg means the cast is over
+type castEndedMsg struct {
+	session *castSession
+}
+
+// findCastDevices looks for Cast devices and DLNA renderers at once in
+// the background; it only fails if both searches do
+func findCastDevices() tea.Cmd {
+	return func() tea.Msg {
+		renderers := make(chan castDevicesMsg, 1)
+		go func() {
+			devices, err := discoverDLNARenderers()
+			renderers <- castDevicesMsg{devices: devices, err: err}
+		}()
+		devices, err := discoverCastDevices()
+		dlna := <-renderers
+		if err != nil && dlna.err != nil {
+			return castDevicesMsg{err: err}
+		}
+		devices = append(devices, dlna.devices...)
+		sort.Slice(devices, func(i, j int) bool { return devices[i].name < devices[j].name })
+		return castDevicesMsg{devices: devices}
+	}
+}
+
+// castStream sends an extracted stream to a device
+func castStream(id int, device castDevice, streamURL, title string) tea.Cmd {
+	return func() tea.Msg {
+		slog.Info("casting", "device", device.name, "addr", device.addr, "title", title)
+		session, err := startCast(device, streamURL, title)
+		if err != nil {
+			slog.Error("cast failed", "device", device.name, "err", err)
+		}
+		return castStartedMsg{id: id, session: session, err: err}
+	}
+}
+
+// waitCastStatus waits for the next status from the device, or the end
+// of the cast
+func waitCastStatus(session *castSession) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case status := <-session.statuses:
+			return castStatusMsg{session: session, status: status}
+		case <-session.done:
+			return castEndedMsg{session: session}
+		}
+	}
+}
+
+// castVolume changes the device's volume by delta
+func castVolume(session *castSession, current, delta float64) tea.Cmd {
+	return func() tea.Msg {
+		_ = session.control.setVolume(current + delta)
+		return nil
+	}
+}
+
+// castMute mutes or unmutes the device
+func castMute(session *castSession, muted bool) tea.Cmd {
+	return func() tea.Msg {
+		_ = session.control.setMuted(muted)
+		return nil
+	}
+}
+
+// castPause pauses or resumes the device; its status follows
+func castPause(session *castSession, paused bool) tea.Cmd {
+	return func() tea.Msg {
+		_ = session.control.pause(paused)
+		return nil
+	}
+}
+
+// stopCast stops the device; castEndedMsg follows
+func stopCast(session *castSession) tea.Cmd {
+	return func() tea.Msg {
+		session.stop()
+		return nil
+	}
+}
+
+// castPickerContent renders the list of Cast devices to choose from
+func (m model) castPickerContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	name := ""
+	if m.selectedIndex < len(m.config.Presets) {
+		name = m.config.Presets[m.selectedIndex].Name
+	}
+
+	var rows []string
+	switch {
+	case m.castScanning:
+		rows = append(rows, muted.Render("Looking for devices on your network…"))
+	case len(m.castDevices) == 0:
+		rows = append(rows, muted.Render("No Chromecasts, Google, Sonos or DLNA speakers found."))
+	}
+	if !m.castScanning {
+		for i, device := range m.castDevices {
+			row := device.name
+			if device.model != "" {
+				row += muted.Render(" (" + device.model + ")")
+			}
+			switch {
+			case device.sonos:
+				row += lipgloss.NewStyle().Foreground(theme.Info).Render(" Sonos")
+			case device.dlna:
+				row += lipgloss.NewStyle().Foreground(theme.Info).Render(" DLNA")
+			}
+			if i == m.castCursor {
+				row = lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• "+device.name) + strings.TrimPrefix(row, device.name)
+			} else {
+				row = "  " + row
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	content := fmt.Sprintf("Cast '%s' to:\n\n%s", name, strings.Join(rows, "\n"))
+	if m.castError != "" {
+		content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Danger).Render(m.castError)
+	}
+	return content + "\n\n" + muted.Render("Enter to cast • r to search again • ESC to cancel")
+}
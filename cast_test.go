@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// castPipe connects two castConns back to back
+func castPipe(t *testing.T) (*castConn, *castConn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return &castConn{conn: a, r: bufio.NewReader(a)}, &castConn{conn: b, r: bufio.NewReader(b)}
+}
+
+// castReaderOf reads Cast messages from raw bytes
+func castReaderOf(data []byte) *castConn {
+	return &castConn{r: bufio.NewReader(bytes.NewReader(data))}
+}
+
+// castFrame length-prefixes a CastMessage
+func castFrame(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+}
+
+func TestCastMessageEncoding(t *testing.T) {
+	sender, receiver := castPipe(t)
+	go func() { _ = sender.send("receiver-0", castReceiverNS, map[string]string{"type": "GET_STATUS"}) }()
+
+	var header [4]byte
+	if _, err := io.ReadFull(receiver.r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(receiver.r, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Field by field, as the CastMessage protobuf has them
+	want := []byte{0x08, 0x00}
+	want = append(want, 0x12, 8)
+	want = append(want, "sender-0"...)
+	want = append(want, 0x1a, 10)
+	want = append(want, "receiver-0"...)
+	want = append(want, 0x22, byte(len(castReceiverNS)))
+	want = append(want, castReceiverNS...)
+	want = append(want, 0x28, 0x00)
+	want = append(want, 0x32, 21)
+	want = append(want, `{"type":"GET_STATUS"}`...)
+	if !bytes.Equal(msg, want) {
+		t.Errorf("got  % x\nwant % x", msg, want)
+	}
+}
+
+func TestCastMessageRoundTrip(t *testing.T) {
+	sender, receiver := castPipe(t)
+	// Big enough for two-byte varint lengths
+	payload := map[string]interface{}{"type": "LOAD", "media": map[string]string{"contentId": strings.Repeat("x", 300)}}
+	go func() { _ = sender.send("web-5", castMediaNS, payload) }()
+
+	msg, err := receiver.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.source != "sender-0" || msg.destination != "web-5" || msg.namespace != castMediaNS {
+		t.Errorf("got %+v", msg)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(msg.payload), &got); err != nil || got["type"] != "LOAD" {
+		t.Errorf("payload %q: %v", msg.payload, err)
+	}
+}
+
+func TestCastReadSkipsUnknownFields(t *testing.T) {
+	msg := []byte{0x08, 0x00}
+	msg = protoString(msg, 2, "receiver-0")
+	msg = protoString(msg, 3, "sender-0")
+	msg = protoString(msg, 4, castHeartbeatNS)
+	msg = append(msg, 0x28, 0x00)
+	msg = protoString(msg, 6, `{"type":"PING"}`)
+	// A binary payload field, unused
+	msg = protoString(msg, 7, "\x00\x01")
+	// A field from a later protocol version, as a big varint
+	msg = append(msg, 0x40, 0xff, 0xff, 0x03)
+
+	got, err := castReaderOf(castFrame(msg)).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := castMessage{source: "receiver-0", destination: "sender-0", namespace: castHeartbeatNS, payload: `{"type":"PING"}`}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCastReadRejectsBadMessages(t *testing.T) {
+	tooLarge := binary.BigEndian.AppendUint32(nil, 64<<10+1)
+	tests := map[string][]byte{
+		"too large":           tooLarge,
+		"cut short":           castFrame(protoString(nil, 6, "hello"))[:7],
+		"string past the end": castFrame([]byte{0x32, 50, 'a'}),
+		"bad varint":          castFrame([]byte{0x08, 0xff}),
+		"fixed64 field":       castFrame([]byte{0x09, 1, 2, 3, 4, 5, 6, 7, 8}),
+	}
+	for name, data := range tests {
+		if msg, err := castReaderOf(data).read(); err == nil {
+			t.Errorf("%s: read %+v", name, msg)
+		}
+	}
+}
+
+func TestMDNSQuery(t *testing.T) {
+	want := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range []string{"_googlecast", "_tcp", "local"} {
+		want = append(append(want, byte(len(label))), label...)
+	}
+	want = append(want, 0, 0, 12, 0, 1)
+	if got := mdnsQuery("_googlecast._tcp.local"); !bytes.Equal(got, want) {
+		t.Errorf("got  % x\nwant % x", got, want)
+	}
+}
+
+// mdnsName encodes a DNS name without compression
+func mdnsName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(name, ".") {
+		b = append(append(b, byte(len(label))), label...)
+	}
+	return append(b, 0)
+}
+
+// mdnsRecord encodes a resource record of class IN
+func mdnsRecord(name []byte, rtype uint16, data []byte) []byte {
+	b := binary.BigEndian.AppendUint16(append([]byte(nil), name...), rtype)
+	b = binary.BigEndian.AppendUint16(b, 0x8001)
+	b = binary.BigEndian.AppendUint32(b, 120)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+func TestParseMDNS(t *testing.T) {
+	msg := []byte{0, 0, 0x84, 0, 0, 0, 0, 2, 0, 0, 0, 2}
+	service := len(msg)
+	ptrName := mdnsName("_googlecast._tcp.local")
+	instanceName := append(mdnsName("Kitchen-abc")[:12], 0xC0, byte(service))
+	msg = append(msg, mdnsRecord(ptrName, 12, instanceName)...)
+	// The instance name, compressed, as the SRV and TXT records' owner
+	instance := len(msg) - len(instanceName)
+	pointer := []byte{0xC0, byte(instance)}
+
+	srv := []byte{0, 0, 0, 0, 0x1F, 0x49}
+	srv = append(srv, mdnsName("kitchen.local")...)
+	msg = append(msg, mdnsRecord(pointer, 33, srv)...)
+	txt := []byte{}
+	for _, kv := range []string{"fn=Kitchen speaker", "md=Google Home Mini"} {
+		txt = append(append(txt, byte(len(kv))), kv...)
+	}
+	msg = append(msg, mdnsRecord(pointer, 16, txt)...)
+	msg = append(msg, mdnsRecord(mdnsName("kitchen.local"), 1, []byte{192, 168, 1, 20})...)
+
+	records := map[string]*mdnsInstance{}
+	if err := parseMDNS(msg, net.IPv4(192, 168, 1, 1), records); err != nil {
+		t.Fatal(err)
+	}
+	record := records["Kitchen-abc._googlecast._tcp.local"]
+	if record == nil {
+		t.Fatalf("no instance in %v", records)
+	}
+	if record.port != 8009 || record.target != "kitchen.local" || record.txt["fn"] != "Kitchen speaker" || record.txt["md"] != "Google Home Mini" {
+		t.Errorf("got %+v", record)
+	}
+	if host := records["kitchen.local"]; host == nil || !host.ip.Equal(net.IPv4(192, 168, 1, 20)) {
+		t.Errorf("no A record for the target: %+v", host)
+	}
+}
+
+func TestParseMDNSRejectsBadMessages(t *testing.T) {
+	header := []byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	tests := map[string][]byte{
+		"short":           header[:8],
+		"pointer loop":    append(append([]byte(nil), header...), 0xC0, 12),
+		"label past end":  append(append([]byte(nil), header...), 40, 'a'),
+		"record past end": append(append([]byte(nil), header...), mdnsRecord(mdnsName("a.local"), 1, []byte{1, 2, 3, 4})[:16]...),
+		"rdata past the end": func() []byte {
+			record := mdnsRecord(mdnsName("a.local"), 1, []byte{1, 2, 3, 4})
+			return append(append([]byte(nil), header...), record[:len(record)-2]...)
+		}(),
+	}
+	for name, msg := range tests {
+		if err := parseMDNS(msg, nil, map[string]*mdnsInstance{}); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes, so wrappers can tell failures apart
+const (
+	exitOK         = 0
+	exitError      = 1  // Anything not covered below
+	exitExtraction = 2  // The stream URL couldn't be extracted
+	exitNoPlayer   = 3  // mpv isn't installed
+	exitConfig     = 4  // The config couldn't be read, parsed or saved
+	exitUsage      = 64 // Bad arguments, as in sysexits.h
+)
+
+// quiet is set by -quiet and keeps progress messages off the terminal;
+// errors are still printed
+var quiet bool
+
+// codedError is an error that should end the process with a given code
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e codedError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code for an error, exitError unless it says
+func exitCode(err error) int {
+	var coded codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return exitError
+}
+
+// fail prints an error and returns its exit code
+func fail(code int, err error) int {
+	fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+	return code
+}
+
+// requirePlayer checks mpv is installed before trying to play
+func requirePlayer() error {
+	if _, err := exec.LookPath("mpv"); err != nil {
+		return codedError{exitNoPlayer, fmt.Errorf("mpv isn't installed; see lofitui doctor")}
+	}
+	return nil
+}
+
+// runPlay plays a preset (by name) or a URL straight away, for scripts and
+// launchers. It blocks until playback ends.
+func runPlay(args []string) int {
+	flags := newFlagSet("play")
+	video := flags.Bool("video", false, "Render the video in the terminal instead of playing audio only")
+	random := flags.Bool("random", false, "Play a random preset")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	target := strings.TrimSpace(strings.Join(positional, " "))
+	if (target == "") == !*random {
+		return usageError(flags, "play needs a preset name, a URL or -random")
+	}
+	if code, handed := handOffPlay(target, *random); handed {
+		return code
+	}
+
+	if err := requirePlayer(); err != nil {
+		return fail(exitCode(err), err)
+	}
+	streamURL, title, url, err := loadPlayTarget(target, *random, func(title string) {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Loading %s…\n", title)
+		}
+	})
+	if err != nil {
+		return fail(exitCode(err), err)
+	}
+
+	mode := []string{"--no-video"}
+	if *video && !audioOnly {
+		mode = []string{"--vo=tct", "--quiet"}
+	}
+	if quiet {
+		mode = append(mode, "--really-quiet")
+	}
+	mode = append(mode, "--input-ipc-server="+getMPVSocketPath())
+	config, err := loadConfig()
+	if err != nil {
+		config = getDefaultConfig()
+	}
+	cmd := exec.Command("mpv", mpvArgs(streamURL, title, mode, append(configArgs(config), presetArgs(config, url)...))...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: mpv: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// handOffPlay has the lofitui already running play target, in
+// single-instance mode: the TUI if one is open, otherwise the daemon
+func handOffPlay(target string, random bool) (code int, handed bool) {
+	config, err := loadConfig()
+	if err != nil || !config.SingleInstance {
+		return 0, false
+	}
+	if random {
+		if len(config.Presets) == 0 {
+			return 0, false
+		}
+		target = config.Presets[rand.Intn(len(config.Presets))].URL
+	}
+	message, ok, err := handOff(target)
+	if !ok {
+		message, err = askDaemon(daemonRequest{Command: "play", Args: []string{target}})
+		if errors.Is(err, errNoDaemon) {
+			return 0, false
+		}
+	}
+	if err != nil {
+		return fail(exitError, err), true
+	}
+	if !quiet {
+		fmt.Println("Handed over to the running lofitui: " + message)
+	}
+	return exitOK, true
+}
+
+// loadPlayTarget picks the preset (by name or at random) or URL to play
+// and extracts its stream URL, counting the play for the sort modes as the
+// TUI does. loading is told the title before the slow extraction starts.
+func loadPlayTarget(target string, random bool, loading func(title string)) (streamURL, title, url string, err error) {
+	config, err := loadConfig()
+	if err != nil {
+		return "", "", "", codedError{exitConfig, err}
+	}
+
+	url, title, index := target, "Custom Stream", -1
+	if random {
+		if len(config.Presets) == 0 {
+			return "", "", "", fmt.Errorf("there are no presets to pick from")
+		}
+		index = rand.Intn(len(config.Presets))
+		url, title = config.Presets[index].URL, config.Presets[index].Name
+	} else if !strings.Contains(target, "://") {
+		index, err = findPreset(config.Presets, target)
+		if err != nil {
+			return "", "", "", err
+		}
+		url, title = config.Presets[index].URL, config.Presets[index].Name
+	}
+
+	loading(title)
+	streamURL, err = resolveStreamURL(context.Background(), url, nil)
+	if err != nil {
+		return "", "", "", codedError{exitExtraction, fmt.Errorf("failed to load stream: %w", err)}
+	}
+	rememberPlay(title, url)
+	if index >= 0 {
+		recordPlay(config, index)
+		_ = saveConfig(config)
+	}
+	return streamURL, title, url, nil
+}
+
+// listEntry is a preset as printed by "lofitui list -json". Unlike the
+// config, every field is always present.
+type listEntry struct {
+	Name       string     `json:"name"`
+	URL        string     `json:"url"`
+	Tags       []string   `json:"tags"`
+	PlayCount  int        `json:"play_count"`
+	LastPlayed *time.Time `json:"last_played"`
+}
+
+// presetEntries converts presets for JSON output
+func presetEntries(presets []Preset) []listEntry {
+	entries := make([]listEntry, len(presets))
+	for i, preset := range presets {
+		tags := preset.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		entries[i] = listEntry{preset.Name, preset.URL, tags, preset.PlayCount, preset.LastPlayed}
+	}
+	return entries
+}
+
+// runList prints the presets as a table, or as JSON for scripts
+func runList(args []string) int {
+	flags := newFlagSet("list")
+	asJSON := flags.Bool("json", false, "Print JSON instead of a table")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "list takes no arguments")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(presetEntries(config.Presets), "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+			return exitError
+		}
+		fmt.Println(string(data))
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPLAYS\tTAGS\tURL")
+	for _, preset := range config.Presets {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", preset.Name, preset.PlayCount, strings.Join(preset.Tags, ","), preset.URL)
+	}
+	w.Flush()
+	return exitOK
+}
+
+// parseArgs parses flags that may come before, between or after the
+// positional arguments, and returns the positional ones
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// runAdd adds a preset
+func runAdd(args []string) int {
+	flags := newFlagSet("add")
+	tags := flags.String("tags", "", "Comma separated `tags`")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) != 2 {
+		return usageError(flags, "add needs a name and a URL")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+	if err := addPreset(config, Preset{Name: positional[0], URL: positional[1], Tags: parseTags(*tags)}); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+	return saveOrFail(config)
+}
+
+// runRemove removes a preset
+func runRemove(args []string) int {
+	flags := newFlagSet("rm")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "rm needs a preset name")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+	if err := removePreset(config, args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+	return saveOrFail(config)
+}
+
+// runEdit changes a preset's name, URL or tags, keeping its play stats
+func runEdit(args []string) int {
+	flags := newFlagSet("edit")
+	newName := flags.String("name", "", "New `name`")
+	newURL := flags.String("url", "", "New `URL`")
+	tags := flags.String("tags", "", "Comma separated `tags`, replacing the current ones")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "edit needs a preset name")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+	// Only change what was asked for; -tags "" clears the tags
+	var change presetChange
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			change.Name = newName
+		case "url":
+			change.URL = newURL
+		case "tags":
+			parsed := parseTags(*tags)
+			change.Tags = &parsed
+		}
+	})
+	if err := updatePreset(config, positional[0], change); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+	return saveOrFail(config)
+}
+
+// runExport prints the presets to stdout in the export format
+func runExport(args []string) int {
+	flags := newFlagSet("export")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "export takes no arguments")
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+	data, err := marshalPresets(config.Presets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+	fmt.Println(string(data))
+	return exitOK
+}
+
+// runImport replaces the presets with those in an export ("-" for stdin),
+// or with -merge adds the ones whose names aren't taken
+func runImport(args []string) int {
+	flags := newFlagSet("import")
+	merge := flags.Bool("merge", false, "Add to the presets instead of replacing them")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "import needs a file")
+	}
+
+	var data []byte
+	if positional[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(positional[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+	presets, err := unmarshalPresets(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+	message := fmt.Sprintf("Imported %d presets", len(presets))
+	if *merge {
+		message = fmt.Sprintf("Added %d of %d presets", mergePresets(config, presets), len(presets))
+	} else {
+		config.Presets = presets
+	}
+	if !quiet {
+		fmt.Fprintln(os.Stderr, message)
+	}
+	return saveOrFail(config)
+}
+
+// saveOrFail saves the config and returns the exit code
+func saveOrFail(config *Config) int {
+	if err := saveConfig(config); err != nil {
+		return fail(exitConfig, err)
+	}
+	return exitOK
+}
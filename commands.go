@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// command is a subcommand of the CLI. Each one parses its own flags with
+// newFlagSet, which also accepts the global flags and prints its help.
+type command struct {
+	name    string
+	args    string // Synopsis of the arguments, e.g. "<name> <url>"
+	summary string
+	run     func(args []string) int
+}
+
+// commands are the subcommands in the order help lists them. They're set
+// in init since their run functions print help from this list.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"play", "[-video] [-random] <name|url>", "Play a preset or URL without the TUI", runPlay},
+		{"resume", "", "Play whatever played last", runResume},
+		{"list", "[-json]", "List the presets", runList},
+		{"add", "<name> <url> [-tags a,b]", "Add a preset", runAdd},
+		{"rm", "<name>", "Remove a preset", runRemove},
+		{"edit", "<name> [-name new] [-url url] [-tags a,b]", "Change a preset", runEdit},
+		{"search", "<query> [-limit N] [-json]", "Find live streams on YouTube", runSearch},
+		{"packs", "list | install <name>", "List or install community preset packs", runPacks},
+		{"history", "[-limit N] [-json]", "Show what played recently", runHistory},
+		{"stats", "[-days N | -week] [-export csv|json]", "Sum up the listening history", runStats},
+		{"export", "", "Print the presets as JSON", runExport},
+		{"import", "<file|-> [-merge]", "Replace the presets with an export, or add to them", runImport},
+		{"download", "<name|url>...", "Save VODs for offline listening", runDownload},
+		{"doctor", "", "Check dependencies, config and network", runDoctor},
+		{"daemon", "", "Play headless, controlled with ctl", runDaemon},
+		{"status", "[-format template] [-waybar] [-follow]", "Print what the daemon is playing on one line, for status bars", runStatus},
+		{"tray", "", "Show a system tray icon controlling the daemon (needs a build with -tags tray)", runTray},
+		{"open", "<lofitui://link> | -register", "Open a lofitui:// link, or register lofitui to open them", runOpen},
+		{"sync", "host [-address addr] | join <address>", "Share what the daemon plays for friends to listen along, or join a session", runSync},
+		{"ctl", "<command> [args]", "Control the daemon: play [name|url], pause, stop, next, prev, volume [N|+N|-N], record, clip, status, quit", runCtl},
+		{"help", "[command]", "Show help for lofitui or a command", runHelp},
+		{"man", "", "Print the man page", runMan},
+	}
+}
+
+// Global flags, accepted before the command or among its own flags
+var (
+	configFile string // Use this config file instead of the default
+	profile    string // Use a named profile's config file
+	logLevel   string // Overrides the config's log_level
+)
+
+// globalFlags are the names addGlobalFlags registers, which command help
+// leaves out
+var globalFlags = map[string]bool{
+	"quiet": true, "theme": true, "no-color": true, "config": true, "profile": true, "log-level": true,
+}
+
+// helpOutput is where help goes: stderr when it's shown for a mistake,
+// stdout when it was asked for
+var helpOutput io.Writer = os.Stderr
+
+// addGlobalFlags registers the global flags on a flag set, keeping any
+// values already parsed
+func addGlobalFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&quiet, "quiet", quiet, "Only print errors")
+	fs.StringVar(&themeOverride, "theme", themeOverride, "Use a `theme` for this run: "+strings.Join(themeNames(), ", "))
+	fs.BoolVar(&noColor, "no-color", noColor, "Disable colors, like NO_COLOR")
+	fs.StringVar(&configFile, "config", configFile, "Use this config `file`")
+	fs.StringVar(&profile, "profile", profile, "Use the config of a `profile`, e.g. work")
+	fs.StringVar(&logLevel, "log-level", logLevel, "Log `level`: debug, info, warn or error")
+}
+
+// applyGlobalFlags checks the global flags and puts them into effect
+func applyGlobalFlags() error {
+	if _, ok := themes[themeOverride]; themeOverride != "" && !ok {
+		return fmt.Errorf("unknown theme %q; try %s", themeOverride, strings.Join(themeNames(), ", "))
+	}
+	if configFile != "" && profile != "" {
+		return fmt.Errorf("-config and -profile can't be used together")
+	}
+	if strings.ContainsAny(profile, `/\`) {
+		return fmt.Errorf("bad profile name %q", profile)
+	}
+	setupLogging()
+	if logLevel != "" {
+		level, err := parseLogLevel(logLevel)
+		if err != nil {
+			return err
+		}
+		logLevelVar.Set(level)
+	}
+
+	// Subcommands like doctor use the theme too; the TUI applies it again
+	// with the configured name
+	applyTheme("")
+	return nil
+}
+
+// findCommand returns the subcommand called name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand runs a subcommand and returns the process exit code
+func runCommand(args []string) int {
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return usageError(nil, fmt.Sprintf("unknown command %q", args[0]))
+	}
+	return cmd.run(args[1:])
+}
+
+// newFlagSet returns the flag set for a subcommand, with the global flags
+// and the command's help
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(helpOutput)
+	fs.Usage = func() {
+		printCommandHelp(fs.Output(), name, fs)
+	}
+	addGlobalFlags(fs)
+	return fs
+}
+
+// parseCommand parses a subcommand's arguments, flags and positional ones
+// in any order, and applies the global flags
+func parseCommand(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyGlobalFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return nil, err
+	}
+	return positional, nil
+}
+
+// parseFailed returns the exit code for a failed parseCommand: -h is fine,
+// anything else is bad usage
+func parseFailed(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// usageError prints a usage mistake with the command's help, or the
+// general usage if fs is nil, and returns exitUsage
+func usageError(fs *flag.FlagSet, message string) int {
+	fmt.Fprintf(os.Stderr, "lofitui: %s\n\n", message)
+	if fs != nil {
+		fs.Usage()
+	} else {
+		printUsage(os.Stderr)
+	}
+	return exitUsage
+}
+
+// printFlags lists the flags of fs that pass keep
+func printFlags(w io.Writer, fs *flag.FlagSet, keep func(name string) bool) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fs.VisitAll(func(f *flag.Flag) {
+		if !keep(f.Name) {
+			return
+		}
+		arg, help := flag.UnquoteUsage(f)
+		if arg != "" {
+			arg = " <" + arg + ">"
+		}
+		fmt.Fprintf(tw, "  -%s%s\t%s\n", f.Name, arg, help)
+	})
+	tw.Flush()
+}
+
+// printCommandHelp prints a subcommand's synopsis, summary and own flags
+func printCommandHelp(w io.Writer, name string, fs *flag.FlagSet) {
+	cmd, _ := findCommand(name)
+	fmt.Fprintf(w, "Usage: lofitui %s %s\n\n%s\n", cmd.name, cmd.args, cmd.summary)
+
+	own := false
+	fs.VisitAll(func(f *flag.Flag) {
+		own = own || !globalFlags[f.Name]
+	})
+	if own {
+		fmt.Fprintln(w, "\nOptions:")
+		printFlags(w, fs, func(name string) bool { return !globalFlags[name] })
+	}
+	fmt.Fprintln(w, "\nGlobal options such as -quiet and -profile work here too; see lofitui help.")
+}
+
+// exitCodeHelp describes the exit codes, for help and the man page
+var exitCodeHelp = [][2]string{
+	{"0", "OK"},
+	{"1", "Any other error, e.g. no such preset"},
+	{"2", "The stream couldn't be extracted"},
+	{"3", "mpv isn't installed"},
+	{"4", "The config couldn't be read, parsed or saved"},
+	{"64", "Bad arguments"},
+}
+
+// printUsage prints the general help: how to start the TUI, the commands
+// and the options
+func printUsage(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Usage:")
+	fmt.Fprintln(tw, "  lofitui [options] [url]\tStart the TUI, playing a URL if given")
+	fmt.Fprintln(tw, "  lofitui <command> [arguments]\tRun a command")
+	fmt.Fprintln(tw, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nOptions:")
+	printFlags(w, flag.CommandLine, func(name string) bool { return !globalFlags[name] })
+	fmt.Fprintln(w, "\nGlobal options, also accepted after a command:")
+	printFlags(w, flag.CommandLine, func(name string) bool { return globalFlags[name] })
+
+	fmt.Fprintln(w, "\nExit codes:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, code := range exitCodeHelp {
+		fmt.Fprintf(tw, "  %s\t%s\n", code[0], code[1])
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun lofitui help <command> for a command's options.")
+}
+
+// runHelp prints the general help, or a command's
+func runHelp(args []string) int {
+	flags := newFlagSet("help")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	switch len(positional) {
+	case 0:
+		printUsage(os.Stdout)
+		return exitOK
+	case 1:
+		cmd, ok := findCommand(positional[0])
+		if !ok {
+			return usageError(nil, fmt.Sprintf("unknown command %q", positional[0]))
+		}
+		// Commands print their help when asked with -h
+		helpOutput = os.Stdout
+		return cmd.run([]string{"-h"})
+	default:
+		return usageError(flags, "help takes at most one command")
+	}
+}
+
+// manEscape escapes text for roff
+func manEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// runMan prints a man page generated from the commands and flags, e.g.
+// lofitui man > /usr/local/share/man/man1/lofitui.1
+func runMan(args []string) int {
+	flags := newFlagSet("man")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "man takes no arguments")
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, ".TH LOFITUI 1 %q \"lofitui %s\"\n", time.Now().Format("2006-01-02"), version)
+	fmt.Fprintln(w, ".SH NAME\nlofitui \\- play lofi streams from the terminal")
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintln(w, ".B lofitui\n[\\fIoptions\\fR] [\\fIurl\\fR]\n.br")
+	fmt.Fprintln(w, ".B lofitui\n\\fIcommand\\fR [\\fIarguments\\fR]")
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	fmt.Fprintln(w, "Without a command, lofitui starts a TUI for picking and playing streams with mpv and yt\\-dlp. The commands below play and manage streams without it.")
+
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, ".TP\n.B %s\n", manEscape(strings.TrimSpace(cmd.name+" "+cmd.args)))
+		fmt.Fprintln(w, manEscape(cmd.summary))
+	}
+
+	manFlags := func(keep func(name string) bool) {
+		flag.CommandLine.VisitAll(func(f *flag.Flag) {
+			if !keep(f.Name) {
+				return
+			}
+			arg, help := flag.UnquoteUsage(f)
+			if arg != "" {
+				arg = " \\fI" + arg + "\\fR"
+			}
+			fmt.Fprintf(w, ".TP\n.B \\-%s%s\n%s\n", manEscape(f.Name), arg, manEscape(help))
+		})
+	}
+	fmt.Fprintln(w, ".SH OPTIONS")
+	manFlags(func(name string) bool { return !globalFlags[name] })
+	fmt.Fprintln(w, ".SH GLOBAL OPTIONS\nThese are also accepted after a command.")
+	manFlags(func(name string) bool { return globalFlags[name] })
+
+	fmt.Fprintln(w, ".SH EXIT STATUS")
+	for _, code := range exitCodeHelp {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", code[0], manEscape(code[1]))
+	}
+	fmt.Fprintln(w, ".SH FILES")
+	fmt.Fprintln(w, ".TP\n.I ~/.config/lofitui/config.json\nThe config, or \\fIprofiles/<name>.json\\fR next to it with \\fB\\-profile\\fR.")
+	fmt.Fprintln(w, ".TP\n.I ~/.config/lofitui/history.jsonl\nThe listening history.")
+	fmt.Fprintln(w, ".SH SEE ALSO\n.BR mpv (1),\n.BR yt\\-dlp (1)")
+	return exitOK
+}
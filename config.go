@@ -1,21 +1,392 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Preset represents a single lofi stream
 type Preset struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name       string     `json:"name"`
+	URL        string     `json:"url"`
+	Tags       []string   `json:"tags,omitempty"`
+	Quality    string     `json:"quality,omitempty"`  // best, 720p, 480p, 360p, 144p or audio
+	MPVArgs    []string   `json:"mpv_args,omitempty"` // On top of the config's mpv_args
+	PlayCount  int        `json:"play_count,omitempty"`
+	LastPlayed *time.Time `json:"last_played,omitempty"`
+}
+
+// PomodoroConfig holds the Pomodoro timer settings
+type PomodoroConfig struct {
+	WorkMinutes  int    `json:"work_minutes"`
+	BreakMinutes int    `json:"break_minutes"`
+	BreakAction  string `json:"break_action"` // "duck", "pause" or "none"
+	BreakVolume  int    `json:"break_volume"` // Volume used when break_action is "duck"
+}
+
+// AlarmConfig holds the wake-up alarm settings
+type AlarmConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Time        string `json:"time"`   // Local clock time, "HH:MM"
+	Preset      string `json:"preset"` // Name of the preset to play
+	RampMinutes int    `json:"ramp_minutes"`
+	Volume      int    `json:"volume"` // Volume reached at the end of the ramp
+}
+
+// LoadingConfig customizes the loading screen
+type LoadingConfig struct {
+	Spinner  string   `json:"spinner"`            // Spinner style, e.g. "dot", "moon", "meter"
+	Pack     string   `json:"pack"`               // Built-in message pack, e.g. "lofi", "cafe"
+	Messages []string `json:"messages,omitempty"` // Custom messages, used instead of the pack
+}
+
+// IdentifyConfig holds the song identification settings
+type IdentifyConfig struct {
+	Service string `json:"service"` // "audd" or "acoustid"
+	Token   string `json:"token"`   // API token (AudD) or client key (AcoustID)
+	Seconds int    `json:"seconds"` // How much audio to record
+}
+
+// RemoteConfig holds the daemon's HTTP remote control settings
+type RemoteConfig struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"` // Listen address, localhost only by default
+	Token   string `json:"token"`   // Required by every request; generated if empty
+}
+
+// MQTTConfig holds the daemon's MQTT settings for home automation
+type MQTTConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Broker   string `json:"broker"` // host:port
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Topic    string `json:"topic"` // Prefix of every topic
+
+	// Discovery announces the player to Home Assistant's MQTT discovery,
+	// under DiscoveryPrefix
+	Discovery       bool   `json:"discovery"`
+	DiscoveryPrefix string `json:"discovery_prefix,omitempty"`
+}
+
+// HooksConfig holds shell commands run when playback changes, with the
+// stream in $LOFITUI_TITLE, $LOFITUI_URL and $LOFITUI_TRACK
+type HooksConfig struct {
+	OnPlay        string `json:"on_play,omitempty"`
+	OnStop        string `json:"on_stop,omitempty"`
+	OnTrackChange string `json:"on_track_change,omitempty"`
+}
+
+// ListenBrainzConfig submits the tracks played to ListenBrainz
+type ListenBrainzConfig struct {
+	Token string `json:"token,omitempty"` // From https://listenbrainz.org/settings/
+	URL   string `json:"url"`             // API root, for self-hosted servers
+}
+
+// NowPlayingFileConfig keeps a text file holding what's playing, for OBS
+// and other streaming software
+type NowPlayingFileConfig struct {
+	Path   string `json:"path,omitempty"`
+	Format string `json:"format,omitempty"` // Go template, like "lofitui status -format"
+}
+
+// SnapcastConfig plays to a Snapcast server's pipe source, so the stream
+// plays in sync in every room
+type SnapcastConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Fifo         string `json:"fifo"`          // The pipe source's path
+	SampleFormat string `json:"sample_format"` // The source's sampleformat, rate:bits:channels
+}
+
+// AutoPauseConfig pauses playback when the screen locks or the computer
+// goes to sleep
+type AutoPauseConfig struct {
+	Enabled bool `json:"enabled"`
+	Resume  bool `json:"resume"` // Resume on unlock or wake, if it was paused for it
+}
+
+// DuckingConfig turns lofitui down while other apps play audio, e.g. a
+// call or a video
+type DuckingConfig struct {
+	Enabled bool     `json:"enabled"`
+	Volume  int      `json:"volume"`           // Percent of the volume to duck to
+	Ignore  []string `json:"ignore,omitempty"` // Apps whose audio doesn't count, e.g. "Spotify"
+}
+
+// RecordingConfig says where recordings go and when they stop by
+// themselves; zero caps mean no limit
+type RecordingConfig struct {
+	Directory  string `json:"directory,omitempty"` // Default ~/Music/lofitui
+	MaxMinutes int    `json:"max_minutes"`
+	MaxMB      int    `json:"max_mb"`
+}
+
+// ScheduledRecording records a preset at a set time, daily or weekly
+type ScheduledRecording struct {
+	Preset  string `json:"preset"`
+	Time    string `json:"time"`              // "HH:MM"
+	Weekday string `json:"weekday,omitempty"` // e.g. "sat" for weekly, empty for daily
+	Minutes int    `json:"minutes"`
+}
+
+// ReplayConfig keeps the last few minutes of the stream in memory so they
+// can be saved after the fact
+type ReplayConfig struct {
+	Enabled  bool `json:"enabled"`
+	Minutes  int  `json:"minutes"`   // How much a clip saves
+	BufferMB int  `json:"buffer_mb"` // Memory mpv may keep behind the playback position
+}
+
+// OfflineConfig says where presets saved for offline listening go and how
+// much room they may take; a negative size means no limit
+type OfflineConfig struct {
+	Directory string `json:"directory,omitempty"` // Default lofitui/offline in the cache directory
+	MaxMB     int    `json:"max_mb"`
+}
+
+// AmbientConfig holds the ambient sound generator's settings
+type AmbientConfig struct {
+	Volume int          `json:"volume"` // 1-100
+	Tones  []TonePreset `json:"tones"`
+}
+
+// TonePreset is a binaural beat or isochronic tone offered with the
+// ambient sounds
+type TonePreset struct {
+	Name    string  `json:"name"`
+	Kind    string  `json:"kind"`    // "binaural" (for headphones) or "isochronic"
+	Carrier float64 `json:"carrier"` // Pitch of the tone, in Hz
+	Beat    float64 `json:"beat"`    // Beat or pulse rate, in Hz
+}
+
+// HotkeysConfig has the daemon grab global hotkeys through a helper
+// program, so it can be controlled without focusing a terminal
+type HotkeysConfig struct {
+	Enabled  bool              `json:"enabled"`
+	Helper   string            `json:"helper,omitempty"`   // "sxhkd" or "skhd", by default the one for the OS
+	Bindings map[string]string `json:"bindings,omitempty"` // Keys by action, e.g. "pause": "super+alt+space"
+}
+
+// NotificationsConfig turns desktop notifications on
+type NotificationsConfig struct {
+	Enabled bool `json:"enabled"`
 }
 
 // Config represents the application configuration
 type Config struct {
-	Presets []Preset `json:"presets"`
+	Presets  []Preset       `json:"presets"`
+	Pomodoro PomodoroConfig `json:"pomodoro"`
+
+	// SleepFadeSeconds is how long the sleep timer fades the volume out
+	SleepFadeSeconds int `json:"sleep_fade_seconds"`
+
+	Alarm AlarmConfig `json:"alarm"`
+
+	// TerminalPlayback hands the terminal over to mpv's video output
+	// instead of keeping the TUI on screen with a Now Playing view,
+	// controlling mpv over its IPC socket
+	TerminalPlayback bool `json:"terminal_playback,omitempty"`
+
+	// Player is what plays streams in the terminal: "mpv" (the default),
+	// "vlc", "ffplay" or "custom", which runs PlayerCommand. Everything
+	// that controls playback while it plays needs mpv.
+	Player string `json:"player,omitempty"`
+
+	// PlayerCommand is the custom player's command and arguments, with
+	// {url}, {page_url} and {title} filled in
+	PlayerCommand []string `json:"player_command,omitempty"`
+
+	// MPVArgs are extra options for every mpv lofitui starts, e.g.
+	// "--ao=pipewire" or "--volume=40"
+	MPVArgs []string `json:"mpv_args,omitempty"`
+
+	// CrossfadeSeconds fades from the stream playing into the next over
+	// this long when you play another from the menu with background
+	// playback; zero cuts straight over
+	CrossfadeSeconds int `json:"crossfade_seconds,omitempty"`
+
+	// MaxReconnects is how many times in a row a dropped stream is loaded
+	// again before giving up and going back; negative never reconnects
+	MaxReconnects int `json:"max_reconnects"`
+
+	// AudioOnly plays streams without rendering their video in the
+	// terminal, and fetches only their audio where there's a format for it
+	AudioOnly bool `json:"audio_only"`
+
+	// ConfirmBeforePlay shows the stream's title, channel and live status
+	// once it's loaded, and waits for Enter before playing it
+	ConfirmBeforePlay bool `json:"confirm_before_play"`
+
+	// Theme is the color scheme: "default", "high-contrast" or "deuteranopia"
+	Theme string `json:"theme"`
+
+	// SortMode orders the preset list: "manual", "name", "plays" or "recent"
+	SortMode string `json:"sort_mode"`
+
+	Loading LoadingConfig `json:"loading"`
+
+	// Keybindings maps action names to keys, overriding the defaults
+	Keybindings map[string]string `json:"keybindings,omitempty"`
+
+	Identify IdentifyConfig `json:"identify"`
+
+	Remote RemoteConfig `json:"remote"`
+
+	MQTT MQTTConfig `json:"mqtt"`
+
+	Hooks HooksConfig `json:"hooks"`
+
+	// PacksIndex is where "lofitui packs" finds preset packs
+	PacksIndex string `json:"packs_index"`
+
+	// Notifications shows desktop notifications when playback starts, the
+	// track changes or a stream drops
+	Notifications NotificationsConfig `json:"notifications"`
+
+	// ListenBrainz scrobbles tracks when a token is set
+	ListenBrainz ListenBrainzConfig `json:"listenbrainz"`
+
+	NowPlayingFile NowPlayingFileConfig `json:"now_playing_file"`
+
+	// AudioDevice is mpv's audio output, e.g. an AirPlay speaker; empty
+	// for the system default
+	AudioDevice string `json:"audio_device,omitempty"`
+
+	Snapcast SnapcastConfig `json:"snapcast"`
+
+	AutoPause AutoPauseConfig `json:"auto_pause"`
+
+	Ducking DuckingConfig `json:"ducking"`
+
+	Hotkeys HotkeysConfig `json:"hotkeys"`
+
+	Recording RecordingConfig `json:"recording"`
+
+	ScheduledRecordings []ScheduledRecording `json:"scheduled_recordings,omitempty"`
+
+	Replay ReplayConfig `json:"replay"`
+
+	Offline OfflineConfig `json:"offline"`
+
+	// SingleInstance keeps to one TUI: starting another, or "lofitui play",
+	// hands the stream to the one running instead
+	SingleInstance bool `json:"single_instance"`
+
+	Ambient AmbientConfig `json:"ambient"`
+
+	// HideSuggestions turns off the main menu's "Suggested for now" row
+	HideSuggestions bool `json:"hide_suggestions,omitempty"`
+
+	// CheckForUpdates looks for a newer release on startup, in the
+	// background; DismissedUpdate is the release not to mention again
+	CheckForUpdates bool   `json:"check_for_updates"`
+	DismissedUpdate string `json:"dismissed_update,omitempty"`
+
+	// LogLevel is how much goes to lofitui.log: "debug", "info", "warn" or
+	// "error". -log-level overrides it.
+	LogLevel string `json:"log_level"`
+
+	// saved is the config as last loaded or saved, to merge with
+	saved []byte
+}
+
+// applyDefaults fills in settings missing from older config files
+func (c *Config) applyDefaults() {
+	if c.Pomodoro.WorkMinutes <= 0 {
+		c.Pomodoro.WorkMinutes = 25
+	}
+	if c.Pomodoro.BreakMinutes <= 0 {
+		c.Pomodoro.BreakMinutes = 5
+	}
+	switch c.Pomodoro.BreakAction {
+	case "duck", "pause", "none":
+	default:
+		c.Pomodoro.BreakAction = "duck"
+	}
+	if c.Pomodoro.BreakVolume <= 0 || c.Pomodoro.BreakVolume > 100 {
+		c.Pomodoro.BreakVolume = 30
+	}
+	if c.SleepFadeSeconds <= 0 {
+		c.SleepFadeSeconds = 60
+	}
+	if c.Alarm.RampMinutes <= 0 {
+		c.Alarm.RampMinutes = 5
+	}
+	if c.Alarm.Volume <= 0 || c.Alarm.Volume > 100 {
+		c.Alarm.Volume = 70
+	}
+	if c.Theme == "" {
+		c.Theme = "default"
+	}
+	if _, ok := sortLabels[c.SortMode]; !ok {
+		c.SortMode = "manual"
+	}
+	if c.Loading.Spinner == "" {
+		c.Loading.Spinner = "dot"
+	}
+	if c.Loading.Pack == "" {
+		c.Loading.Pack = "lofi"
+	}
+	switch c.Identify.Service {
+	case "audd", "acoustid":
+	default:
+		c.Identify.Service = "audd"
+	}
+	if c.Identify.Seconds <= 0 {
+		c.Identify.Seconds = 12
+	}
+	if c.Remote.Address == "" {
+		c.Remote.Address = "127.0.0.1:8765"
+	}
+	if c.MQTT.Broker == "" {
+		c.MQTT.Broker = "localhost:1883"
+	}
+	if c.MQTT.Topic == "" {
+		c.MQTT.Topic = "lofitui"
+	}
+	if c.MQTT.DiscoveryPrefix == "" {
+		c.MQTT.DiscoveryPrefix = "homeassistant"
+	}
+	if c.PacksIndex == "" {
+		c.PacksIndex = defaultPacksIndex
+	}
+	if c.ListenBrainz.URL == "" {
+		c.ListenBrainz.URL = defaultListenBrainzURL
+	}
+	if c.Snapcast.Fifo == "" {
+		c.Snapcast.Fifo = defaultSnapcastFifo
+	}
+	if c.Snapcast.SampleFormat == "" {
+		c.Snapcast.SampleFormat = defaultSnapcastFormat
+	}
+	if c.Replay.Minutes <= 0 {
+		c.Replay.Minutes = 5
+	}
+	if c.Replay.BufferMB <= 0 {
+		c.Replay.BufferMB = 200
+	}
+	if c.Ducking.Volume <= 0 || c.Ducking.Volume > 100 {
+		c.Ducking.Volume = 30
+	}
+	if c.Ambient.Volume <= 0 || c.Ambient.Volume > 100 {
+		c.Ambient.Volume = 50
+	}
+	if c.Ambient.Tones == nil {
+		c.Ambient.Tones = defaultTones()
+	}
+	if c.Offline.MaxMB == 0 {
+		c.Offline.MaxMB = 2048
+	}
+	if c.MaxReconnects == 0 {
+		c.MaxReconnects = 6
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = "info"
+	}
 }
 
 // getConfigDir returns the config directory path following XDG spec
@@ -37,10 +408,16 @@ func getConfigDir() (string, error) {
 
 // getConfigPath returns the full path to the config file
 func getConfigPath() (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
 	configDir, err := getConfigDir()
 	if err != nil {
 		return "", err
 	}
+	if profile != "" {
+		return filepath.Join(configDir, "profiles", profile+".json"), nil
+	}
 	return filepath.Join(configDir, "config.json"), nil
 }
 
@@ -53,40 +430,54 @@ func loadConfig() (*Config, error) {
 
 	// If config doesn't exist, return defaults
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return getDefaultConfig(), nil
+		slog.Debug("no config, using defaults", "path", configPath)
+		// The defaults are what another instance saving first would
+		// have started from, so its changes are merged rather than lost
+		config := getDefaultConfig()
+		config.remember()
+		return config, nil
 	}
 
 	// Read config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
+		slog.Error("failed to read config", "path", configPath, "err", err)
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
 	// Parse JSON
 	var config Config
 	if err := json.Unmarshal(data, &config); err != nil {
+		slog.Error("failed to parse config", "path", configPath, "err", err)
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	config.applyDefaults()
+	config.remember()
+	applyConfigLogLevel(config.LogLevel)
+	slog.Debug("loaded config", "path", configPath, "presets", len(config.Presets))
 
 	return &config, nil
 }
 
-// saveConfig saves configuration to disk
+// saveConfig saves configuration to disk. Another instance may have saved
+// since this one loaded the config, so its changes are merged in rather
+// than overwritten, and config is updated to what was saved.
 func saveConfig(config *Config) error {
-	configDir, err := getConfigDir()
+	configPath, err := getConfigPath()
 	if err != nil {
 		return err
 	}
 
 	// Create config directory if it doesn't exist
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	configPath, err := getConfigPath()
+	unlock, err := lockConfig(configPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to lock config: %w", err)
 	}
+	defer unlock()
 
 	// Marshal config to JSON with indentation
 	data, err := json.MarshalIndent(config, "", "  ")
@@ -94,17 +485,44 @@ func saveConfig(config *Config) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Write to file
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// Merge in what was saved since loading, if anything
+	if onDisk, err := readConfigFile(configPath); err == nil && config.saved != nil && !bytes.Equal(onDisk, config.saved) {
+		ours, _ := json.Marshal(config)
+		merged, err := mergeConfig(config.saved, ours, onDisk)
+		if err != nil {
+			return fmt.Errorf("failed to merge config: %w", err)
+		}
+		var fresh Config
+		if err := json.Unmarshal(merged, &fresh); err != nil {
+			return fmt.Errorf("failed to merge config: %w", err)
+		}
+		fresh.applyDefaults()
+		*config = fresh
+		if data, err = json.MarshalIndent(config, "", "  "); err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		slog.Info("merged config changes saved by another instance", "path", configPath)
+	}
+
+	// Write to a temporary file first so nothing reads a half-written config
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Error("failed to write config", "path", configPath, "err", err)
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp, configPath); err != nil {
+		slog.Error("failed to write config", "path", configPath, "err", err)
 		return fmt.Errorf("failed to write config: %w", err)
 	}
+	config.remember()
+	slog.Debug("saved config", "path", configPath)
 
 	return nil
 }
 
 // getDefaultConfig returns the default configuration
 func getDefaultConfig() *Config {
-	return &Config{
+	config := &Config{
 		Presets: []Preset{
 			{Name: "Lofi Girl - Study", URL: "https://www.youtube.com/watch?v=jfKfPfyJRdk"},
 			{Name: "Lofi Girl - Sleep", URL: "https://www.youtube.com/watch?v=DWcJFNfaw9c"},
@@ -118,4 +536,6 @@ func getDefaultConfig() *Config {
 			{Name: "Homework Radio", URL: "https://www.youtube.com/watch?v=lTRiuFIWV54"},
 		},
 	}
+	config.applyDefaults()
+	return config
 }
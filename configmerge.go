@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// lockConfig takes the lock that keeps two instances from saving the
+// config at the same time, waiting for the other to finish
+func lockConfig(configPath string) (func(), error) {
+	file, err := os.OpenFile(configPath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// remember records the config as it is on disk, so a later save can tell
+// its own changes from another instance's
+func (c *Config) remember() {
+	c.saved, _ = json.Marshal(c)
+}
+
+// readConfigFile reads the config on disk, with defaults applied so it
+// compares with a Config marshalled in memory
+func readConfigFile(configPath string) ([]byte, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	config.applyDefaults()
+	return json.Marshal(&config)
+}
+
+// mergeConfig merges the changes made since base in ours and theirs,
+// setting by setting. Ours win where both changed the same setting, except
+// for presets, which are merged one by one.
+func mergeConfig(base, ours, theirs []byte) ([]byte, error) {
+	var b, o, t map[string]json.RawMessage
+	for _, decode := range []struct {
+		data []byte
+		into *map[string]json.RawMessage
+	}{{base, &b}, {ours, &o}, {theirs, &t}} {
+		if err := json.Unmarshal(decode.data, decode.into); err != nil {
+			return nil, err
+		}
+	}
+
+	merged := t
+	for key, value := range o {
+		switch {
+		case key == "presets":
+			presets, err := mergeSavedPresets(b[key], value, t[key])
+			if err != nil {
+				return nil, err
+			}
+			merged[key] = presets
+		case !bytes.Equal(value, b[key]):
+			merged[key] = value
+		}
+	}
+	// Settings left out because they were cleared here
+	for key := range b {
+		if _, ok := o[key]; !ok && bytes.Equal(t[key], b[key]) {
+			delete(merged, key)
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// mergeSavedPresets merges two edits of the preset list, matching presets by
+// URL: presets either side added are kept, presets either side removed are
+// dropped, and a preset only they changed takes their change
+func mergeSavedPresets(base, ours, theirs json.RawMessage) (json.RawMessage, error) {
+	var b, o, t []Preset
+	for _, decode := range []struct {
+		data json.RawMessage
+		into *[]Preset
+	}{{base, &b}, {ours, &o}, {theirs, &t}} {
+		if len(decode.data) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(decode.data, decode.into); err != nil {
+			return nil, err
+		}
+	}
+	byURL := func(presets []Preset) map[string]json.RawMessage {
+		found := make(map[string]json.RawMessage, len(presets))
+		for _, preset := range presets {
+			found[preset.URL], _ = json.Marshal(preset)
+		}
+		return found
+	}
+	baseByURL, theirsByURL := byURL(b), byURL(t)
+
+	var merged []Preset
+	kept := make(map[string]bool)
+	for _, preset := range o {
+		kept[preset.URL] = true
+		mine, _ := json.Marshal(preset)
+		original, inBase := baseByURL[preset.URL]
+		theirs, inTheirs := theirsByURL[preset.URL]
+		switch {
+		case !inBase || !bytes.Equal(mine, original):
+			// Added or changed here
+			merged = append(merged, preset)
+		case !inTheirs:
+			// Removed there and untouched here
+		default:
+			var changed Preset
+			_ = json.Unmarshal(theirs, &changed)
+			merged = append(merged, changed)
+		}
+	}
+	for _, preset := range t {
+		if _, inBase := baseByURL[preset.URL]; !inBase && !kept[preset.URL] {
+			// Added there
+			kept[preset.URL] = true
+			merged = append(merged, preset)
+		}
+	}
+	return json.Marshal(merged)
+}
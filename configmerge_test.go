@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// mergeTestPresets runs mergeConfig on configs that only hold presets, and
+// returns the merged presets' names
+func mergeTestPresets(t *testing.T, base, ours, theirs []Preset) []string {
+	t.Helper()
+	encode := func(presets []Preset) []byte {
+		data, err := json.Marshal(Config{Presets: presets, Theme: "default"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	merged, err := mergeConfig(encode(base), encode(ours), encode(theirs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config Config
+	if err := json.Unmarshal(merged, &config); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, preset := range config.Presets {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+func TestMergePresets(t *testing.T) {
+	a := Preset{Name: "A", URL: "https://example.com/a"}
+	b := Preset{Name: "B", URL: "https://example.com/b"}
+	c := Preset{Name: "C", URL: "https://example.com/c"}
+	d := Preset{Name: "D", URL: "https://example.com/d"}
+	renamed := func(preset Preset, name string) Preset {
+		preset.Name = name
+		return preset
+	}
+	moved := func(preset Preset, url string) Preset {
+		preset.URL = url
+		return preset
+	}
+
+	for _, test := range []struct {
+		name               string
+		base, ours, theirs []Preset
+		want               []string
+	}{
+		{"both added different presets", []Preset{a}, []Preset{a, b}, []Preset{a, c}, []string{"A", "B", "C"}},
+		{"both added the same preset", []Preset{a}, []Preset{a, b}, []Preset{a, b}, []string{"A", "B"}},
+		{"added here, removed there", []Preset{a, b}, []Preset{a, b, c}, []Preset{a}, []string{"A", "C"}},
+		{"removed here, added there", []Preset{a, b}, []Preset{a}, []Preset{a, b, d}, []string{"A", "D"}},
+		{"edited there only", []Preset{a, b}, []Preset{a, b}, []Preset{a, renamed(b, "B2")}, []string{"A", "B2"}},
+		{"edited on both sides", []Preset{a, b}, []Preset{a, renamed(b, "Ours")}, []Preset{a, renamed(b, "Theirs")}, []string{"A", "Ours"}},
+		{"edited here, removed there", []Preset{a, b}, []Preset{a, renamed(b, "B2")}, []Preset{a}, []string{"A", "B2"}},
+		{"URL changed here", []Preset{a, b}, []Preset{a, moved(b, "https://example.com/b2")}, []Preset{a, b}, []string{"A", "B"}},
+		{"URL changed there", []Preset{a, b}, []Preset{a, b}, []Preset{a, moved(renamed(b, "B2"), "https://example.com/b2")}, []string{"A", "B2"}},
+		{"URL changed on both sides", []Preset{a, b}, []Preset{a, moved(renamed(b, "Ours"), "https://example.com/b2")}, []Preset{a, moved(renamed(b, "Theirs"), "https://example.com/b3")}, []string{"A", "Ours", "Theirs"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := mergeTestPresets(t, test.base, test.ours, test.theirs); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("merged presets = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeSettings(t *testing.T) {
+	base, _ := json.Marshal(Config{Theme: "default", SortMode: "manual"})
+	ours, _ := json.Marshal(Config{Theme: "high-contrast", SortMode: "manual"})
+	theirs, _ := json.Marshal(Config{Theme: "deuteranopia", SortMode: "name"})
+	merged, err := mergeConfig(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config Config
+	if err := json.Unmarshal(merged, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Theme != "high-contrast" || config.SortMode != "name" {
+		t.Errorf("theme %q, sort mode %q; want ours where both changed, theirs where only they did", config.Theme, config.SortMode)
+	}
+}
+
+// Two instances started before there was a config each save a preset
+func TestSaveConfigMergesFirstSaves(t *testing.T) {
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	t.Cleanup(func() { configFile = "" })
+
+	first, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Presets = append(first.Presets, Preset{Name: "First", URL: "https://example.com/first"})
+	second.Presets = append(second.Presets, Preset{Name: "Second", URL: "https://example.com/second"})
+	if err := saveConfig(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveConfig(second); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, preset := range saved.Presets {
+		found[preset.Name] = true
+	}
+	if !found["First"] || !found["Second"] {
+		t.Errorf("saved presets %v, want both instances' additions", saved.Presets)
+	}
+}
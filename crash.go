@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// crashLogLines is how many of the last log lines go into a crash report
+const crashLogLines = 50
+
+var (
+	crashMu     sync.Mutex
+	crashReport string // Path of the report written for the panic, if any
+)
+
+// crashGuard wraps the model so a panic anywhere in Update, View or a
+// command leaves a crash report behind. Bubble Tea itself recovers the
+// panic and restores the terminal; the guard only sees it on the way past.
+type crashGuard struct {
+	tea.Model
+}
+
+func (g crashGuard) Init() tea.Cmd {
+	defer reportPanic()
+	return guardCmd(g.Model.Init())
+}
+
+func (g crashGuard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	defer reportPanic()
+	next, cmd := g.Model.Update(msg)
+	return crashGuard{next}, guardCmd(cmd)
+}
+
+func (g crashGuard) View() string {
+	defer reportPanic()
+	return g.Model.View()
+}
+
+// guardCmd wraps a command, and the commands of a batch it returns, so
+// panics in them are reported too
+func guardCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		defer reportPanic()
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			guarded := make(tea.BatchMsg, len(batch))
+			for i, cmd := range batch {
+				guarded[i] = guardCmd(cmd)
+			}
+			return guarded
+		}
+		return msg
+	}
+}
+
+// reportPanic writes a crash report for a panic in progress and then lets
+// it carry on to Bubble Tea. Only the first panic is reported.
+func reportPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	crashMu.Lock()
+	if crashReport == "" {
+		if path, err := writeCrashReport(r, debug.Stack()); err == nil {
+			crashReport = path
+		} else {
+			crashReport = "-"
+		}
+	}
+	crashMu.Unlock()
+	panic(r)
+}
+
+// writeCrashReport saves what's needed to file an issue about a panic: the
+// stack, the build and the last things logged
+func writeCrashReport(r interface{}, stack []byte) (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, "crashes")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	var report strings.Builder
+	now := time.Now()
+	fmt.Fprintf(&report, "lofitui crashed at %s\n\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&report, "version: %s (commit %s, built %s)\n", version, commit, date)
+	fmt.Fprintf(&report, "go: %s %s/%s\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&report, "panic: %v\n\n%s\n", r, stack)
+	if lines, err := readLogTail(); err == nil && len(lines) > 0 {
+		report.WriteString("last log lines:\n")
+		for _, line := range lines[max(0, len(lines)-crashLogLines):] {
+			fmt.Fprintf(&report, "%s %-5s %s\n", line.at, line.level, line.rest)
+		}
+	}
+
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".txt")
+	if err := os.WriteFile(path, []byte(report.String()), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// reportedCrash returns the path of the crash report, if one was written
+func reportedCrash() (string, bool) {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+	return crashReport, crashReport != "" && crashReport != "-"
+}
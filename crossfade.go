@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/willyv3/lofitui/pkg/lofitui"
+)
+
+// crossfadeStartTimeout is how long the new stream gets to start playing
+// before the old one is faded out anyway
+const crossfadeStartTimeout = 30 * time.Second
+
+// handOverSocket moves the mpv playing now off the IPC socket, so the next
+// one can have it, and returns the old one under its new name. A unix
+// socket keeps listening when its file is renamed.
+func handOverSocket() (lofitui.Player, bool) {
+	old := getMPVSocketPath() + ".fading"
+	if err := os.Rename(getMPVSocketPath(), old); err != nil {
+		slog.Warn("can't crossfade, stopping the old stream instead", "err", err)
+		return lofitui.Player{}, false
+	}
+	return lofitui.Player{Socket: old, Timeout: mpvIPCTimeout}, true
+}
+
+// crossfade waits for the new mpv, started silent, to play, then fades it
+// in to volume as the old one fades out over length, and quits the old one.
+// A negative volume fades in to the old one's.
+func crossfade(old lofitui.Player, length time.Duration, volume float64) tea.Cmd {
+	return func() tea.Msg {
+		defer os.Remove(old.Socket)
+		from, err := old.Float("volume")
+		if err != nil {
+			from = 100
+		}
+		if volume < 0 {
+			volume = from
+		}
+
+		deadline := time.Now().Add(crossfadeStartTimeout)
+		for time.Now().Before(deadline) {
+			if _, err := mpvGetFloat("time-pos"); err == nil {
+				break
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		const steps = 20
+		for i := 1; i <= steps; i++ {
+			done := float64(i) / steps
+			_ = old.SetProperty("volume", from*(1-done))
+			_ = mpvSetProperty("volume", volume*done)
+			time.Sleep(length / steps)
+		}
+		_, _ = old.Command("quit")
+		return nil
+	}
+}
+
+// quitFading quits the old mpv of a crossfade still going, e.g. on quitting
+func quitFading() {
+	fading := lofitui.Player{Socket: getMPVSocketPath() + ".fading", Timeout: mpvIPCTimeout}
+	if _, err := fading.Command("quit"); err == nil {
+		_ = os.Remove(fading.Socket)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willyv3/lofitui/pkg/lofitui"
+)
+
+// lastVolume is the last volume mpv was set to, or -1
+func (f *fakeMPV) lastVolume() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	volume := -1.0
+	for _, command := range f.commands {
+		if len(command) == 3 && command[0] == "set_property" && command[1] == "volume" {
+			volume, _ = command[2].(float64)
+		}
+	}
+	return volume
+}
+
+func TestCrossfadeVolume(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		volume float64
+		want   float64
+	}{
+		{"the old stream's", -1, 50},
+		{"the preset's own", 80, 80},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			incoming := newFakeMPV(t)
+			outgoing := listenFakeMPV(t, getMPVSocketPath()+".fading")
+			old := lofitui.Player{Socket: getMPVSocketPath() + ".fading", Timeout: mpvIPCTimeout}
+
+			crossfade(old, 20*time.Millisecond, test.volume)()
+			if got := incoming.lastVolume(); got != test.want {
+				t.Errorf("faded in to %v, want %v", got, test.want)
+			}
+			if got := outgoing.lastVolume(); got != 0 {
+				t.Errorf("faded out to %v, want 0", got)
+			}
+			if !outgoing.sent("quit") {
+				t.Error("the old stream wasn't quit")
+			}
+		})
+	}
+}
+
+func TestArgsVolume(t *testing.T) {
+	if _, ok := argsVolume([]string{"--no-video"}); ok {
+		t.Error("found a volume in args without one")
+	}
+	if volume, ok := argsVolume([]string{"--volume=40", "--mute=no", "--volume=65"}); !ok || volume != 65 {
+		t.Errorf("argsVolume = %v, %v; want the last, 65", volume, ok)
+	}
+}
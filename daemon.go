@@ -0,0 +1,570 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/willyv3/lofitui/pkg/lofitui"
+)
+
+// daemonRequest is a command sent by "lofitui ctl"
+type daemonRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// daemonResponse is the daemon's reply to a command
+type daemonResponse struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// daemon plays streams headless and takes commands over a unix socket
+type daemon struct {
+	mu                 sync.Mutex
+	mpv                *lofitui.Process
+	playing            int                // Config index of the current preset, -1 for URLs or nothing
+	title              string             // What's playing, empty when stopped
+	url                string             // The page URL of what's playing
+	loads              int                // Counts loads and stops, so a slow load that's been overtaken is dropped
+	recording          string             // File the stream is being recorded to, empty when it isn't
+	stopRecordingWatch context.CancelFunc // Stops enforcing the recording's caps
+	quit               chan struct{}
+	exited             chan struct{} // Closed when mpv exits
+	events             *eventHub
+}
+
+// daemonSocketPath returns where the daemon listens for commands
+func daemonSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "lofitui.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("lofitui-%d.ctl.sock", os.Getuid()))
+}
+
+// runDaemon starts an idle mpv and serves commands until told to quit
+func runDaemon(args []string) int {
+	flags := newFlagSet("daemon")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "daemon takes no arguments")
+	}
+	if err := requirePlayer(); err != nil {
+		return fail(exitCode(err), err)
+	}
+
+	socket := daemonSocketPath()
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "lofitui: a daemon is already running on %s\n", socket)
+		return exitError
+	}
+	// Nobody answered, so any socket file left is stale
+	_ = os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+	defer os.Remove(socket)
+
+	// Closing the terminal shouldn't stop the music; mpv inherits this
+	signal.Ignore(syscall.SIGHUP)
+
+	d := &daemon{playing: -1, quit: make(chan struct{}), exited: make(chan struct{}), events: newEventHub()}
+	if err := d.startPlayer(); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: failed to start mpv: %v\n", err)
+		return exitError
+	}
+	defer d.stopPlayer()
+	go d.watchPlayer()
+	go d.printEvents()
+	defer startMPRISOrLog(d.mprisControls()).close()
+
+	if err := d.startRemote(); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: remote control: %v\n", err)
+		return exitError
+	}
+	if config, err := loadConfig(); err == nil {
+		if config.MQTT.Enabled {
+			go d.runMQTT(config.MQTT)
+		}
+		go d.runHooks(config)
+		go watchAutoPause(config.AutoPause)
+		go watchDucking(config.Ducking, nil)
+		go runRecordingScheduler(context.Background(), true)
+		if config.Hotkeys.Enabled {
+			if stop, err := startHotkeys(config.Hotkeys); err != nil {
+				fmt.Fprintf(os.Stderr, "lofitui: hotkeys: %v\n", err)
+			} else {
+				defer stop()
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "lofitui daemon listening on %s\n", socket)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-stop:
+	case <-d.quit:
+	case <-d.exited:
+		printEvent(playerEvent{Event: "error", Status: daemonStatus{State: "stopped"}, Error: "mpv exited unexpectedly"})
+		slog.Error("mpv exited unexpectedly")
+		fmt.Fprintln(os.Stderr, "lofitui: mpv exited unexpectedly")
+		return exitError
+	}
+	listener.Close()
+	return exitOK
+}
+
+// startPlayer runs mpv idle in the background, waiting for streams
+func (d *daemon) startPlayer() error {
+	var args []string
+	if config, err := loadConfig(); err == nil {
+		args = append(configArgs(config), replayArgs(config.Replay)...)
+	}
+	mpv, err := startIdleMPV(args...)
+	if err != nil {
+		return err
+	}
+	d.mpv = mpv
+	go func() {
+		<-mpv.Exited()
+		close(d.exited)
+	}()
+	return nil
+}
+
+// startIdleMPV runs mpv idle in the background, audio only, and waits for
+// its IPC socket
+func startIdleMPV(extraArgs ...string) (*lofitui.Process, error) {
+	args := []string{"--idle=yes", "--no-video", "--no-terminal"}
+	args = append(args, audioClientArgs()...)
+	args = append(args, nowPlayingArgs()...)
+	return lofitui.Start(getMPVSocketPath(), append(args, extraArgs...)...)
+}
+
+// stopPlayer quits mpv, killing it if it doesn't listen
+func (d *daemon) stopPlayer() {
+	d.mpv.Stop(3 * time.Second)
+	<-d.exited
+}
+
+// serve handles one client connection. Each line is either a ctl command
+// or a JSON-RPC 2.0 request.
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var version struct {
+			JSONRPC string `json:"jsonrpc"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &version) != nil || version.JSONRPC != "" {
+			if resp := d.handleRPC(scanner.Bytes()); resp != nil {
+				_ = encoder.Encode(resp)
+			}
+			continue
+		}
+
+		var req daemonRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			_ = encoder.Encode(daemonResponse{Message: "bad request: " + err.Error()})
+			continue
+		}
+		message, err := d.handle(req)
+		if err != nil {
+			_ = encoder.Encode(daemonResponse{Message: err.Error()})
+			continue
+		}
+		_ = encoder.Encode(daemonResponse{OK: true, Message: message})
+	}
+}
+
+// handle runs a command and returns what to tell the client
+func (d *daemon) handle(req daemonRequest) (message string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	slog.Info("daemon command", "command", req.Command, "args", req.Args)
+	defer func() {
+		if err != nil {
+			slog.Warn("daemon command failed", "command", req.Command, "err", err)
+		}
+	}()
+
+	switch req.Command {
+	case "play":
+		if len(req.Args) == 0 {
+			// Resume whatever was paused
+			return "playing", mpvSetProperty("pause", false)
+		}
+		return d.play(strings.Join(req.Args, " "))
+	case "pause":
+		if _, err := mpvCommand("cycle", "pause"); err != nil {
+			return "", err
+		}
+		if paused, _ := mpvGetBool("pause"); paused {
+			return "paused", nil
+		}
+		return "playing", nil
+	case "stop":
+		d.endRecording()
+		// A stream still loading shouldn't start once stopped
+		d.loads++
+		d.playing, d.title, d.url = -1, "", ""
+		_, err := mpvCommand("stop")
+		return "stopped", err
+	case "next", "prev":
+		config, err := loadConfig()
+		if err != nil {
+			return "", err
+		}
+		if len(config.Presets) == 0 {
+			return "", fmt.Errorf("there are no presets")
+		}
+		step := 1
+		if req.Command == "prev" {
+			step = -1
+		}
+		return d.playPreset(config, skipPreset(d.playing, step, len(config.Presets)))
+	case "volume":
+		if len(req.Args) == 1 {
+			if err := d.setVolume(req.Args[0]); err != nil {
+				return "", err
+			}
+		}
+		volume, err := mpvGetFloat("volume")
+		return fmt.Sprintf("volume %.0f%%", volume), err
+	case "record":
+		return d.toggleRecording()
+	case "clip":
+		return d.clip()
+	case "status":
+		return d.status(), nil
+	case "quit":
+		select {
+		case <-d.quit:
+		default:
+			close(d.quit)
+		}
+		return "bye", nil
+	default:
+		return "", fmt.Errorf("unknown command %q", req.Command)
+	}
+}
+
+// play starts a preset (by name) or a URL
+func (d *daemon) play(target string) (string, error) {
+	if strings.Contains(target, "://") {
+		message, err := d.load(target, "Custom Stream")
+		if err != nil {
+			return "", err
+		}
+		d.playing = -1
+		return message, nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	index, err := findPreset(config.Presets, target)
+	if err != nil {
+		return "", err
+	}
+	return d.playPreset(config, index)
+}
+
+// playPreset starts the preset at index, counting the play
+func (d *daemon) playPreset(config *Config, index int) (string, error) {
+	preset := config.Presets[index]
+	message, err := d.load(preset.URL, preset.Name)
+	if err != nil {
+		return "", err
+	}
+	d.playing = index
+	recordPlay(config, index)
+	_ = saveConfig(config)
+	return message, nil
+}
+
+// load extracts a page's stream URL and hands it to mpv. d.mu must be
+// held; it's let go while yt-dlp runs, so other commands aren't held up,
+// and if another load or a stop comes in meanwhile this one is dropped.
+func (d *daemon) load(pageURL, title string) (string, error) {
+	d.loads++
+	load := d.loads
+	d.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	streamURL, err := resolveStreamURL(ctx, pageURL, nil)
+	d.mu.Lock()
+	if load != d.loads {
+		return "", fmt.Errorf("%s was superseded before it loaded", title)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to load stream: %w", err)
+		d.events.publish(playerEvent{Event: "error", Status: d.currentStatus(), Error: err.Error()})
+		return "", err
+	}
+	// mpv would carry on recording into the next stream
+	d.endRecording()
+	_ = mpvSetProperty("force-media-title", title)
+	if _, err := mpvCommand("loadfile", streamURL, "replace"); err != nil {
+		return "", err
+	}
+	_ = mpvSetProperty("pause", false)
+	d.title, d.url = title, pageURL
+	rememberPlay(title, pageURL)
+	return "playing " + title, nil
+}
+
+// toggleRecording starts recording the stream, or stops the recording in
+// progress. d.mu must be held.
+func (d *daemon) toggleRecording() (string, error) {
+	if d.recording != "" && isRecording(d.recording) {
+		path := d.recording
+		d.endRecording()
+		return "saved " + path, nil
+	}
+	if d.title == "" {
+		return "", fmt.Errorf("nothing is playing")
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	path, err := startRecording(config.Recording, d.title)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.recording, d.stopRecordingWatch = path, cancel
+	go watchRecording(ctx, path, config.Recording)
+	return "recording to " + path, nil
+}
+
+// clip saves the last few minutes of the stream. d.mu must be held.
+func (d *daemon) clip() (string, error) {
+	if d.title == "" {
+		return "", fmt.Errorf("nothing is playing")
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	path, err := saveClip(config.Replay, config.Recording, d.title)
+	if err != nil {
+		return "", err
+	}
+	return "saved " + path, nil
+}
+
+// endRecording stops the recording in progress, if any. d.mu must be held.
+func (d *daemon) endRecording() {
+	if d.recording == "" {
+		return
+	}
+	_ = stopRecording()
+	d.stopRecordingWatch()
+	d.recording, d.stopRecordingWatch = "", nil
+}
+
+// mprisControls lets MPRIS control the daemon like ctl does
+func (d *daemon) mprisControls() mprisControls {
+	command := func(name string) func() error {
+		return func() error {
+			_, err := d.handle(daemonRequest{Command: name})
+			return err
+		}
+	}
+	return mprisControls{
+		status: func() daemonStatus {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return d.currentStatus()
+		},
+		stop: command("stop"),
+		next: command("next"),
+		prev: command("prev"),
+	}
+}
+
+// setVolume sets the volume to an absolute value ("60") or changes it
+// relative to the current one ("+5", "-5")
+func (d *daemon) setVolume(arg string) error {
+	value, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return fmt.Errorf("bad volume %q", arg)
+	}
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		current, err := mpvGetFloat("volume")
+		if err != nil {
+			return err
+		}
+		value += current
+	}
+	return mpvSetProperty("volume", max(0, min(100, value)))
+}
+
+// daemonStatus is what the daemon is doing
+type daemonStatus struct {
+	State    string  `json:"state"` // "playing", "paused" or "stopped"
+	Title    string  `json:"title,omitempty"`
+	URL      string  `json:"url,omitempty"` // The page URL
+	Track    string  `json:"track,omitempty"`
+	Volume   float64 `json:"volume"`
+	Position float64 `json:"position,omitempty"` // Seconds
+	Duration float64 `json:"duration,omitempty"` // Seconds, zero for live streams
+}
+
+// currentStatus asks mpv what's going on. d.mu must be held.
+func (d *daemon) currentStatus() daemonStatus {
+	status := mpvStatus(d.title)
+	if d.title != "" {
+		status.URL = d.url
+	}
+	return status
+}
+
+// mpvStatus asks mpv what's going on with the stream called title, if any
+func mpvStatus(title string) daemonStatus {
+	status := daemonStatus{State: "stopped", Title: title}
+	status.Volume, _ = mpvGetFloat("volume")
+	if title == "" {
+		return status
+	}
+	status.State = "playing"
+	if paused, _ := mpvGetBool("pause"); paused {
+		status.State = "paused"
+	}
+	status.Track = currentTrack()
+	status.Position, _ = mpvGetFloat("time-pos")
+	status.Duration, _ = mpvGetFloat("duration")
+	return status
+}
+
+// status describes what the daemon is doing, one fact per line
+func (d *daemon) status() string {
+	status := d.currentStatus()
+	if status.State == "stopped" {
+		return "stopped"
+	}
+	lines := []string{status.State + ": " + status.Title}
+	if status.Track != "" {
+		lines = append(lines, "track: "+status.Track)
+	}
+	lines = append(lines, fmt.Sprintf("volume: %.0f%%", status.Volume))
+	if status.Position > 0 {
+		elapsed := formatDuration(time.Duration(status.Position) * time.Second)
+		if status.Duration > 0 {
+			elapsed += " / " + formatDuration(time.Duration(status.Duration)*time.Second)
+		}
+		lines = append(lines, "position: "+elapsed)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runCtl sends one command to the daemon and prints its reply
+func runCtl(args []string) int {
+	// Flags stop at the daemon command, so "volume -5" isn't taken for one
+	flags := newFlagSet("ctl")
+	if err := flags.Parse(args); err != nil {
+		return parseFailed(err)
+	}
+	if err := applyGlobalFlags(); err != nil {
+		return usageError(flags, err.Error())
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return usageError(flags, "ctl needs a command")
+	}
+
+	message, err := askDaemon(daemonRequest{Command: args[0], Args: args[1:]})
+	if err != nil {
+		return fail(exitError, err)
+	}
+	fmt.Println(message)
+	return exitOK
+}
+
+// errNoDaemon means nothing answered on the daemon's socket
+var errNoDaemon = errors.New("the daemon isn't running; start it with lofitui daemon")
+
+// askDaemon sends the daemon a command and returns its reply
+func askDaemon(req daemonRequest) (string, error) {
+	conn, err := net.Dial("unix", daemonSocketPath())
+	if err != nil {
+		return "", errNoDaemon
+	}
+	defer conn.Close()
+
+	// Loading a stream can take a while
+	_ = conn.SetDeadline(time.Now().Add(90 * time.Second))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return "", err
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return "", fmt.Errorf("no reply from the daemon: %w", err)
+	}
+	if !resp.OK {
+		return "", errors.New(resp.Message)
+	}
+	return resp.Message, nil
+}
+
+// fetchDaemonStatus asks the daemon what it's doing, as a status rather
+// than ctl's text
+func fetchDaemonStatus() (daemonStatus, error) {
+	var status daemonStatus
+	conn, err := net.DialTimeout("unix", daemonSocketPath(), time.Second)
+	if err != nil {
+		return status, errNoDaemon
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := json.NewEncoder(conn).Encode(rpcRequest{JSONRPC: "2.0", Method: "player.status", ID: json.RawMessage("1")}); err != nil {
+		return status, err
+	}
+	var resp struct {
+		Result *daemonStatus `json:"result"`
+		Error  *rpcError     `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return status, fmt.Errorf("no reply from the daemon: %w", err)
+	}
+	if resp.Error != nil || resp.Result == nil {
+		return status, fmt.Errorf("the daemon couldn't say what it's doing")
+	}
+	return *resp.Result, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowYTDLP puts a yt-dlp on PATH that takes a second to extract anything
+func slowYTDLP(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\nsleep 1\necho https://stream.example/live\n"
+	if err := os.WriteFile(filepath.Join(dir, "yt-dlp"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func testDaemon(t *testing.T) *daemon {
+	t.Helper()
+	testModel(t)
+	return &daemon{playing: -1, quit: make(chan struct{}), exited: make(chan struct{}), events: newEventHub()}
+}
+
+func TestDaemonStatusWhileLoading(t *testing.T) {
+	f := newFakeMPV(t)
+	d := testDaemon(t)
+	slowYTDLP(t)
+
+	loaded := make(chan error, 1)
+	go func() {
+		_, err := d.handle(daemonRequest{Command: "play", Args: []string{"https://example.com/live"}})
+		loaded <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	answered := make(chan struct{})
+	go func() {
+		_, _ = d.handle(daemonRequest{Command: "status"})
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("status waited for the stream to load")
+	}
+
+	if err := <-loaded; err != nil {
+		t.Fatal(err)
+	}
+	if !f.sent("loadfile") {
+		t.Error("the stream was never handed to mpv")
+	}
+	if d.url != "https://example.com/live" {
+		t.Errorf("url = %q", d.url)
+	}
+}
+
+func TestDaemonStopWhileLoading(t *testing.T) {
+	f := newFakeMPV(t)
+	d := testDaemon(t)
+	slowYTDLP(t)
+
+	loaded := make(chan error, 1)
+	go func() {
+		_, err := d.handle(daemonRequest{Command: "play", Args: []string{"https://example.com/live"}})
+		loaded <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := d.handle(daemonRequest{Command: "stop"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := <-loaded
+	if err == nil || !strings.Contains(err.Error(), "superseded") {
+		t.Errorf("load after stop: err = %v", err)
+	}
+	if f.sent("loadfile") {
+		t.Error("a stopped load was still handed to mpv")
+	}
+	if d.title != "" || d.url != "" || d.playing != -1 {
+		t.Errorf("after stop: title %q, url %q, playing %d", d.title, d.url, d.playing)
+	}
+}
+
+func TestDaemonStopForgetsURL(t *testing.T) {
+	newFakeMPV(t)
+	d := testDaemon(t)
+	d.title, d.url = "Lofi Girl", "https://example.com/live"
+
+	if _, err := d.handle(daemonRequest{Command: "stop"}); err != nil {
+		t.Fatal(err)
+	}
+	if d.url != "" {
+		t.Errorf("url = %q after stop", d.url)
+	}
+}
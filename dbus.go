@@ -0,0 +1,684 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// D-Bus message types
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+	dbusSignal       = 4
+)
+
+// D-Bus header fields
+const (
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldErrorName   = 4
+	dbusFieldReplySerial = 5
+	dbusFieldDestination = 6
+	dbusFieldSender      = 7
+	dbusFieldSignature   = 8
+)
+
+// dbusNoReplyExpected is the header flag of calls that want no reply
+const dbusNoReplyExpected = 0x1
+
+// dbusMaxMessage is the largest message the spec allows
+const dbusMaxMessage = 128 << 20
+
+// errNoSessionBus means there's no D-Bus session to talk to, e.g. on macOS
+var errNoSessionBus = errors.New("no D-Bus session bus")
+
+// D-Bus types that Go strings can't tell apart
+type (
+	dbusObjectPath string
+	dbusSignature  string
+	dbusVariant    struct{ value interface{} }
+	dbusStruct     []interface{}
+)
+
+// dbusArray is an array of any type, named by signature so empty ones
+// can be sent too
+type dbusArray struct {
+	elem  string
+	items []interface{}
+}
+
+// dbusMessage is a method call, reply, error or signal
+type dbusMessage struct {
+	kind        byte
+	flags       byte
+	serial      uint32
+	path        dbusObjectPath
+	iface       string
+	member      string
+	errorName   string
+	replySerial uint32
+	destination string
+	sender      string
+	body        []interface{}
+}
+
+// dbusConn is a minimal D-Bus client: enough to own a name, answer method
+// calls and emit or follow signals, which is all an MPRIS player needs
+type dbusConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	mu     sync.Mutex // Serializes writes and serials
+	serial uint32
+}
+
+// dbusSessionBus connects and logs in to the session bus
+func dbusSessionBus() (*dbusConn, error) {
+	address := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if address == "" {
+		return nil, errNoSessionBus
+	}
+	return dbusDial(address)
+}
+
+// dbusSystemBus connects and logs in to the system bus, where logind is
+func dbusSystemBus() (*dbusConn, error) {
+	address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS")
+	if address == "" {
+		address = "unix:path=/var/run/dbus/system_bus_socket"
+	}
+	return dbusDial(address)
+}
+
+// dbusDial connects and logs in to a bus at a D-Bus address
+func dbusDial(address string) (*dbusConn, error) {
+	// The address may list several to try, e.g.
+	// "unix:path=/run/user/1000/bus;unix:abstract=/tmp/dbus-x,guid=..."
+	var conn net.Conn
+	err := fmt.Errorf("no usable D-Bus address in %q", address)
+	for _, addr := range strings.Split(address, ";") {
+		transport, params, _ := strings.Cut(addr, ":")
+		if transport != "unix" {
+			continue
+		}
+		var path string
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			value, _ = url.PathUnescape(value)
+			switch key {
+			case "path":
+				path = value
+			case "abstract":
+				path = "@" + value
+			}
+		}
+		if path == "" {
+			continue
+		}
+		if conn, err = net.DialTimeout("unix", path, 5*time.Second); err == nil {
+			break
+		}
+	}
+	if conn == nil {
+		return nil, err
+	}
+
+	// SASL EXTERNAL: the bus checks our uid against the socket's peer
+	r := bufio.NewReader(conn)
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := fmt.Fprintf(conn, "\x00AUTH EXTERNAL %s\r\n", uid); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		conn.Close()
+		return nil, fmt.Errorf("the bus refused us: %s", strings.TrimSpace(line))
+	}
+	if _, err := io.WriteString(conn, "BEGIN\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	c := &dbusConn{conn: conn, r: r}
+	if _, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// call makes a method call and waits for its reply. Anything else that
+// arrives meanwhile is dropped, so it's only for setting up before the
+// connection is served.
+func (c *dbusConn) call(destination string, path dbusObjectPath, iface, member string, args ...interface{}) ([]interface{}, error) {
+	serial, err := c.send(&dbusMessage{
+		kind: dbusMethodCall, destination: destination, path: path, iface: iface, member: member, body: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if msg.replySerial != serial {
+			continue
+		}
+		if msg.kind == dbusError {
+			return nil, fmt.Errorf("%s: %v", msg.errorName, msg.body)
+		}
+		return msg.body, nil
+	}
+}
+
+// reply answers a method call, unless the caller asked for no answer
+func (c *dbusConn) reply(call *dbusMessage, body ...interface{}) error {
+	if call.flags&dbusNoReplyExpected != 0 {
+		return nil
+	}
+	_, err := c.send(&dbusMessage{kind: dbusMethodReturn, replySerial: call.serial, destination: call.sender, body: body})
+	return err
+}
+
+// replyError answers a method call with an error
+func (c *dbusConn) replyError(call *dbusMessage, name, message string) error {
+	if call.flags&dbusNoReplyExpected != 0 {
+		return nil
+	}
+	_, err := c.send(&dbusMessage{
+		kind: dbusError, replySerial: call.serial, destination: call.sender, errorName: name, body: []interface{}{message},
+	})
+	return err
+}
+
+// emit broadcasts a signal
+func (c *dbusConn) emit(path dbusObjectPath, iface, member string, body ...interface{}) error {
+	_, err := c.send(&dbusMessage{kind: dbusSignal, path: path, iface: iface, member: member, body: body})
+	return err
+}
+
+// send writes a message, returning the serial it was given
+func (c *dbusConn) send(msg *dbusMessage) (uint32, error) {
+	var body dbusEncoder
+	var signature string
+	for _, value := range msg.body {
+		signature += dbusSignatureOf(value)
+		body.encode(value)
+	}
+
+	// Header fields, in the order of their codes; empty ones are left out
+	type field struct {
+		code  byte
+		value interface{}
+	}
+	var fields []field
+	if msg.path != "" {
+		fields = append(fields, field{dbusFieldPath, msg.path})
+	}
+	for _, f := range []struct {
+		code  byte
+		value string
+	}{
+		{dbusFieldInterface, msg.iface},
+		{dbusFieldMember, msg.member},
+		{dbusFieldErrorName, msg.errorName},
+	} {
+		if f.value != "" {
+			fields = append(fields, field{f.code, f.value})
+		}
+	}
+	if msg.replySerial != 0 {
+		fields = append(fields, field{dbusFieldReplySerial, msg.replySerial})
+	}
+	if msg.destination != "" {
+		fields = append(fields, field{dbusFieldDestination, msg.destination})
+	}
+	if signature != "" {
+		fields = append(fields, field{dbusFieldSignature, dbusSignature(signature)})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serial++
+	header := dbusEncoder{buf: []byte{'l', msg.kind, msg.flags, 1}}
+	header.uint32(uint32(len(body.buf)))
+	header.uint32(c.serial)
+	header.array(8, func() {
+		for _, f := range fields {
+			header.align(8)
+			header.buf = append(header.buf, f.code)
+			header.encode(dbusVariant{f.value})
+		}
+	})
+	header.align(8)
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, err := c.conn.Write(append(header.buf, body.buf...))
+	return c.serial, err
+}
+
+// read reads the next message
+func (c *dbusConn) read() (*dbusMessage, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(c.r, fixed); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("dbus: bad byte order %q", fixed[0])
+	}
+	bodyLength := int(order.Uint32(fixed[4:]))
+	headerLength := 16 + int(order.Uint32(fixed[12:]))
+	padded := (headerLength + 7) / 8 * 8
+	if bodyLength < 0 || headerLength < 16 || padded+bodyLength > dbusMaxMessage {
+		return nil, fmt.Errorf("dbus: message too big")
+	}
+	buf := make([]byte, padded+bodyLength)
+	copy(buf, fixed)
+	if _, err := io.ReadFull(c.r, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	msg := &dbusMessage{kind: fixed[1], flags: fixed[2], serial: order.Uint32(fixed[8:])}
+	header := dbusDecoder{buf: buf[:headerLength], pos: 12, order: order}
+	fields, _, err := header.decode("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+	var signature string
+	for _, f := range fields.([]interface{}) {
+		pair := f.([]interface{})
+		code, _ := pair[0].(byte)
+		switch value := pair[1].(type) {
+		case dbusObjectPath:
+			msg.path = value
+		case dbusSignature:
+			signature = string(value)
+		case uint32:
+			if code == dbusFieldReplySerial {
+				msg.replySerial = value
+			}
+		case string:
+			switch code {
+			case dbusFieldInterface:
+				msg.iface = value
+			case dbusFieldMember:
+				msg.member = value
+			case dbusFieldErrorName:
+				msg.errorName = value
+			case dbusFieldDestination:
+				msg.destination = value
+			case dbusFieldSender:
+				msg.sender = value
+			}
+		}
+	}
+
+	body := dbusDecoder{buf: buf[padded:], order: order}
+	for signature != "" {
+		var value interface{}
+		if value, signature, err = body.decode(signature); err != nil {
+			return nil, err
+		}
+		msg.body = append(msg.body, value)
+	}
+	return msg, nil
+}
+
+// dbusSignatureOf returns the D-Bus type of a Go value we know how to send
+func dbusSignatureOf(value interface{}) string {
+	switch v := value.(type) {
+	case byte:
+		return "y"
+	case bool:
+		return "b"
+	case int32:
+		return "i"
+	case uint32:
+		return "u"
+	case int64:
+		return "x"
+	case float64:
+		return "d"
+	case string:
+		return "s"
+	case dbusObjectPath:
+		return "o"
+	case dbusSignature:
+		return "g"
+	case dbusVariant:
+		return "v"
+	case []string:
+		return "as"
+	case map[string]interface{}:
+		return "a{sv}"
+	case dbusArray:
+		return "a" + v.elem
+	case dbusStruct:
+		signature := "("
+		for _, member := range v {
+			signature += dbusSignatureOf(member)
+		}
+		return signature + ")"
+	}
+	panic(fmt.Sprintf("dbus: can't send %T", value))
+}
+
+// dbusEncoder marshals values in little-endian wire format. Alignment is
+// relative to the start of buf, which must start a message or its body.
+type dbusEncoder struct {
+	buf []byte
+}
+
+func (e *dbusEncoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *dbusEncoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(append(e.buf, s...), 0)
+}
+
+// array writes the length-prefixed array that elems encodes
+func (e *dbusEncoder) array(elemAlign int, elems func()) {
+	e.uint32(0)
+	at := len(e.buf) - 4
+	// The padding before the first element isn't counted in the length
+	e.align(elemAlign)
+	start := len(e.buf)
+	elems()
+	binary.LittleEndian.PutUint32(e.buf[at:], uint32(len(e.buf)-start))
+}
+
+func (e *dbusEncoder) encode(value interface{}) {
+	switch v := value.(type) {
+	case byte:
+		e.buf = append(e.buf, v)
+	case bool:
+		if v {
+			e.uint32(1)
+		} else {
+			e.uint32(0)
+		}
+	case int32:
+		e.uint32(uint32(v))
+	case uint32:
+		e.uint32(v)
+	case int64:
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v))
+	case float64:
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+	case string:
+		e.string(v)
+	case dbusObjectPath:
+		e.string(string(v))
+	case dbusSignature:
+		e.buf = append(append(append(e.buf, byte(len(v))), v...), 0)
+	case dbusVariant:
+		e.encode(dbusSignature(dbusSignatureOf(v.value)))
+		e.encode(v.value)
+	case []string:
+		e.array(4, func() {
+			for _, s := range v {
+				e.string(s)
+			}
+		})
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.array(8, func() {
+			for _, key := range keys {
+				e.align(8)
+				e.string(key)
+				e.encode(dbusVariant{v[key]})
+			}
+		})
+	case dbusArray:
+		elemAlign := 4
+		switch v.elem[0] {
+		case 'y', 'g', 'v':
+			elemAlign = 1
+		case 'n', 'q':
+			elemAlign = 2
+		case 'x', 't', 'd', '(', '{':
+			elemAlign = 8
+		}
+		e.array(elemAlign, func() {
+			for _, item := range v.items {
+				e.encode(item)
+			}
+		})
+	case dbusStruct:
+		e.align(8)
+		for _, member := range v {
+			e.encode(member)
+		}
+	default:
+		panic(fmt.Sprintf("dbus: can't send %T", value))
+	}
+}
+
+// dbusDecoder unmarshals values by signature. Variants decode to the value
+// they hold, arrays and structs to []interface{}.
+type dbusDecoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errDBusShort = errors.New("dbus: message cut short")
+
+func (d *dbusDecoder) align(n int) {
+	d.pos = (d.pos + n - 1) / n * n
+}
+
+func (d *dbusDecoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) || n < 0 {
+		return nil, errDBusShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *dbusDecoder) fixed(size int) ([]byte, error) {
+	d.align(size)
+	return d.next(size)
+}
+
+// dbusSplitType splits the first complete type off a signature
+func dbusSplitType(signature string) (string, string, error) {
+	if signature == "" {
+		return "", "", fmt.Errorf("dbus: bad signature")
+	}
+	switch signature[0] {
+	case 'a':
+		elem, rest, err := dbusSplitType(signature[1:])
+		return "a" + elem, rest, err
+	case '(', '{':
+		depth := 0
+		for i, c := range signature {
+			switch c {
+			case '(', '{':
+				depth++
+			case ')', '}':
+				depth--
+				if depth == 0 {
+					return signature[:i+1], signature[i+1:], nil
+				}
+			}
+		}
+		return "", "", fmt.Errorf("dbus: bad signature %q", signature)
+	}
+	return signature[:1], signature[1:], nil
+}
+
+// decode reads a value of the first type in signature, returning the rest
+func (d *dbusDecoder) decode(signature string) (interface{}, string, error) {
+	typ, rest, err := dbusSplitType(signature)
+	if err != nil {
+		return nil, "", err
+	}
+	value, err := d.decodeType(typ)
+	return value, rest, err
+}
+
+func (d *dbusDecoder) decodeType(typ string) (interface{}, error) {
+	switch typ[0] {
+	case 'y':
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		b, err := d.fixed(4)
+		if err != nil {
+			return nil, err
+		}
+		return d.order.Uint32(b) != 0, nil
+	case 'n', 'q':
+		b, err := d.fixed(2)
+		if err != nil {
+			return nil, err
+		}
+		if typ[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'i', 'u', 'h':
+		b, err := d.fixed(4)
+		if err != nil {
+			return nil, err
+		}
+		if typ[0] == 'i' {
+			return int32(d.order.Uint32(b)), nil
+		}
+		return d.order.Uint32(b), nil
+	case 'x', 't', 'd':
+		b, err := d.fixed(8)
+		if err != nil {
+			return nil, err
+		}
+		v := d.order.Uint64(b)
+		switch typ[0] {
+		case 'x':
+			return int64(v), nil
+		case 'd':
+			return math.Float64frombits(v), nil
+		}
+		return v, nil
+	case 's', 'o':
+		b, err := d.fixed(4)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.next(int(d.order.Uint32(b)) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if typ[0] == 'o' {
+			return dbusObjectPath(s[:len(s)-1]), nil
+		}
+		return string(s[:len(s)-1]), nil
+	case 'g':
+		n, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.next(int(n[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return dbusSignature(s[:len(s)-1]), nil
+	case 'v':
+		signature, err := d.decodeType("g")
+		if err != nil {
+			return nil, err
+		}
+		value, rest, err := d.decode(string(signature.(dbusSignature)))
+		if err == nil && rest != "" {
+			err = fmt.Errorf("dbus: bad variant signature %q", signature)
+		}
+		return value, err
+	case 'a':
+		b, err := d.fixed(4)
+		if err != nil {
+			return nil, err
+		}
+		length := int(d.order.Uint32(b))
+		elem := typ[1:]
+		switch elem[0] {
+		case 'x', 't', 'd', '(', '{':
+			d.align(8)
+		}
+		end := d.pos + length
+		if end > len(d.buf) || length < 0 {
+			return nil, errDBusShort
+		}
+		values := []interface{}{}
+		for d.pos < end {
+			value, err := d.decodeType(elem)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case '(', '{':
+		d.align(8)
+		var values []interface{}
+		members := typ[1 : len(typ)-1]
+		for members != "" {
+			var value interface{}
+			var err error
+			if value, members, err = d.decode(members); err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("dbus: can't decode type %q", typ)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// dbusPipe connects two dbusConns back to back
+func dbusPipe(t *testing.T) (*dbusConn, *dbusConn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return &dbusConn{conn: a, r: bufio.NewReader(a)}, &dbusConn{conn: b, r: bufio.NewReader(b)}
+}
+
+// dbusRoundTrip sends msg from one end of a pipe and reads it at the other
+func dbusRoundTrip(t *testing.T, msg *dbusMessage) *dbusMessage {
+	t.Helper()
+	sender, receiver := dbusPipe(t)
+	sent := make(chan error, 1)
+	go func() {
+		_, err := sender.send(msg)
+		sent <- err
+	}()
+	got, err := receiver.read()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	return got
+}
+
+func TestDBusHeaderRoundTrip(t *testing.T) {
+	tests := []*dbusMessage{
+		{kind: dbusSignal, path: "/org/mpris/MediaPlayer2", iface: "org.freedesktop.DBus.Properties", member: "PropertiesChanged"},
+		{kind: dbusMethodCall, flags: dbusNoReplyExpected, path: "/org/freedesktop/DBus", iface: "org.freedesktop.DBus", member: "Hello", destination: "org.freedesktop.DBus"},
+		{kind: dbusMethodReturn, replySerial: 42, destination: ":1.7"},
+		{kind: dbusError, replySerial: 3, destination: ":1.7", errorName: "org.freedesktop.DBus.Error.UnknownMethod", body: []interface{}{"no such method"}},
+	}
+	for _, want := range tests {
+		got := dbusRoundTrip(t, want)
+		if got.serial == 0 {
+			t.Errorf("%s: no serial", want.member)
+		}
+		got.serial = 0
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %+v gave %+v", want, got)
+		}
+	}
+}
+
+func TestDBusBodyRoundTrip(t *testing.T) {
+	body := []interface{}{
+		byte(9),
+		true,
+		int32(-5),
+		uint32(7),
+		int64(-3_000_000_000),
+		1.5,
+		"Lofi Girl",
+		dbusObjectPath("/org/mpris/MediaPlayer2/Track/1"),
+		dbusSignature("a{sv}"),
+		dbusVariant{"held"},
+		[]string{"a", "bc"},
+		map[string]interface{}{"Volume": 0.5, "Title": "x", "Length": int64(60)},
+		dbusStruct{int32(1), "a", []string{}},
+		dbusArray{elem: "(ss)"},
+		dbusArray{elem: "y", items: []interface{}{byte(1), byte(2)}},
+		map[string]interface{}{},
+	}
+	want := []interface{}{
+		byte(9),
+		true,
+		int32(-5),
+		uint32(7),
+		int64(-3_000_000_000),
+		1.5,
+		"Lofi Girl",
+		dbusObjectPath("/org/mpris/MediaPlayer2/Track/1"),
+		dbusSignature("a{sv}"),
+		"held",
+		[]interface{}{"a", "bc"},
+		// Dict entries come out in key order
+		[]interface{}{
+			[]interface{}{"Length", int64(60)},
+			[]interface{}{"Title", "x"},
+			[]interface{}{"Volume", 0.5},
+		},
+		[]interface{}{int32(1), "a", []interface{}{}},
+		[]interface{}{},
+		[]interface{}{byte(1), byte(2)},
+		[]interface{}{},
+	}
+	got := dbusRoundTrip(t, &dbusMessage{kind: dbusSignal, path: "/x", iface: "a.b", member: "C", body: body})
+	if len(got.body) != len(want) {
+		t.Fatalf("got %d values, want %d: %#v", len(got.body), len(want), got.body)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got.body[i], want[i]) {
+			t.Errorf("value %d (%s): got %#v, want %#v", i, dbusSignatureOf(body[i]), got.body[i], want[i])
+		}
+	}
+}
+
+func TestDBusEncoding(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  []byte
+	}{
+		{"foo", []byte{3, 0, 0, 0, 'f', 'o', 'o', 0}},
+		{dbusSignature("as"), []byte{2, 'a', 's', 0}},
+		{true, []byte{1, 0, 0, 0}},
+		// The padding up to the first element isn't in the length
+		{map[string]interface{}{}, []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+		{dbusVariant{uint32(2)}, []byte{1, 'u', 0, 0, 2, 0, 0, 0}},
+		{[]string{"a"}, []byte{6, 0, 0, 0, 1, 0, 0, 0, 'a', 0}},
+	}
+	for _, test := range tests {
+		var e dbusEncoder
+		e.encode(test.value)
+		if !bytes.Equal(e.buf, test.want) {
+			t.Errorf("encode(%#v) = %v, want %v", test.value, e.buf, test.want)
+		}
+	}
+}
+
+func TestDBusDecodeBigEndian(t *testing.T) {
+	d := dbusDecoder{buf: []byte{0, 0, 0, 3, 'f', 'o', 'o', 0, 0, 0, 0, 7}, order: binary.BigEndian}
+	value, rest, err := d.decode("su")
+	if err != nil || value != "foo" {
+		t.Fatalf("decode = %#v, %v", value, err)
+	}
+	value, _, err = d.decode(rest)
+	if err != nil || value != uint32(7) {
+		t.Fatalf("decode = %#v, %v", value, err)
+	}
+}
+
+func TestDBusDecodeBadInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		buf       []byte
+		signature string
+	}{
+		{"string past the end", []byte{200, 0, 0, 0, 'a', 0}, "s"},
+		{"array past the end", []byte{0xff, 0xff, 0xff, 0x7f, 0, 0, 0, 0}, "ay"},
+		{"short number", []byte{1, 0}, "u"},
+		{"signature past the end", []byte{9, 'a'}, "g"},
+		{"unclosed struct", []byte{}, "(ss"},
+		{"variant holding two values", []byte{2, 'y', 'y', 0, 1, 2}, "v"},
+	}
+	for _, test := range tests {
+		d := dbusDecoder{buf: test.buf, order: binary.LittleEndian}
+		if value, _, err := d.decode(test.signature); err == nil {
+			t.Errorf("%s: decoded %#v", test.name, value)
+		}
+	}
+}
+
+func TestDBusReadRejectsBadMessages(t *testing.T) {
+	var e dbusEncoder
+	e.encode("x")
+	oversize := append([]byte{'l', dbusSignal, 0, 1}, make([]byte, 12)...)
+	binary.LittleEndian.PutUint32(oversize[4:], dbusMaxMessage)
+
+	for name, data := range map[string][]byte{
+		"bad byte order": append([]byte{'x', dbusSignal, 0, 1}, make([]byte, 12)...),
+		"too big":        oversize,
+		"cut short":      {'l', dbusSignal, 0, 1, 8, 0, 0, 0},
+	} {
+		c := &dbusConn{r: bufio.NewReader(bytes.NewReader(data))}
+		if msg, err := c.read(); err == nil {
+			t.Errorf("%s: read %+v", name, msg)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dependency is a program lofitui can't play streams without
+type dependency struct {
+	name    string // Program looked for on PATH
+	pkg     string // Package it's in, where that differs, e.g. ffmpeg for ffplay
+	purpose string
+	url     string // Install instructions, for systems we have no command for
+}
+
+// missingDependencies returns the programs needed to play streams that
+// aren't on PATH: yt-dlp and the configured player
+func missingDependencies(config *Config) []dependency {
+	needed := []dependency{
+		{name: "yt-dlp", purpose: "finds the stream behind a YouTube link", url: "https://github.com/yt-dlp/yt-dlp#installation"},
+	}
+	switch player := backendBinary(config); player {
+	case "mpv":
+		needed = append(needed, dependency{name: "mpv", purpose: "plays the streams", url: "https://mpv.io/installation/"})
+	case "vlc":
+		needed = append(needed, dependency{name: "vlc", purpose: "plays the streams", url: "https://www.videolan.org/vlc/"})
+	case "ffplay":
+		needed = append(needed, dependency{name: "ffplay", pkg: "ffmpeg", purpose: "plays the streams", url: "https://ffmpeg.org/download.html"})
+	case "":
+	default:
+		needed = append(needed, dependency{name: player, purpose: "is your player_command"})
+	}
+
+	var missing []dependency
+	for _, dep := range needed {
+		if _, err := exec.LookPath(dep.name); err != nil {
+			missing = append(missing, dep)
+		}
+	}
+	return missing
+}
+
+// installCommand returns the command installing deps with this system's
+// package manager, or "" if we don't know it
+func installCommand(deps []dependency) string {
+	var pkgs []string
+	for _, dep := range deps {
+		// A custom player could be anything
+		if dep.url == "" {
+			return ""
+		}
+		pkg := dep.name
+		if dep.pkg != "" {
+			pkg = dep.pkg
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	if len(pkgs) == 0 {
+		return ""
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return "brew install " + strings.Join(pkgs, " ")
+	case "windows":
+		return "scoop install " + strings.Join(pkgs, " ")
+	case "freebsd":
+		return "sudo pkg install " + strings.Join(pkgs, " ")
+	case "linux":
+		// Distributions say what they are, and what they're like
+		for _, id := range linuxDistro() {
+			switch id {
+			case "debian", "ubuntu":
+				return "sudo apt install " + strings.Join(pkgs, " ")
+			case "fedora", "rhel", "centos":
+				return "sudo dnf install " + strings.Join(pkgs, " ")
+			case "arch":
+				return "sudo pacman -S " + strings.Join(pkgs, " ")
+			case "opensuse", "suse":
+				return "sudo zypper install " + strings.Join(pkgs, " ")
+			case "alpine":
+				return "sudo apk add " + strings.Join(pkgs, " ")
+			case "void":
+				return "sudo xbps-install " + strings.Join(pkgs, " ")
+			case "nixos":
+				return "nix profile install nixpkgs#" + strings.Join(pkgs, " nixpkgs#")
+			}
+		}
+	}
+	return ""
+}
+
+// linuxDistro returns the ID and ID_LIKE entries of /etc/os-release, most
+// specific first
+func linuxDistro() []string {
+	file, err := os.Open("/etc/os-release")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var id, like []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			id = strings.Fields(value)
+		case "ID_LIKE":
+			like = strings.Fields(value)
+		}
+	}
+	// "opensuse-tumbleweed" is like "opensuse"
+	ids := append(id, like...)
+	for i, distro := range ids {
+		ids[i], _, _ = strings.Cut(distro, "-")
+	}
+	return ids
+}
+
+// checkDependencies looks for the missing programs again, showing what's
+// still missing in place of the current view. It reports whether anything
+// is.
+func (m *model) checkDependencies() bool {
+	m.missing = missingDependencies(m.config)
+	if len(m.missing) == 0 {
+		return false
+	}
+	if m.state != dependencyView {
+		m.replaceView(dependencyView)
+	}
+	return true
+}
+
+// dependencyContent renders what's missing and how to install it
+func (m model) dependencyContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	missing := lipgloss.NewStyle().Foreground(theme.Danger)
+
+	content := "Missing Dependencies\n\nlofitui needs these to play streams:\n"
+	for _, dep := range m.missing {
+		content += "\n" + missing.Render("✗ "+dep.name) + muted.Render(" — "+dep.purpose)
+	}
+
+	if command := installCommand(m.missing); command != "" {
+		content += "\n\nInstall them with:\n\n" + lipgloss.NewStyle().Foreground(theme.Accent).Render(command)
+	} else {
+		content += "\n\nInstall them from:\n"
+		for _, dep := range m.missing {
+			if dep.url != "" {
+				content += "\n" + dep.name + ": " + dep.url
+			} else {
+				content += "\n" + dep.name + ": check player_command in the config"
+			}
+		}
+	}
+	return content + "\n\n" + muted.Render("r=check again • Enter=continue anyway • q=quit")
+}
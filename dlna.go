@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UPnP AV services a renderer must have for us to play on it
+const (
+	avTransportService      = "urn:schemas-upnp-org:service:AVTransport:1"
+	renderingControlService = "urn:schemas-upnp-org:service:RenderingControl:1"
+)
+
+// dlnaTimeout bounds a single request to a renderer, and dlnaPoll is how
+// often a playing renderer is asked how it's doing
+const (
+	dlnaTimeout = 5 * time.Second
+	dlnaPoll    = 2 * time.Second
+)
+
+// discoverDLNARenderers asks the LAN over SSDP for UPnP media renderers,
+// listening for answers for castDiscoveryTime
+func discoverDLNARenderers() ([]castDevice, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Sonos speakers answer as ZonePlayers, with a renderer inside
+	group := &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+	for _, target := range []string{"urn:schemas-upnp-org:device:MediaRenderer:1", "urn:schemas-upnp-org:device:ZonePlayer:1"} {
+		search := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: 239.255.255.250:1900\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n" +
+			"ST: " + target + "\r\n\r\n"
+		if _, err := conn.WriteToUDP([]byte(search), group); err != nil {
+			return nil, err
+		}
+	}
+
+	// Collect where each renderer describes itself, then fetch those
+	locations := map[string]bool{}
+	_ = conn.SetReadDeadline(time.Now().Add(castDiscoveryTime))
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		if location := resp.Header.Get("Location"); location != "" {
+			locations[location] = true
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var devices []castDevice
+	for location := range locations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			device, err := describeRenderer(location)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			devices = append(devices, device)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return devices, nil
+}
+
+// upnpDevice is a device in a UPnP description; devices can nest
+type upnpDevice struct {
+	FriendlyName string `xml:"friendlyName"`
+	Manufacturer string `xml:"manufacturer"`
+	ModelName    string `xml:"modelName"`
+	RoomName     string `xml:"roomName"` // Sonos only
+	Services     []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// describeRenderer fetches a renderer's description to find its name and
+// the services we control it through
+func describeRenderer(location string) (castDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dlnaTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return castDevice{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return castDevice{}, err
+	}
+	defer resp.Body.Close()
+
+	var description struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&description); err != nil {
+		return castDevice{}, err
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return castDevice{}, err
+	}
+	if description.URLBase != "" {
+		if b, err := url.Parse(description.URLBase); err == nil {
+			base = b
+		}
+	}
+
+	root := description.Device
+	device := castDevice{name: root.FriendlyName, model: root.ModelName, dlna: true}
+	if strings.HasPrefix(root.Manufacturer, "Sonos") {
+		// Sonos' friendly names are "IP - Model"; the room is what people know
+		device.sonos = true
+		if root.RoomName != "" {
+			device.name = root.RoomName
+		}
+	}
+	var find func(d upnpDevice)
+	find = func(d upnpDevice) {
+		for _, service := range d.Services {
+			control, err := base.Parse(strings.TrimSpace(service.ControlURL))
+			if err != nil {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(service.ServiceType, strings.TrimSuffix(avTransportService, "1")) && device.avTransport == "":
+				device.avTransport = control.String()
+			case strings.HasPrefix(service.ServiceType, strings.TrimSuffix(renderingControlService, "1")) && device.renderingControl == "":
+				device.renderingControl = control.String()
+			}
+		}
+		for _, child := range d.Devices {
+			find(child)
+		}
+	}
+	find(description.Device)
+	if device.avTransport == "" {
+		return castDevice{}, fmt.Errorf("dlna: %s has no AVTransport service", device.name)
+	}
+	if device.name == "" {
+		device.name = base.Host
+	}
+	return device, nil
+}
+
+// soapCall invokes a UPnP action, returning the response's arguments by
+// name. args are written in order, as some renderers insist.
+func soapCall(controlURL, service, action string, args ...[2]string) (map[string]string, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, service)
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>%s</%s>", arg[0], html.EscapeString(arg[1]), arg[0])
+	}
+	fmt.Fprintf(&body, "</u:%s></s:Body></s:Envelope>", action)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dlnaTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", controlURL, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, service, action))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var fault struct {
+			Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+		}
+		_ = xml.Unmarshal(data, &fault)
+		if fault.Description != "" {
+			return nil, fmt.Errorf("dlna: %s: %s", action, fault.Description)
+		}
+		return nil, fmt.Errorf("dlna: %s: %s", action, resp.Status)
+	}
+
+	// The response's arguments are the children of Body's only element
+	results := map[string]string{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 4 {
+				var value string
+				if err := decoder.DecodeElement(&value, &t); err != nil {
+					return nil, err
+				}
+				results[t.Name.Local] = value
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// didlMetadata describes the stream for renderers that show what's
+// playing. Sonos wants live streams to be broadcasts.
+func didlMetadata(streamURL, title string, live bool) string {
+	class := "object.item.audioItem.musicTrack"
+	if live {
+		class = "object.item.audioItem.audioBroadcast"
+	}
+	return `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+		`<item id="0" parentID="-1" restricted="1">` +
+		`<dc:title>` + html.EscapeString(title) + `</dc:title>` +
+		`<upnp:class>` + class + `</upnp:class>` +
+		`<res protocolInfo="http-get:*:*:*">` + html.EscapeString(streamURL) + `</res>` +
+		`</item></DIDL-Lite>`
+}
+
+// dlnaRenderer controls playback on a UPnP AV renderer
+type dlnaRenderer struct {
+	device castDevice
+	quit   chan struct{}
+	once   sync.Once
+}
+
+// startDLNA hands the stream to a renderer and starts it playing
+func (s *castSession) startDLNA(streamURL, title string) error {
+	r := &dlnaRenderer{device: s.device, quit: make(chan struct{})}
+	instance := [2]string{"InstanceID", "0"}
+	if _, err := soapCall(r.device.avTransport, avTransportService, "SetAVTransportURI",
+		instance, [2]string{"CurrentURI", streamURL}, [2]string{"CurrentURIMetaData", didlMetadata(streamURL, title, isHLS(streamURL))}); err != nil {
+		return err
+	}
+	if _, err := soapCall(r.device.avTransport, avTransportService, "Play", instance, [2]string{"Speed", "1"}); err != nil {
+		return err
+	}
+	s.control = r
+	go s.pollRenderer(r)
+	return nil
+}
+
+// pollRenderer follows the renderer's state until it stops playing, goes
+// away or we stop it
+func (s *castSession) pollRenderer(r *dlnaRenderer) {
+	defer close(s.done)
+	ticker := time.NewTicker(dlnaPoll)
+	defer ticker.Stop()
+
+	// Renderers can report STOPPED for a moment before they get going
+	started, failures := false, 0
+	for since := time.Now(); ; {
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := soapCall(r.device.avTransport, avTransportService, "GetTransportInfo", [2]string{"InstanceID", "0"})
+		if err != nil {
+			if failures++; failures >= 3 {
+				s.finish(err)
+				return
+			}
+			continue
+		}
+		failures = 0
+
+		state := map[string]string{"PLAYING": "PLAYING", "PAUSED_PLAYBACK": "PAUSED", "TRANSITIONING": "BUFFERING"}[info["CurrentTransportState"]]
+		if state == "" {
+			if started || time.Since(since) > castTimeout {
+				s.finish(fmt.Errorf("dlna: %s stopped playing", r.device.name))
+				return
+			}
+			continue
+		}
+		started = true
+		volume, muted := r.volume()
+		s.setStatus(func(cs *castStatus) { cs.state, cs.volume, cs.muted = state, volume, muted })
+	}
+}
+
+// volume reads the renderer's volume and mute state, if it has them
+func (r *dlnaRenderer) volume() (float64, bool) {
+	if r.device.renderingControl == "" {
+		return 0, false
+	}
+	args := [][2]string{{"InstanceID", "0"}, {"Channel", "Master"}}
+	var volume float64
+	if result, err := soapCall(r.device.renderingControl, renderingControlService, "GetVolume", args...); err == nil {
+		fmt.Sscan(result["CurrentVolume"], &volume)
+	}
+	result, err := soapCall(r.device.renderingControl, renderingControlService, "GetMute", args...)
+	return volume, err == nil && (result["CurrentMute"] == "1" || result["CurrentMute"] == "true")
+}
+
+// setVolume sets the renderer's volume, 0-100
+func (r *dlnaRenderer) setVolume(volume float64) error {
+	if r.device.renderingControl == "" {
+		return fmt.Errorf("dlna: %s has no volume control", r.device.name)
+	}
+	_, err := soapCall(r.device.renderingControl, renderingControlService, "SetVolume",
+		[2]string{"InstanceID", "0"}, [2]string{"Channel", "Master"}, [2]string{"DesiredVolume", fmt.Sprint(int(min(max(volume, 0), 100)))})
+	return err
+}
+
+// setMuted mutes or unmutes the renderer
+func (r *dlnaRenderer) setMuted(muted bool) error {
+	if r.device.renderingControl == "" {
+		return fmt.Errorf("dlna: %s has no volume control", r.device.name)
+	}
+	desired := "0"
+	if muted {
+		desired = "1"
+	}
+	_, err := soapCall(r.device.renderingControl, renderingControlService, "SetMute",
+		[2]string{"InstanceID", "0"}, [2]string{"Channel", "Master"}, [2]string{"DesiredMute", desired})
+	return err
+}
+
+// pause pauses or resumes the renderer
+func (r *dlnaRenderer) pause(paused bool) error {
+	if paused {
+		_, err := soapCall(r.device.avTransport, avTransportService, "Pause", [2]string{"InstanceID", "0"})
+		return err
+	}
+	_, err := soapCall(r.device.avTransport, avTransportService, "Play", [2]string{"InstanceID", "0"}, [2]string{"Speed", "1"})
+	return err
+}
+
+// stop stops the renderer and stops following it
+func (r *dlnaRenderer) stop() error {
+	r.once.Do(func() { close(r.quit) })
+	_, err := soapCall(r.device.avTransport, avTransportService, "Stop", [2]string{"InstanceID", "0"})
+	return err
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ytdlpMaxAge is how old yt-dlp can get before YouTube changes are likely
+// to have broken it
+const ytdlpMaxAge = 90 * 24 * time.Hour
+
+// doctorCheck is the outcome of one doctor check
+type doctorCheck struct {
+	name   string
+	status string // "ok", "warn" or "fail"
+	detail string
+	fix    string // What to do about a warning or failure
+}
+
+// runDoctor checks the dependencies, config and network, printing a fix for
+// each problem. It exits non-zero if anything needed for playback is broken.
+func runDoctor(args []string) int {
+	flags := newFlagSet("doctor")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "doctor takes no arguments")
+	}
+
+	checks := []doctorCheck{
+		checkMPV(),
+		checkYtdlp(),
+		checkOptionalTool("ffmpeg", "needed only to identify songs", "install ffmpeg with your package manager"),
+		checkConfig(),
+		checkNetwork(),
+		checkAmbientPlayer(),
+	}
+	if runtime.GOOS == "darwin" {
+		checks = append(checks, checkNowPlaying())
+	}
+	if runtime.GOOS == "linux" {
+		checks = append(checks, checkMPRISScript())
+	}
+	if config, err := loadConfig(); err == nil {
+		if config.ListenBrainz.Token != "" {
+			checks = append(checks, checkListenBrainz(config.ListenBrainz))
+		}
+		if config.Snapcast.Enabled {
+			checks = append(checks, checkSnapcast(config.Snapcast))
+		}
+		if config.Hotkeys.Enabled {
+			checks = append(checks, checkHotkeys(config.Hotkeys))
+		}
+		if binary := backendBinary(config); binary != "mpv" {
+			// mpv is still needed for background playback and the daemon
+			if checks[0].status == "fail" {
+				checks[0].status = "warn"
+			}
+			checks = append(checks, checkPlayer(config))
+		}
+		if config.Ducking.Enabled && runtime.GOOS == "linux" {
+			checks = append(checks, checkOptionalTool("pactl", "needed to duck for other audio", "install pulseaudio-utils (it works with PipeWire too)"))
+		}
+	}
+
+	styles := map[string]lipgloss.Style{
+		"ok":   lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+		"warn": lipgloss.NewStyle().Foreground(theme.Warning),
+		"fail": lipgloss.NewStyle().Foreground(theme.Danger),
+	}
+	symbols := map[string]string{"ok": "✓", "warn": "!", "fail": "✗"}
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+
+	failed := false
+	for _, check := range checks {
+		fmt.Printf("%s %s: %s\n", styles[check.status].Render(symbols[check.status]), check.name, check.detail)
+		if check.fix != "" {
+			fmt.Println(muted.Render("    → " + check.fix))
+		}
+		failed = failed || check.status == "fail"
+	}
+
+	if failed {
+		return exitError
+	}
+	return exitOK
+}
+
+// commandVersion runs "<name> --version" and returns the first line
+func commandVersion(name string) (string, error) {
+	output, err := exec.Command(name, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return line, nil
+}
+
+// checkMPV makes sure mpv is installed
+func checkMPV() doctorCheck {
+	check := doctorCheck{name: "mpv"}
+	if _, err := exec.LookPath("mpv"); err != nil {
+		check.status, check.detail = "fail", "not found on PATH"
+		check.fix = "install mpv: https://mpv.io/installation/ (e.g. brew install mpv, apt install mpv)"
+		return check
+	}
+	version, err := commandVersion("mpv")
+	if err != nil {
+		check.status, check.detail = "fail", "found but won't run: "+err.Error()
+		check.fix = "reinstall mpv"
+		return check
+	}
+	// "mpv 0.38.0 Copyright © 2000-2024 mpv/MPlayer/mplayer2 projects"
+	fields := strings.Fields(version)
+	check.status, check.detail = "ok", strings.Join(fields[:min(2, len(fields))], " ")
+	return check
+}
+
+// checkPlayer makes sure the player chosen instead of mpv is installed
+func checkPlayer(config *Config) doctorCheck {
+	check := doctorCheck{name: "player"}
+	if _, err := playerBackend(config); err != nil {
+		check.status, check.detail = "fail", err.Error()
+		check.fix = `set "player" in the config to mpv, vlc, ffplay or custom`
+		return check
+	}
+	binary := backendBinary(config)
+	if _, err := exec.LookPath(binary); err != nil {
+		check.status, check.detail = "fail", binary+" not found on PATH"
+		check.fix = "install " + binary + ", or set \"player\" back to mpv"
+		return check
+	}
+	check.status, check.detail = "ok", binary
+	return check
+}
+
+// checkYtdlp makes sure yt-dlp is installed and recent enough for YouTube
+func checkYtdlp() doctorCheck {
+	check := doctorCheck{name: "yt-dlp"}
+	if _, err := exec.LookPath("yt-dlp"); err != nil {
+		check.status, check.detail = "fail", "not found on PATH"
+		check.fix = "install yt-dlp: https://github.com/yt-dlp/yt-dlp#installation (e.g. brew install yt-dlp, pipx install yt-dlp)"
+		return check
+	}
+	version, err := commandVersion("yt-dlp")
+	if err != nil {
+		check.status, check.detail = "fail", "found but won't run: "+err.Error()
+		check.fix = "reinstall yt-dlp"
+		return check
+	}
+
+	// Versions are release dates, e.g. "2024.08.06"
+	check.status, check.detail = "ok", version
+	if released, err := time.Parse("2006.01.02", version[:min(10, len(version))]); err == nil &&
+		time.Since(released) > ytdlpMaxAge {
+		check.status = "warn"
+		check.detail += fmt.Sprintf(" (%d days old)", int(time.Since(released).Hours()/24))
+		check.fix = "YouTube changes often break old versions; update with yt-dlp -U or your package manager"
+	}
+	return check
+}
+
+// checkOptionalTool reports whether a tool only some features need is installed
+func checkOptionalTool(name, purpose, fix string) doctorCheck {
+	check := doctorCheck{name: name}
+	if _, err := exec.LookPath(name); err != nil {
+		check.status, check.detail, check.fix = "warn", "not found ("+purpose+")", fix
+		return check
+	}
+	check.status, check.detail = "ok", "found"
+	return check
+}
+
+// checkConfig makes sure the config file parses and its presets make sense
+func checkConfig() doctorCheck {
+	check := doctorCheck{name: "config"}
+	path, err := getConfigPath()
+	if err != nil {
+		check.status, check.detail = "fail", err.Error()
+		check.fix = "set HOME or XDG_CONFIG_HOME"
+		return check
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		check.status, check.detail = "ok", path+" doesn't exist yet; defaults will be used"
+		return check
+	}
+	if err != nil {
+		check.status, check.detail = "fail", err.Error()
+		check.fix = "check the permissions of " + path
+		return check
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		check.status, check.detail = "fail", path+": "+err.Error()
+		check.fix = "fix the JSON, or move the file away to start over with the defaults"
+		return check
+	}
+
+	var problems []string
+	seen := map[string]bool{}
+	for i, preset := range config.Presets {
+		switch {
+		case strings.TrimSpace(preset.Name) == "":
+			problems = append(problems, fmt.Sprintf("preset %d has no name", i+1))
+		case strings.TrimSpace(preset.URL) == "":
+			problems = append(problems, fmt.Sprintf("preset %q has no URL", preset.Name))
+		case seen[strings.ToLower(preset.Name)]:
+			problems = append(problems, fmt.Sprintf("preset %q appears twice", preset.Name))
+		}
+		if _, ok := findQualityTier(preset.Quality); !ok {
+			problems = append(problems, fmt.Sprintf("preset %q has an unknown quality %q", preset.Name, preset.Quality))
+		}
+		seen[strings.ToLower(preset.Name)] = true
+	}
+	if _, ok := themes[config.Theme]; config.Theme != "" && !ok {
+		problems = append(problems, fmt.Sprintf("unknown theme %q", config.Theme))
+	}
+	// Configs from before log_level have none, which means info
+	if _, err := parseLogLevel(config.LogLevel); config.LogLevel != "" && err != nil {
+		problems = append(problems, fmt.Sprintf("unknown log_level %q", config.LogLevel))
+	}
+	if _, err := parseNowPlayingFormat(config.NowPlayingFile.Format); err != nil {
+		problems = append(problems, fmt.Sprintf("bad now_playing_file format: %v", err))
+	}
+	for _, arg := range config.MPVArgs {
+		// A separate value, e.g. ["--volume", "40"], would be taken as a file
+		if !strings.HasPrefix(arg, "--") {
+			problems = append(problems, fmt.Sprintf("mpv_args %q isn't an --option=value", arg))
+		}
+	}
+	for _, preset := range config.Presets {
+		for _, arg := range preset.MPVArgs {
+			if !strings.HasPrefix(arg, "--") {
+				problems = append(problems, fmt.Sprintf("preset %q: mpv_args %q isn't an --option=value", preset.Name, arg))
+			}
+		}
+	}
+	for action := range config.Keybindings {
+		if !isKeyAction(action) {
+			problems = append(problems, fmt.Sprintf("unknown keybinding action %q", action))
+		}
+	}
+
+	if len(problems) > 0 {
+		check.status, check.detail = "warn", strings.Join(problems, "; ")
+		check.fix = "edit " + path
+		return check
+	}
+	check.status, check.detail = "ok", fmt.Sprintf("%s (%d presets)", path, len(config.Presets))
+	return check
+}
+
+// checkNetwork makes sure YouTube can be reached
+func checkNetwork() doctorCheck {
+	check := doctorCheck{name: "network"}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Head("https://www.youtube.com")
+	if err != nil {
+		check.status, check.detail = "fail", "can't reach YouTube: "+err.Error()
+		check.fix = "check your internet connection, proxy (HTTPS_PROXY) or firewall"
+		return check
+	}
+	resp.Body.Close()
+	check.status, check.detail = "ok", "YouTube is reachable"
+	return check
+}
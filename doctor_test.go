@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckConfigWithoutNewerSettings(t *testing.T) {
+	// A config as written before most settings existed
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	t.Cleanup(func() { configFile = "" })
+	data := `{"presets": [{"name": "Lofi Girl", "url": "https://www.youtube.com/watch?v=jfKfPfyJRdk"}]}`
+	if err := os.WriteFile(configFile, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if check := checkConfig(); check.status != "ok" {
+		t.Errorf("%s: %s", check.status, check.detail)
+	}
+}
+
+func TestCheckConfigLogLevel(t *testing.T) {
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	t.Cleanup(func() { configFile = "" })
+	if err := os.WriteFile(configFile, []byte(`{"log_level": "loud"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if check := checkConfig(); check.status != "warn" {
+		t.Errorf("log_level loud: %s, want a warning", check.status)
+	}
+}
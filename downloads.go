@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// downloadsRefresh is how often the downloads view catches up with the
+// queue and the recordings directory
+const downloadsRefresh = 500 * time.Millisecond
+
+// maxListedRecordings is how many finished recordings the downloads view
+// lists, newest first
+const maxListedRecordings = 20
+
+// downloadItem is a row of the downloads view: a download, an offline
+// copy or a recording
+type downloadItem struct {
+	title    string
+	state    string  // A download's state, "saved" for offline copies, "recording" or "recorded"
+	progress float64 // 0 to 1, for downloads
+	url      string  // The page, for downloads and offline copies
+	path     string  // The file, once there is one
+	size     int64
+	err      error
+}
+
+// downloadsTickMsg refreshes the downloads view opened at the given time
+type downloadsTickMsg struct {
+	opened time.Time
+}
+
+// downloadsTick schedules the next refresh of the downloads view
+func downloadsTick(opened time.Time) tea.Cmd {
+	return tea.Tick(downloadsRefresh, func(time.Time) tea.Msg {
+		return downloadsTickMsg{opened: opened}
+	})
+}
+
+// showingDownloads reports whether the downloads view is open, on screen
+// or under something played from it
+func (m model) showingDownloads() bool {
+	return m.state == downloadsView || slices.Contains(m.navStack, downloadsView)
+}
+
+// refreshDownloads gathers the downloads view's rows: the queue, then the
+// presets saved for offline, then the recordings
+func (m *model) refreshDownloads() {
+	var items []downloadItem
+	for _, job := range m.downloads.snapshot() {
+		if job.state == "done" {
+			continue
+		}
+		items = append(items, downloadItem{title: job.title, state: job.state, progress: job.progress, url: job.url, err: job.err})
+	}
+	for _, preset := range m.config.Presets {
+		if path, ok := offlineCopy(m.config.Offline, preset.URL); ok {
+			item := downloadItem{title: preset.Name, state: "saved", url: preset.URL, path: path}
+			if info, err := os.Stat(path); err == nil {
+				item.size = info.Size()
+			}
+			items = append(items, item)
+		}
+	}
+	m.downloadItems = append(items, m.recordingItems()...)
+	m.downloadCursor = max(0, min(m.downloadCursor, len(m.downloadItems)-1))
+}
+
+// recordingItems lists the recording in progress and the newest finished
+// ones
+func (m model) recordingItems() []downloadItem {
+	dir, err := recordingDirectory(m.config.Recording)
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") {
+			files = append(files, info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+
+	var items []downloadItem
+	for _, info := range files[:min(len(files), maxListedRecordings)] {
+		path := filepath.Join(dir, info.Name())
+		item := downloadItem{
+			title: strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())),
+			state: "recorded",
+			path:  path,
+			size:  info.Size(),
+		}
+		if path == m.recording {
+			item.state = "recording"
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// formatSize renders a file size in MB, or KB when it's small
+func formatSize(size int64) string {
+	if size < 1<<20 {
+		return fmt.Sprintf("%d KB", size>>10)
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+}
+
+// downloadsContent renders the downloads view
+func (m model) downloadsContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	bar := progress.New(progress.WithSolidFill(string(theme.Info)), progress.WithoutPercentage(), progress.WithWidth(20))
+	var rows []string
+	if len(m.downloadItems) == 0 {
+		rows = append(rows, muted.Render("Nothing downloaded or recorded yet"))
+	}
+	// Scroll to keep the cursor on screen in short terminals
+	visible := max(3, m.height-12)
+	first := max(0, min(m.downloadCursor-visible/2, len(m.downloadItems)-visible))
+	for i, item := range m.downloadItems {
+		if i < first || i >= first+visible {
+			continue
+		}
+		var status string
+		switch item.state {
+		case "downloading", "paused":
+			status = bar.ViewAs(item.progress) + fmt.Sprintf(" %3.0f%%", item.progress*100)
+			if item.state == "paused" {
+				status += muted.Render(" paused")
+			}
+		case "queued":
+			status = muted.Render("queued")
+		case "failed":
+			status = lipgloss.NewStyle().Foreground(theme.Danger).Render("failed")
+			if i == m.downloadCursor && item.err != nil {
+				status += muted.Render(": " + item.err.Error())
+			}
+		case "recording":
+			status = lipgloss.NewStyle().Foreground(theme.Danger).Render("● REC ") + formatSize(item.size)
+		case "saved":
+			status = muted.Render("offline ") + formatSize(item.size)
+		default:
+			status = muted.Render(formatSize(item.size))
+		}
+
+		prefix := "  "
+		title := item.title
+		if i == m.downloadCursor {
+			prefix = lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• ")
+			title = lipgloss.NewStyle().Bold(theme.Bold).Render(title)
+		}
+		rows = append(rows, prefix+title+"  "+status)
+	}
+
+	help := "↑/↓ select • Enter to play • p pause/resume • x cancel • ESC to go back"
+	return "Downloads & Recordings\n\n" + strings.Join(rows, "\n") + "\n\n" + muted.Render(help)
+}
+
+// selectedDownload returns the row under the cursor, if any
+func (m model) selectedDownload() (downloadItem, bool) {
+	if m.downloadCursor >= len(m.downloadItems) {
+		return downloadItem{}, false
+	}
+	return m.downloadItems[m.downloadCursor], true
+}
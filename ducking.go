@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// audioClientName is what lofitui's own audio is called in PulseAudio and
+// PipeWire, so ducking can tell it from other apps'
+const audioClientName = "lofitui"
+
+// duckRestoreDelay is how long other audio has to stay quiet before the
+// volume comes back, so a pause between videos doesn't pump the music
+const duckRestoreDelay = 3 * time.Second
+
+// audioClientArgs names mpv's audio stream after lofitui
+func audioClientArgs() []string {
+	return []string{"--audio-client-name=" + audioClientName}
+}
+
+// ducker turns the stream, and the ambient sound if there is one, down
+// while other apps play audio
+type ducker struct {
+	cfg     DuckingConfig
+	ambient *ambientPlayer // nil outside the TUI
+	ducked  bool
+	saved   float64 // mpv's volume before ducking
+	lowered float64 // The volume ducked to, to tell if it was changed since
+}
+
+// watchDucking follows PulseAudio (or PipeWire's PulseAudio server) for as
+// long as the process runs, ducking whenever another app's audio plays.
+// Without pactl, e.g. on macOS, it does nothing.
+func watchDucking(cfg DuckingConfig, ambient *ambientPlayer) {
+	if !cfg.Enabled {
+		return
+	}
+	if _, err := exec.LookPath("pactl"); err != nil {
+		slog.Debug("no pactl, not ducking for other audio")
+		return
+	}
+	cmd := exec.Command("pactl", "subscribe")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		slog.Warn("can't watch for other audio, not ducking", "err", err)
+		return
+	}
+	defer cmd.Process.Kill()
+	slog.Debug("ducking for other audio", "volume", cfg.Volume)
+
+	// Only whether something changed matters, not what. Other audio may
+	// already be playing, so look straight away too.
+	changed := make(chan struct{}, 1)
+	changed <- struct{}{}
+	go func() {
+		defer close(changed)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if strings.Contains(scanner.Text(), "sink-input") {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	d := &ducker{cfg: cfg, ambient: ambient}
+	var restore <-chan time.Time
+	for {
+		select {
+		case _, ok := <-changed:
+			if !ok {
+				slog.Debug("lost PulseAudio, not ducking any more")
+				d.restore()
+				return
+			}
+			if app, ok := otherAudioPlaying(cfg.Ignore); ok {
+				restore = nil
+				d.duck(app)
+			} else if d.ducked && restore == nil {
+				restore = time.After(duckRestoreDelay)
+			}
+		case <-restore:
+			restore = nil
+			d.restore()
+		}
+	}
+}
+
+// otherAudioPlaying returns the name of an app other than lofitui playing
+// audio, if any. Event sounds and ignored apps don't count.
+func otherAudioPlaying(ignore []string) (string, bool) {
+	cmd := exec.Command("pactl", "list", "sink-inputs")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", false
+	}
+	for _, input := range parseSinkInputs(string(output)) {
+		app := input["application.name"]
+		if input["corked"] == "yes" || input["media.role"] == "event" || app == audioClientName {
+			continue
+		}
+		ignored := false
+		for _, name := range ignore {
+			ignored = ignored || strings.EqualFold(name, app)
+		}
+		if !ignored {
+			return app, true
+		}
+	}
+	return "", false
+}
+
+// parseSinkInputs reads "pactl list sink-inputs" into the properties of
+// each sink input, with "corked" for whether it's paused
+func parseSinkInputs(output string) []map[string]string {
+	var inputs []map[string]string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "Sink Input #") {
+			inputs = append(inputs, map[string]string{})
+			continue
+		}
+		if len(inputs) == 0 {
+			continue
+		}
+		input := inputs[len(inputs)-1]
+		line = strings.TrimSpace(line)
+		if corked, ok := strings.CutPrefix(line, "Corked: "); ok {
+			input["corked"] = corked
+		} else if key, value, ok := strings.Cut(line, " = "); ok {
+			input[key] = strings.Trim(value, `"`)
+		}
+	}
+	return inputs
+}
+
+// duck turns lofitui down for app
+func (d *ducker) duck(app string) {
+	if d.ducked {
+		return
+	}
+	d.ducked = true
+	if d.ambient != nil {
+		d.ambient.duckTo.Store(int32(d.cfg.Volume))
+	}
+	volume, err := mpvGetFloat("volume")
+	if err != nil {
+		// Nothing's playing; the ambient sound is all there is to duck
+		d.saved, d.lowered = 0, 0
+		return
+	}
+	d.saved, d.lowered = volume, math.Round(volume*float64(d.cfg.Volume)/100)
+	fadeVolume(volume, d.lowered, 300*time.Millisecond)
+	slog.Info("ducked for other audio", "app", app, "volume", d.lowered)
+}
+
+// restore brings the volume back, unless it was changed while ducked
+func (d *ducker) restore() {
+	if !d.ducked {
+		return
+	}
+	d.ducked = false
+	if d.ambient != nil {
+		d.ambient.duckTo.Store(100)
+	}
+	if volume, err := mpvGetFloat("volume"); err == nil && d.saved > 0 && math.Abs(volume-d.lowered) < 1 {
+		fadeVolume(volume, d.saved, time.Second)
+		slog.Info("restored the volume after other audio", "volume", d.saved)
+	}
+}
+
+// fadeVolume moves mpv's volume from one level to another over a while,
+// rather than jumping
+func fadeVolume(from, to float64, over time.Duration) {
+	const steps = 10
+	for i := 1; i <= steps; i++ {
+		if err := mpvSetProperty("volume", from+(to-from)*float64(i)/steps); err != nil {
+			return
+		}
+		time.Sleep(over / steps)
+	}
+}
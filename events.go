@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/willyv3/lofitui/pkg/lofitui"
+)
+
+// playerEvent is a state change pushed to event subscribers
+type playerEvent struct {
+	Event  string       `json:"event"` // "now_playing", "track", "volume", "paused", "resumed", "stopped", "stream_ended" or "error"
+	Status daemonStatus `json:"status"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// printEvent writes an event to stdout as a line of JSON
+func printEvent(event playerEvent) {
+	_ = json.NewEncoder(os.Stdout).Encode(event)
+}
+
+// printEvents prints every event to stdout until the daemon quits, for
+// wrappers that read the daemon's output instead of polling it
+func (d *daemon) printEvents() {
+	events := d.events.subscribe()
+	for {
+		select {
+		case event := <-events:
+			printEvent(event)
+		case <-d.quit:
+			return
+		case <-d.exited:
+			return
+		}
+	}
+}
+
+// eventHub fans player events out to subscribers
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan playerEvent]bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: map[chan playerEvent]bool{}}
+}
+
+// subscribe returns a channel receiving every event from now on
+func (h *eventHub) subscribe() chan playerEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan playerEvent, 16)
+	h.subs[ch] = true
+	return ch
+}
+
+// unsubscribe stops and closes a subscription
+func (h *eventHub) unsubscribe(ch chan playerEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[ch] {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// publish sends an event to every subscriber, skipping any that have
+// fallen behind rather than holding up the player
+func (h *eventHub) publish(event playerEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// watchPlayer observes mpv's properties and publishes changes as events
+// until mpv goes away. It sees changes made from anywhere: ctl, HTTP,
+// MPRIS or the stream simply ending.
+func (d *daemon) watchPlayer() {
+	// mpv reports every property's value when it's first observed; only
+	// later changes are events
+	last := map[string]interface{}{}
+	lastTrack := ""
+	properties := []string{"pause", "volume", "idle-active", "metadata", "chapter-metadata"}
+	_ = player().Watch(properties, func(resp lofitui.Event) {
+		var event string
+		switch resp.Event {
+		case "file-loaded":
+			event = "now_playing"
+		case "property-change":
+			if resp.Name == "metadata" || resp.Name == "chapter-metadata" {
+				// Metadata is a map, so compare the track title it yields
+				if track := currentTrack(); track != lastTrack {
+					lastTrack = track
+					event = "track"
+				}
+				break
+			}
+			previous, seen := last[resp.Name]
+			last[resp.Name] = resp.Data
+			if !seen || previous == resp.Data {
+				return
+			}
+			switch resp.Name {
+			case "pause":
+				event = "resumed"
+				if resp.Data == true {
+					event = "paused"
+				}
+			case "volume":
+				event = "volume"
+			case "idle-active":
+				if resp.Data == true {
+					event = "stream_ended"
+				}
+			}
+		}
+		if event == "" {
+			return
+		}
+
+		d.mu.Lock()
+		if event == "stream_ended" {
+			// The stop command forgets the title before mpv goes idle
+			if d.title == "" {
+				event = "stopped"
+			}
+			d.title, d.url = "", ""
+		}
+		d.events.publish(playerEvent{Event: event, Status: d.currentStatus()})
+		d.mu.Unlock()
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/willyv3/lofitui/pkg/lofitui"
+)
+
+// How often headless mode checks on mpv, and how often it prints the position
+const (
+	headlessPoll     = 2 * time.Second
+	headlessProgress = time.Minute
+)
+
+// runHeadless plays a preset or URL without the TUI, printing one plain
+// line per change instead of mpv's status line, for systemd and scripts.
+// With jsonEvents it prints the daemon's JSON events instead.
+func runHeadless(target string, random, jsonEvents bool) int {
+	if (target == "") == !random {
+		return usageError(nil, "-no-tui needs a preset name, a URL or -random")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		config = getDefaultConfig()
+	}
+
+	var title, url string
+	report := func(event, line string, err error) {
+		status := daemonStatus{State: "stopped"}
+		if event != "stopped" && event != "stream_ended" && err == nil {
+			status = mpvStatus(title)
+		}
+		status.Title, status.URL = title, url
+		handleEvent(config, event, status)
+
+		if !jsonEvents {
+			if line != "" && !quiet {
+				fmt.Println(line)
+			}
+			return
+		}
+		if status.State == "stopped" {
+			status.Title, status.URL = "", ""
+		}
+		e := playerEvent{Event: event, Status: status}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		printEvent(e)
+	}
+	failed := func(err error) int {
+		report("error", "", err)
+		return fail(exitCode(err), err)
+	}
+
+	if err := requirePlayer(); err != nil {
+		return failed(err)
+	}
+	streamURL, title, url, err := loadPlayTarget(target, random, func(title string) {
+		if !jsonEvents && !quiet {
+			fmt.Printf("Loading %s\n", title)
+		}
+	})
+	if err != nil {
+		return failed(err)
+	}
+
+	mpv, err := lofitui.Start(getMPVSocketPath(), mpvArgs(streamURL, title, []string{"--no-video", "--no-terminal"}, append(configArgs(config), presetArgs(config, url)...))...)
+	if err != nil {
+		return failed(fmt.Errorf("failed to start mpv: %w", err))
+	}
+	go watchAutoPause(config.AutoPause)
+	go watchDucking(config.Ducking, nil)
+	report("now_playing", "Playing "+title, nil)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	// MPRIS stops like Ctrl+C does, so it isn't reported as a drop
+	stream := &mprisStream{}
+	stream.set(title, url)
+	defer startMPRISOrLog(mprisControls{status: stream.status, stop: func() error {
+		select {
+		case stop <- os.Interrupt:
+		default:
+		}
+		return nil
+	}}).close()
+	poll := time.NewTicker(headlessPoll)
+	defer poll.Stop()
+
+	var track string
+	var lastProgress time.Time
+	for {
+		select {
+		case <-stop:
+			mpv.Stop(3 * time.Second)
+			report("stopped", "Stopped", nil)
+			return exitOK
+		case <-mpv.Exited():
+			if err := mpv.Wait(); err != nil {
+				return failed(fmt.Errorf("mpv: %w", err))
+			}
+			report("stream_ended", "Stream ended", nil)
+			return exitOK
+		case <-poll.C:
+			if current := currentTrack(); current != "" && current != track {
+				track = current
+				report("track", "Track "+track, nil)
+			}
+			// Events already carry the position
+			if jsonEvents || time.Since(lastProgress) < headlessProgress {
+				continue
+			}
+			position, err := mpvGetFloat("time-pos")
+			if err != nil {
+				continue
+			}
+			lastProgress = time.Now()
+			progress := formatDuration(time.Duration(position) * time.Second)
+			if duration, _ := mpvGetFloat("duration"); duration > 0 {
+				progress += " / " + formatDuration(time.Duration(duration)*time.Second)
+			}
+			fmt.Printf("Position %s\n", progress)
+		}
+	}
+}
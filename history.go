@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// getHistoryPath returns where the listening history is kept, one JSON
+// play record per line
+func getHistoryPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "history.jsonl"), nil
+}
+
+// appendHistory adds a play to the history
+func appendHistory(record playRecord) {
+	path, err := getHistoryPath()
+	if err != nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	_, _ = file.Write(append(line, '\n'))
+}
+
+// loadHistory returns the last limit plays, newest first; limit <= 0
+// returns all of them. Lines that don't parse are skipped.
+func loadHistory(limit int) ([]playRecord, error) {
+	path, err := getHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer file.Close()
+
+	var records []playRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record playRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// runHistory prints recent plays, newest first
+func runHistory(args []string) int {
+	flags := newFlagSet("history")
+	limit := flags.Int("limit", 20, "Show the last `N` plays, 0 for all")
+	asJSON := flags.Bool("json", false, "Print JSON instead of a table")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "history takes no arguments")
+	}
+
+	records, err := loadHistory(*limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+
+	if *asJSON {
+		if records == nil {
+			records = []playRecord{}
+		}
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+			return exitError
+		}
+		fmt.Println(string(data))
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PLAYED\tTITLE\tURL")
+	for _, record := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", record.At.Local().Format("Mon 2006-01-02 15:04"), record.Title, record.URL)
+	}
+	w.Flush()
+	return exitOK
+}
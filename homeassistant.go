@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// haDevice groups lofitui's entities into one device in Home Assistant
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// haEntity is the discovery config of one entity. Home Assistant's MQTT
+// integration has no media player, so the player is split into the parts
+// it does have: sensors for the state, buttons for the controls, a
+// number for the volume and a select for the presets.
+type haEntity struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	Icon              string   `json:"icon,omitempty"`
+	StateTopic        string   `json:"state_topic,omitempty"`
+	ValueTemplate     string   `json:"value_template,omitempty"`
+	CommandTopic      string   `json:"command_topic,omitempty"`
+	PayloadPress      *string  `json:"payload_press,omitempty"`
+	Options           []string `json:"options,omitempty"`
+	Min               *float64 `json:"min,omitempty"`
+	Max               *float64 `json:"max,omitempty"`
+	Step              *float64 `json:"step,omitempty"`
+	UnitOfMeasurement string   `json:"unit_of_measurement,omitempty"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	Device            haDevice `json:"device"`
+}
+
+// publishDiscovery announces the daemon's entities to Home Assistant.
+// The presets select lists the presets as they are now, so changes show
+// up when the daemon next connects.
+func publishDiscovery(client *mqttClient, cfg MQTTConfig) error {
+	hostname, _ := os.Hostname()
+	id := "lofitui_" + strings.NewReplacer(".", "_", "-", "_", " ", "_").Replace(hostname)
+	device := haDevice{Identifiers: []string{id}, Name: "lofitui on " + hostname, Manufacturer: "lofitui", Model: "daemon"}
+	prefix := cfg.Topic
+	entity := func(key, name, icon string) haEntity {
+		return haEntity{Name: name, UniqueID: id + "_" + key, Icon: icon, AvailabilityTopic: prefix + "/availability", Device: device}
+	}
+
+	type announcement struct {
+		component, key string
+		config         haEntity
+	}
+	var announcements []announcement
+
+	state := entity("state", "State", "mdi:music")
+	state.StateTopic, state.ValueTemplate = prefix+"/state", "{{ value_json.state }}"
+	announcements = append(announcements, announcement{"sensor", "state", state})
+
+	nowPlaying := entity("now_playing", "Now playing", "mdi:radio")
+	nowPlaying.StateTopic = prefix + "/now_playing"
+	announcements = append(announcements, announcement{"sensor", "now_playing", nowPlaying})
+
+	track := entity("track", "Track", "mdi:music-note")
+	track.StateTopic, track.ValueTemplate = prefix+"/state", "{{ value_json.track | default('') }}"
+	announcements = append(announcements, announcement{"sensor", "track", track})
+
+	// Play with no payload resumes; pause toggles
+	empty := ""
+	for _, button := range []struct{ command, name, icon string }{
+		{"play", "Play", "mdi:play"},
+		{"pause", "Play/pause", "mdi:play-pause"},
+		{"stop", "Stop", "mdi:stop"},
+		{"next", "Next preset", "mdi:skip-next"},
+		{"prev", "Previous preset", "mdi:skip-previous"},
+	} {
+		config := entity(button.command, button.name, button.icon)
+		config.CommandTopic, config.PayloadPress = prefix+"/command/"+button.command, &empty
+		announcements = append(announcements, announcement{"button", button.command, config})
+	}
+
+	low, high, step := 0.0, 100.0, 1.0
+	volume := entity("volume", "Volume", "mdi:volume-high")
+	volume.StateTopic, volume.ValueTemplate = prefix+"/state", "{{ value_json.volume | int }}"
+	volume.CommandTopic = prefix + "/command/volume"
+	volume.Min, volume.Max, volume.Step, volume.UnitOfMeasurement = &low, &high, &step, "%"
+	announcements = append(announcements, announcement{"number", "volume", volume})
+
+	// Picking a preset plays it. Select needs options, so it's left out
+	// without presets.
+	if config, err := loadConfig(); err == nil && len(config.Presets) > 0 {
+		preset := entity("preset", "Preset", "mdi:playlist-music")
+		preset.StateTopic, preset.ValueTemplate = prefix+"/state", "{{ value_json.title | default('') }}"
+		preset.CommandTopic = prefix + "/command/play"
+		for _, p := range config.Presets {
+			preset.Options = append(preset.Options, p.Name)
+		}
+		announcements = append(announcements, announcement{"select", "preset", preset})
+	}
+
+	for _, a := range announcements {
+		payload, err := json.Marshal(a.config)
+		if err != nil {
+			return err
+		}
+		topic := cfg.DiscoveryPrefix + "/" + a.component + "/" + id + "/" + a.key + "/config"
+		if err := client.publish(topic, payload, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// hookCommand returns the configured hook for a player event, if any
+func hookCommand(hooks HooksConfig, event string) string {
+	switch event {
+	case "now_playing":
+		return hooks.OnPlay
+	case "track":
+		return hooks.OnTrackChange
+	case "stopped", "stream_ended":
+		return hooks.OnStop
+	}
+	return ""
+}
+
+// runHook runs the hook for a player event in the background, passing the
+// stream's details in LOFITUI_* environment variables. Its output is
+// dropped so it can't mess up the TUI.
+func runHook(hooks HooksConfig, event string, status daemonStatus) {
+	command := hookCommand(hooks, event)
+	if command == "" {
+		return
+	}
+	shell := []string{"sh", "-c"}
+	if runtime.GOOS == "windows" {
+		shell = []string{"cmd", "/C"}
+	}
+	cmd := exec.Command(shell[0], append(shell[1:], command)...)
+	cmd.Env = append(os.Environ(),
+		"LOFITUI_EVENT="+event,
+		"LOFITUI_TITLE="+status.Title,
+		"LOFITUI_URL="+status.URL,
+		"LOFITUI_TRACK="+status.Track,
+	)
+	slog.Debug("running hook", "event", event, "command", command)
+	if err := cmd.Start(); err != nil {
+		slog.Warn("hook failed to start", "event", event, "err", err)
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("hook failed", "event", event, "err", err)
+		}
+	}()
+}
+
+// handleEvent runs the hook, shows the notification, scrobbles the track
+// and updates the now playing file for a player event
+func handleEvent(config *Config, event string, status daemonStatus) {
+	runHook(config.Hooks, event, status)
+	writeNowPlaying(config.NowPlayingFile, event, status)
+	notifyEvent(config.Notifications, event, status)
+	scrobbleEvent(config.ListenBrainz, event, status)
+}
+
+// runHooks handles the daemon's events until it quits. Stop events come
+// after the title is forgotten, so they get the last stream's.
+func (d *daemon) runHooks(config *Config) {
+	events := d.events.subscribe()
+	var last daemonStatus
+	for {
+		select {
+		case event := <-events:
+			status := event.Status
+			if event.Event == "stopped" || event.Event == "stream_ended" {
+				status.Title, status.URL = last.Title, last.URL
+			} else if status.Title != "" {
+				last = status
+			}
+			handleEvent(config, event.Event, status)
+		case <-d.quit:
+			return
+		case <-d.exited:
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// hotkeyActions are the daemon commands global hotkeys can run, by the
+// action name used in the config
+var hotkeyActions = map[string][]string{
+	"pause":       {"pause"},
+	"stop":        {"stop"},
+	"next":        {"next"},
+	"prev":        {"prev"},
+	"volume_up":   {"volume", "+5"},
+	"volume_down": {"volume", "-5"},
+}
+
+// defaultHotkeys are used for actions the config doesn't bind
+var defaultHotkeys = map[string]string{
+	"pause":       "super+alt+space",
+	"next":        "super+alt+right",
+	"prev":        "super+alt+left",
+	"volume_up":   "super+alt+up",
+	"volume_down": "super+alt+down",
+}
+
+// hotkeyHelper is a program that grabs global hotkeys and runs commands
+// for them: sxhkd on X11, skhd on macOS
+type hotkeyHelper struct {
+	name string
+	line func(keys, command string) string // One binding in its config
+}
+
+// hotkeyHelpers are the helpers lofitui knows how to configure
+var hotkeyHelpers = map[string]hotkeyHelper{
+	"sxhkd": {"sxhkd", func(keys, command string) string {
+		return sxhkdKeys(keys) + "\n\t" + command + "\n"
+	}},
+	"skhd": {"skhd", func(keys, command string) string {
+		return skhdKeys(keys) + " : " + command + "\n"
+	}},
+}
+
+// sxhkdKeys turns "super+alt+up" into sxhkd's "super + alt + Up"
+func sxhkdKeys(keys string) string {
+	parts := strings.Split(strings.ToLower(keys), "+")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		// Named keys are X keysyms, which are case sensitive
+		switch part {
+		case "up", "down", "left", "right", "home", "end", "prior", "next":
+			part = strings.ToUpper(part[:1]) + part[1:]
+		case "ctrl":
+			part = "control"
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, " + ")
+}
+
+// skhdKeys turns "super+alt+up" into skhd's "cmd + alt - up"
+func skhdKeys(keys string) string {
+	parts := strings.Split(strings.ToLower(keys), "+")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "super" {
+			part = "cmd"
+		}
+		parts[i] = part
+	}
+	key := parts[len(parts)-1]
+	if len(parts) == 1 {
+		return key
+	}
+	return strings.Join(parts[:len(parts)-1], " + ") + " - " + key
+}
+
+// findHotkeyHelper returns the configured helper, by default the one for
+// the OS, and where it's installed
+func findHotkeyHelper(cfg HotkeysConfig) (helper hotkeyHelper, path string, err error) {
+	name := cfg.Helper
+	if name == "" {
+		name = "sxhkd"
+		if runtime.GOOS == "darwin" {
+			name = "skhd"
+		}
+	}
+	helper, ok := hotkeyHelpers[name]
+	if !ok {
+		return helper, "", fmt.Errorf("unknown hotkey helper %q; use sxhkd or skhd", name)
+	}
+	path, err = exec.LookPath(helper.name)
+	if err != nil {
+		return helper, "", fmt.Errorf("%s isn't installed", helper.name)
+	}
+	return helper, path, nil
+}
+
+// checkHotkeys makes sure the hotkey helper is there
+func checkHotkeys(cfg HotkeysConfig) doctorCheck {
+	check := doctorCheck{name: "hotkeys"}
+	helper, _, err := findHotkeyHelper(cfg)
+	if err != nil {
+		check.status, check.detail = "warn", err.Error()
+		check.fix = "install sxhkd (X11) or skhd (macOS); on Wayland, bind lofitui ctl commands in your compositor instead"
+		return check
+	}
+	check.status, check.detail = "ok", "the daemon grabs them with "+helper.name
+	return check
+}
+
+// startHotkeys runs the hotkey helper with the configured bindings for as
+// long as the daemon runs. The returned function stops it.
+func startHotkeys(cfg HotkeysConfig) (stop func(), err error) {
+	helper, path, err := findHotkeyHelper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := map[string]string{}
+	for action, keys := range defaultHotkeys {
+		bindings[action] = keys
+	}
+	for action, keys := range cfg.Bindings {
+		if _, ok := hotkeyActions[action]; !ok {
+			return nil, fmt.Errorf("unknown hotkey action %q", action)
+		}
+		bindings[action] = keys
+	}
+	// In a stable order, so the helper's config doesn't churn
+	actions := make([]string, 0, len(bindings))
+	for action := range bindings {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	var config strings.Builder
+	config.WriteString("# Written by lofitui daemon; edit the hotkeys section of its config instead\n")
+	for _, action := range actions {
+		// An empty binding turns the action off
+		if strings.TrimSpace(bindings[action]) == "" {
+			continue
+		}
+		command := fmt.Sprintf("%q ctl %s", executable, strings.Join(hotkeyActions[action], " "))
+		config.WriteString(helper.line(bindings[action], command))
+	}
+	// The helper runs the commands in this file, so it's made fresh,
+	// readable and writable by the user alone, never at a guessable path
+	// someone else could have got to first
+	written, err := os.CreateTemp("", "lofitui-*."+helper.name)
+	if err != nil {
+		return nil, err
+	}
+	file := written.Name()
+	if _, err := written.WriteString(config.String()); err != nil {
+		written.Close()
+		os.Remove(file)
+		return nil, err
+	}
+	if err := written.Close(); err != nil {
+		os.Remove(file)
+		return nil, err
+	}
+
+	cmd := exec.Command(path, "-c", file)
+	if err := cmd.Start(); err != nil {
+		os.Remove(file)
+		return nil, err
+	}
+	slog.Info("global hotkeys on", "helper", helper.name, "config", file)
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("hotkey helper exited", "helper", helper.name, "err", err)
+		}
+	}()
+	return func() {
+		_ = cmd.Process.Kill()
+		os.Remove(file)
+	}, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Song identification endpoints
+const (
+	auddURL     = "https://api.audd.io/"
+	acoustIDURL = "https://api.acoustid.org/v2/lookup"
+)
+
+// identifyTimeout bounds a whole identification, recording included
+const identifyTimeout = time.Minute
+
+// apiTimeout bounds a single request to a web API, in case the caller's
+// context doesn't
+const apiTimeout = 30 * time.Second
+
+// identifyMsg carries the result of identifying the song that's playing
+type identifyMsg struct {
+	song string // "Artist – Title", empty if nothing matched
+	err  error
+}
+
+// identifySong records a few seconds of the stream and asks the configured
+// fingerprinting service what song it is
+func identifySong(streamURL string, cfg IdentifyConfig) tea.Cmd {
+	return func() tea.Msg {
+		if cfg.Token == "" {
+			return identifyMsg{err: fmt.Errorf("set identify.token in the config to use %s", cfg.Service)}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), identifyTimeout)
+		defer cancel()
+
+		sample, err := recordSample(ctx, streamURL, cfg.Seconds)
+		if err != nil {
+			return identifyMsg{err: err}
+		}
+		defer os.Remove(sample)
+
+		var song string
+		if cfg.Service == "acoustid" {
+			song, err = lookupAcoustID(ctx, sample, cfg.Token)
+		} else {
+			song, err = lookupAudD(ctx, sample, cfg.Token)
+		}
+		return identifyMsg{song: song, err: err}
+	}
+}
+
+// recordSample saves the next few seconds of the stream's audio to a
+// temporary file with ffmpeg. The file is created here, under a name
+// nobody can guess, so ffmpeg's -y only ever overwrites it.
+func recordSample(ctx context.Context, streamURL string, seconds int) (string, error) {
+	file, err := os.CreateTemp("", "lofitui-sample-*.mp3")
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	file.Close()
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-loglevel", "error",
+		"-i", streamURL, "-t", strconv.Itoa(seconds), "-vn", "-ac", "1", path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to record audio: %s", strings.TrimSpace(string(output)+" "+err.Error()))
+	}
+	return path, nil
+}
+
+// lookupAudD uploads the sample to AudD
+func lookupAudD(ctx context.Context, sample, token string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("api_token", token)
+	part, err := form.CreateFormFile("file", filepath.Base(sample))
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(sample)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(part, file); err != nil {
+		return "", err
+	}
+	form.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", auddURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var result struct {
+		Status string `json:"status"`
+		Error  *struct {
+			Message string `json:"error_message"`
+		} `json:"error"`
+		Result *struct {
+			Artist string `json:"artist"`
+			Title  string `json:"title"`
+		} `json:"result"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return "", err
+	}
+	if result.Status != "success" {
+		if result.Error != nil {
+			return "", fmt.Errorf("audd: %s", result.Error.Message)
+		}
+		return "", fmt.Errorf("audd: %s", result.Status)
+	}
+	if result.Result == nil {
+		return "", nil
+	}
+	return result.Result.Artist + " – " + result.Result.Title, nil
+}
+
+// lookupAcoustID fingerprints the sample with chromaprint's fpcalc and
+// looks the fingerprint up on AcoustID
+func lookupAcoustID(ctx context.Context, sample, key string) (string, error) {
+	output, err := exec.CommandContext(ctx, "fpcalc", "-json", sample).Output()
+	if err != nil {
+		return "", fmt.Errorf("fpcalc failed (is chromaprint installed?): %w", err)
+	}
+	var fp struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint string  `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(output, &fp); err != nil {
+		return "", fmt.Errorf("failed to parse fpcalc output: %w", err)
+	}
+
+	query := url.Values{
+		"client":      {key},
+		"meta":        {"recordings"},
+		"duration":    {strconv.Itoa(int(fp.Duration))},
+		"fingerprint": {fp.Fingerprint},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", acoustIDURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Status string `json:"status"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Results []struct {
+			Recordings []struct {
+				Title   string `json:"title"`
+				Artists []struct {
+					Name string `json:"name"`
+				} `json:"artists"`
+			} `json:"recordings"`
+		} `json:"results"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return "", err
+	}
+	if result.Status != "ok" {
+		if result.Error != nil {
+			return "", fmt.Errorf("acoustid: %s", result.Error.Message)
+		}
+		return "", fmt.Errorf("acoustid: %s", result.Status)
+	}
+	for _, match := range result.Results {
+		for _, recording := range match.Recordings {
+			if recording.Title == "" {
+				continue
+			}
+			var artists []string
+			for _, artist := range recording.Artists {
+				artists = append(artists, artist.Name)
+			}
+			return strings.Join(artists, ", ") + " – " + recording.Title, nil
+		}
+	}
+	return "", nil
+}
+
+// doJSON sends req and decodes the JSON reply into v. A reply other than
+// 2xx is an error, with the service's own message if it gave one.
+func doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("User-Agent", "lofitui/"+version+" (https://github.com/willyv3/lofitui)")
+	client := http.Client{Timeout: apiTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var reply struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&reply)
+		if reply.Error == "" {
+			reply.Error = reply.Message
+		}
+		if reply.Error != "" {
+			return fmt.Errorf("%s replied %s: %s", req.URL.Host, resp.Status, reply.Error)
+		}
+		return fmt.Errorf("%s replied %s", req.URL.Host, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s reply: %w", req.URL.Host, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDoJSONRejectsErrorReplies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"code": 401, "error": "Invalid authorization token."}`))
+		case "/html":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html>Bad Gateway</html>"))
+		default:
+			_, _ = w.Write([]byte(`{"status": "ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	for path, want := range map[string]string{
+		"/json": "401 Unauthorized: Invalid authorization token.",
+		"/html": "502 Bad Gateway",
+	} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		var v struct{}
+		err := doJSON(req, &v)
+		if err == nil || !strings.HasSuffix(err.Error(), want) {
+			t.Errorf("%s: err = %v, want one ending %q", path, err, want)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/ok", nil)
+	var reply struct {
+		Status string `json:"status"`
+	}
+	if err := doJSON(req, &reply); err != nil || reply.Status != "ok" {
+		t.Errorf("ok reply: %+v, %v", reply, err)
+	}
+}
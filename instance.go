@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// instanceSocketPath returns where a single-instance TUI listens for
+// streams handed to it
+func instanceSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "lofitui-tui.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("lofitui-%d.tui.sock", os.Getuid()))
+}
+
+// handoffTarget is a stream another lofitui asked the running one to play
+type handoffTarget struct {
+	url, title string
+	index      int // Config index of the preset, -1 for URLs
+}
+
+// handoffMsg tells the TUI a stream was handed to it
+type handoffMsg struct{}
+
+// handoffQueue holds the stream handed over until the TUI gets to it,
+// which is once mpv has quit if something was playing
+type handoffQueue struct {
+	mu     sync.Mutex
+	target *handoffTarget
+}
+
+// put replaces any stream waiting with target
+func (q *handoffQueue) put(target handoffTarget) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.target = &target
+}
+
+// take returns and clears the stream waiting, if any
+func (q *handoffQueue) take() (handoffTarget, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.target == nil {
+		return handoffTarget{}, false
+	}
+	target := *q.target
+	q.target = nil
+	return target, true
+}
+
+// resolveHandoff finds what to play for a preset name or URL. A URL of a
+// preset plays as that preset.
+func resolveHandoff(config *Config, target string) (handoffTarget, error) {
+	if strings.Contains(target, "://") {
+		for i, preset := range config.Presets {
+			if preset.URL == target {
+				return handoffTarget{url: preset.URL, title: preset.Name, index: i}, nil
+			}
+		}
+		return handoffTarget{url: target, title: "Custom Stream", index: -1}, nil
+	}
+	index, err := findPreset(config.Presets, target)
+	if err != nil {
+		return handoffTarget{}, err
+	}
+	return handoffTarget{url: config.Presets[index].URL, title: config.Presets[index].Name, index: index}, nil
+}
+
+// errInstanceRunning means another TUI already has the instance socket
+var errInstanceRunning = errors.New("lofitui is already running in another terminal")
+
+// claimInstance makes this TUI the single instance, listening for
+// streams other lofituis hand it. Nothing is served until serveHandoffs.
+func claimInstance() (net.Listener, error) {
+	socket := instanceSocketPath()
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		conn.Close()
+		return nil, errInstanceRunning
+	}
+	// Nobody answered, so any socket file left is stale
+	_ = os.Remove(socket)
+	return net.Listen("unix", socket)
+}
+
+// serveHandoffs plays the streams handed over until the listener is closed
+func serveHandoffs(listener net.Listener, queue *handoffQueue, stream *mprisStream, send func(tea.Msg)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go serveHandoff(conn, queue, stream, send)
+	}
+}
+
+// serveHandoff takes one "play" request, in the daemon's ctl format
+func serveHandoff(conn net.Conn, queue *handoffQueue, stream *mprisStream, send func(tea.Msg)) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	encoder := json.NewEncoder(conn)
+
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return
+	}
+	var req daemonRequest
+	if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+		_ = encoder.Encode(daemonResponse{Message: "bad request: " + err.Error()})
+		return
+	}
+	if req.Command != "play" || len(req.Args) == 0 {
+		_ = encoder.Encode(daemonResponse{Message: fmt.Sprintf("unknown command %q", req.Command)})
+		return
+	}
+	config, err := loadConfig()
+	if err != nil {
+		_ = encoder.Encode(daemonResponse{Message: err.Error()})
+		return
+	}
+	target, err := resolveHandoff(config, strings.Join(req.Args, " "))
+	if err != nil {
+		_ = encoder.Encode(daemonResponse{Message: err.Error()})
+		return
+	}
+	slog.Info("stream handed over", "title", target.title, "url", target.url)
+
+	queue.put(target)
+	// With mpv in the terminal the TUI is suspended until it quits
+	if title, _ := stream.current(); title != "" {
+		_ = quitMPV()
+	}
+	go send(handoffMsg{})
+	_ = encoder.Encode(daemonResponse{OK: true, Message: "loading " + target.title})
+}
+
+// handOff asks a running single-instance TUI to play target. ok is false
+// when there's none to ask.
+func handOff(target string) (message string, ok bool, err error) {
+	conn, err := net.DialTimeout("unix", instanceSocketPath(), time.Second)
+	if err != nil {
+		return "", false, nil
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Command: "play", Args: []string{target}}); err != nil {
+		return "", true, err
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return "", true, fmt.Errorf("no reply from the running lofitui: %w", err)
+	}
+	if !resp.OK {
+		return "", true, errors.New(resp.Message)
+	}
+	return resp.Message, true, nil
+}
+
+// handOffOrRefuse is what a second TUI does in single-instance mode: hand
+// its stream to the one running, or say why it won't start
+func handOffOrRefuse(target string) int {
+	if target == "" {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", errInstanceRunning)
+		return exitError
+	}
+	message, _, err := handOff(target)
+	if err != nil {
+		return fail(exitError, err)
+	}
+	if !quiet {
+		fmt.Println("Handed over to the running lofitui: " + message)
+	}
+	return exitOK
+}
+
+// playHandedOff starts the stream handed over, from the main menu
+func (m *model) playHandedOff(target handoffTarget) tea.Cmd {
+	m.cancelLoading()
+	m.zen = false
+	m.navStack, m.state = nil, mainMenuView
+	m.pushView(loadingView)
+	return m.startLoading(target.url, target.title, target.index)
+}
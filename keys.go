@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// keyAction is an action whose key can be rebound in the keybinding editor
+type keyAction struct {
+	id     string   // Name used in the "keybindings" config section
+	label  string   // Shown in the editor
+	def    string   // Default key
+	scopes []string // Views the key is active in; keys must be unique per scope
+}
+
+// keyActions lists every rebindable action, in the order the editor shows them
+var keyActions = []keyAction{
+	{"play", "Play selected stream", "enter", []string{"main", "manage"}},
+	{"manage", "Manage presets", "m", []string{"main"}},
+	{"custom_url", "Play a custom URL", "c", []string{"main"}},
+	{"cast", "Cast to a speaker or TV", "C", []string{"main"}},
+	{"sort", "Cycle sort order", "o", []string{"main"}},
+	{"pomodoro", "Start/stop Pomodoro", "p", []string{"main"}},
+	{"sleep_timer", "Sleep timer", "s", []string{"main"}},
+	{"alarm", "Set alarm", "a", []string{"main"}},
+	{"recordings", "Scheduled recordings", "R", []string{"main"}},
+	{"downloads", "Downloads and recordings", "d", []string{"main"}},
+	{"ambient", "Ambient sounds", "n", []string{"main"}},
+	{"audio_only", "Toggle audio only", "v", []string{"main"}},
+	{"quality", "Choose the quality", "Q", []string{"main"}},
+	{"keybindings", "Edit keybindings", "K", []string{"main"}},
+	{"dismiss", "Dismiss the update notice", "x", []string{"main"}},
+	{"quit", "Quit", "q", []string{"main"}},
+	{"add_preset", "Add preset", "a", []string{"manage"}},
+	{"edit_preset", "Edit preset", "e", []string{"manage"}},
+	{"delete_preset", "Delete preset", "d", []string{"manage"}},
+	{"tag_presets", "Tag presets", "t", []string{"manage"}},
+	{"export_presets", "Export presets", "x", []string{"manage"}},
+	{"restore_defaults", "Restore default presets", "r", []string{"manage"}},
+	{"download", "Save for offline", "o", []string{"manage"}},
+	{"stop", "Stop playback", "q", []string{"playing"}},
+	{"zen", "Toggle zen mode", "z", []string{"playing"}},
+	{"track_info", "Toggle track info", "i", []string{"playing"}},
+	{"identify", "Identify the song", "w", []string{"playing"}},
+	{"volume_up", "Volume up", "+", []string{"playing"}},
+	{"volume_down", "Volume down", "-", []string{"playing"}},
+	{"mute", "Mute", "m", []string{"playing"}},
+	{"pause", "Pause/resume", "p", []string{"playing"}},
+	{"audio_output", "Audio output", "o", []string{"playing"}},
+	{"record", "Start/stop recording", "r", []string{"playing"}},
+	{"clip", "Save the last few minutes", "c", []string{"playing"}},
+	{"mixer", "Mix in an ambient sound", "x", []string{"playing"}},
+	{"browse", "Browse while playing", "b", []string{"main", "playing"}},
+	{"slower", "Slow down (VODs)", "[", []string{"playing"}},
+	{"faster", "Speed up (VODs)", "]", []string{"playing"}},
+}
+
+// isKeyAction reports whether id names a rebindable action
+func isKeyAction(id string) bool {
+	for _, a := range keyActions {
+		if a.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedKeys are handled by the list, filter, or navigation and can't be bound
+var reservedKeys = map[string]bool{
+	"esc": true, "ctrl+c": true, "/": true, "?": true, " ": true,
+	"up": true, "down": true, "left": true, "right": true,
+	"j": true, "k": true, "h": true, "l": true, "g": true, "G": true,
+	"pgup": true, "pgdown": true, "home": true, "end": true,
+}
+
+// key returns the key bound to an action
+func (c *Config) key(action string) string {
+	if key, ok := c.Keybindings[action]; ok && key != "" {
+		return key
+	}
+	for _, a := range keyActions {
+		if a.id == action {
+			return a.def
+		}
+	}
+	return ""
+}
+
+// bindKey assigns a key to an action, refusing reserved keys and keys
+// already used by another action in the same view
+func (c *Config) bindKey(action keyAction, key string) error {
+	if reservedKeys[key] {
+		return fmt.Errorf("%s is reserved for navigation", key)
+	}
+	for _, other := range keyActions {
+		if other.id == action.id || c.key(other.id) != key || !sharesScope(action, other) {
+			continue
+		}
+		return fmt.Errorf("%s is already used by %q", key, other.label)
+	}
+
+	if c.Keybindings == nil {
+		c.Keybindings = map[string]string{}
+	}
+	if key == action.def {
+		delete(c.Keybindings, action.id)
+	} else {
+		c.Keybindings[action.id] = key
+	}
+	return nil
+}
+
+// sharesScope reports whether two actions are ever active in the same view
+func sharesScope(a, b keyAction) bool {
+	for _, sa := range a.scopes {
+		for _, sb := range b.scopes {
+			if sa == sb {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mainMenuHelp builds the main menu help line from the current keybindings
+func (m model) mainMenuHelp(sortLabel string) string {
+	k := m.config.key
+	help := []string{"/=search"}
+	// The stream left playing is a key away
+	if m.detached {
+		help = append(help, k("browse")+"=now playing")
+	}
+	return strings.Join(append(help,
+		k("manage")+"=manage presets",
+		k("custom_url")+"=custom URL",
+		k("cast")+"=cast",
+		k("sort")+"=sort ("+sortLabel+")",
+		k("pomodoro")+"=pomodoro",
+		k("sleep_timer")+"=sleep timer",
+		k("alarm")+"=alarm",
+		k("recordings")+"=recordings",
+		k("downloads")+"=downloads",
+		k("ambient")+"=ambient",
+		k("audio_only")+"=audio only",
+		k("quality")+"=quality",
+		k("keybindings")+"=keys",
+		k("quit")+"=quit",
+	), " • ")
+}
+
+// managePresetsHelp builds the manage view help line from the current keybindings
+func (m model) managePresetsHelp() string {
+	k := m.config.key
+	return strings.Join([]string{
+		"/=search",
+		"space=mark",
+		k("add_preset") + "=add",
+		k("edit_preset") + "=edit",
+		k("delete_preset") + "=delete",
+		k("tag_presets") + "=tag",
+		k("export_presets") + "=export",
+		k("download") + "=save offline",
+		k("restore_defaults") + "=restore defaults",
+		k("play") + "=play",
+		"ESC=back",
+	}, " • ")
+}
+
+// nowPlayingHelp builds the Now Playing help line from the current keybindings
+func (m model) nowPlayingHelp() string {
+	k := m.config.key
+	help := []string{
+		k("stop") + "/ESC=stop",
+		"space/" + k("pause") + "=pause",
+	}
+	// Live streams can't be seeked or sped up
+	if m.playback.seekable {
+		help = append(help, "←→=seek")
+	}
+	if m.playback.duration > 0 {
+		help = append(help, k("slower")+"/"+k("faster")+"=speed")
+	}
+	return strings.Join(append(help,
+		k("volume_up")+"/"+k("volume_down")+"/↑↓=volume",
+		k("mute")+"=mute",
+		k("zen")+"=zen",
+		k("track_info")+"=track info",
+		k("identify")+"=identify song",
+		k("audio_output")+"=output",
+		k("record")+"=record",
+		k("clip")+"=clip that",
+		k("mixer")+"=mixer",
+		k("browse")+"=browse",
+	), " • ")
+}
+
+// castingHelp builds the casting view's help line from the current keybindings
+func (m model) castingHelp() string {
+	k := m.config.key
+	return strings.Join([]string{
+		k("stop") + "/ESC=stop",
+		"space/" + k("pause") + "=pause",
+		k("volume_up") + "/" + k("volume_down") + "/↑↓=volume",
+		k("mute") + "=mute",
+	}, " • ")
+}
+
+// keybindingsContent renders the keybinding editor's action table
+func (m model) keybindingsContent() string {
+	var rows []string
+	for i, action := range keyActions {
+		key := m.config.key(action.id)
+		if i == m.keyCursor && m.capturingKey {
+			key = "press a key…"
+		}
+		row := fmt.Sprintf("%-26s %s", action.label, key)
+		if i == m.keyCursor {
+			row = lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• " + row)
+		} else {
+			row = "  " + row
+		}
+		rows = append(rows, row)
+	}
+
+	help := "Enter to rebind • Backspace to reset • ESC to go back"
+	if m.capturingKey {
+		help = "Press the new key • ESC to cancel"
+	}
+
+	content := "Keybindings\n\n" + strings.Join(rows, "\n")
+	if m.keyError != "" {
+		content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Danger).Render(m.keyError)
+	}
+	return content + "\n\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render(help)
+}
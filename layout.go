@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Below these sizes the TUI falls back to a bare, borderless layout
+const (
+	minLayoutWidth  = 30
+	minLayoutHeight = 8
+)
+
+// compactHeight is the terminal height below which dialogs drop their
+// padding and blank lines to fit
+const compactHeight = 18
+
+// tooSmall reports whether the terminal is below the minimal layout size
+func (m model) tooSmall() bool {
+	return m.width < minLayoutWidth || m.height < minLayoutHeight
+}
+
+// clampDialogWidth picks a dialog width from the terminal width minus a
+// margin, kept within [minWidth, maxWidth] but never wider than the terminal
+func clampDialogWidth(termWidth, margin, minWidth, maxWidth int) int {
+	width := termWidth - margin
+	if width < minWidth {
+		width = minWidth
+	}
+	if width > maxWidth {
+		width = maxWidth
+	}
+	// Leave room for the border
+	if width > termWidth-2 {
+		width = termWidth - 2
+	}
+	return width
+}
+
+// fitInputWidth shrinks a text input's preferred width to fit a dialog,
+// leaving room for the padding, prompt and cursor
+func fitInputWidth(preferred, dialogWidth int) int {
+	width := dialogWidth - 7
+	if preferred < width {
+		width = preferred
+	}
+	if width < 5 {
+		width = 5
+	}
+	return width
+}
+
+// renderDialog draws content in a bordered box centered on screen. Short
+// terminals get a compact box, tiny ones just the bare content.
+func (m model) renderDialog(color lipgloss.Color, width int, content string) string {
+	if m.tooSmall() {
+		return m.fitToTerminal(strings.ReplaceAll(content, "\n\n", "\n"))
+	}
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		m.dialogBox(color, width, content),
+	)
+}
+
+// dialogBox draws content in a bordered box, compact in short terminals
+func (m model) dialogBox(color lipgloss.Color, width int, content string) string {
+	style := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(color).
+		Padding(1, 2).
+		Width(width)
+
+	if m.height < compactHeight {
+		style = style.Padding(0, 1)
+		content = strings.ReplaceAll(content, "\n\n", "\n")
+	}
+
+	return style.Render(content)
+}
+
+// renderHelp renders a dimmed help line, wrapped to the terminal width
+func renderHelp(text string, width int) string {
+	return lipgloss.NewStyle().
+		Foreground(theme.Muted).
+		Padding(1, 0, 0, 2).
+		Width(width).
+		Render(text)
+}
+
+// listHeight returns the list height that leaves room for the help lines
+func (m model) listHeight() int {
+	// Measure with the longest sort label so the list doesn't jump around
+	helpHeight := lipgloss.Height(renderHelp(m.mainMenuHelp(sortLabels["recent"]), m.width))
+	if h := lipgloss.Height(renderHelp(m.managePresetsHelp(), m.width)); h > helpHeight {
+		helpHeight = h
+	}
+	// One more line for the stream preview, and one for the suggestions
+	listHeight := m.height - helpHeight - 3
+	if len(m.suggestions) > 0 {
+		listHeight--
+	}
+	if listHeight < 3 {
+		listHeight = 3
+	}
+	return listHeight
+}
+
+// minimalListView shows just the selected preset for tiny terminals
+func (m model) minimalListView(title, help string) string {
+	line := "No presets"
+	if preset, ok := m.list.SelectedItem().(presetItem); ok {
+		line = fmt.Sprintf("▶ %d/%d %s", m.list.Index()+1, len(m.list.Items()), preset.Name)
+	}
+	return m.fitToTerminal(strings.Join([]string{title, line, help}, "\n"))
+}
+
+// fitToTerminal truncates content so it never spills past the terminal
+func (m model) fitToTerminal(content string) string {
+	return lipgloss.NewStyle().
+		MaxWidth(m.width).
+		MaxHeight(m.height).
+		Render(content)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultListenBrainzURL is the public ListenBrainz API; self-hosted
+// servers can be set in the config
+const defaultListenBrainzURL = "https://api.listenbrainz.org"
+
+// listenBrainzTimeout bounds a single submission
+const listenBrainzTimeout = 15 * time.Second
+
+// How long a track must play to count as a listen. Live streams don't say
+// how long tracks are, so one that played until the next started counts
+// after listenMinimum, and one cut off by stopping after listenStopped.
+const (
+	listenMinimum = 30 * time.Second
+	listenStopped = 4 * time.Minute
+)
+
+// listenBrainzTrack is the track being listened to now
+var listenBrainzTrack struct {
+	sync.Mutex
+	artist, track, url string
+	since              time.Time
+}
+
+// lbListen is one listen in a ListenBrainz submission
+type lbListen struct {
+	ListenedAt    int64 `json:"listened_at,omitempty"`
+	TrackMetadata struct {
+		ArtistName     string            `json:"artist_name"`
+		TrackName      string            `json:"track_name"`
+		AdditionalInfo map[string]string `json:"additional_info"`
+	} `json:"track_metadata"`
+}
+
+// splitTrack splits a stream's "Artist - Title" track into its parts; ok
+// is false for tracks that don't name an artist, which can't be submitted
+func splitTrack(track string) (artist, title string, ok bool) {
+	artist, title, ok = strings.Cut(track, " - ")
+	artist, title = strings.TrimSpace(artist), strings.TrimSpace(title)
+	return artist, title, ok && artist != "" && title != ""
+}
+
+// scrobbleEvent submits listens to ListenBrainz as tracks change: "playing
+// now" when one starts, and a listen when it has played long enough
+func scrobbleEvent(cfg ListenBrainzConfig, event string, status daemonStatus) {
+	if cfg.Token == "" {
+		return
+	}
+	switch event {
+	case "now_playing", "track", "stopped", "stream_ended":
+	default:
+		return
+	}
+
+	t := &listenBrainzTrack
+	t.Lock()
+	defer t.Unlock()
+
+	// The track playing until now is done with
+	if t.track != "" {
+		played := time.Since(t.since)
+		if played >= listenStopped || (event == "track" && played >= listenMinimum) {
+			listen := newListen(t.artist, t.track, t.url)
+			listen.ListenedAt = t.since.Unix()
+			go submitListens(cfg, "single", listen)
+		}
+		t.track = ""
+	}
+
+	if event == "stopped" || event == "stream_ended" {
+		return
+	}
+	artist, track, ok := splitTrack(status.Track)
+	if !ok {
+		return
+	}
+	t.artist, t.track, t.url, t.since = artist, track, status.URL, time.Now()
+	go submitListens(cfg, "playing_now", newListen(artist, track, status.URL))
+}
+
+// newListen describes a track, crediting the stream it came from
+func newListen(artist, track, url string) lbListen {
+	var listen lbListen
+	listen.TrackMetadata.ArtistName = artist
+	listen.TrackMetadata.TrackName = track
+	listen.TrackMetadata.AdditionalInfo = map[string]string{
+		"media_player":              "mpv",
+		"submission_client":         "lofitui",
+		"submission_client_version": version,
+	}
+	if url != "" {
+		listen.TrackMetadata.AdditionalInfo["origin_url"] = url
+	}
+	return listen
+}
+
+// submitListens posts listens of a type ("single" or "playing_now")
+func submitListens(cfg ListenBrainzConfig, listenType string, listens ...lbListen) {
+	if err := postListenBrainz(cfg, listenType, listens); err != nil {
+		slog.Warn("ListenBrainz submission failed", "type", listenType, "err", err)
+		return
+	}
+	slog.Debug("submitted to ListenBrainz", "type", listenType, "track", listens[0].TrackMetadata.TrackName)
+}
+
+// postListenBrainz sends a submission and checks it was accepted
+func postListenBrainz(cfg ListenBrainzConfig, listenType string, listens []lbListen) error {
+	data, err := json.Marshal(map[string]interface{}{"listen_type": listenType, "payload": listens})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), listenBrainzTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(cfg.URL, "/")+"/1/submit-listens", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return err
+	}
+	if result.Status != "ok" {
+		return fmt.Errorf("listenbrainz: %s", result.Error)
+	}
+	return nil
+}
+
+// validateListenBrainzToken asks ListenBrainz whose token it is
+func validateListenBrainzToken(cfg ListenBrainzConfig) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), listenBrainzTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(cfg.URL, "/")+"/1/validate-token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Token "+cfg.Token)
+
+	var result struct {
+		Valid    bool   `json:"valid"`
+		UserName string `json:"user_name"`
+		Message  string `json:"message"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return "", err
+	}
+	if !result.Valid {
+		return "", fmt.Errorf("listenbrainz: %s", result.Message)
+	}
+	return result.UserName, nil
+}
+
+// checkListenBrainz makes sure the ListenBrainz token works
+func checkListenBrainz(cfg ListenBrainzConfig) doctorCheck {
+	check := doctorCheck{name: "listenbrainz"}
+	user, err := validateListenBrainzToken(cfg)
+	if err != nil {
+		check.status, check.detail = "warn", err.Error()
+		check.fix = "copy your user token from https://listenbrainz.org/settings/ into listenbrainz.token"
+		return check
+	}
+	check.status, check.detail = "ok", "submitting listens as "+user
+	return check
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+)
+
+// loadingMessageInterval is how long each loading message stays up
+const loadingMessageInterval = 3 * time.Second
+
+// spinners are the spinner styles selectable with "loading.spinner"
+var spinners = map[string]spinner.Spinner{
+	"dot":       spinner.Dot,
+	"line":      spinner.Line,
+	"minidot":   spinner.MiniDot,
+	"jump":      spinner.Jump,
+	"pulse":     spinner.Pulse,
+	"points":    spinner.Points,
+	"globe":     spinner.Globe,
+	"moon":      spinner.Moon,
+	"monkey":    spinner.Monkey,
+	"meter":     spinner.Meter,
+	"hamburger": spinner.Hamburger,
+	"ellipsis":  spinner.Ellipsis,
+}
+
+// flavorPacks are the built-in sets of rotating loading messages
+var flavorPacks = map[string][]string{
+	"classic": {
+		"Please wait while we fetch the stream",
+	},
+	"lofi": {
+		"brewing beats…",
+		"tuning the radio…",
+		"dusting off the vinyl…",
+		"finding a comfy chair…",
+		"warming up the tape deck…",
+	},
+	"cafe": {
+		"grinding the beans…",
+		"steaming the milk…",
+		"finding a window seat…",
+		"the barista is choosing a record…",
+	},
+	"rainy": {
+		"listening to the rain…",
+		"putting the kettle on…",
+		"wrapping up in a blanket…",
+		"watching droplets race down the glass…",
+	},
+	"space": {
+		"aligning the satellites…",
+		"boosting the signal…",
+		"drifting past Saturn…",
+		"receiving transmission…",
+	},
+}
+
+// loadingSpinner returns the configured spinner, falling back to dots
+func loadingSpinner(name string) spinner.Spinner {
+	if s, ok := spinners[name]; ok {
+		return s
+	}
+	return spinner.Dot
+}
+
+// loadingMessages returns the custom messages if any, else the chosen pack
+func loadingMessages(cfg LoadingConfig) []string {
+	if len(cfg.Messages) > 0 {
+		return cfg.Messages
+	}
+	if pack, ok := flavorPacks[cfg.Pack]; ok {
+		return pack
+	}
+	return flavorPacks["lofi"]
+}
+
+// loadingMessage picks the message to show after loading for elapsed
+func loadingMessage(cfg LoadingConfig, elapsed time.Duration) string {
+	messages := loadingMessages(cfg)
+	return messages[int(elapsed/loadingMessageInterval)%len(messages)]
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxLogSize is how big the log grows before it's moved to lofitui.log.1
+const maxLogSize = 1 << 20
+
+// maxLogFiles is how many rotated logs are kept, lofitui.log.1 being the
+// newest
+const maxLogFiles = 3
+
+// logLevelVar is the log's level: -log-level if given, else the config's
+var logLevelVar slog.LevelVar
+
+var setupLoggingOnce sync.Once
+
+// getLogPath returns where the log is written
+func getLogPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "lofitui.log"), nil
+}
+
+// parseLogLevel parses "debug", "info", "warn" or "error"
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("unknown log level %q; try debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// setupLogging sends slog's output to the log file, so problems in the TUI
+// can be looked into afterwards. Logging is best effort: if the file can't
+// be opened, logs are dropped.
+func setupLogging() {
+	setupLoggingOnce.Do(func() {
+		var w io.Writer = io.Discard
+		if path, err := getLogPath(); err == nil {
+			_ = os.MkdirAll(filepath.Dir(path), 0755)
+			if file, err := openRotatingLog(path); err == nil {
+				w = file
+			}
+		}
+		handler := slog.NewTextHandler(w, &slog.HandlerOptions{Level: &logLevelVar})
+		slog.SetDefault(slog.New(handler).With("pid", os.Getpid()))
+	})
+}
+
+// rotatingLog is the log file, moved aside to lofitui.log.1 once it
+// passes maxLogSize, so a daemon that runs for weeks doesn't fill the disk.
+// Other processes may share the file, so its size is checked each time.
+type rotatingLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// openRotatingLog opens the log for appending, rotating it first if it's
+// already too big
+func openRotatingLog(path string) (*rotatingLog, error) {
+	l := &rotatingLog{path: path}
+	if info, err := os.Stat(path); err == nil && info.Size() > maxLogSize {
+		rotateLogs(path)
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	l.file = file
+	return nil
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if info, err := l.file.Stat(); err == nil && info.Size()+int64(len(p)) > maxLogSize {
+		l.file.Close()
+		// Another process may have rotated it already
+		if info, err := os.Stat(l.path); err == nil && info.Size() > maxLogSize/2 {
+			rotateLogs(l.path)
+		}
+		if err := l.open(); err != nil {
+			return 0, err
+		}
+	}
+	return l.file.Write(p)
+}
+
+// rotateLogs shifts lofitui.log to lofitui.log.1, .1 to .2 and so on,
+// dropping the oldest
+func rotateLogs(path string) {
+	for i := maxLogFiles - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	_ = os.Rename(path, path+".1")
+}
+
+// applyConfigLogLevel uses the config's log level unless -log-level was given
+func applyConfigLogLevel(name string) {
+	if logLevel != "" {
+		return
+	}
+	if level, err := parseLogLevel(name); err == nil {
+		logLevelVar.Set(level)
+	}
+}
+
+// lastLines returns the last n non-empty lines of s joined by " | ", to
+// keep a command's output to one log line
+func lastLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
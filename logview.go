@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// logViewLines is how much of the end of the log the log viewer reads
+const logViewLines = 500
+
+// logRefresh is how often the log viewer reads the log again
+const logRefresh = 2 * time.Second
+
+// logLine is a log record split into what the viewer shows
+type logLine struct {
+	at    string // Time of day
+	level string // "DEBUG", "INFO", "WARN" or "ERROR"
+	rest  string // The message and attributes
+}
+
+// parseLogLine splits a line of slog's text output, e.g.
+// time=2024-05-01T21:30:00.000+02:00 level=INFO msg="extracting stream" url=...
+func parseLogLine(line string) logLine {
+	var parsed logLine
+	if rest, ok := strings.CutPrefix(line, "time="); ok {
+		var value string
+		value, line, _ = strings.Cut(rest, " ")
+		if at, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			parsed.at = at.Local().Format("Jan 2 15:04:05")
+		}
+	}
+	if rest, ok := strings.CutPrefix(line, "level="); ok {
+		parsed.level, line, _ = strings.Cut(rest, " ")
+	}
+	parsed.rest = strings.TrimPrefix(line, "msg=")
+	return parsed
+}
+
+// readLogTail returns the last lines of the log, oldest first
+func readLogTail() ([]logLine, error) {
+	path, err := getLogPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// Only the end is wanted, and the log is at most maxLogSize
+	if info, err := file.Stat(); err == nil && info.Size() > 256<<10 {
+		_, _ = file.Seek(-256<<10, io.SeekEnd)
+	}
+
+	var lines []logLine
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "time=") {
+			lines = append(lines, parseLogLine(line))
+		}
+	}
+	if len(lines) > logViewLines {
+		lines = lines[len(lines)-logViewLines:]
+	}
+	return lines, scanner.Err()
+}
+
+// logTickMsg reads the log again for the viewer opened at the given time
+type logTickMsg struct {
+	opened time.Time
+}
+
+// logTick schedules the next read of the log
+func logTick(opened time.Time) tea.Cmd {
+	return tea.Tick(logRefresh, func(time.Time) tea.Msg {
+		return logTickMsg{opened: opened}
+	})
+}
+
+// openLogView shows the log viewer on top of whatever is showing
+func (m *model) openLogView() tea.Cmd {
+	m.pushView(logView)
+	m.logScroll = 0
+	m.logOpened = time.Now()
+	m.refreshLog()
+	return logTick(m.logOpened)
+}
+
+// refreshLog reads the log again
+func (m *model) refreshLog() {
+	lines, err := readLogTail()
+	m.logLines, m.logError = lines, ""
+	if err != nil {
+		m.logError = err.Error()
+	}
+}
+
+// shownLogLines returns the lines the log viewer shows, all of them or
+// only the problems
+func (m model) shownLogLines() []logLine {
+	if !m.logProblemsOnly {
+		return m.logLines
+	}
+	var lines []logLine
+	for _, line := range m.logLines {
+		if line.level == "WARN" || line.level == "ERROR" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// logViewHeight is how many log lines fit on screen
+func (m model) logViewHeight() int {
+	return max(1, m.height-4)
+}
+
+// logContent renders the log viewer, scrolled logScroll lines up from the
+// newest
+func (m model) logContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	levels := map[string]lipgloss.Style{
+		"DEBUG": muted,
+		"INFO":  lipgloss.NewStyle().Foreground(theme.Info),
+		"WARN":  lipgloss.NewStyle().Foreground(theme.Warning),
+		"ERROR": lipgloss.NewStyle().Foreground(theme.Danger),
+	}
+
+	title := "Log"
+	if m.logProblemsOnly {
+		title += " (warnings and errors)"
+	}
+	lines := m.shownLogLines()
+	height := m.logViewHeight()
+	end := max(0, len(lines)-m.logScroll)
+	start := max(0, end-height)
+
+	var rows []string
+	switch {
+	case m.logError != "":
+		rows = append(rows, lipgloss.NewStyle().Foreground(theme.Danger).Render(m.logError))
+	case len(lines) == 0:
+		rows = append(rows, muted.Render("Nothing logged yet"))
+	}
+	for _, line := range lines[start:end] {
+		style, ok := levels[line.level]
+		if !ok {
+			style = muted
+		}
+		row := muted.Render(line.at) + " " + style.Render(padRight(line.level, 5)) + " " + line.rest
+		rows = append(rows, ansi.Truncate(row, m.width-2, "…"))
+	}
+
+	filter := "e problems only"
+	if m.logProblemsOnly {
+		filter = "e everything"
+	}
+	path, _ := getLogPath()
+	help := "↑/↓ scroll • " + filter + " • ESC to go back • " + path
+	return lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render(title) + "\n" +
+		strings.Join(rows, "\n") + "\n" + ansi.Truncate(muted.Render(help), m.width, "…")
+}
+
+// padRight pads s with spaces to width runes
+func padRight(s string, width int) string {
+	if n := len([]rune(s)); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// mediaControlOptions are the mpv options that put it in macOS's Now
+// Playing widget and hand it the media keys, newest mpv first
+var mediaControlOptions = []string{"--media-controls=yes", "--input-media-keys=yes"}
+
+var (
+	mediaControlsOnce sync.Once
+	mediaControlArgs  []string
+)
+
+// nowPlayingArgs has mpv on macOS show the stream in Control Center and
+// answer the media keys, with whichever option the installed mpv has.
+// Passing mpv an option it doesn't know stops it from starting, so it's
+// asked first.
+func nowPlayingArgs() []string {
+	if runtime.GOOS != "darwin" {
+		return nil
+	}
+	mediaControlsOnce.Do(func() {
+		if option, ok := mediaControlOption(); ok {
+			mediaControlArgs = []string{option}
+		}
+	})
+	return mediaControlArgs
+}
+
+// mediaControlOption returns the media control option the installed mpv
+// supports
+func mediaControlOption() (string, bool) {
+	output, err := exec.Command("mpv", "--list-options").Output()
+	if err != nil {
+		return "", false
+	}
+	for _, option := range mediaControlOptions {
+		name, _, _ := strings.Cut(option, "=")
+		if strings.Contains(string(output), name+" ") {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+// checkNowPlaying makes sure mpv can show up in macOS's Now Playing widget
+func checkNowPlaying() doctorCheck {
+	check := doctorCheck{name: "now playing"}
+	option, ok := mediaControlOption()
+	if !ok {
+		check.status, check.detail = "warn", "this mpv can't show up in Control Center or take the media keys"
+		check.fix = "update mpv: brew upgrade mpv"
+		return check
+	}
+	name, _, _ := strings.Cut(option, "=")
+	check.status, check.detail = "ok", "Control Center and the media keys work through mpv's "+name
+	return check
+}
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand"
+	"net"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/willyv3/lofitui/pkg/lofitui"
 )
 
 // Version info - set by goreleaser
@@ -22,10 +33,15 @@ var (
 	date    = "unknown"
 )
 
+// audioOnly is set by -audio-only to keep mpv from rendering video, e.g.
+// over SSH where the terminal video output is wasteful. The TUI starts
+// with it, or the config's audio_only, and can toggle it.
+var audioOnly bool
+
 var (
 	titleStyle        = lipgloss.NewStyle().MarginLeft(2)
 	itemStyle         = lipgloss.NewStyle().PaddingLeft(4)
-	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("170"))
+	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(theme.Accent)
 	paginationStyle   = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
 	helpStyle         = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
 	quitTextStyle     = lipgloss.NewStyle().Margin(1, 0, 2, 4)
@@ -44,57 +60,207 @@ const (
 	editPresetView
 	deleteConfirmView
 	restoreDefaultsConfirmView
+	sleepTimerView
+	alarmView
+	nowPlayingView
+	keybindingsView
+	bulkTagView
+	castPickerView
+	castingView
+	audioDeviceView
+	recordingsView
+	downloadsView
+	restoreSessionView
+	logView
+	ambientView
+	mixerView
+	streamInfoView
+	dependencyView
+	qualityView
 )
 
 // Messages
 type streamURLMsg struct {
+	id    int // Matches model.loadID of the load that produced it
 	url   string
 	title string
 	err   error
 }
-type streamEndedMsg struct{}
+type streamEndedMsg struct {
+	err  error // Why mpv failed, nil if it exited normally
+	load int   // loadID of the stream, for mpv in the background
+}
 
-// Implement list.Item interface for Preset
-func (p Preset) FilterValue() string { return p.Name }
+// extractLogMsg is a line of yt-dlp output shown while loading
+type extractLogMsg struct {
+	id   int // Matches model.loadID of the load that produced it
+	line string
+}
 
-type itemDelegate struct{}
+// extractLogLines is how many lines of yt-dlp output the loading view shows
+const extractLogLines = 4
+
+type itemDelegate struct {
+	marked map[int]bool // Presets marked for a bulk action, by config index
+}
 
 func (d itemDelegate) Height() int                             { return 1 }
 func (d itemDelegate) Spacing() int                            { return 0 }
 func (d itemDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
 func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
-	preset, ok := listItem.(Preset)
+	preset, ok := listItem.(presetItem)
 	if !ok {
 		return
 	}
 
-	str := fmt.Sprintf("%d. %s", index+1, preset.Name)
-
-	fn := itemStyle.Render
+	// Style each segment separately so filter highlights don't reset the
+	// selected row's color
+	base := lipgloss.NewStyle()
+	prefix := itemStyle.Render("")
 	if index == m.Index() {
-		fn = func(s ...string) string {
-			return selectedItemStyle.Render("• " + strings.Join(s, " "))
-		}
+		base = selectedItemStyle.UnsetPaddingLeft()
+		prefix = selectedItemStyle.Render("• ")
+	}
+	dim := lipgloss.NewStyle().Foreground(theme.Muted)
+
+	matches := m.MatchesForItem(index)
+	str := base.Render(fmt.Sprintf("%d. ", index+1))
+	if d.marked[preset.index] {
+		str += lipgloss.NewStyle().Foreground(theme.Highlight).Render("✓ ")
 	}
+	str += highlightField(preset.Name, 0, matches, base)
 
-	fmt.Fprint(w, fn(str))
+	// Tags follow the name; the URL is only shown when that's what matched
+	tags := strings.Join(preset.Tags, " ")
+	tagsStart := len([]rune(preset.Name)) + 1
+	if tags != "" {
+		str += dim.Render(" [") + highlightField(tags, tagsStart, matches, dim) + dim.Render("]")
+	}
+	urlStart := tagsStart + len([]rune(tags)) + 1
+	if matchesIn(matches, urlStart, len([]rune(preset.URL))) {
+		str += " " + highlightField(preset.URL, urlStart, matches, dim)
+	}
+
+	// Truncate long lines rather than letting them wrap on narrow terminals
+	fmt.Fprint(w, ansi.Truncate(prefix+str, m.Width(), "…"))
 }
 
 type model struct {
-	list          list.Model
-	textInput     textinput.Model
-	nameInput     textinput.Model // For add/edit preset name
-	urlInput      textinput.Model // For add/edit preset URL
-	spinner       spinner.Model
-	config        *Config
-	state         viewState
-	quitting      bool
-	width         int
-	height        int
-	ready         bool   // Track if we've received initial WindowSizeMsg
-	loadingTitle  string // What we're loading
-	selectedIndex int    // For edit/delete operations
-	focusedInput  int    // Which input is focused (0=name, 1=url)
+	list               list.Model
+	textInput          textinput.Model
+	nameInput          textinput.Model // For add/edit preset name
+	urlInput           textinput.Model // For add/edit preset URL
+	tagsInput          textinput.Model // For add/edit preset tags
+	alarmInput         textinput.Model // For the alarm time
+	scheduleInput      textinput.Model // For a scheduled recording's time and length
+	spinner            spinner.Model
+	progress           progress.Model // Position bar for VODs in the Now Playing view
+	volumeBar          progress.Model // Animated volume meter in the Now Playing view
+	config             *Config
+	state              viewState
+	quitting           bool
+	width              int
+	height             int
+	ready              bool                     // Track if we've received initial WindowSizeMsg
+	loadingTitle       string                   // What we're loading
+	loadingURL         string                   // The page URL of what we're loading
+	loadingSince       time.Time                // When loading started, to rotate the messages
+	selectedIndex      int                      // For edit/delete operations
+	playingIndex       int                      // Config index of the preset being loaded, -1 for custom URLs
+	navStack           []viewState              // Views to return to on ESC, innermost last
+	loadID             int                      // Identifies the current extraction
+	cancelLoad         context.CancelFunc       // Cancels the current extraction
+	extractLog         []string                 // Last few lines of yt-dlp output
+	extractLines       chan string              // yt-dlp output for the current extraction
+	keyCursor          int                      // Selected action in the keybinding editor
+	capturingKey       bool                     // Waiting for the new key of the selected action
+	keyError           string                   // Why the last rebind was refused
+	hoverURL           string                   // URL of the preset under the cursor
+	previews           map[string]streamPreview // Fetched previews by URL
+	previewPending     map[string]bool          // URLs whose preview is being fetched
+	marked             map[int]bool             // Presets marked in the manage view, by config index
+	bulkIndexes        []int                    // Presets the open delete/tag dialog applies to
+	notice             string                   // One-off message shown in the manage view
+	focusedInput       int                      // Which input is focused (0=name, 1=url, 2=tags)
+	pomodoro           *pomodoro                // Running Pomodoro cycle, nil when off
+	sleepDeadline      time.Time                // When the sleep timer stops playback, zero when off
+	nextAlarm          time.Time                // When the alarm fires next, zero when off
+	alarmRamp          bool                     // The stream being loaded was started by the alarm
+	nowPlaying         string                   // Title of the stream playing in the background
+	playback           playbackStatusMsg        // Latest position reported by mpv
+	zen                bool                     // Full screen marquee in the Now Playing view
+	zenStarted         time.Time                // When zen mode was entered, drives the marquee
+	showTrackInfo      bool                     // Show the track info pane in the Now Playing view
+	streamURL          string                   // Direct URL of the stream playing in the background
+	stream             *mprisStream             // The stream MPRIS reports, shared with its server
+	handoff            *handoffQueue            // A stream another lofitui handed over, in single-instance mode
+	ambient            *ambientPlayer           // Ambient sound playing alongside any stream
+	ambientCursor      int                      // Selected sound in the ambient picker
+	ambientError       string                   // Why the ambient sound couldn't start
+	mixerRow           int                      // Layer selected in the mixer
+	detached           bool                     // Browsing while a stream plays in the background
+	replaceStream      bool                     // The stream playing makes way for the one loading, e.g. the alarm's
+	replacing          bool                     // The stream playing is quitting so pendingStream can play
+	playingLoad        int                      // loadID of the stream playing in the background
+	fadingLoad         int                      // loadID of the stream being crossfaded out
+	pollID             int                      // Identifies the playback polling, so only one runs
+	tier               string                   // Quality tier of the stream being loaded, "" for the best
+	pickedTier         string                   // Quality chosen in the picker for the next load
+	qualityCursor      int                      // Selected tier in the quality picker
+	missing            []dependency             // Programs needed for playback that aren't installed
+	audioOnly          bool                     // Play without video this session, from the config, -audio-only or the toggle
+	pendingStream      streamURLMsg             // Extracted stream waiting on the Ready to Play view
+	streamConfirmed    bool                     // The pending stream was confirmed, so play it
+	streamInfo         *streamInfo              // Details of the stream being loaded, once fetched
+	streamInfoErr      error                    // Why the details couldn't be fetched
+	suggestions        []int                    // Config indexes of the presets suggested for now
+	downloads          *downloadQueue           // Presets being saved for offline, shared across updates
+	identifying        bool                     // Song identification in progress
+	identified         *identifyMsg             // Result of the last song identification
+	startCmd           tea.Cmd                  // Run at startup, e.g. to load a URL given on the command line
+	volume             float64                  // mpv's volume, 0-100
+	muted              bool                     // mpv is muted
+	trackInfos         map[string]trackInfo     // Lyrics lookups by track title
+	stopWatchers       context.CancelFunc       // Stops background watchers of the current stream
+	stopSleepWatch     context.CancelFunc       // Stops the sleep timer's watcher of the current stream
+	stopPomodoroWatch  context.CancelFunc       // Stops the Pomodoro's watcher of the current stream
+	castDevices        []castDevice             // Cast devices found on the LAN
+	castScanning       bool                     // Looking for Cast devices
+	castCursor         int                      // Selected device in the cast picker
+	castTarget         *castDevice              // Device the stream being loaded is for, nil to play locally
+	castError          string                   // Why the last cast failed
+	casting            *castSession             // The cast in progress, nil when not casting
+	castState          string                   // The cast device's player state, e.g. "BUFFERING"
+	audioDevices       []audioDevice            // mpv's audio outputs, nil until fetched
+	audioCurrent       string                   // The output mpv is playing to
+	audioCursor        int                      // Selected output in the picker
+	audioError         string                   // Why the outputs couldn't be listed
+	reconnects         int                      // Reconnects since the stream last settled, 0 for a fresh load
+	resumeAt           float64                  // Seconds into a dropped VOD to pick up from, 0 for the start
+	playingSince       time.Time                // When mpv was started on the current stream
+	stalledSince       time.Time                // When mpv started waiting on its cache, zero while playing
+	resuming           bool                     // The load carries on the stream that was playing, e.g. a reconnect
+	quality            int                      // Index into qualityLevels the stream is loaded at
+	stalls             []time.Time              // When mpv recently ran out of cache
+	switchingQuality   bool                     // mpv was quit to load the stream at a lower quality
+	qualityNotice      string                   // Why the stream is playing at a lower quality
+	recording          string                   // File the stream is being recorded to, empty when it isn't
+	recordingSince     time.Time                // When the recording started
+	stopRecordingWatch context.CancelFunc       // Stops enforcing the recording's caps
+	recordNotice       string                   // Where the last recording was saved, or why it failed
+	scheduleCursor     int                      // Selected scheduled recording in the recordings view
+	scheduleError      string                   // Why the last schedule was refused
+	downloadItems      []downloadItem           // Rows of the downloads view
+	downloadCursor     int                      // Selected row in the downloads view
+	downloadsOpened    time.Time                // When the downloads view was opened, to tell its refreshes apart
+	crashedSession     savedSession             // The session offered for restoring after a crash
+	updateVersion      string                   // A newer release to mention, until dismissed
+	logLines           []logLine                // The end of the log, for the log viewer
+	logError           string                   // Why the log couldn't be read
+	logScroll          int                      // Lines the log viewer is scrolled up from the newest
+	logProblemsOnly    bool                     // The log viewer shows only warnings and errors
+	logOpened          time.Time                // When the log viewer was opened, to tell its refreshes apart
+	startVolume        float64                  // Volume to start the next stream at, 0 for mpv's default
 }
 
 func initialModel() model {
@@ -105,21 +271,21 @@ func initialModel() model {
 		config = getDefaultConfig()
 		_ = saveConfig(config) // Ignore error on initial save
 	}
+	applyTheme(config.Theme)
 
 	// Create list items from config
-	items := make([]list.Item, len(config.Presets))
-	for i, preset := range config.Presets {
-		items[i] = preset
-	}
+	items := sortedPresetItems(config.Presets, config.SortMode)
 
 	const defaultWidth = 20
 
 	// Setup list
-	l := list.New(items, itemDelegate{}, defaultWidth, len(items))
+	marked := map[int]bool{}
+	l := list.New(items, itemDelegate{marked: marked}, defaultWidth, len(items))
 	l.Title = "LofiTUI - Select a Stream"
 	l.SetShowStatusBar(false)
-	l.SetFilteringEnabled(false)
-	l.SetShowHelp(false) // Disable default help
+	l.SetFilteringEnabled(true)
+	l.Filter = presetFilter
+	l.SetShowHelp(false)       // Disable default help
 	l.DisableQuitKeybindings() // Disable default quit keys
 	l.Styles.Title = titleStyle
 	l.Styles.PaginationStyle = paginationStyle
@@ -140,57 +306,617 @@ func initialModel() model {
 	ui.Placeholder = "YouTube URL"
 	ui.Width = 50
 
+	// Setup tags input for add/edit
+	gi := textinput.New()
+	gi.Placeholder = "Tags, comma separated (optional)"
+	gi.Width = 50
+
+	// Setup alarm time input
+	ai := textinput.New()
+	ai.Placeholder = "HH:MM"
+	ai.CharLimit = 5
+	ai.Width = 10
+
+	// Setup scheduled recording input
+	ri := textinput.New()
+	ri.Placeholder = "sat 21:00 60"
+	ri.Width = 20
+
 	// Setup spinner
 	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-
-	return model{
-		list:      l,
-		textInput: ti,
-		nameInput: ni,
-		urlInput:  ui,
-		spinner:   s,
-		config:    config,
-		state:     mainMenuView,
+	s.Spinner = loadingSpinner(config.Loading.Spinner)
+	s.Style = lipgloss.NewStyle().Foreground(theme.Highlight)
+
+	m := model{
+		stream:         &mprisStream{},
+		handoff:        &handoffQueue{},
+		ambient:        newAmbientPlayer(config.Ambient.Volume),
+		downloads:      newDownloadQueue(config.Offline),
+		list:           l,
+		textInput:      ti,
+		nameInput:      ni,
+		urlInput:       ui,
+		tagsInput:      gi,
+		alarmInput:     ai,
+		scheduleInput:  ri,
+		spinner:        s,
+		progress:       progress.New(progress.WithGradient(string(theme.Accent), string(theme.Highlight)), progress.WithoutPercentage()),
+		volumeBar:      progress.New(progress.WithSolidFill(string(theme.Info)), progress.WithoutPercentage(), progress.WithWidth(20)),
+		config:         config,
+		audioOnly:      audioOnly || config.AudioOnly,
+		state:          mainMenuView,
+		previews:       map[string]streamPreview{},
+		previewPending: map[string]bool{},
+		marked:         marked,
+		trackInfos:     map[string]trackInfo{},
 	}
+
+	// Arm a previously configured alarm
+	if config.Alarm.Enabled {
+		if next, err := nextAlarmTime(config.Alarm.Time, time.Now()); err == nil {
+			m.nextAlarm = next
+		}
+	}
+	m.refreshSuggestions()
+
+	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{m.startCmd, sessionTick(), checkForUpdate(m.config)}
+	if !m.nextAlarm.IsZero() {
+		cmds = append(cmds, alarmTick(m.nextAlarm))
+	}
+	// Preview the preset the cursor starts on
+	if url := m.selectedURL(); url != "" {
+		cmds = append(cmds, previewHover(url))
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case streamURLMsg:
+		// Ignore results of loads that were cancelled or superseded
+		if msg.id != m.loadID {
+			return m, nil
+		}
+		m.cancelLoad = nil
 		// URL extracted, now play it
 		if msg.err != nil {
-			// Error loading stream, go back to menu
-			m.state = mainMenuView
+			slog.Error("failed to load stream, going back", "title", m.loadingTitle, "url", m.loadingURL,
+				"err", msg.err, "output", strings.Join(m.extractLog, " | "))
+			// yt-dlp isn't installed: say so rather than just going back
+			if errors.Is(msg.err, exec.ErrNotFound) && m.checkDependencies() {
+				m.alarmRamp = false
+				m.castTarget = nil
+				return m, nil
+			}
+			// A dropped stream keeps trying while the network is down
+			if m.resuming {
+				if m.reconnects < m.config.MaxReconnects {
+					return m, m.scheduleReconnect()
+				}
+				handleEvent(m.config, "stream_ended", daemonStatus{Title: m.loadingTitle, URL: m.loadingURL})
+			}
+			// Error loading stream, go back to where we came from
+			m.popView()
+			m.alarmRamp = false
+			if m.castTarget != nil {
+				m.castTarget = nil
+				m.castError = "Couldn't load the stream"
+			}
+			return m, nil
+		}
+		// Casting hands the URL to the device instead of mpv
+		if m.castTarget != nil {
+			device := *m.castTarget
+			m.castTarget = nil
+			return m, castStream(msg.id, device, msg.url, msg.title)
+		}
+		// Show what's about to play first, if asked to; not for alarms or
+		// reconnects, which nobody's there to confirm
+		if m.config.ConfirmBeforePlay && !m.streamConfirmed && !m.resuming && !m.alarmRamp {
+			m.pendingStream = msg
+			m.replaceView(streamInfoView)
 			return m, nil
 		}
+		m.streamConfirmed = false
+		// A stream left playing while browsing makes way for this one,
+		// fading out as it fades in if it can
+		var fadeFrom *lofitui.Player
+		if m.detached || m.replaceStream {
+			if m.replaceStream {
+				// Its Now Playing view, and anything opened from there,
+				// went with it
+				for i, view := range m.navStack {
+					if view == nowPlayingView {
+						m.navStack = m.navStack[:i]
+						break
+					}
+				}
+				m.zen = false
+			}
+			m.detached, m.replaceStream = false, false
+			// An alarm ramps up on its own
+			if m.config.CrossfadeSeconds > 0 && !m.alarmRamp && !m.config.TerminalPlayback && backendBinary(m.config) == "mpv" {
+				if old, ok := handOverSocket(); ok {
+					fadeFrom = &old
+				}
+			}
+			if fadeFrom == nil {
+				m.replacing = true
+				m.pendingStream = msg
+				return m, m.stopMPV()
+			}
+			// Its watchers and recording end with it
+			m.stopStreamWatchers()
+			m.endRecording()
+			m.fadingLoad = m.playingLoad
+			handleEvent(m.config, "stopped", daemonStatus{Title: m.nowPlaying})
+		}
+		m.stream.set(m.loadingTitle, m.loadingURL)
+		// A reconnect carries on the same play
+		if !m.resuming {
+			rememberPlay(m.loadingTitle, m.loadingURL)
+			handleEvent(m.config, "now_playing", daemonStatus{Title: m.loadingTitle, URL: m.loadingURL})
+			// Count the play for the sort modes
+			if m.playingIndex >= 0 {
+				recordPlay(m.config, m.playingIndex)
+				saveConfig(m.config)
+				m = refreshList(m)
+			}
+		}
+		// Start watchers that control mpv while the TUI is suspended
+		ctx, cancel := context.WithCancel(context.Background())
+		m.stopWatchers = cancel
+		m.startPomodoroWatch()
+		m.startSleepWatch()
+		extraArgs := append(configArgs(m.config), presetArgs(m.config, m.loadingURL)...)
+		extraArgs = append(extraArgs, replayArgs(m.config.Replay)...)
+		if m.startVolume > 0 {
+			extraArgs = append(extraArgs, fmt.Sprintf("--volume=%.0f", m.startVolume))
+			m.startVolume = 0
+		}
+		if m.alarmRamp {
+			// Start silent and ramp up to the alarm volume
+			m.alarmRamp = false
+			ramp := time.Duration(m.config.Alarm.RampMinutes) * time.Minute
+			go watchVolumeRamp(ctx, m.config.Alarm.Volume, ramp)
+			extraArgs = append(extraArgs, "--volume=0")
+		}
+		if m.resumeAt > 0 {
+			extraArgs = append(extraArgs, fmt.Sprintf("--start=%.0f", m.resumeAt))
+			m.resumeAt = 0
+		}
+		m.playingSince = time.Now()
+		m.stalledSince = time.Time{}
+		m.stalls = nil
+		m.playback = playbackStatusMsg{}
+		m.recordNotice = ""
+		player, err := playerBackend(m.config)
+		if err != nil {
+			slog.Error("bad player in the config, playing with mpv", "err", err)
+			player = mpvBackend{}
+		}
+		// Only mpv can play in the background, controlled over its IPC
+		if !m.config.TerminalPlayback && player.controllable() {
+			// Keep the TUI up and show the Now Playing view in place of loading
+			m.replaceView(nowPlayingView)
+			m.nowPlaying = msg.title
+			m.streamURL = msg.url
+			m.identifying = false
+			m.identified = nil
+			m.pollID++
+			m.playingLoad = msg.id
+			if fadeFrom != nil {
+				fade := time.Duration(m.config.CrossfadeSeconds) * time.Second
+				// A preset with its own volume fades in to that
+				volume, ok := argsVolume(presetArgs(m.config, m.loadingURL))
+				if !ok {
+					volume = -1
+				}
+				return m, tea.Batch(
+					startMPV(msg.id, msg.url, msg.title, append(extraArgs, "--volume=0")...),
+					pollPlayback(m.pollID),
+					crossfade(*fadeFrom, fade, volume),
+				)
+			}
+			return m, tea.Batch(
+				startMPV(msg.id, msg.url, msg.title, extraArgs...),
+				pollPlayback(m.pollID),
+			)
+		}
 		// Launch mpv with the extracted URL
-		return m, playMPV(msg.url, msg.title)
+		return m, playMPV(player, msg.url, m.loadingURL, msg.title, !m.audioOnly, extraArgs...)
+
+	case streamInfoMsg:
+		if msg.id == m.loadID {
+			m.streamInfo, m.streamInfoErr = &msg.info, msg.err
+		}
+		return m, nil
+
+	case handoffMsg:
+		// Something is playing: it's quitting, and the handover is played
+		// once it has
+		if title, _ := m.stream.current(); title != "" {
+			_ = quitMPV()
+			return m, nil
+		}
+		if target, ok := m.handoff.take(); ok {
+			return m, m.playHandedOff(target)
+		}
+		return m, nil
 
 	case streamEndedMsg:
-		// Stream finished, return to main menu
-		m.state = mainMenuView
+		// The old stream of a crossfade is done with
+		if msg.load != 0 && msg.load == m.fadingLoad {
+			m.fadingLoad = 0
+			return m, nil
+		}
+		// A stream left playing while browsing ended, or made way for another
+		detached, replacing := m.detached, m.replacing
+		m.detached, m.replacing = false, false
+		// Stream finished, go back to where it was started from, closing
+		// the mixer (and the ambient picker opened from it) on the way
+		for !detached && !replacing && (m.state == mixerView || m.state == ambientView) {
+			m.popView()
+		}
+		// One left playing while browsing is handled as if from Now Playing,
+		// so it reconnects in front of what's being browsed, or goes back to it
+		if detached {
+			m.pushView(nowPlayingView)
+		}
+		m.stopStreamWatchers()
+		// mpv finishes the recording as it quits
+		m.endRecording()
+		stopped, step := m.stream.takeEnd()
+		// mpv was quit to play a stream handed over by another lofitui
+		if target, ok := m.handoff.take(); ok {
+			m.switchingQuality = false
+			m.stream.set("", "")
+			handleEvent(m.config, "stopped", daemonStatus{Title: m.loadingTitle, URL: m.loadingURL})
+			return m, m.playHandedOff(target)
+		}
+		if replacing {
+			m.switchingQuality = false
+			m.stream.set("", "")
+			handleEvent(m.config, "stopped", daemonStatus{Title: m.nowPlaying})
+			// It was confirmed already, if it needed to be
+			m.streamConfirmed = true
+			return m.Update(m.pendingStream)
+		}
+		// An expired sleep timer is done once it has stopped the stream
+		if !m.sleepDeadline.IsZero() && time.Now().After(m.sleepDeadline) {
+			m.sleepDeadline = time.Time{}
+			stopped = true
+		}
+		// In the background the stream dropped unless it was stopped; with
+		// mpv in the terminal, quitting it is how you stop, so only a
+		// failure counts
+		event := "stopped"
+		if !stopped && step == 0 && (m.state == nowPlayingView || msg.err != nil) {
+			event = "stream_ended"
+		}
+		// Lowering the quality quit mpv to load it again
+		if m.switchingQuality && !stopped && step == 0 {
+			m.switchingQuality = false
+			if m.playback.duration > 0 {
+				m.resumeAt = m.playback.position
+			}
+			m.replaceView(loadingView)
+			m.stream.set("", "")
+			return m, m.reload()
+		}
+		m.switchingQuality = false
+		// The player isn't installed
+		if errors.Is(msg.err, exec.ErrNotFound) && m.checkDependencies() {
+			m.stream.set("", "")
+			return m, nil
+		}
+		// A stream that dropped is loaded again rather than given up on
+		if event == "stream_ended" && m.shouldReconnect() {
+			return m, m.scheduleReconnect()
+		}
+		m.zen = false
+		m.popView()
+		m.stream.set("", "")
+		handleEvent(m.config, event, daemonStatus{Title: m.loadingTitle, URL: m.loadingURL})
+		// It may be a different hour from when the menu was last shown
+		m.refreshSuggestions()
+		// Media keys' next and previous stop the stream to play another
+		if step != 0 && len(m.config.Presets) > 0 {
+			index := skipPreset(m.playingIndex, step, len(m.config.Presets))
+			m.pushView(loadingView)
+			return m, m.startLoading(m.config.Presets[index].URL, m.config.Presets[index].Name, index)
+		}
+		return m, nil
+
+	case recordingMsg:
+		if msg.err != nil {
+			m.recordNotice = "Couldn't record: " + msg.err.Error()
+			return m, nil
+		}
+		// The stream may have ended while mpv was being asked
+		if m.state != nowPlayingView {
+			return m, nil
+		}
+		m.startedRecording(msg.path)
+		return m, nil
+
+	case clipMsg:
+		if msg.err != nil {
+			m.recordNotice = "Couldn't save a clip: " + msg.err.Error()
+			return m, nil
+		}
+		m.recordNotice = "Saved the last few minutes to " + msg.path
+		return m, nil
+
+	case reconnectMsg:
+		// Ignore reconnects that were cancelled or superseded
+		if msg.id != m.loadID {
+			return m, nil
+		}
+		return m, m.reload()
+
+	case castDevicesMsg:
+		m.castScanning = false
+		m.castDevices = msg.devices
+		m.castCursor = 0
+		if msg.err != nil {
+			m.castError = "Couldn't search the network: " + msg.err.Error()
+		}
+		return m, nil
+
+	case castStartedMsg:
+		// A cast whose load was abandoned isn't wanted any more
+		if msg.id != m.loadID {
+			if msg.session != nil {
+				return m, stopCast(msg.session)
+			}
+			return m, nil
+		}
+		// Back to the picker on failure, else stopping returns to the menu
+		m.popView()
+		if msg.err != nil {
+			m.castError = "Couldn't cast: " + msg.err.Error()
+			return m, nil
+		}
+		m.replaceView(castingView)
+		m.casting = msg.session
+		m.castState = ""
+		m.nowPlaying = m.loadingTitle
+		rememberPlay(m.loadingTitle, m.loadingURL)
+		handleEvent(m.config, "now_playing", daemonStatus{Title: m.loadingTitle, URL: m.loadingURL})
+		if m.playingIndex >= 0 {
+			recordPlay(m.config, m.playingIndex)
+			saveConfig(m.config)
+			m = refreshList(m)
+		}
+		return m, waitCastStatus(m.casting)
+
+	case castStatusMsg:
+		if msg.session != m.casting {
+			return m, nil
+		}
+		m.castState = msg.status.state
+		return m, tea.Batch(waitCastStatus(m.casting), m.setVolume(msg.status.volume, msg.status.muted))
+
+	case castEndedMsg:
+		if msg.session != m.casting {
+			return m, nil
+		}
+		m.casting = nil
+		event := "stopped"
+		if stopped, err := msg.session.ended(); !stopped && err != nil {
+			slog.Warn("cast ended", "device", msg.session.device.name, "err", err)
+			event = "stream_ended"
+		}
+		m.popView()
+		handleEvent(m.config, event, daemonStatus{Title: m.loadingTitle, URL: m.loadingURL})
+		return m, nil
+
+	case audioDevicesMsg:
+		if msg.err != nil {
+			m.audioError = "Couldn't list outputs: " + msg.err.Error()
+			return m, nil
+		}
+		m.audioDevices, m.audioCurrent = msg.devices, msg.current
+		for i, device := range m.audioDevices {
+			if device.name == m.audioCurrent {
+				m.audioCursor = i
+			}
+		}
 		return m, nil
 
+	case previewHoverMsg:
+		// Fetch only if the cursor is still there and we don't already know
+		if msg.url != m.selectedURL() || m.previewPending[msg.url] {
+			return m, nil
+		}
+		if preview, ok := m.previews[msg.url]; ok && time.Since(preview.fetched) < previewTTL {
+			return m, nil
+		}
+		m.previewPending[msg.url] = true
+		return m, fetchPreview(msg.url)
+
+	case previewMsg:
+		delete(m.previewPending, msg.url)
+		m.previews[msg.url] = msg.preview
+		return m, nil
+
+	case extractLogMsg:
+		// Show the tail of yt-dlp's output, ignoring stale loads
+		if msg.id != m.loadID {
+			return m, nil
+		}
+		m.extractLog = append(m.extractLog, msg.line)
+		if len(m.extractLog) > extractLogLines {
+			m.extractLog = m.extractLog[len(m.extractLog)-extractLogLines:]
+		}
+		return m, waitForExtractLog(msg.id, m.extractLines)
+
+	case seekedMsg:
+		m.playback.position = msg.position
+		return m, nil
+
+	case speedMsg:
+		m.playback.speed = float64(msg)
+		return m, nil
+
+	case pausedMsg:
+		// Show the change without waiting for the next poll
+		m.playback.paused = bool(msg)
+		return m, nil
+
+	case playbackStatusMsg:
+		// Keep polling while the stream plays, whichever view is up
+		if title, _ := m.stream.current(); msg.poll != m.pollID || title == "" {
+			return m, nil
+		}
+		if msg.track != "" && msg.track != m.playback.track {
+			handleEvent(m.config, "track", daemonStatus{Title: m.nowPlaying, URL: m.loadingURL, Track: msg.track})
+		}
+		// Running out of cache again and again means the connection can't
+		// keep up with this quality
+		if msg.stalled && !m.playback.stalled && m.noteStall() {
+			m.playback = msg
+			return m, m.lowerQuality()
+		}
+		m.playback = msg
+		// A recording that reached its cap has been stopped by its watcher;
+		// the first poll after starting may predate it
+		if m.recording != "" && msg.recording != m.recording && time.Since(m.recordingSince) > 2*time.Second {
+			m.recordNotice = "Recording stopped at its limit, saved to " + m.recording
+			m.endRecording()
+		}
+		// mpv waits for the network indefinitely, so a long stall is a drop
+		if !msg.stalled {
+			m.stalledSince = time.Time{}
+		} else if m.stalledSince.IsZero() {
+			m.stalledSince = time.Now()
+		} else if time.Since(m.stalledSince) > stallTimeout {
+			slog.Warn("stream stalled, dropping it", "title", m.nowPlaying, "stalled", time.Since(m.stalledSince))
+			m.stalledSince = time.Time{}
+			return m, func() tea.Msg {
+				_ = quitMPV()
+				return nil
+			}
+		}
+		return m, tea.Batch(pollPlayback(m.pollID), m.lookupTrack(), m.setVolume(msg.volume.volume, msg.volume.muted))
+
+	case volumeMsg:
+		return m, m.setVolume(msg.volume, msg.muted)
+
+	case progress.FrameMsg:
+		// Animate the volume meter
+		bar, cmd := m.volumeBar.Update(msg)
+		m.volumeBar = bar.(progress.Model)
+		return m, cmd
+
+	case identifyMsg:
+		// Drop results for a stream that has since been replaced
+		if !m.identifying {
+			return m, nil
+		}
+		m.identifying = false
+		m.identified = &msg
+		return m, nil
+
+	case trackInfoMsg:
+		m.trackInfos[msg.track] = msg.info
+		return m, nil
+
+	case downloadsTickMsg:
+		// Keep refreshing only while the view that scheduled this is open
+		if !m.showingDownloads() || !m.downloadsOpened.Equal(msg.opened) {
+			return m, nil
+		}
+		m.refreshDownloads()
+		return m, downloadsTick(m.downloadsOpened)
+
+	case logTickMsg:
+		// Keep reading only for the log viewer that scheduled this
+		if m.state != logView || !m.logOpened.Equal(msg.opened) {
+			return m, nil
+		}
+		m.refreshLog()
+		return m, logTick(m.logOpened)
+
+	case updateMsg:
+		m.updateVersion = msg.version
+		return m, nil
+
+	case sessionTickMsg:
+		return m, tea.Batch(m.saveSession(), sessionTick())
+
+	case zenTickMsg:
+		// Keep scrolling only for the zen session that scheduled this tick
+		if m.zen && m.state == nowPlayingView && m.zenStarted.Equal(msg.started) {
+			return m, zenTick(m.zenStarted)
+		}
+		return m, nil
+
+	case pomodoroTickMsg:
+		// Keep ticking only for the cycle that scheduled this tick
+		if m.pomodoro != nil && m.pomodoro.started.Equal(msg.started) {
+			return m, pomodoroTick(m.pomodoro.started)
+		}
+		return m, nil
+
+	case sleepTickMsg:
+		// Keep ticking only for the timer that scheduled this tick
+		if m.sleepDeadline.IsZero() || !m.sleepDeadline.Equal(msg.deadline) {
+			return m, nil
+		}
+		if time.Now().After(m.sleepDeadline) {
+			m.sleepDeadline = time.Time{}
+			return m, nil
+		}
+		return m, sleepTick(m.sleepDeadline)
+
+	case alarmTickMsg:
+		// Keep ticking only for the alarm that scheduled this tick
+		if m.nextAlarm.IsZero() || !m.nextAlarm.Equal(msg.at) {
+			return m, nil
+		}
+		now := time.Now()
+		if now.Before(m.nextAlarm) {
+			return m, alarmTick(m.nextAlarm)
+		}
+
+		// Re-arm for tomorrow, then fire unless we're already busy
+		// loading something or missed the alarm by a wide margin
+		late := now.Sub(m.nextAlarm) > time.Minute
+		m.nextAlarm, _ = nextAlarmTime(m.config.Alarm.Time, now)
+		tick := alarmTick(m.nextAlarm)
+		if late || m.state == loadingView {
+			return m, tick
+		}
+		for i, preset := range m.config.Presets {
+			if preset.Name == m.config.Alarm.Preset {
+				// A stream playing makes way for the alarm's, once that's
+				// loaded; one left playing while browsing does anyway
+				if title, _ := m.stream.current(); title != "" && !m.detached {
+					m.replaceStream = true
+				}
+				m.pushView(loadingView)
+				m.alarmRamp = true
+				return m, tea.Batch(tick, m.startLoading(preset.URL, preset.Name, i))
+			}
+		}
+		return m, tick
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		m.ready = true
 
 		// Update list dimensions - full width, account for title and help
-		listHeight := msg.Height - 4
-		if listHeight < 5 {
-			listHeight = 5
-		}
 		m.list.SetWidth(msg.Width)
-		m.list.SetHeight(listHeight)
+		m.list.SetHeight(m.listHeight())
 
-		// Update text input width to be responsive
+		// Update text input width to be responsive, but keep it inside its dialog
 		inputWidth := msg.Width - 20
 		if inputWidth < 30 {
 			inputWidth = 30
@@ -198,187 +924,888 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if inputWidth > 80 {
 			inputWidth = 80
 		}
-		m.textInput.Width = inputWidth
+		m.textInput.Width = fitInputWidth(inputWidth, clampDialogWidth(msg.Width, 10, 40, 80))
+		m.nameInput.Width = fitInputWidth(50, clampDialogWidth(msg.Width, 10, 60, 80))
+		m.urlInput.Width = m.nameInput.Width
+		m.tagsInput.Width = m.nameInput.Width
+
+		// Size the position bar to fit inside the Now Playing dialog
+		m.progress.Width = clampDialogWidth(msg.Width, 10, 40, 70) - 20
+		if m.progress.Width < 5 {
+			m.progress.Width = 5
+		}
 
 		return m, nil
 
 	case tea.KeyMsg:
+		// While typing a filter, keys belong to the list's filter input
+		if (m.state == mainMenuView || m.state == managePresetsView) && m.list.SettingFilter() {
+			break
+		}
+		// ESC clears an applied filter before it goes back
+		if msg.String() == "esc" && m.list.FilterState() == list.FilterApplied &&
+			(m.state == mainMenuView || m.state == managePresetsView) {
+			m.list.ResetFilter()
+			return m, nil
+		}
+
+		// The log viewer opens from any view, to see what just went wrong
+		if msg.String() == "ctrl+l" && m.state != logView {
+			return m, m.openLogView()
+		}
+
 		switch m.state {
 		case mainMenuView:
 			switch msg.String() {
-			case "ctrl+c", "q":
-				m.state = quitConfirmView
+			case "ctrl+c", m.config.key("quit"):
+				m.pushView(quitConfirmView)
 				return m, nil
-			case "c":
-				m.state = customURLView
+			case m.config.key("custom_url"):
+				m.pushView(customURLView)
 				m.textInput.Focus()
 				return m, textinput.Blink
-			case "m":
+			case m.config.key("manage"):
 				// Open preset management
-				m.state = managePresetsView
+				m.pushView(managePresetsView)
 				return m, nil
-			case "enter":
+			case m.config.key("pomodoro"):
+				// Toggle the Pomodoro timer
+				if m.pomodoro != nil {
+					m.pomodoro = nil
+					m.startPomodoroWatch()
+					return m, nil
+				}
+				m.pomodoro = newPomodoro(m.config.Pomodoro)
+				// A stream left playing while browsing gets its breaks too
+				m.startPomodoroWatch()
+				return m, pomodoroTick(m.pomodoro.started)
+			case m.config.key("sleep_timer"):
+				// Open the sleep timer dialog
+				m.pushView(sleepTimerView)
+				return m, nil
+			case m.config.key("sort"):
+				// Cycle the sort mode
+				m.config.SortMode = nextSortMode(m.config.SortMode)
+				saveConfig(m.config)
+				m = refreshList(m)
+				m.list.Select(0)
+				return m, nil
+			case "1", "2", "3":
+				// Play a suggestion
+				if n := int(msg.String()[0] - '1'); n < len(m.suggestions) && m.suggestions[n] < len(m.config.Presets) {
+					preset := m.config.Presets[m.suggestions[n]]
+					m.pushView(loadingView)
+					return m, m.startLoading(preset.URL, preset.Name, m.suggestions[n])
+				}
+				return m, nil
+			case m.config.key("dismiss"):
+				// Don't mention this release again
+				if m.updateVersion != "" {
+					m.config.DismissedUpdate = m.updateVersion
+					m.updateVersion = ""
+					saveConfig(m.config)
+				}
+				return m, nil
+			case m.config.key("keybindings"):
+				// Open the keybinding editor
+				m.pushView(keybindingsView)
+				m.keyCursor = 0
+				m.capturingKey = false
+				m.keyError = ""
+				return m, nil
+			case m.config.key("alarm"):
+				// Open the alarm dialog for the selected preset
+				if preset, ok := m.list.SelectedItem().(presetItem); ok {
+					m.pushView(alarmView)
+					m.selectedIndex = preset.index
+					m.alarmInput.SetValue(m.config.Alarm.Time)
+					if m.config.Alarm.Preset != preset.Name {
+						m.alarmInput.SetValue("")
+					}
+					m.alarmInput.Focus()
+					return m, textinput.Blink
+				}
+			case m.config.key("ambient"):
+				m.openAmbient()
+				return m, nil
+			case m.config.key("browse"):
+				// Back to the stream left playing
+				if m.detached {
+					m.detached = false
+					m.pushView(nowPlayingView)
+				}
+				return m, nil
+			case m.config.key("quality"):
+				m.openQualityPicker()
+				return m, nil
+			case m.config.key("audio_only"):
+				// Just for this session; the config sets the default
+				m.audioOnly = !m.audioOnly
+				return m, nil
+			case m.config.key("downloads"):
+				// Show downloads and recordings
+				m.pushView(downloadsView)
+				m.downloadCursor = 0
+				m.downloadsOpened = time.Now()
+				m.refreshDownloads()
+				return m, downloadsTick(m.downloadsOpened)
+			case m.config.key("recordings"):
+				// Manage scheduled recordings, adding for the selected preset
+				if preset, ok := m.list.SelectedItem().(presetItem); ok {
+					m.pushView(recordingsView)
+					m.selectedIndex = preset.index
+					m.scheduleCursor = 0
+					m.scheduleError = ""
+					m.scheduleInput.SetValue("")
+					m.scheduleInput.Focus()
+					return m, textinput.Blink
+				}
+			case m.config.key("cast"):
+				// Pick a Cast device to play the selected preset on
+				if preset, ok := m.list.SelectedItem().(presetItem); ok {
+					m.pushView(castPickerView)
+					m.selectedIndex = preset.index
+					m.castError = ""
+					m.castScanning = true
+					return m, findCastDevices()
+				}
+			case m.config.key("play"):
 				// Play selected preset
-				if preset, ok := m.list.SelectedItem().(Preset); ok {
-					m.state = loadingView
-					m.loadingTitle = preset.Name
-					return m, tea.Batch(
-						spinner.Tick,
-						extractStreamURL(preset.URL, preset.Name),
-					)
+				if preset, ok := m.list.SelectedItem().(presetItem); ok {
+					m.pushView(loadingView)
+					return m, m.startLoading(preset.URL, preset.Name, preset.index)
+				}
+			}
+
+		case castPickerView:
+			switch msg.String() {
+			case "esc":
+				m.popView()
+				return m, nil
+			case "up", "k":
+				if m.castCursor > 0 {
+					m.castCursor--
+				}
+			case "down", "j":
+				if m.castCursor < len(m.castDevices)-1 {
+					m.castCursor++
+				}
+			case "r":
+				// Search again, e.g. after plugging a device in
+				if !m.castScanning {
+					m.castError = ""
+					m.castScanning = true
+					return m, findCastDevices()
+				}
+			case "enter":
+				if m.castScanning || m.castCursor >= len(m.castDevices) || m.selectedIndex >= len(m.config.Presets) {
+					return m, nil
+				}
+				device := m.castDevices[m.castCursor]
+				preset := m.config.Presets[m.selectedIndex]
+				m.castTarget = &device
+				m.castError = ""
+				m.pushView(loadingView)
+				return m, m.startLoading(preset.URL, preset.Name, m.selectedIndex)
+			}
+			return m, nil
+
+		case audioDeviceView:
+			switch msg.String() {
+			case "esc":
+				m.popView()
+				return m, nil
+			case "up", "k":
+				if m.audioCursor > 0 {
+					m.audioCursor--
+				}
+			case "down", "j":
+				if m.audioCursor < len(m.audioDevices)-1 {
+					m.audioCursor++
+				}
+			case "enter":
+				if m.audioCursor >= len(m.audioDevices) {
+					return m, nil
+				}
+				// Remember the choice for the next streams too
+				device := m.audioDevices[m.audioCursor].name
+				m.config.AudioDevice = device
+				if device == "auto" {
+					m.config.AudioDevice = ""
+				}
+				saveConfig(m.config)
+				m.popView()
+				return m, switchAudioDevice(device)
+			}
+			return m, nil
+
+		case castingView:
+			switch msg.String() {
+			case m.config.key("pause"), " ":
+				return m, castPause(m.casting, m.castState != "PAUSED")
+			case m.config.key("volume_up"), "up":
+				return m, castVolume(m.casting, m.volume, volumeStep)
+			case m.config.key("volume_down"), "down":
+				return m, castVolume(m.casting, m.volume, -volumeStep)
+			case m.config.key("mute"):
+				return m, castMute(m.casting, !m.muted)
+			case "esc", "ctrl+c", m.config.key("stop"):
+				// Stop the device; castEndedMsg brings us back to the menu
+				return m, stopCast(m.casting)
+			}
+			return m, nil
+
+		case customURLView:
+			switch msg.String() {
+			case "ctrl+c", "esc":
+				m.popView()
+				m.textInput.SetValue("")
+				return m, nil
+			case "enter":
+				url := m.textInput.Value()
+				if url != "" {
+					// The dialog is done with, so ESC from loading goes back to the menu
+					m.replaceView(loadingView)
+					m.textInput.SetValue("")
+					return m, m.startLoading(url, "Custom Stream", -1)
+				}
+			}
+
+		case qualityView:
+			switch msg.String() {
+			case "esc":
+				m.popView()
+				return m, nil
+			case "up", "k":
+				m.qualityCursor = max(m.qualityCursor-1, 0)
+			case "down", "j":
+				m.qualityCursor = min(m.qualityCursor+1, len(qualityTiers)-1)
+			case "d":
+				// Play this preset at this quality from now on
+				if m.selectedIndex >= len(m.config.Presets) {
+					return m, nil
+				}
+				m.config.Presets[m.selectedIndex].Quality = qualityTiers[m.qualityCursor].id
+				if m.qualityCursor == 0 {
+					m.config.Presets[m.selectedIndex].Quality = ""
+				}
+				saveConfig(m.config)
+			case "enter":
+				if m.selectedIndex >= len(m.config.Presets) {
+					return m, nil
+				}
+				preset := m.config.Presets[m.selectedIndex]
+				m.pickedTier = qualityTiers[m.qualityCursor].id
+				m.replaceView(loadingView)
+				return m, m.startLoading(preset.URL, preset.Name, m.selectedIndex)
+			}
+			return m, nil
+
+		case dependencyView:
+			switch msg.String() {
+			case "r":
+				if !m.checkDependencies() {
+					m.popView()
+				}
+				return m, nil
+			case "enter", "esc":
+				m.popView()
+				return m, nil
+			case "q", "ctrl+c":
+				m.quitting = true
+				return m, tea.Quit
+			}
+			return m, nil
+
+		case quitConfirmView:
+			switch msg.String() {
+			case "y", "Y":
+				m.quitting = true
+				return m, tea.Quit
+			case "n", "N", "esc":
+				m.popView()
+				return m, nil
+			}
+
+		case managePresetsView:
+			m.notice = ""
+			switch msg.String() {
+			case "esc":
+				// Unmark everything before going back
+				if len(m.marked) > 0 {
+					m.clearMarks()
+					return m, nil
+				}
+				m.popView()
+				return m, nil
+			case " ":
+				// Mark the selected preset and move on to the next
+				if preset, ok := m.list.SelectedItem().(presetItem); ok {
+					m.toggleMark(preset.index)
+					m.list.CursorDown()
+				}
+				return m, nil
+			case m.config.key("add_preset"):
+				// Add new preset
+				m.pushView(addPresetView)
+				m.nameInput.SetValue("")
+				m.urlInput.SetValue("")
+				m.tagsInput.SetValue("")
+				m = focusPresetInput(m, 0)
+				return m, textinput.Blink
+			case m.config.key("edit_preset"):
+				// Edit selected preset
+				if preset, ok := m.list.SelectedItem().(presetItem); ok {
+					m.pushView(editPresetView)
+					m.selectedIndex = preset.index
+					m.nameInput.SetValue(preset.Name)
+					m.urlInput.SetValue(preset.URL)
+					m.tagsInput.SetValue(strings.Join(preset.Tags, ", "))
+					m = focusPresetInput(m, 0)
+					return m, textinput.Blink
+				}
+			case m.config.key("delete_preset"):
+				// Delete the marked presets, or the selected one
+				if m.bulkIndexes = m.bulkTargets(); len(m.bulkIndexes) > 0 {
+					m.pushView(deleteConfirmView)
+				}
+				return m, nil
+			case m.config.key("tag_presets"):
+				// Add tags to the marked presets, or the selected one
+				if m.bulkIndexes = m.bulkTargets(); len(m.bulkIndexes) > 0 {
+					m.pushView(bulkTagView)
+					m.tagsInput.SetValue("")
+					m.tagsInput.Focus()
+					return m, textinput.Blink
+				}
+				return m, nil
+			case m.config.key("export_presets"):
+				// Export the marked presets, or the selected one
+				var presets []Preset
+				for _, index := range m.bulkTargets() {
+					presets = append(presets, m.config.Presets[index])
+				}
+				if len(presets) == 0 {
+					return m, nil
+				}
+				path, err := exportPresets(presets)
+				if err != nil {
+					m.notice = lipgloss.NewStyle().Foreground(theme.Danger).Render("  Export failed: " + err.Error())
+					return m, nil
+				}
+				m.notice = lipgloss.NewStyle().Foreground(theme.Muted).Render(fmt.Sprintf("  Exported %d presets to %s", len(presets), path))
+				m.clearMarks()
+				return m, nil
+			case m.config.key("download"):
+				// Save the marked presets, or the selected one, for offline
+				queued := 0
+				for _, index := range m.bulkTargets() {
+					preset := m.config.Presets[index]
+					if m.downloads.add(preset.Name, preset.URL) {
+						queued++
+					}
+				}
+				notice := fmt.Sprintf("  Saving %d presets for offline in the background", queued)
+				if queued == 0 {
+					notice = "  Already saved or being saved for offline"
+				}
+				m.notice = lipgloss.NewStyle().Foreground(theme.Muted).Render(notice)
+				m.clearMarks()
+				return m, nil
+			case m.config.key("restore_defaults"):
+				// Restore defaults
+				m.pushView(restoreDefaultsConfirmView)
+				return m, nil
+			case m.config.key("play"):
+				// Play selected preset from manage view
+				if preset, ok := m.list.SelectedItem().(presetItem); ok {
+					m.pushView(loadingView)
+					return m, m.startLoading(preset.URL, preset.Name, preset.index)
+				}
+			}
+
+		case addPresetView:
+			switch msg.String() {
+			case "esc":
+				m.popView()
+				return m, nil
+			case "tab":
+				// Cycle through the name, URL and tags inputs
+				m = focusPresetInput(m, (m.focusedInput+1)%3)
+				return m, textinput.Blink
+			case "shift+tab":
+				m = focusPresetInput(m, (m.focusedInput+2)%3)
+				return m, textinput.Blink
+			case "enter":
+				// Save new preset
+				name := strings.TrimSpace(m.nameInput.Value())
+				url := strings.TrimSpace(m.urlInput.Value())
+				if name != "" && url != "" {
+					newPreset := Preset{Name: name, URL: url, Tags: parseTags(m.tagsInput.Value())}
+					m.config.Presets = append(m.config.Presets, newPreset)
+					saveConfig(m.config)
+					m = refreshList(m)
+					m.popView()
 				}
+				return m, nil
+			}
+
+		case editPresetView:
+			switch msg.String() {
+			case "esc":
+				m.popView()
+				return m, nil
+			case "tab":
+				// Cycle through the name, URL and tags inputs
+				m = focusPresetInput(m, (m.focusedInput+1)%3)
+				return m, textinput.Blink
+			case "shift+tab":
+				m = focusPresetInput(m, (m.focusedInput+2)%3)
+				return m, textinput.Blink
+			case "enter":
+				// Save edited preset
+				name := strings.TrimSpace(m.nameInput.Value())
+				url := strings.TrimSpace(m.urlInput.Value())
+				if name != "" && url != "" && m.selectedIndex < len(m.config.Presets) {
+					// Keep play stats when renaming or changing the URL
+					m.config.Presets[m.selectedIndex].Name = name
+					m.config.Presets[m.selectedIndex].URL = url
+					m.config.Presets[m.selectedIndex].Tags = parseTags(m.tagsInput.Value())
+					saveConfig(m.config)
+					m = refreshList(m)
+					m.popView()
+				}
+				return m, nil
+			}
+
+		case deleteConfirmView:
+			switch msg.String() {
+			case "y", "Y":
+				// Confirm delete; indexes shift afterwards, so marks go too
+				m.config.Presets = deletePresets(m.config.Presets, m.bulkIndexes)
+				saveConfig(m.config)
+				m.clearMarks()
+				m = refreshList(m)
+				m.popView()
+				return m, nil
+			case "n", "N", "esc":
+				m.popView()
+				return m, nil
+			}
+
+		case bulkTagView:
+			switch msg.String() {
+			case "esc":
+				m.tagsInput.Blur()
+				m.popView()
+				return m, nil
+			case "enter":
+				if tags := parseTags(m.tagsInput.Value()); len(tags) > 0 {
+					for _, index := range m.bulkIndexes {
+						if index < len(m.config.Presets) {
+							addTags(&m.config.Presets[index], tags)
+						}
+					}
+					saveConfig(m.config)
+					m.clearMarks()
+					m = refreshList(m)
+				}
+				m.tagsInput.Blur()
+				m.popView()
+				return m, nil
+			}
+
+		case sleepTimerView:
+			switch msg.String() {
+			case "1", "2", "3":
+				// Arm the timer with the chosen duration
+				option := sleepTimerOptions[int(msg.String()[0]-'1')]
+				m.sleepDeadline = time.Now().Add(option)
+				// A stream left playing while browsing is stopped by it too
+				m.startSleepWatch()
+				m.popView()
+				return m, sleepTick(m.sleepDeadline)
+			case "0":
+				// Turn the timer off
+				m.sleepDeadline = time.Time{}
+				m.startSleepWatch()
+				m.popView()
+				return m, nil
+			case "esc":
+				m.popView()
+				return m, nil
+			}
+
+		case keybindingsView:
+			key := msg.String()
+			if m.capturingKey {
+				// The next key pressed becomes the action's new binding
+				m.capturingKey = false
+				if key == "esc" {
+					return m, nil
+				}
+				if err := m.config.bindKey(keyActions[m.keyCursor], key); err != nil {
+					m.keyError = err.Error()
+					return m, nil
+				}
+				m.keyError = ""
+				saveConfig(m.config)
+				m.list.SetHeight(m.listHeight())
+				return m, nil
+			}
+			switch key {
+			case "esc":
+				m.popView()
+				return m, nil
+			case "up", "k":
+				if m.keyCursor > 0 {
+					m.keyCursor--
+				}
+			case "down", "j":
+				if m.keyCursor < len(keyActions)-1 {
+					m.keyCursor++
+				}
+			case "enter":
+				m.capturingKey = true
+				m.keyError = ""
+			case "backspace", "delete":
+				// Reset to the default key
+				action := keyActions[m.keyCursor]
+				if err := m.config.bindKey(action, action.def); err != nil {
+					m.keyError = err.Error()
+					return m, nil
+				}
+				m.keyError = ""
+				saveConfig(m.config)
+				m.list.SetHeight(m.listHeight())
+			}
+			return m, nil
+
+		case loadingView:
+			switch msg.String() {
+			case "esc":
+				// Abandon the extraction and go back
+				m.cancelLoading()
+				m.alarmRamp = false
+				m.castTarget = nil
+				m.popView()
+				return m, nil
+			}
+
+		case nowPlayingView:
+			switch msg.String() {
+			case m.config.key("volume_up"), "up":
+				// The arrows work too, for keyboards where + needs shift
+				return m, changeVolume(m.volume, volumeStep)
+			case m.config.key("volume_down"), "down":
+				return m, changeVolume(m.volume, -volumeStep)
+			case m.config.key("mute"):
+				return m, toggleMute()
+			case m.config.key("pause"), " ":
+				// Space always pauses too, as in mpv itself
+				return m, togglePause()
+			case m.config.key("slower"), m.config.key("faster"), "backspace":
+				// Speed is for VODs; live streams can't get ahead
+				if m.playback.duration <= 0 {
+					return m, nil
+				}
+				switch msg.String() {
+				case m.config.key("slower"):
+					return m, changeSpeed(m.playback.speed, -speedStep)
+				case m.config.key("faster"):
+					return m, changeSpeed(m.playback.speed, speedStep)
+				}
+				return m, changeSpeed(m.playback.speed, 0)
+			case "left", "right", "shift+left", "shift+right":
+				// Skip an intro or go back a bit, where the stream allows
+				if !m.playback.seekable {
+					return m, nil
+				}
+				delta := seekStep
+				if strings.HasPrefix(msg.String(), "shift+") {
+					delta = seekJump
+				}
+				if strings.HasSuffix(msg.String(), "left") {
+					delta = -delta
+				}
+				return m, seek(delta)
+			case m.config.key("identify"):
+				// Record a sample and ask what song it is
+				if m.identifying || m.streamURL == "" {
+					return m, nil
+				}
+				m.identifying = true
+				m.identified = nil
+				return m, identifySong(m.streamURL, m.config.Identify)
+			case m.config.key("record"):
+				return m, m.toggleRecording()
+			case m.config.key("clip"):
+				return m, m.clip()
+			case m.config.key("browse"):
+				// Back to the menu with the stream still playing
+				m.detached = true
+				m.zen = false
+				m.popView()
+				return m, nil
+			case m.config.key("mixer"):
+				// Balance the stream against the ambient sound
+				m.pushView(mixerView)
+				return m, nil
+			case m.config.key("audio_output"):
+				// Pick the output to play to, e.g. an AirPlay speaker
+				m.pushView(audioDeviceView)
+				m.audioDevices, m.audioCurrent, m.audioCursor, m.audioError = nil, "", 0, ""
+				return m, fetchAudioDevices()
+			case m.config.key("track_info"):
+				// Toggle the track info pane
+				m.showTrackInfo = !m.showTrackInfo
+				return m, m.lookupTrack()
+			case m.config.key("zen"):
+				// Toggle the full screen marquee
+				m.zen = !m.zen
+				if m.zen {
+					m.zenStarted = time.Now()
+					return m, zenTick(m.zenStarted)
+				}
+				return m, nil
+			case "esc":
+				// ESC leaves zen mode before it stops playback
+				if m.zen {
+					m.zen = false
+					return m, nil
+				}
+				return m, m.stopMPV()
+			case "ctrl+c", m.config.key("stop"):
+				// Stop playback; streamEndedMsg brings us back to the menu
+				return m, m.stopMPV()
+			}
+
+		case alarmView:
+			switch msg.String() {
+			case "esc":
+				m.popView()
+				return m, nil
+			case "enter":
+				clock := strings.TrimSpace(m.alarmInput.Value())
+				if clock == "" {
+					// An empty time turns the alarm off
+					m.config.Alarm.Enabled = false
+					m.nextAlarm = time.Time{}
+					saveConfig(m.config)
+					m.popView()
+					return m, nil
+				}
+				next, err := nextAlarmTime(clock, time.Now())
+				if err != nil || m.selectedIndex >= len(m.config.Presets) {
+					return m, nil
+				}
+				m.config.Alarm.Enabled = true
+				m.config.Alarm.Time = next.Format("15:04")
+				m.config.Alarm.Preset = m.config.Presets[m.selectedIndex].Name
+				saveConfig(m.config)
+				m.nextAlarm = next
+				m.popView()
+				return m, alarmTick(m.nextAlarm)
+			}
+
+		case logView:
+			lines := len(m.shownLogLines())
+			switch msg.String() {
+			case "esc", "ctrl+l":
+				m.popView()
+			case "up", "k":
+				m.logScroll = min(m.logScroll+1, max(0, lines-m.logViewHeight()))
+			case "down", "j":
+				m.logScroll = max(0, m.logScroll-1)
+			case "pgup":
+				m.logScroll = min(m.logScroll+m.logViewHeight(), max(0, lines-m.logViewHeight()))
+			case "pgdown":
+				m.logScroll = max(0, m.logScroll-m.logViewHeight())
+			case "home", "g":
+				m.logScroll = max(0, lines-m.logViewHeight())
+			case "end", "G":
+				m.logScroll = 0
+			case "e":
+				m.logProblemsOnly = !m.logProblemsOnly
+				m.logScroll = 0
 			}
+			return m, nil
 
-		case customURLView:
+		case streamInfoView:
 			switch msg.String() {
-			case "ctrl+c", "esc":
-				m.state = mainMenuView
-				m.textInput.SetValue("")
-				return m, nil
 			case "enter":
-				url := m.textInput.Value()
-				if url != "" {
-					m.state = loadingView
-					m.loadingTitle = "Custom Stream"
-					m.textInput.SetValue("")
-					return m, tea.Batch(
-						spinner.Tick,
-						extractStreamURL(url, "Custom Stream"),
-					)
-				}
+				m.streamConfirmed = true
+				m.replaceView(loadingView)
+				return m.Update(m.pendingStream)
+			case "esc":
+				m.alarmRamp = false
+				m.popView()
+				return m, nil
 			}
+			return m, nil
 
-		case quitConfirmView:
+		case mixerView:
 			switch msg.String() {
-			case "y", "Y":
-				m.quitting = true
-				return m, tea.Quit
-			case "n", "N", "esc":
-				m.state = mainMenuView
+			case "esc":
+				if volume := int(m.ambient.volume.Load()); volume > 0 && volume != m.config.Ambient.Volume {
+					m.config.Ambient.Volume = volume
+					saveConfig(m.config)
+				}
+				m.popView()
+				return m, nil
+			case "up", "down", "tab":
+				m.mixerRow = (m.mixerRow + 1) % mixerRows
+				return m, nil
+			case "left", "-":
+				return m, m.mixerVolume(-volumeStep)
+			case "right", "+", "=":
+				return m, m.mixerVolume(volumeStep)
+			case "m":
+				return m, m.mixerMute()
+			case "n":
+				m.openAmbient()
 				return m, nil
 			}
+			return m, nil
 
-		case managePresetsView:
+		case ambientView:
 			switch msg.String() {
 			case "esc":
-				m.state = mainMenuView
+				// Keep the volume for next time
+				if volume := int(m.ambient.volume.Load()); volume > 0 && volume != m.config.Ambient.Volume {
+					m.config.Ambient.Volume = volume
+					saveConfig(m.config)
+				}
+				m.popView()
 				return m, nil
-			case "a":
-				// Add new preset
-				m.state = addPresetView
-				m.nameInput.SetValue("")
-				m.urlInput.SetValue("")
-				m.focusedInput = 0
-				m.nameInput.Focus()
-				m.urlInput.Blur()
-				return m, textinput.Blink
-			case "e":
-				// Edit selected preset
-				if preset, ok := m.list.SelectedItem().(Preset); ok {
-					m.state = editPresetView
-					m.selectedIndex = m.list.Index()
-					m.nameInput.SetValue(preset.Name)
-					m.urlInput.SetValue(preset.URL)
-					m.focusedInput = 0
-					m.nameInput.Focus()
-					m.urlInput.Blur()
-					return m, textinput.Blink
+			case "up":
+				if m.ambientCursor > 0 {
+					m.ambientCursor--
 				}
-			case "d", "x":
-				// Delete selected preset
-				m.state = deleteConfirmView
-				m.selectedIndex = m.list.Index()
 				return m, nil
-			case "r":
-				// Restore defaults
-				m.state = restoreDefaultsConfirmView
+			case "down":
+				if m.ambientCursor < len(m.ambientChoices())-1 {
+					m.ambientCursor++
+				}
+				return m, nil
+			case "+", "=":
+				m.ambient.setVolume(int(m.ambient.volume.Load()) + 5)
+				return m, nil
+			case "-":
+				m.ambient.setVolume(int(m.ambient.volume.Load()) - 5)
 				return m, nil
 			case "enter":
-				// Play selected preset from manage view
-				if preset, ok := m.list.SelectedItem().(Preset); ok {
-					m.state = loadingView
-					m.loadingTitle = preset.Name
-					return m, tea.Batch(
-						spinner.Tick,
-						extractStreamURL(preset.URL, preset.Name),
-					)
+				// Toggle the selected sound
+				m.ambientError = ""
+				choices := m.ambientChoices()
+				if m.ambientCursor >= len(choices) {
+					return m, nil
+				}
+				sound := choices[m.ambientCursor]
+				if playing, ok := m.ambient.playing(); ok && playing.id == sound.id {
+					m.ambient.stop()
+					return m, nil
 				}
+				if err := m.ambient.play(sound); err != nil {
+					slog.Warn("failed to start ambient sound", "sound", sound.id, "err", err)
+					m.ambientError = err.Error()
+				}
+				return m, nil
 			}
 
-		case addPresetView:
+		case downloadsView:
 			switch msg.String() {
 			case "esc":
-				m.state = managePresetsView
-				return m, nil
-			case "tab", "shift+tab":
-				// Toggle between name and URL inputs
-				if m.focusedInput == 0 {
-					m.focusedInput = 1
-					m.nameInput.Blur()
-					m.urlInput.Focus()
-				} else {
-					m.focusedInput = 0
-					m.nameInput.Focus()
-					m.urlInput.Blur()
+				m.popView()
+				return m, nil
+			case "up":
+				if m.downloadCursor > 0 {
+					m.downloadCursor--
 				}
-				return m, textinput.Blink
+				return m, nil
+			case "down":
+				if m.downloadCursor < len(m.downloadItems)-1 {
+					m.downloadCursor++
+				}
+				return m, nil
+			case "p":
+				if item, ok := m.selectedDownload(); ok && item.url != "" && item.state != "saved" {
+					m.downloads.togglePause(item.url)
+					m.refreshDownloads()
+				}
+				return m, nil
+			case "x":
+				item, ok := m.selectedDownload()
+				switch {
+				case !ok:
+				case item.state == "recording":
+					cmd := m.toggleRecording()
+					m.refreshDownloads()
+					return m, cmd
+				case item.url != "" && item.state != "saved":
+					m.downloads.cancel(item.url)
+					m.refreshDownloads()
+				}
+				return m, nil
 			case "enter":
-				// Save new preset
-				name := strings.TrimSpace(m.nameInput.Value())
-				url := strings.TrimSpace(m.urlInput.Value())
-				if name != "" && url != "" {
-					newPreset := Preset{Name: name, URL: url}
-					m.config.Presets = append(m.config.Presets, newPreset)
-					saveConfig(m.config)
-					m = refreshList(m)
-					m.state = managePresetsView
+				// Play a finished file
+				if item, ok := m.selectedDownload(); ok && (item.state == "saved" || item.state == "recorded") {
+					m.pushView(loadingView)
+					return m, m.startLoading(item.path, item.title, -1)
 				}
 				return m, nil
 			}
 
-		case editPresetView:
+		case recordingsView:
 			switch msg.String() {
 			case "esc":
-				m.state = managePresetsView
-				return m, nil
-			case "tab", "shift+tab":
-				// Toggle between name and URL inputs
-				if m.focusedInput == 0 {
-					m.focusedInput = 1
-					m.nameInput.Blur()
-					m.urlInput.Focus()
-				} else {
-					m.focusedInput = 0
-					m.nameInput.Focus()
-					m.urlInput.Blur()
+				m.popView()
+				return m, nil
+			case "up":
+				if m.scheduleCursor > 0 {
+					m.scheduleCursor--
 				}
-				return m, textinput.Blink
+				return m, nil
+			case "down":
+				if m.scheduleCursor < len(m.config.ScheduledRecordings)-1 {
+					m.scheduleCursor++
+				}
+				return m, nil
+			case "ctrl+d":
+				recs := m.config.ScheduledRecordings
+				if m.scheduleCursor >= len(recs) {
+					return m, nil
+				}
+				m.config.ScheduledRecordings = append(recs[:m.scheduleCursor:m.scheduleCursor], recs[m.scheduleCursor+1:]...)
+				saveConfig(m.config)
+				m.scheduleCursor = max(0, min(m.scheduleCursor, len(m.config.ScheduledRecordings)-1))
+				return m, nil
 			case "enter":
-				// Save edited preset
-				name := strings.TrimSpace(m.nameInput.Value())
-				url := strings.TrimSpace(m.urlInput.Value())
-				if name != "" && url != "" && m.selectedIndex < len(m.config.Presets) {
-					m.config.Presets[m.selectedIndex] = Preset{Name: name, URL: url}
-					saveConfig(m.config)
-					m = refreshList(m)
-					m.state = managePresetsView
+				rec, err := parseSchedule(m.scheduleInput.Value())
+				if err != nil {
+					m.scheduleError = err.Error()
+					return m, nil
 				}
+				if m.selectedIndex >= len(m.config.Presets) {
+					return m, nil
+				}
+				rec.Preset = m.config.Presets[m.selectedIndex].Name
+				m.config.ScheduledRecordings = append(m.config.ScheduledRecordings, rec)
+				saveConfig(m.config)
+				m.scheduleInput.SetValue("")
+				m.scheduleError = ""
+				m.scheduleCursor = len(m.config.ScheduledRecordings) - 1
 				return m, nil
 			}
 
-		case deleteConfirmView:
+		case restoreSessionView:
 			switch msg.String() {
-			case "y", "Y":
-				// Confirm delete
-				if m.selectedIndex < len(m.config.Presets) {
-					m.config.Presets = append(m.config.Presets[:m.selectedIndex], m.config.Presets[m.selectedIndex+1:]...)
-					saveConfig(m.config)
-					m = refreshList(m)
-				}
-				m.state = managePresetsView
-				return m, nil
+			case "y", "Y", "enter":
+				clearSession()
+				return m, m.restoreSession(m.crashedSession)
 			case "n", "N", "esc":
-				m.state = managePresetsView
+				clearSession()
+				m.popView()
 				return m, nil
 			}
 
@@ -386,13 +1813,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "y", "Y":
 				// Restore defaults
-				m.config = getDefaultConfig()
+				m.config.Presets = getDefaultConfig().Presets
 				saveConfig(m.config)
+				m.clearMarks()
 				m = refreshList(m)
-				m.state = managePresetsView
+				m.popView()
 				return m, nil
 			case "n", "N", "esc":
-				m.state = managePresetsView
+				m.popView()
 				return m, nil
 			}
 		}
@@ -403,28 +1831,58 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch m.state {
 	case mainMenuView, managePresetsView:
 		m.list, cmd = m.list.Update(msg)
+		// Start the hover timer whenever the cursor lands on another preset
+		if url := m.selectedURL(); url != m.hoverURL {
+			m.hoverURL = url
+			if url != "" {
+				cmd = tea.Batch(cmd, previewHover(url))
+			}
+		}
 	case customURLView:
 		m.textInput, cmd = m.textInput.Update(msg)
+	case alarmView:
+		m.alarmInput, cmd = m.alarmInput.Update(msg)
+	case recordingsView:
+		m.scheduleInput, cmd = m.scheduleInput.Update(msg)
 	case loadingView:
 		m.spinner, cmd = m.spinner.Update(msg)
+	case bulkTagView:
+		m.tagsInput, cmd = m.tagsInput.Update(msg)
 	case addPresetView, editPresetView:
-		if m.focusedInput == 0 {
+		switch m.focusedInput {
+		case 0:
 			m.nameInput, cmd = m.nameInput.Update(msg)
-		} else {
+		case 1:
 			m.urlInput, cmd = m.urlInput.Update(msg)
+		case 2:
+			m.tagsInput, cmd = m.tagsInput.Update(msg)
 		}
 	}
 
 	return m, cmd
 }
 
+// focusPresetInput focuses one of the add/edit inputs (0=name, 1=url, 2=tags)
+func focusPresetInput(m model, index int) model {
+	m.focusedInput = index
+	m.nameInput.Blur()
+	m.urlInput.Blur()
+	m.tagsInput.Blur()
+	switch index {
+	case 0:
+		m.nameInput.Focus()
+	case 1:
+		m.urlInput.Focus()
+	case 2:
+		m.tagsInput.Focus()
+	}
+	return m
+}
+
 // refreshList rebuilds the list from config
 func refreshList(m model) model {
-	items := make([]list.Item, len(m.config.Presets))
-	for i, preset := range m.config.Presets {
-		items[i] = preset
-	}
-	m.list.SetItems(items)
+	m.list.SetItems(sortedPresetItems(m.config.Presets, m.config.SortMode))
+	m.refreshSuggestions()
 	return m
 }
 
@@ -435,122 +1893,125 @@ func (m model) View() string {
 
 	switch m.state {
 	case mainMenuView:
-		// Reset list title for main menu
+		// Reset list title for main menu, with the Pomodoro countdown if running
 		m.list.Title = "LofiTUI - Select a Stream"
+		if m.pomodoro != nil {
+			m.list.Title += "  " + m.pomodoro.status()
+		}
+		if !m.sleepDeadline.IsZero() {
+			m.list.Title += "  " + sleepStatus(m.sleepDeadline)
+		}
+		if !m.nextAlarm.IsZero() {
+			m.list.Title += "  " + alarmStatus(m.config.Alarm)
+		}
+		if status := m.ambient.status(); status != "" {
+			m.list.Title += "  " + status
+		}
+		if m.audioOnly {
+			m.list.Title += "  ♫ audio only"
+		}
+		if m.detached {
+			m.list.Title += "  ♪ " + m.nowPlaying
+			if m.muted {
+				m.list.Title += " 🔇"
+			}
+		}
+		m.list.Title = ansi.Truncate(m.list.Title, m.width-titleStyle.GetMarginLeft(), "…")
 
-		// Show main menu with help text
-		helpText := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("240")).
-			Padding(1, 0, 0, 2).
-			Render("m=manage presets • c=custom URL • q=quit")
-		return m.list.View() + "\n" + helpText
+		if m.tooSmall() {
+			return m.minimalListView(m.list.Title, m.config.key("play")+"=play • "+m.config.key("quit")+"=quit")
+		}
 
-	case customURLView:
-		// Responsive dialog width
-		dialogWidth := m.width - 10
-		if dialogWidth < 40 {
-			dialogWidth = 40
+		// Show main menu with help text
+		help := m.mainMenuHelp(sortLabels[m.config.SortMode])
+		// An update notice takes the preview's line until it's dismissed
+		line := m.previewLine()
+		if m.updateVersion != "" {
+			line = m.updateLine()
 		}
-		if dialogWidth > 80 {
-			dialogWidth = 80
+		var suggestions string
+		if len(m.suggestions) > 0 {
+			suggestions = m.suggestionsLine() + "\n"
 		}
+		return suggestions + m.list.View() + "\n" + ansi.Truncate(line, m.width, "…") + "\n" + renderHelp(help, m.width)
 
-		style := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("170")).
-			Padding(1, 2).
-			Width(dialogWidth)
+	case customURLView:
+		// Responsive dialog width
+		dialogWidth := clampDialogWidth(m.width, 10, 40, 80)
 
 		content := fmt.Sprintf(
 			"Enter Custom YouTube URL\n\n%s\n\n%s",
 			m.textInput.View(),
-			lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Press Enter to play • ESC to cancel"),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press Enter to play • ESC to cancel"),
 		)
 
-		return lipgloss.Place(
-			m.width,
-			m.height,
-			lipgloss.Center,
-			lipgloss.Center,
-			style.Render(content),
-		)
+		return m.renderDialog(theme.Accent, dialogWidth, content)
 
 	case quitConfirmView:
 		// Responsive dialog width
-		dialogWidth := m.width - 20
-		if dialogWidth < 30 {
-			dialogWidth = 30
-		}
-		if dialogWidth > 50 {
-			dialogWidth = 50
-		}
-
-		style := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("196")).
-			Padding(1, 2).
-			Width(dialogWidth)
+		dialogWidth := clampDialogWidth(m.width, 20, 30, 50)
 
 		content := fmt.Sprintf(
 			"Are you sure you want to quit?\n\n%s",
-			lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Press Y to quit • N to cancel"),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press Y to quit • N to cancel"),
 		)
 
-		return lipgloss.Place(
-			m.width,
-			m.height,
-			lipgloss.Center,
-			lipgloss.Center,
-			style.Render(content),
-		)
+		return m.renderDialog(theme.Danger, dialogWidth, content)
 
 	case loadingView:
 		// Show loading spinner
+		dialogWidth := clampDialogWidth(m.width, 0, 50, 50)
+		title := m.loadingTitle
+		if tier, ok := findQualityTier(m.tier); ok && tier.id != "best" && !m.audioOnly {
+			title += " (" + tier.label + ")"
+		}
 		content := fmt.Sprintf(
-			"%s Loading %s...\n\nPlease wait while we fetch the stream",
+			"%s Loading %s...\n\n%s",
 			m.spinner.View(),
-			m.loadingTitle,
+			title,
+			loadingMessage(m.config.Loading, time.Since(m.loadingSince)),
 		)
+		if m.reconnects > 0 {
+			content = fmt.Sprintf(
+				"%s Reconnecting to %s…\n\nThe stream dropped, trying again (%d of %d)",
+				m.spinner.View(),
+				m.loadingTitle,
+				m.reconnects,
+				m.config.MaxReconnects,
+			)
+		}
 
-		style := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("205")).
-			Padding(1, 2).
-			Width(50)
-
-		return lipgloss.Place(
-			m.width,
-			m.height,
-			lipgloss.Center,
-			lipgloss.Center,
-			style.Render(content),
-		)
+		// Tail of yt-dlp's output, so slow extractions don't look frozen
+		if len(m.extractLog) > 0 {
+			var lines []string
+			for _, line := range m.extractLog {
+				lines = append(lines, ansi.Truncate(line, dialogWidth-4, "…"))
+			}
+			content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render(strings.Join(lines, "\n"))
+		}
+
+		return m.renderDialog(theme.Highlight, dialogWidth, content)
 
 	case managePresetsView:
 		// Update list title for manage view
 		m.list.Title = "Manage Presets"
+		if len(m.marked) > 0 {
+			m.list.Title += fmt.Sprintf(" (%d marked)", len(m.marked))
+		}
 
-		// Show list with management instructions
-		helpText := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("240")).
-			Padding(1, 0, 0, 2).
-			Render("a=add • e=edit • d=delete • r=restore defaults • Enter=play • ESC=back")
-		return m.list.View() + "\n" + helpText
-
-	case addPresetView, editPresetView:
-		dialogWidth := m.width - 10
-		if dialogWidth < 60 {
-			dialogWidth = 60
+		if m.tooSmall() {
+			return m.minimalListView(m.list.Title, m.config.key("add_preset")+"=add • "+m.config.key("edit_preset")+"=edit • "+m.config.key("delete_preset")+"=delete • ESC=back")
 		}
-		if dialogWidth > 80 {
-			dialogWidth = 80
+
+		// Show list with management instructions, or the result of an export
+		status := m.previewLine()
+		if m.notice != "" {
+			status = m.notice
 		}
+		return m.list.View() + "\n" + ansi.Truncate(status, m.width, "…") + "\n" + renderHelp(m.managePresetsHelp(), m.width)
 
-		style := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("170")).
-			Padding(1, 2).
-			Width(dialogWidth)
+	case addPresetView, editPresetView:
+		dialogWidth := clampDialogWidth(m.width, 10, 60, 80)
 
 		title := "Add New Preset"
 		if m.state == editPresetView {
@@ -558,126 +2019,508 @@ func (m model) View() string {
 		}
 
 		content := fmt.Sprintf(
-			"%s\n\nName:\n%s\n\nURL:\n%s\n\n%s",
+			"%s\n\nName:\n%s\n\nURL:\n%s\n\nTags:\n%s\n\n%s",
 			title,
 			m.nameInput.View(),
 			m.urlInput.View(),
-			lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Press Enter to save • TAB to switch fields • ESC to cancel"),
+			m.tagsInput.View(),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press Enter to save • TAB to switch fields • ESC to cancel"),
 		)
 
-		return lipgloss.Place(
-			m.width,
-			m.height,
-			lipgloss.Center,
-			lipgloss.Center,
-			style.Render(content),
-		)
+		return m.renderDialog(theme.Accent, dialogWidth, content)
 
 	case deleteConfirmView:
-		dialogWidth := m.width - 20
-		if dialogWidth < 40 {
-			dialogWidth = 40
-		}
-		if dialogWidth > 60 {
-			dialogWidth = 60
+		dialogWidth := clampDialogWidth(m.width, 20, 40, 60)
+
+		question := fmt.Sprintf("Delete %d presets?", len(m.bulkIndexes))
+		if len(m.bulkIndexes) == 1 && m.bulkIndexes[0] < len(m.config.Presets) {
+			question = fmt.Sprintf("Delete preset '%s'?", m.config.Presets[m.bulkIndexes[0]].Name)
 		}
 
-		style := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("196")).
-			Padding(1, 2).
-			Width(dialogWidth)
+		content := fmt.Sprintf(
+			"%s\n\n%s",
+			question,
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press Y to confirm • N to cancel"),
+		)
 
-		presetName := ""
-		if m.selectedIndex < len(m.config.Presets) {
-			presetName = m.config.Presets[m.selectedIndex].Name
+		return m.renderDialog(theme.Danger, dialogWidth, content)
+
+	case bulkTagView:
+		dialogWidth := clampDialogWidth(m.width, 10, 60, 80)
+
+		target := "the selected preset"
+		if len(m.bulkIndexes) > 1 {
+			target = fmt.Sprintf("%d presets", len(m.bulkIndexes))
 		}
 
 		content := fmt.Sprintf(
-			"Delete preset '%s'?\n\n%s",
-			presetName,
-			lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Press Y to confirm • N to cancel"),
+			"Add Tags\n\nTags to add to %s:\n%s\n\n%s",
+			target,
+			m.tagsInput.View(),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press Enter to save • ESC to cancel"),
 		)
 
-		return lipgloss.Place(
-			m.width,
-			m.height,
-			lipgloss.Center,
-			lipgloss.Center,
-			style.Render(content),
-		)
+		return m.renderDialog(theme.Accent, dialogWidth, content)
+
+	case restoreSessionView:
+		return m.renderDialog(theme.Accent, clampDialogWidth(m.width, 20, 50, 70), m.restoreSessionContent())
 
 	case restoreDefaultsConfirmView:
-		dialogWidth := m.width - 20
-		if dialogWidth < 50 {
-			dialogWidth = 50
+		dialogWidth := clampDialogWidth(m.width, 20, 50, 70)
+
+		content := fmt.Sprintf(
+			"Restore Default Presets?\n\n%s\n\n%s",
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("This will replace all current presets with the original 10 defaults."),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press Y to confirm • N to cancel"),
+		)
+
+		return m.renderDialog(theme.Warning, dialogWidth, content)
+
+	case sleepTimerView:
+		dialogWidth := clampDialogWidth(m.width, 20, 40, 60)
+
+		options := ""
+		for i, option := range sleepTimerOptions {
+			options += fmt.Sprintf("%d. Stop in %d minutes\n", i+1, int(option.Minutes()))
+		}
+		options += "0. Turn off"
+
+		current := "The sleep timer is off."
+		if !m.sleepDeadline.IsZero() {
+			current = fmt.Sprintf("Playback stops in %s.", formatDuration(time.Until(m.sleepDeadline)))
+		}
+
+		content := fmt.Sprintf(
+			"Sleep Timer\n\n%s\n\n%s\n\n%s",
+			lipgloss.NewStyle().Foreground(theme.Muted).Render(current),
+			options,
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press 1-3 to set • 0 to turn off • ESC to cancel"),
+		)
+
+		return m.renderDialog(theme.Info, dialogWidth, content)
+
+	case nowPlayingView:
+		if m.zen && !m.tooSmall() {
+			return m.zenView()
+		}
+
+		// VODs get a position bar, live streams just a badge
+		position := lipgloss.NewStyle().Foreground(theme.Danger).Render("● LIVE")
+		if m.playback.duration > 0 {
+			percent := m.playback.position / m.playback.duration
+			position = fmt.Sprintf(
+				"%s  %s / %s",
+				m.progress.ViewAs(percent),
+				formatDuration(time.Duration(m.playback.position)*time.Second),
+				formatDuration(time.Duration(m.playback.duration)*time.Second),
+			)
+		}
+		if m.playback.speed > 0 && m.playback.speed != 1 {
+			position += "  " + lipgloss.NewStyle().Foreground(theme.Info).Render(fmt.Sprintf("%.1f×", m.playback.speed))
+		}
+		if m.playback.paused {
+			position += "  " + lipgloss.NewStyle().Foreground(theme.Warning).Render("⏸ Paused")
+		}
+
+		var timers []string
+		if m.pomodoro != nil {
+			timers = append(timers, m.pomodoro.status())
 		}
-		if dialogWidth > 70 {
-			dialogWidth = 70
+		if !m.sleepDeadline.IsZero() {
+			timers = append(timers, sleepStatus(m.sleepDeadline))
 		}
 
-		style := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("208")).
-			Padding(1, 2).
-			Width(dialogWidth)
+		content := "Now Playing\n\n" + m.nowPlaying
+		if m.playback.track != "" {
+			content += "\n" + lipgloss.NewStyle().Foreground(theme.Accent).Render("♪ "+m.playback.track)
+		}
+		content += "\n\n" + position + "\n" + m.volumeLine()
+		if status := m.ambient.status(); status != "" {
+			content += "\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render("+ "+status)
+		}
+		if m.qualityNotice != "" {
+			content += "\n" + lipgloss.NewStyle().Foreground(theme.Warning).Render("⚠ "+m.qualityNotice)
+		}
+		if m.recording != "" {
+			content += "\n" + lipgloss.NewStyle().Foreground(theme.Danger).Render("● REC "+formatDuration(time.Since(m.recordingSince)))
+		} else if m.recordNotice != "" {
+			content += "\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render(m.recordNotice)
+		}
+
+		// Song identification result
+		switch {
+		case m.identifying:
+			content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render("Listening…")
+		case m.identified != nil && m.identified.err != nil:
+			content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Danger).Render("Couldn't identify: "+m.identified.err.Error())
+		case m.identified != nil && m.identified.song == "":
+			content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render("No match found")
+		case m.identified != nil:
+			content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Info).Render("Identified: "+m.identified.song)
+		}
+		if len(timers) > 0 {
+			content += "\n\n" + strings.Join(timers, "  ")
+		}
+		content += "\n\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render(m.nowPlayingHelp())
+
+		if m.showTrackInfo && !m.tooSmall() {
+			// Track info goes in a side pane when there's room, else below
+			dialogWidth := clampDialogWidth(m.width/2, 2, 36, 70)
+			paneWidth := m.width - dialogWidth - 5
+			if paneWidth >= 30 {
+				pane := m.trackInfoPane(paneWidth-4, m.height-6)
+				return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+					lipgloss.JoinHorizontal(lipgloss.Top,
+						m.dialogBox(theme.Highlight, dialogWidth, content),
+						" ",
+						m.dialogBox(theme.Info, paneWidth, pane),
+					))
+			}
+			content += "\n\n" + m.trackInfoPane(clampDialogWidth(m.width, 10, 40, 70)-4, m.height-lipgloss.Height(content)-8)
+		}
+
+		return m.renderDialog(theme.Highlight, clampDialogWidth(m.width, 10, 40, 70), content)
+
+	case audioDeviceView:
+		return m.renderDialog(theme.Info, clampDialogWidth(m.width, 10, 40, 70), m.audioDeviceContent())
+
+	case logView:
+		return m.fitToTerminal(m.logContent())
+
+	case qualityView:
+		return m.renderDialog(theme.Highlight, clampDialogWidth(m.width, 10, 40, 60), m.qualityContent())
+
+	case dependencyView:
+		return m.renderDialog(theme.Danger, clampDialogWidth(m.width, 10, 50, 70), m.dependencyContent())
+
+	case streamInfoView:
+		return m.renderDialog(theme.Highlight, clampDialogWidth(m.width, 10, 50, 70), m.streamInfoContent())
+
+	case mixerView:
+		return m.renderDialog(theme.Highlight, clampDialogWidth(m.width, 10, 50, 70), m.mixerContent())
 
+	case ambientView:
+		return m.renderDialog(theme.Accent, clampDialogWidth(m.width, 10, 40, 60), m.ambientContent())
+
+	case downloadsView:
+		return m.renderDialog(theme.Info, clampDialogWidth(m.width, 10, 50, 80), m.downloadsContent())
+
+	case recordingsView:
+		return m.renderDialog(theme.Danger, clampDialogWidth(m.width, 10, 50, 70), m.recordingsContent())
+
+	case castPickerView:
+		return m.renderDialog(theme.Info, clampDialogWidth(m.width, 10, 40, 60), m.castPickerContent())
+
+	case castingView:
+		state := map[string]string{"PLAYING": "▶ Playing", "PAUSED": "⏸ Paused", "BUFFERING": "Buffering…"}[m.castState]
+		if state == "" {
+			state = "Starting…"
+		}
 		content := fmt.Sprintf(
-			"Restore Default Presets?\n\n%s\n\n%s",
-			lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("This will replace all current presets with the original 10 defaults."),
-			lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Press Y to confirm • N to cancel"),
+			"Casting to %s\n\n%s\n\n%s\n%s\n\n%s",
+			m.casting.device.name,
+			m.nowPlaying,
+			lipgloss.NewStyle().Foreground(theme.Accent).Render(state),
+			m.volumeLine(),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render(m.castingHelp()),
 		)
+		return m.renderDialog(theme.Highlight, clampDialogWidth(m.width, 10, 40, 70), content)
+
+	case keybindingsView:
+		return m.renderDialog(theme.Accent, clampDialogWidth(m.width, 10, 50, 60), m.keybindingsContent())
+
+	case alarmView:
+		dialogWidth := clampDialogWidth(m.width, 20, 40, 60)
 
-		return lipgloss.Place(
-			m.width,
-			m.height,
-			lipgloss.Center,
-			lipgloss.Center,
-			style.Render(content),
+		presetName := ""
+		if m.selectedIndex < len(m.config.Presets) {
+			presetName = m.config.Presets[m.selectedIndex].Name
+		}
+
+		content := fmt.Sprintf(
+			"Wake up to '%s' at:\n\n%s\n\n%s\n\n%s",
+			presetName,
+			m.alarmInput.View(),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render(fmt.Sprintf("Volume ramps up to %d%% over %d minutes.", m.config.Alarm.Volume, m.config.Alarm.RampMinutes)),
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("Press Enter to set • clear and Enter to turn off • ESC to cancel"),
 		)
+
+		return m.renderDialog(theme.Alarm, dialogWidth, content)
 	}
 
 	return ""
 }
 
-// extractStreamURL extracts the actual stream URL using yt-dlp
-func extractStreamURL(youtubeURL string, title string) tea.Cmd {
+// extractStreamURL extracts the actual stream URL using yt-dlp, sending
+// its progress lines to logLines (closed when yt-dlp exits)
+func extractStreamURL(ctx context.Context, id int, youtubeURL string, title string, format string, logLines chan<- string) tea.Cmd {
 	return func() tea.Msg {
-		cmd := exec.Command("yt-dlp", "-f", "best", "-g", youtubeURL)
-		output, err := cmd.Output()
+		defer close(logLines)
+		streamURL, err := resolveStreamFormat(ctx, youtubeURL, format, &lineWriter{lines: logLines})
 		if err != nil {
-			return streamURLMsg{err: err}
+			return streamURLMsg{id: id, err: err}
 		}
-		streamURL := strings.TrimSpace(string(output))
-		return streamURLMsg{url: streamURL, title: title}
+		return streamURLMsg{id: id, url: streamURL, title: title}
 	}
 }
 
-// playMPV launches mpv with the extracted stream URL
-func playMPV(streamURL string, title string) tea.Cmd {
+// resolveStreamURL asks yt-dlp for the direct stream URL of a page,
+// writing its progress output to stderr if given
+func resolveStreamURL(ctx context.Context, pageURL string, stderr io.Writer) (string, error) {
+	return resolveStreamFormat(ctx, pageURL, "best", stderr)
+}
+
+// resolveStreamFormat is resolveStreamURL for a yt-dlp format other than
+// the best, e.g. a smaller one for a slow connection
+func resolveStreamFormat(ctx context.Context, pageURL, format string, stderr io.Writer) (string, error) {
+	// Local files, e.g. recordings, play as they are
+	if info, err := os.Stat(pageURL); err == nil && info.Mode().IsRegular() {
+		return pageURL, nil
+	}
+	if path, ok := offlineFallback(pageURL); ok {
+		if stderr != nil {
+			fmt.Fprintln(stderr, "Offline; playing the saved copy")
+		}
+		return path, nil
+	}
+	started := time.Now()
+	slog.Info("extracting stream", "url", pageURL, "format", format)
+	streamURL, err := lofitui.ResolveStream(ctx, pageURL, format, stderr)
+	if err != nil {
+		attrs := []any{"url", pageURL, "err", err, "elapsed", time.Since(started)}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			attrs = append(attrs, "output", lastLines(string(exitErr.Stderr), 5))
+		}
+		slog.Error("extraction failed", attrs...)
+		return "", err
+	}
+	slog.Info("extracted stream", "url", pageURL, "elapsed", time.Since(started))
+	return streamURL, nil
+}
+
+// waitForExtractLog waits for the next line of yt-dlp output
+func waitForExtractLog(id int, logLines <-chan string) tea.Cmd {
+	return func() tea.Msg {
+		line, ok := <-logLines
+		if !ok {
+			return nil
+		}
+		return extractLogMsg{id: id, line: line}
+	}
+}
+
+// lineWriter splits written output into lines and sends the interesting
+// ones to a channel, dropping them rather than blocking if nobody reads
+type lineWriter struct {
+	lines   chan<- string
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexAny(w.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+		// Skip blank lines and yt-dlp's debug dump
+		if line == "" || strings.HasPrefix(line, "[debug]") {
+			continue
+		}
+		select {
+		case w.lines <- line:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// mpvArgs builds the mpv arguments shared by both playback modes
+func mpvArgs(streamURL string, title string, modeArgs []string, extraArgs []string) []string {
+	args := append(modeArgs, "--force-media-title="+title)
+	args = append(args, audioClientArgs()...)
+	args = append(args, nowPlayingArgs()...)
+	args = append(args, extraArgs...)
+	return append(args, streamURL)
+}
+
+// logPlayerExit logs how mpv ended
+func logPlayerExit(title string, err error) {
+	if err != nil {
+		slog.Error("mpv failed", "title", title, "err", err)
+		return
+	}
+	slog.Info("stream ended", "title", title)
+}
+
+// playMPV hands the terminal to the player with the extracted stream URL,
+// rendering the video as text if video is set
+func playMPV(player backend, streamURL, pageURL, title string, video bool, extraArgs ...string) tea.Cmd {
 	return tea.ExecProcess(
-		exec.Command("mpv", "--vo=tct", "--quiet", "--script=/etc/mpv/scripts/mpris.so", "--force-media-title="+title, streamURL),
+		player.command(streamURL, pageURL, title, video, extraArgs),
 		func(err error) tea.Msg {
 			// Stream ended (user quit mpv or it errored)
-			return streamEndedMsg{}
+			logPlayerExit(title, err)
+			return streamEndedMsg{err: err}
 		},
 	)
 }
 
+// startMPV runs mpv in the background, leaving the terminal to the TUI.
+// load identifies the stream when it ends.
+func startMPV(load int, streamURL string, title string, extraArgs ...string) tea.Cmd {
+	return func() tea.Msg {
+		slog.Info("starting mpv in the background", "title", title)
+		mpv, err := lofitui.Start(getMPVSocketPath(), mpvArgs(streamURL, title, []string{"--no-video", "--no-terminal"}, extraArgs)...)
+		if err == nil {
+			// Blocks until the stream ends (user stopped it or it errored)
+			err = mpv.Wait()
+		}
+		logPlayerExit(title, err)
+		return streamEndedMsg{err: err, load: load}
+	}
+}
+
+// stopStreamWatchers stops the watchers of the stream that just ended,
+// the timers' included
+func (m *model) stopStreamWatchers() {
+	if m.stopWatchers != nil {
+		m.stopWatchers()
+		m.stopWatchers = nil
+	}
+	if m.stopSleepWatch != nil {
+		m.stopSleepWatch()
+		m.stopSleepWatch = nil
+	}
+	if m.stopPomodoroWatch != nil {
+		m.stopPomodoroWatch()
+		m.stopPomodoroWatch = nil
+	}
+}
+
+// stopMPV asks the running mpv instance to quit
+func (m model) stopMPV() tea.Cmd {
+	stream := m.stream
+	return func() tea.Msg {
+		_ = stream.stop()
+		return nil
+	}
+}
+
 func main() {
 	// Parse flags
 	versionFlag := flag.Bool("version", false, "Print version information")
 	flag.BoolVar(versionFlag, "v", false, "Print version information (shorthand)")
-	flag.Parse()
+	randomFlag := flag.Bool("random", false, "Play a random preset on startup")
+	flag.BoolVar(&audioOnly, "audio-only", false, "Never render video, whatever the config says")
+	flag.BoolVar(&snapcast, "snapcast", false, "Play to the Snapcast server's pipe, whatever the config says")
+	noTUIFlag := flag.Bool("no-tui", false, "Play a preset or URL with plain line output instead of the TUI")
+	jsonFlag := flag.Bool("json", false, "With -no-tui, print JSON events instead of plain lines")
+	addGlobalFlags(flag.CommandLine)
+	flag.Usage = func() {
+		printUsage(flag.CommandLine.Output())
+	}
+	// Exit code 2 means a failed extraction here, so handle bad flags ourselves
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		os.Exit(parseFailed(err))
+	}
+	if err := applyGlobalFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		os.Exit(exitUsage)
+	}
 
 	if *versionFlag {
 		fmt.Printf("lofitui %s\ncommit: %s\nbuilt: %s\n", version, commit, date)
-		os.Exit(0)
+		os.Exit(exitOK)
+	}
+
+	if *noTUIFlag {
+		os.Exit(runHeadless(strings.TrimSpace(strings.Join(flag.Args(), " ")), *randomFlag, *jsonFlag))
+	}
+
+	// Subcommands run without the TUI; a URL is played in it
+	if flag.NArg() > 0 && !strings.Contains(flag.Arg(0), "://") {
+		os.Exit(runCommand(flag.Args()))
+	}
+
+	// Skip the menu and go straight to loading; ESC still leads back to it
+	m := initialModel()
+	if missing := missingDependencies(m.config); len(missing) > 0 {
+		// Say what's missing up front rather than failing to load
+		m.missing = missing
+		m.pushView(dependencyView)
+	} else if flag.NArg() > 0 {
+		m.pushView(loadingView)
+		m.startCmd = m.startLoading(flag.Arg(0), "Custom Stream", -1)
+	} else if *randomFlag && len(m.config.Presets) > 0 {
+		index := rand.Intn(len(m.config.Presets))
+		m.pushView(loadingView)
+		m.startCmd = m.startLoading(m.config.Presets[index].URL, m.config.Presets[index].Name, index)
+	} else if session, ok := loadCrashedSession(); ok {
+		// The last run crashed or was killed while playing
+		m.crashedSession = session
+		m.pushView(restoreSessionView)
+	}
+
+	// In single-instance mode a lofitui already running plays the stream
+	var instance net.Listener
+	if m.config.SingleInstance {
+		listener, err := claimInstance()
+		if errors.Is(err, errInstanceRunning) {
+			target := flag.Arg(0)
+			if m.loadingURL != "" {
+				target = m.loadingURL
+			}
+			os.Exit(handOffOrRefuse(target))
+		}
+		if err != nil {
+			slog.Warn("couldn't claim the single instance", "err", err)
+		}
+		instance = listener
 	}
 
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	go watchAutoPause(m.config.AutoPause)
+	go watchDucking(m.config.Ducking, m.ambient)
+	go runRecordingScheduler(context.Background(), false)
+	// Ask mpv about its media controls now rather than on the first play
+	go nowPlayingArgs()
+	stream := m.stream
+	mpris := startMPRISOrLog(mprisControls{
+		status: stream.status,
+		stop:   stream.stop,
+		next:   func() error { return stream.skipBy(1) },
+		prev:   func() error { return stream.skipBy(-1) },
+	})
+	p := tea.NewProgram(crashGuard{m}, tea.WithAltScreen())
+	if instance != nil {
+		go serveHandoffs(instance, m.handoff, m.stream, p.Send)
+		defer os.Remove(instanceSocketPath())
+		defer instance.Close()
+	}
+	_, err := p.Run()
+	mpris.close()
+	m.ambient.stop()
+	// Quitting from the menu leaves a stream playing in the background
+	if title, _ := m.stream.current(); title != "" {
+		_ = quitMPV()
+	}
+	quitFading()
+	if errors.Is(err, tea.ErrProgramPanic) {
+		// Bubble Tea has put the terminal back; the session is kept so the
+		// next run offers to pick up where this one crashed
+		if path, ok := reportedCrash(); ok {
+			fmt.Fprintf(os.Stderr, "\nlofitui crashed. A crash report was written to %s\n", path)
+			fmt.Fprintln(os.Stderr, "Please attach it to an issue at https://github.com/willyv3/lofitui/issues")
+		}
+		os.Exit(exitError)
+	}
+	clearSession()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// The mixer's rows: the stream and the ambient sound layered under it
+const (
+	mixerStream = iota
+	mixerAmbient
+	mixerRows
+)
+
+// mixerVolume raises or lowers the selected layer's volume by delta
+func (m *model) mixerVolume(delta int) tea.Cmd {
+	if m.mixerRow == mixerStream {
+		return changeVolume(m.volume, float64(delta))
+	}
+	m.ambient.setVolume(int(m.ambient.volume.Load()) + delta)
+	return nil
+}
+
+// mixerMute mutes or unmutes the selected layer
+func (m *model) mixerMute() tea.Cmd {
+	if m.mixerRow == mixerStream {
+		return toggleMute()
+	}
+	m.ambient.muted.Store(!m.ambient.muted.Load())
+	return nil
+}
+
+// mixerContent renders the mixer: a volume for each layer
+func (m model) mixerContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	bar := progress.New(progress.WithSolidFill(string(theme.Info)), progress.WithoutPercentage(), progress.WithWidth(16))
+
+	ambientName, ambientVolume, ambientMuted := "nothing", float64(m.ambient.volume.Load()), m.ambient.muted.Load()
+	if sound, ok := m.ambient.playing(); ok {
+		ambientName = sound.icon + " " + sound.name
+	}
+	layers := []struct {
+		label, name string
+		volume      float64
+		muted       bool
+	}{
+		{"Stream", m.nowPlaying, m.volume, m.muted},
+		{"Ambient", ambientName, ambientVolume, ambientMuted},
+	}
+
+	var rows []string
+	for i, layer := range layers {
+		level := bar
+		amount := fmt.Sprintf(" %3.0f%%", layer.volume)
+		if layer.muted {
+			level.FullColor = string(theme.Muted)
+			amount = " " + lipgloss.NewStyle().Foreground(theme.Danger).Render("MUTED")
+		}
+		name := ansi.Truncate(layer.name, 24, "…")
+		row := fmt.Sprintf("%-8s %s %s%s", layer.label, padRight(name, 24), level.ViewAs(layer.volume/100), amount)
+		if i == m.mixerRow {
+			row = lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• ") + row
+		} else {
+			row = "  " + row
+		}
+		rows = append(rows, row)
+	}
+
+	help := "↑/↓ choose • ←/→ volume • m mute • n ambient sound • ESC to go back"
+	return "Mixer\n\n" + strings.Join(rows, "\n") + "\n\n" + muted.Render(help)
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MPRIS2 object path and interfaces
+const (
+	mprisPath            = dbusObjectPath("/org/mpris/MediaPlayer2")
+	mprisNoTrack         = dbusObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+	mprisInterface       = "org.mpris.MediaPlayer2"
+	mprisPlayer          = "org.mpris.MediaPlayer2.Player"
+	dbusProperties       = "org.freedesktop.DBus.Properties"
+	dbusIntrospectable   = "org.freedesktop.DBus.Introspectable"
+	dbusPeer             = "org.freedesktop.DBus.Peer"
+	dbusUnknownMethod    = "org.freedesktop.DBus.Error.UnknownMethod"
+	dbusInvalidArgs      = "org.freedesktop.DBus.Error.InvalidArgs"
+	dbusFailed           = "org.freedesktop.DBus.Error.Failed"
+	dbusNotSupported     = "org.freedesktop.DBus.Error.NotSupported"
+	dbusNamePrimaryOwner = 1
+)
+
+// mprisPoll is how often mpv is checked for changes to signal
+const mprisPoll = time.Second
+
+// mprisIntrospection describes our object to D-Bus tools
+const mprisIntrospection = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect"><arg name="data" type="s" direction="out"/></method>
+  </interface>
+  <interface name="org.freedesktop.DBus.Peer">
+    <method name="Ping"/>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface" type="s"/>
+      <arg name="changed" type="a{sv}"/>
+      <arg name="invalidated" type="as"/>
+    </signal>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2">
+    <method name="Raise"/>
+    <method name="Quit"/>
+    <property name="CanQuit" type="b" access="read"/>
+    <property name="CanRaise" type="b" access="read"/>
+    <property name="HasTrackList" type="b" access="read"/>
+    <property name="Identity" type="s" access="read"/>
+    <property name="SupportedUriSchemes" type="as" access="read"/>
+    <property name="SupportedMimeTypes" type="as" access="read"/>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2.Player">
+    <method name="Next"/>
+    <method name="Previous"/>
+    <method name="Pause"/>
+    <method name="PlayPause"/>
+    <method name="Stop"/>
+    <method name="Play"/>
+    <method name="Seek"><arg name="Offset" type="x" direction="in"/></method>
+    <method name="SetPosition">
+      <arg name="TrackId" type="o" direction="in"/>
+      <arg name="Position" type="x" direction="in"/>
+    </method>
+    <method name="OpenUri"><arg name="Uri" type="s" direction="in"/></method>
+    <signal name="Seeked"><arg name="Position" type="x"/></signal>
+    <property name="PlaybackStatus" type="s" access="read"/>
+    <property name="Rate" type="d" access="read"/>
+    <property name="Metadata" type="a{sv}" access="read"/>
+    <property name="Volume" type="d" access="readwrite"/>
+    <property name="Position" type="x" access="read"/>
+    <property name="MinimumRate" type="d" access="read"/>
+    <property name="MaximumRate" type="d" access="read"/>
+    <property name="CanGoNext" type="b" access="read"/>
+    <property name="CanGoPrevious" type="b" access="read"/>
+    <property name="CanPlay" type="b" access="read"/>
+    <property name="CanPause" type="b" access="read"/>
+    <property name="CanSeek" type="b" access="read"/>
+    <property name="CanControl" type="b" access="read"/>
+  </interface>
+</node>`
+
+// mprisControls is how MPRIS sees and drives whoever owns the player. Play
+// and pause go straight to mpv.
+type mprisControls struct {
+	status     func() daemonStatus
+	stop       func() error
+	next, prev func() error // nil when there's nothing to skip to
+}
+
+// mprisServer is lofitui's MPRIS2 player on the session bus, so media
+// keys, playerctl and desktop media widgets can control it
+type mprisServer struct {
+	bus      *dbusConn
+	controls mprisControls
+	done     chan struct{}
+}
+
+// startMPRIS registers lofitui as an MPRIS player. Each process gets its
+// own name, so the TUI and the daemon can both show up.
+func startMPRIS(controls mprisControls) (*mprisServer, error) {
+	bus, err := dbusSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s.lofitui.instance%d", mprisInterface, os.Getpid())
+	reply, err := bus.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RequestName",
+		name, uint32(4)) // DBUS_NAME_FLAG_DO_NOT_QUEUE
+	if err != nil {
+		bus.conn.Close()
+		return nil, err
+	}
+	if len(reply) != 1 || reply[0] != uint32(dbusNamePrimaryOwner) {
+		bus.conn.Close()
+		return nil, fmt.Errorf("%s is taken", name)
+	}
+
+	s := &mprisServer{bus: bus, controls: controls, done: make(chan struct{})}
+	go s.serve()
+	go s.watch()
+	slog.Info("registered MPRIS player", "name", name)
+	return s, nil
+}
+
+// startMPRISOrLog starts MPRIS if there's a session bus, logging why not
+// otherwise; playback works the same either way
+func startMPRISOrLog(controls mprisControls) *mprisServer {
+	s, err := startMPRIS(controls)
+	if errors.Is(err, errNoSessionBus) {
+		slog.Debug("MPRIS disabled", "err", err)
+	} else if err != nil {
+		slog.Warn("failed to start MPRIS", "err", err)
+	}
+	return s
+}
+
+// close takes the player off the bus
+func (s *mprisServer) close() {
+	if s == nil {
+		return
+	}
+	close(s.done)
+	s.bus.conn.Close()
+}
+
+// serve answers method calls until the connection closes
+func (s *mprisServer) serve() {
+	for {
+		msg, err := s.bus.read()
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				slog.Warn("lost the session bus", "err", err)
+			}
+			return
+		}
+		if msg.kind != dbusMethodCall {
+			continue
+		}
+		// Next and Previous load a stream, which takes a while
+		go s.handle(msg)
+	}
+}
+
+// handle answers one method call
+func (s *mprisServer) handle(call *dbusMessage) {
+	if call.path != mprisPath {
+		_ = s.bus.replyError(call, dbusUnknownMethod, "no such object "+string(call.path))
+		return
+	}
+
+	var body []interface{}
+	var err error
+	switch call.iface + "." + call.member {
+	case dbusIntrospectable + ".Introspect":
+		body = []interface{}{mprisIntrospection}
+	case dbusPeer + ".Ping", mprisInterface + ".Raise", mprisInterface + ".Quit":
+	case dbusProperties + ".Get":
+		iface, _ := argString(call, 0)
+		name, _ := argString(call, 1)
+		value, ok := s.properties(iface)[name]
+		if !ok {
+			_ = s.bus.replyError(call, dbusInvalidArgs, "no property "+name+" on "+iface)
+			return
+		}
+		body = []interface{}{dbusVariant{value}}
+	case dbusProperties + ".GetAll":
+		iface, _ := argString(call, 0)
+		body = []interface{}{s.properties(iface)}
+	case dbusProperties + ".Set":
+		iface, _ := argString(call, 0)
+		name, _ := argString(call, 1)
+		volume, ok := argFloat(call, 2)
+		if iface != mprisPlayer || name != "Volume" || !ok {
+			_ = s.bus.replyError(call, dbusInvalidArgs, "can't set "+name+" on "+iface)
+			return
+		}
+		err = mpvSetProperty("volume", max(0, min(100, volume*100)))
+	case mprisPlayer + ".Play":
+		err = mpvSetProperty("pause", false)
+	case mprisPlayer + ".Pause":
+		err = mpvSetProperty("pause", true)
+	case mprisPlayer + ".PlayPause":
+		_, err = mpvCommand("cycle", "pause")
+	case mprisPlayer + ".Stop":
+		err = s.controls.stop()
+	case mprisPlayer + ".Next":
+		if s.controls.next != nil {
+			err = s.controls.next()
+		}
+	case mprisPlayer + ".Previous":
+		if s.controls.prev != nil {
+			err = s.controls.prev()
+		}
+	case mprisPlayer + ".Seek", mprisPlayer + ".SetPosition":
+		// Live streams can't seek; CanSeek says so
+	case mprisPlayer + ".OpenUri":
+		_ = s.bus.replyError(call, dbusNotSupported, "lofitui plays its presets")
+		return
+	default:
+		_ = s.bus.replyError(call, dbusUnknownMethod, "unknown method "+call.iface+"."+call.member)
+		return
+	}
+	if err != nil {
+		slog.Warn("MPRIS call failed", "method", call.member, "err", err)
+		_ = s.bus.replyError(call, dbusFailed, err.Error())
+		return
+	}
+	_ = s.bus.reply(call, body...)
+}
+
+// argString returns a call's i-th argument as a string
+func argString(call *dbusMessage, i int) (string, bool) {
+	if i >= len(call.body) {
+		return "", false
+	}
+	s, ok := call.body[i].(string)
+	return s, ok
+}
+
+// argFloat returns a call's i-th argument as a float
+func argFloat(call *dbusMessage, i int) (float64, bool) {
+	if i >= len(call.body) {
+		return 0, false
+	}
+	f, ok := call.body[i].(float64)
+	return f, ok
+}
+
+// properties returns an interface's properties, nil for unknown ones
+func (s *mprisServer) properties(iface string) map[string]interface{} {
+	switch iface {
+	case mprisInterface:
+		return map[string]interface{}{
+			"CanQuit":             false,
+			"CanRaise":            false,
+			"HasTrackList":        false,
+			"Identity":            "lofitui",
+			"SupportedUriSchemes": []string{},
+			"SupportedMimeTypes":  []string{},
+		}
+	case mprisPlayer:
+		status := s.controls.status()
+		playback := "Stopped"
+		switch status.State {
+		case "playing":
+			playback = "Playing"
+		case "paused":
+			playback = "Paused"
+		}
+		return map[string]interface{}{
+			"PlaybackStatus": playback,
+			"Rate":           1.0,
+			"MinimumRate":    1.0,
+			"MaximumRate":    1.0,
+			"Metadata":       mprisMetadata(status),
+			"Volume":         status.Volume / 100,
+			"Position":       int64(status.Position * 1e6),
+			"CanGoNext":      s.controls.next != nil,
+			"CanGoPrevious":  s.controls.prev != nil,
+			"CanPlay":        status.State != "stopped",
+			"CanPause":       status.State != "stopped",
+			"CanSeek":        false,
+			"CanControl":     true,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// mprisMetadata describes what's playing: the track if the stream says,
+// with the stream as its album, else just the stream
+func mprisMetadata(status daemonStatus) map[string]interface{} {
+	if status.State == "stopped" {
+		return map[string]interface{}{"mpris:trackid": mprisNoTrack}
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(status.Title + "\x00" + status.Track))
+	metadata := map[string]interface{}{
+		"mpris:trackid": dbusObjectPath(fmt.Sprintf("/org/lofitui/track/%x", hash.Sum64())),
+		"xesam:title":   status.Title,
+	}
+	if status.Track != "" {
+		metadata["xesam:title"] = status.Track
+		metadata["xesam:album"] = status.Title
+		// Radio streams usually say "Artist - Title"
+		if artist, title, ok := strings.Cut(status.Track, " - "); ok {
+			metadata["xesam:artist"] = []string{artist}
+			metadata["xesam:title"] = title
+		}
+	}
+	if status.URL != "" {
+		metadata["xesam:url"] = status.URL
+		if art := youtubeThumbnail(status.URL); art != "" {
+			metadata["mpris:artUrl"] = art
+		}
+	}
+	if status.Duration > 0 {
+		metadata["mpris:length"] = int64(status.Duration * 1e6)
+	}
+	return metadata
+}
+
+// youtubeThumbnail returns the thumbnail of a YouTube video URL, or "" if
+// the URL doesn't name a video (e.g. a channel's /live page)
+func youtubeThumbnail(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	var id string
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	switch {
+	case host == "youtu.be":
+		id = strings.Trim(u.Path, "/")
+	case strings.HasSuffix(host, "youtube.com"):
+		id = u.Query().Get("v")
+		for _, prefix := range []string{"/live/", "/shorts/", "/embed/"} {
+			if rest, ok := strings.CutPrefix(u.Path, prefix); ok {
+				id = strings.Trim(rest, "/")
+			}
+		}
+	}
+	if id == "" || strings.Contains(id, "/") {
+		return ""
+	}
+	return "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"
+}
+
+// watch signals property changes until the server closes. mpv doesn't
+// tell us about changes made elsewhere (the TUI, ctl, a stream ending),
+// so it's polled. Position is left out, as clients extrapolate it.
+func (s *mprisServer) watch() {
+	var last map[string]interface{}
+	ticker := time.NewTicker(mprisPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		current := s.properties(mprisPlayer)
+		delete(current, "Position")
+		changed := map[string]interface{}{}
+		for name, value := range current {
+			if !reflect.DeepEqual(last[name], value) {
+				changed[name] = value
+			}
+		}
+		last = current
+		if len(changed) == 0 {
+			continue
+		}
+		if err := s.bus.emit(mprisPath, dbusProperties, "PropertiesChanged", mprisPlayer, changed, []string{}); err != nil {
+			return
+		}
+	}
+}
+
+// mprisStream is the stream the TUI or -no-tui is playing, for MPRIS to
+// report. The daemon keeps its own.
+type mprisStream struct {
+	mu         sync.Mutex
+	title, url string
+	stopped    bool // Playback was stopped on purpose
+	skip       int  // Presets to skip once mpv has quit, for Next and Previous
+}
+
+// set records the stream that's starting, or "" when it stops
+func (s *mprisStream) set(title, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.title, s.url = title, url
+}
+
+// current returns the stream playing, "" between streams
+func (s *mprisStream) current() (title, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.title, s.url
+}
+
+// status asks mpv how the stream is doing
+func (s *mprisStream) status() daemonStatus {
+	s.mu.Lock()
+	title, url := s.title, s.url
+	s.mu.Unlock()
+	// Between streams there's no mpv to ask
+	if idle, err := mpvGetBool("idle-active"); err != nil || idle {
+		title = ""
+	}
+	status := mpvStatus(title)
+	if title != "" {
+		status.URL = url
+	}
+	return status
+}
+
+// stop quits mpv, noting it was on purpose so it isn't taken for the
+// stream dropping
+func (s *mprisStream) stop() error {
+	return s.end(true, 0)
+}
+
+// skipBy quits mpv, asking for the preset step places away to be played
+// next. The TUI can't be told directly while mpv has the terminal, so it
+// picks this up when the stream ends.
+func (s *mprisStream) skipBy(step int) error {
+	return s.end(false, step)
+}
+
+// end quits mpv, leaving why for takeEnd
+func (s *mprisStream) end(stopped bool, skip int) error {
+	s.mu.Lock()
+	s.stopped, s.skip = stopped, skip
+	s.mu.Unlock()
+	if err := quitMPV(); err != nil {
+		s.takeEnd()
+		return fmt.Errorf("nothing is playing")
+	}
+	return nil
+}
+
+// takeEnd returns and clears why mpv was quit: stopped, or skipping to
+// another preset. Neither means it ended by itself.
+func (s *mprisStream) takeEnd() (stopped bool, skip int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stopped, skip = s.stopped, s.skip
+	s.stopped, s.skip = false, 0
+	return stopped, skip
+}
+
+// quitMPV stops the TUI's or -no-tui's mpv, which ends playback there
+func quitMPV() error {
+	_, err := mpvCommand("quit")
+	return err
+}
+
+// mpvScriptDirs are where mpv loads scripts from by itself
+func mpvScriptDirs() []string {
+	var dirs []string
+	switch {
+	case os.Getenv("MPV_HOME") != "":
+		dirs = append(dirs, filepath.Join(os.Getenv("MPV_HOME"), "scripts"))
+	case os.Getenv("XDG_CONFIG_HOME") != "":
+		dirs = append(dirs, filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "mpv", "scripts"))
+	default:
+		if home, err := os.UserHomeDir(); err == nil {
+			dirs = append(dirs, filepath.Join(home, ".config", "mpv", "scripts"))
+		}
+	}
+	return append(dirs, "/etc/mpv/scripts", "/usr/local/etc/mpv/scripts")
+}
+
+// findMPRISScript returns the mpv-mpris script mpv loads by itself, if
+// it's installed in a scripts directory
+func findMPRISScript() (string, bool) {
+	for _, dir := range mpvScriptDirs() {
+		path := filepath.Join(dir, "mpris.so")
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// checkMPRISScript warns when mpv-mpris would put a second player next to
+// lofitui's own in the media controls
+func checkMPRISScript() doctorCheck {
+	check := doctorCheck{name: "mpris"}
+	if path, ok := findMPRISScript(); ok {
+		check.status, check.detail = "warn", "mpv loads "+path+", so media controls show mpv as well as lofitui"
+		check.fix = "lofitui has MPRIS built in; remove the script, or uninstall mpv-mpris, unless you want it for mpv on its own"
+		return check
+	}
+	check.status, check.detail = "ok", "built in"
+	return check
+}
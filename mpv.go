@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/willyv3/lofitui/pkg/lofitui"
+)
+
+// mpvIPCTimeout bounds how long a single IPC round trip may take
+const mpvIPCTimeout = 2 * time.Second
+
+// getMPVSocketPath returns the IPC socket path for this process's mpv instance
+func getMPVSocketPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("lofitui-%d.sock", os.Getpid()))
+}
+
+// player controls this process's mpv instance
+func player() lofitui.Player {
+	return lofitui.Player{Socket: getMPVSocketPath(), Timeout: mpvIPCTimeout}
+}
+
+// configArgs are the mpv options the config asks for: where to play to,
+// then the user's own mpv_args. They go before anything lofitui adds for
+// one stream, such as where to start, so those win.
+func configArgs(config *Config) []string {
+	args := append([]string(nil), outputArgs(config)...)
+	return append(args, config.MPVArgs...)
+}
+
+// presetArgs are the own mpv_args of the preset playing url, if it's a
+// preset's. They go after the config's, so they win.
+func presetArgs(config *Config, url string) []string {
+	for _, preset := range config.Presets {
+		if preset.URL == url {
+			return preset.MPVArgs
+		}
+	}
+	return nil
+}
+
+// argsVolume is the volume the last --volume in args sets, if any
+func argsVolume(args []string) (float64, bool) {
+	for i := len(args) - 1; i >= 0; i-- {
+		value, ok := strings.CutPrefix(args[i], "--volume=")
+		if !ok {
+			continue
+		}
+		volume, err := strconv.ParseFloat(value, 64)
+		return volume, err == nil
+	}
+	return 0, false
+}
+
+// mpvCommand sends a command to the running mpv instance and returns its data
+func mpvCommand(args ...interface{}) (interface{}, error) {
+	return player().Command(args...)
+}
+
+// mpvCommandWithin is mpvCommand for commands that may take longer than a
+// round trip, such as writing out the cache
+func mpvCommandWithin(timeout time.Duration, args ...interface{}) (interface{}, error) {
+	return player().CommandWithin(timeout, args...)
+}
+
+// mpvSetProperty sets a property on the running mpv instance
+func mpvSetProperty(name string, value interface{}) error {
+	return player().SetProperty(name, value)
+}
+
+// mpvGetFloat reads a numeric property from the running mpv instance
+func mpvGetFloat(name string) (float64, error) {
+	return player().Float(name)
+}
+
+// mpvGetString reads a string property from the running mpv instance
+func mpvGetString(name string) (string, error) {
+	return player().String(name)
+}
+
+// mpvGetBool reads a yes/no property from the running mpv instance
+func mpvGetBool(name string) (bool, error) {
+	return player().Bool(name)
+}
+
+// mpvShowText displays a message on mpv's OSD
+func mpvShowText(text string) error {
+	_, err := mpvCommand("show-text", text, 3000)
+	return err
+}
+
+// currentTrack returns the title of the song playing now: the ICY title
+// radio streams send with each song, else the chapter title of mixes
+func currentTrack() string {
+	if title, err := mpvGetString("metadata/by-key/icy-title"); err == nil && title != "" {
+		return title
+	}
+	title, _ := mpvGetString("chapter-metadata/by-key/title")
+	return title
+}
+
+// playbackStatusMsg carries the player's position for the Now Playing view
+type playbackStatusMsg struct {
+	poll      int     // Matches model.pollID of the polling that read it
+	position  float64 // Seconds into the stream
+	duration  float64 // Total length in seconds, zero for live streams
+	track     string  // Title of the song playing now, empty if the stream doesn't say
+	stalled   bool    // mpv is waiting for its cache to fill
+	paused    bool    // Paused by the user, not waiting for its cache
+	seekable  bool    // VODs are, live streams usually aren't
+	speed     float64 // 1 at normal speed
+	recording string  // File mpv is recording the stream to, empty when it isn't
+	volume    volumeMsg
+}
+
+// seekStep and seekJump are how far the arrow keys seek, without and
+// with shift
+const (
+	seekStep = 10 * time.Second
+	seekJump = time.Minute
+)
+
+// seekedMsg reports mpv's position after a seek
+type seekedMsg struct {
+	position float64
+}
+
+// seek moves the position by delta, forwards or back
+func seek(delta time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		_, _ = mpvCommand("seek", delta.Seconds(), "relative")
+		position, _ := mpvGetFloat("time-pos")
+		return seekedMsg{position: position}
+	}
+}
+
+// Playback speed for VODs goes from minSpeed to maxSpeed in speedStep steps
+const (
+	speedStep = 0.1
+	minSpeed  = 0.5
+	maxSpeed  = 2.0
+)
+
+// speedMsg reports mpv's speed after a change
+type speedMsg float64
+
+// changeSpeed makes playback faster or slower by delta, or back to normal
+// speed when delta is zero
+func changeSpeed(current, delta float64) tea.Cmd {
+	return func() tea.Msg {
+		speed := 1.0
+		if delta != 0 {
+			// Round so steps don't drift, e.g. to 0.8999999
+			speed = math.Round(min(max(current+delta, minSpeed), maxSpeed)*10) / 10
+		}
+		_ = mpvSetProperty("speed", speed)
+		speed, _ = mpvGetFloat("speed")
+		return speedMsg(speed)
+	}
+}
+
+// pollPlayback reads the current position and length from mpv after a second
+func pollPlayback(id int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		status := playbackStatusMsg{poll: id}
+		status.position, _ = mpvGetFloat("time-pos")
+		// Live streams have no duration, so an error here means "live"
+		status.duration, _ = mpvGetFloat("duration")
+		status.track = currentTrack()
+		status.stalled, _ = mpvGetBool("paused-for-cache")
+		status.paused, _ = mpvGetBool("pause")
+		status.seekable, _ = mpvGetBool("seekable")
+		status.speed, _ = mpvGetFloat("speed")
+		status.recording, _ = mpvGetString("stream-record")
+		// Pick up volume changes made outside lofitui, e.g. via MPRIS
+		status.volume = readVolume()
+		return status
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+)
+
+// fakeMPV answers mpv's JSON IPC on this process's socket, recording the
+// commands it's sent. Properties read back as 50.
+type fakeMPV struct {
+	mu       sync.Mutex
+	commands [][]interface{}
+}
+
+func newFakeMPV(t *testing.T) *fakeMPV {
+	t.Helper()
+	t.Setenv("TMPDIR", t.TempDir())
+	return listenFakeMPV(t, getMPVSocketPath())
+}
+
+// listenFakeMPV is newFakeMPV on another socket, e.g. for a second mpv
+func listenFakeMPV(t *testing.T, socket string) *fakeMPV {
+	t.Helper()
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeMPV{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeMPV) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req struct {
+			Command   []interface{} `json:"command"`
+			RequestID int           `json:"request_id"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &req) != nil {
+			continue
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, req.Command)
+		f.mu.Unlock()
+		reply, _ := json.Marshal(map[string]interface{}{"error": "success", "data": 50.0, "request_id": req.RequestID})
+		_, _ = conn.Write(append(reply, '\n'))
+	}
+}
+
+// sent reports whether mpv was sent the named command
+func (f *fakeMPV) sent(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, command := range f.commands {
+		if len(command) > 0 && command[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// testModel is the TUI's model with the default config, kept away from
+// the user's own
+func testModel(t *testing.T) model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	return initialModel()
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 packet types, shifted into the fixed header's high nibble
+const (
+	mqttConnect   = 1 << 4
+	mqttConnack   = 2 << 4
+	mqttPublish   = 3 << 4
+	mqttSubscribe = 8<<4 | 0x2 // SUBSCRIBE has reserved flags 0010
+	mqttPingreq   = 12 << 4
+)
+
+// mqttKeepAlive is how often we ping the broker, and mqttRetry how long we
+// wait before reconnecting after losing it
+const (
+	mqttKeepAlive = 60 * time.Second
+	mqttRetry     = 10 * time.Second
+)
+
+// mqttMaxLength is the largest remaining length the protocol can encode,
+// in its four bytes. mqttMaxPacket is the largest packet we read; state and
+// commands are far smaller, so anything bigger is skipped.
+const (
+	mqttMaxLength = 268435455
+	mqttMaxPacket = 1 << 20
+)
+
+var errMQTTLength = errors.New("mqtt: malformed remaining length")
+
+// mqttCommands are the daemon commands accepted on command topics; quit
+// is left out so an automation can't take the daemon down
+var mqttCommands = map[string]bool{
+	"play": true, "pause": true, "stop": true, "next": true, "prev": true, "volume": true,
+}
+
+// mqttClient is a minimal MQTT 3.1.1 client: QoS 0 publish and subscribe,
+// which is all a player status feed and command topics need
+type mqttClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex // Serializes writes
+}
+
+// mqttString encodes a length-prefixed UTF-8 string
+func mqttString(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
+
+// mqttDial connects and logs in to the broker. The will message is
+// published by the broker if we disappear without saying goodbye.
+func mqttDial(cfg MQTTConfig, clientID, willTopic, willMessage string) (*mqttClient, error) {
+	conn, err := net.DialTimeout("tcp", cfg.Broker, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttClient{conn: conn, r: bufio.NewReader(conn)}
+
+	// Clean session, retained QoS 0 will
+	flags := byte(0x02 | 0x04 | 0x20)
+	payload := append(mqttString(clientID), mqttString(willTopic)...)
+	payload = append(payload, mqttString(willMessage)...)
+	if cfg.Username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(cfg.Username)...)
+		if cfg.Password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(cfg.Password)...)
+		}
+	}
+	body := append(mqttString("MQTT"), 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	if err := c.send(mqttConnect, append(body, payload...)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	packetType, body, err := c.read()
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if packetType != mqttConnack || len(body) < 2 || body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused the connection (code %v)", body)
+	}
+	return c, nil
+}
+
+// mqttAppendLength appends a remaining length, 7 bits at a time
+func mqttAppendLength(packet []byte, n int) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			return packet
+		}
+	}
+}
+
+// mqttReadLength reads a remaining length of at most four bytes
+func mqttReadLength(r io.ByteReader) (int, error) {
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(b&0x7F) * multiplier
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+	return 0, errMQTTLength
+}
+
+// send writes a packet with the given fixed header byte
+func (c *mqttClient) send(header byte, body []byte) error {
+	if len(body) > mqttMaxLength {
+		return fmt.Errorf("mqtt: packet of %d bytes is too big", len(body))
+	}
+	packet := mqttAppendLength([]byte{header}, len(body))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(append(packet, body...))
+	return err
+}
+
+// read reads one packet, returning its type (flags masked off) and body.
+// Packets bigger than mqttMaxPacket are skipped.
+func (c *mqttClient) read() (byte, []byte, error) {
+	for {
+		header, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length, err := mqttReadLength(c.r)
+		if err != nil {
+			return 0, nil, err
+		}
+		if length > mqttMaxPacket {
+			if _, err := io.CopyN(io.Discard, c.r, int64(length)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(c.r, body); err != nil {
+			return 0, nil, err
+		}
+		return header & 0xF0, body, nil
+	}
+}
+
+// publish sends a QoS 0 message
+func (c *mqttClient) publish(topic string, payload []byte, retain bool) error {
+	header := byte(mqttPublish)
+	if retain {
+		header |= 0x01
+	}
+	return c.send(header, append(mqttString(topic), payload...))
+}
+
+// subscribe asks for QoS 0 messages on a topic filter
+func (c *mqttClient) subscribe(filter string) error {
+	body := binary.BigEndian.AppendUint16(nil, 1)
+	body = append(body, mqttString(filter)...)
+	return c.send(mqttSubscribe, append(body, 0))
+}
+
+// runMQTT keeps the daemon connected to the broker until it quits:
+// publishing its state and taking commands from <topic>/command/<name>
+func (d *daemon) runMQTT(cfg MQTTConfig) {
+	hostname, _ := os.Hostname()
+	clientID := "lofitui-" + hostname
+	availability := cfg.Topic + "/availability"
+
+	for {
+		client, err := mqttDial(cfg, clientID, availability, "offline")
+		if err == nil {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Connected to MQTT broker %s\n", cfg.Broker)
+			}
+			err = d.serveMQTT(client, cfg)
+			client.conn.Close()
+		}
+		if err == nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "lofitui: mqtt: %v; retrying in %s\n", err, mqttRetry)
+
+		select {
+		case <-d.quit:
+			return
+		case <-d.exited:
+			return
+		case <-time.After(mqttRetry):
+		}
+	}
+}
+
+// serveMQTT publishes state changes and handles commands on one broker
+// connection. It returns nil when the daemon quits, else why the
+// connection was lost.
+func (d *daemon) serveMQTT(client *mqttClient, cfg MQTTConfig) error {
+	prefix := cfg.Topic
+	events := d.events.subscribe()
+	defer d.events.unsubscribe(events)
+
+	publishState := func(status daemonStatus) error {
+		state, _ := json.Marshal(status)
+		if err := client.publish(prefix+"/state", state, true); err != nil {
+			return err
+		}
+		return client.publish(prefix+"/now_playing", []byte(status.Title), true)
+	}
+
+	if err := client.publish(prefix+"/availability", []byte("online"), true); err != nil {
+		return err
+	}
+	if err := client.subscribe(prefix + "/command/+"); err != nil {
+		return err
+	}
+	if cfg.Discovery {
+		if err := publishDiscovery(client, cfg); err != nil {
+			return err
+		}
+	}
+	d.mu.Lock()
+	status := d.currentStatus()
+	d.mu.Unlock()
+	if err := publishState(status); err != nil {
+		return err
+	}
+
+	// Commands arrive on the reader; loading a stream takes a while, so
+	// each one runs on its own
+	lost := make(chan error, 1)
+	go func() {
+		for {
+			packetType, body, err := client.read()
+			if err != nil {
+				lost <- err
+				return
+			}
+			if packetType != mqttPublish || len(body) < 2 {
+				continue
+			}
+			topicLength := int(binary.BigEndian.Uint16(body))
+			if len(body) < 2+topicLength {
+				continue
+			}
+			command := strings.TrimPrefix(string(body[2:2+topicLength]), prefix+"/command/")
+			if !mqttCommands[command] {
+				continue
+			}
+			var args []string
+			if payload := strings.TrimSpace(string(body[2+topicLength:])); payload != "" {
+				args = []string{payload}
+			}
+			go func() {
+				if _, err := d.handle(daemonRequest{Command: command, Args: args}); err != nil {
+					fmt.Fprintf(os.Stderr, "lofitui: mqtt command %s: %v\n", command, err)
+				}
+			}()
+		}
+	}()
+
+	ping := time.NewTicker(mqttKeepAlive / 2)
+	defer ping.Stop()
+	for {
+		select {
+		case event := <-events:
+			if err := publishState(event.Status); err != nil {
+				return err
+			}
+		case <-ping.C:
+			if err := client.send(mqttPingreq, nil); err != nil {
+				return err
+			}
+		case err := <-lost:
+			return err
+		case <-d.quit:
+			_ = client.publish(prefix+"/availability", []byte("offline"), true)
+			return nil
+		case <-d.exited:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestMQTTLengthRoundTrip(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7F}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xFF, 0x7F}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xFF, 0xFF, 0x7F}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+		{mqttMaxLength, []byte{0xFF, 0xFF, 0xFF, 0x7F}},
+	}
+	for _, test := range tests {
+		encoded := mqttAppendLength(nil, test.n)
+		if !bytes.Equal(encoded, test.want) {
+			t.Errorf("mqttAppendLength(%d) = % x, want % x", test.n, encoded, test.want)
+		}
+		n, err := mqttReadLength(bytes.NewReader(encoded))
+		if err != nil || n != test.n {
+			t.Errorf("mqttReadLength(% x) = %d, %v, want %d", encoded, n, err, test.n)
+		}
+	}
+}
+
+func TestMQTTReadLengthRejectsFiveBytes(t *testing.T) {
+	_, err := mqttReadLength(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x7F}))
+	if !errors.Is(err, errMQTTLength) {
+		t.Fatalf("got %v, want %v", err, errMQTTLength)
+	}
+}
+
+// mqttPacket is a raw packet as the broker sees it
+type mqttPacket struct {
+	header byte
+	body   []byte
+}
+
+// readMQTTPacket reads a packet off the broker's end, flags and all
+func readMQTTPacket(t *testing.T, r *bufio.Reader) mqttPacket {
+	t.Helper()
+	header, err := r.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	length, err := mqttReadLength(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		t.Fatal(err)
+	}
+	return mqttPacket{header, body}
+}
+
+func TestMQTTDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	connect := make(chan mqttPacket, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connect <- readMQTTPacket(t, bufio.NewReader(conn))
+		_, _ = conn.Write([]byte{mqttConnack, 2, 0, 0})
+	}()
+
+	client, err := mqttDial(MQTTConfig{Broker: listener.Addr().String(), Username: "u", Password: "p"}, "lofitui", "lofitui/availability", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.conn.Close()
+
+	packet := <-connect
+	want := append(mqttString("MQTT"), 4, 0x02|0x04|0x20|0x80|0x40, 0, 60)
+	for _, s := range []string{"lofitui", "lofitui/availability", "offline", "u", "p"} {
+		want = append(want, mqttString(s)...)
+	}
+	if packet.header != mqttConnect || !bytes.Equal(packet.body, want) {
+		t.Errorf("CONNECT = %#x % x, want %#x % x", packet.header, packet.body, mqttConnect, want)
+	}
+}
+
+func TestMQTTDialRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		readMQTTPacket(t, bufio.NewReader(conn))
+		// Not authorized
+		_, _ = conn.Write([]byte{mqttConnack, 2, 0, 5})
+	}()
+	if _, err := mqttDial(MQTTConfig{Broker: listener.Addr().String()}, "lofitui", "t", "offline"); err == nil {
+		t.Fatal("connected although the broker refused")
+	}
+}
+
+// mqttPipe connects a client to a fake broker's end
+func mqttPipe(t *testing.T) (*mqttClient, net.Conn, *bufio.Reader) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return &mqttClient{conn: a, r: bufio.NewReader(a)}, b, bufio.NewReader(b)
+}
+
+func TestMQTTPublishAndSubscribe(t *testing.T) {
+	client, _, broker := mqttPipe(t)
+
+	go func() { _ = client.publish("lofitui/state", []byte(`{"state":"playing"}`), true) }()
+	packet := readMQTTPacket(t, broker)
+	want := append(mqttString("lofitui/state"), `{"state":"playing"}`...)
+	if packet.header != mqttPublish|0x01 || !bytes.Equal(packet.body, want) {
+		t.Errorf("PUBLISH = %#x %q, want %#x %q", packet.header, packet.body, mqttPublish|0x01, want)
+	}
+
+	go func() { _ = client.subscribe("lofitui/command/+") }()
+	packet = readMQTTPacket(t, broker)
+	want = binary.BigEndian.AppendUint16(nil, 1)
+	want = append(append(want, mqttString("lofitui/command/+")...), 0)
+	if packet.header != mqttSubscribe || !bytes.Equal(packet.body, want) {
+		t.Errorf("SUBSCRIBE = %#x % x, want %#x % x", packet.header, packet.body, mqttSubscribe, want)
+	}
+}
+
+func TestMQTTReadSkipsOversizePackets(t *testing.T) {
+	var stream []byte
+	stream = mqttAppendLength(append(stream, mqttPublish), mqttMaxPacket+1)
+	stream = append(stream, make([]byte, mqttMaxPacket+1)...)
+	command := append(mqttString("lofitui/command/pause"), "x"...)
+	stream = mqttAppendLength(append(stream, mqttPublish|0x01), len(command))
+	stream = append(stream, command...)
+
+	client := &mqttClient{r: bufio.NewReader(bytes.NewReader(stream))}
+	packetType, body, err := client.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packetType != mqttPublish || !bytes.Equal(body, command) {
+		t.Errorf("read %#x %q, want the command after the oversize packet", packetType, body)
+	}
+}
+
+func TestMQTTReadRejectsMalformedLength(t *testing.T) {
+	client := &mqttClient{r: bufio.NewReader(bytes.NewReader([]byte{mqttPublish, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}))}
+	if _, _, err := client.read(); !errors.Is(err, errMQTTLength) {
+		t.Fatalf("got %v, want %v", err, errMQTTLength)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pushView opens a view on top of the current one, so ESC comes back here
+func (m *model) pushView(state viewState) {
+	m.navStack = append(m.navStack, m.state)
+	m.state = state
+}
+
+// replaceView swaps the current view without adding a level, for steps
+// that shouldn't be revisited (e.g. a submitted dialog turning into loading)
+func (m *model) replaceView(state viewState) {
+	m.state = state
+}
+
+// popView goes back one level; the main menu is always at the bottom
+func (m *model) popView() {
+	if len(m.navStack) == 0 {
+		m.state = mainMenuView
+		return
+	}
+	m.state = m.navStack[len(m.navStack)-1]
+	m.navStack = m.navStack[:len(m.navStack)-1]
+}
+
+// startLoading switches to the loading view and extracts the stream URL.
+// index is the preset's position in the config, -1 for custom URLs.
+func (m *model) startLoading(url, title string, index int) tea.Cmd {
+	m.loadingTitle = title
+	m.loadingURL = url
+	m.playingIndex = index
+	m.reconnects = 0
+	m.resumeAt = 0
+	m.resuming = false
+	m.quality = 0
+	// A quality picked just now, else the preset's own
+	m.tier, m.pickedTier = m.pickedTier, ""
+	if m.tier == "" && index >= 0 && index < len(m.config.Presets) {
+		m.tier = m.config.Presets[index].Quality
+	}
+	m.qualityNotice = ""
+	m.streamInfo, m.streamInfoErr = nil, nil
+	m.streamConfirmed = false
+	cmd := m.extract()
+	if m.config.ConfirmBeforePlay {
+		cmd = tea.Batch(cmd, fetchStreamInfo(m.loadID, url))
+	}
+	return cmd
+}
+
+// extract starts extracting the stream URL of what's being loaded
+func (m *model) extract() tea.Cmd {
+	m.loadingSince = time.Now()
+
+	// Each load gets its own id so a cancelled one's result is ignored
+	m.loadID++
+	// A new load or a reconnect replaces an extraction that may still be going
+	if m.cancelLoad != nil {
+		m.cancelLoad()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelLoad = cancel
+
+	m.extractLog = nil
+	m.extractLines = make(chan string, 64)
+
+	return tea.Batch(
+		spinner.Tick,
+		extractStreamURL(ctx, m.loadID, m.loadingURL, m.loadingTitle, m.streamFormat(), m.extractLines),
+		waitForExtractLog(m.loadID, m.extractLines),
+	)
+}
+
+// cancelLoading abandons the extraction in progress, if any
+func (m *model) cancelLoading() {
+	m.loadID++
+	m.replaceStream = false
+	if m.cancelLoad != nil {
+		m.cancelLoad()
+		m.cancelLoad = nil
+	}
+}
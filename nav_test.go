@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestExtractCancelsThePreviousOne(t *testing.T) {
+	m := testModel(t)
+	cancelled := false
+	m.cancelLoad = func() { cancelled = true }
+	m.loadingURL = "https://example.com/live"
+
+	m.extract()
+	if !cancelled {
+		t.Error("the previous extraction was left running")
+	}
+	if m.cancelLoad == nil {
+		t.Error("the new extraction can't be cancelled")
+	}
+	m.cancelLoading()
+}
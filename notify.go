@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// notifyTimeout is how long notifications stay up, in milliseconds
+const notifyTimeout = 5000
+
+// lastNotification is the id of our last D-Bus notification, which the
+// next replaces so track changes don't pile up
+var (
+	lastNotification   uint32
+	lastNotificationMu sync.Mutex
+)
+
+// notifyEvent shows a desktop notification for a player event, if they're
+// on: playback starting, the track changing or the stream dropping
+func notifyEvent(cfg NotificationsConfig, event string, status daemonStatus) {
+	if !cfg.Enabled {
+		return
+	}
+	var summary, body string
+	switch event {
+	case "now_playing":
+		summary, body = "Now playing", status.Title
+	case "track":
+		if status.Track == "" {
+			return
+		}
+		summary, body = status.Title, "♪ "+status.Track
+	case "stream_ended":
+		summary, body = "Stream dropped", status.Title
+	default:
+		return
+	}
+	// The bus can be slow to answer, and the TUI mustn't wait for it
+	go notify(summary, body)
+}
+
+// notify shows a notification through the desktop's notification server,
+// falling back to asking the terminal (OSC 777) when there's none
+func notify(summary, body string) {
+	err := notifyDBus(summary, body)
+	if err == nil {
+		return
+	}
+	slog.Debug("no notification server, asking the terminal", "err", err)
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stdout, "\x1b]777;notify;%s;%s\x1b\\", oscText(summary), oscText(body))
+	}
+}
+
+// notifyDBus sends a notification to org.freedesktop.Notifications, the
+// interface notify-send uses
+func notifyDBus(summary, body string) error {
+	bus, err := dbusSessionBus()
+	if err != nil {
+		return err
+	}
+	defer bus.conn.Close()
+
+	lastNotificationMu.Lock()
+	defer lastNotificationMu.Unlock()
+	reply, err := bus.call("org.freedesktop.Notifications", "/org/freedesktop/Notifications",
+		"org.freedesktop.Notifications", "Notify",
+		"lofitui", lastNotification, "audio-x-generic", summary, body,
+		[]string{}, map[string]interface{}{"category": "x-lofitui.playback"}, int32(notifyTimeout))
+	if err != nil {
+		return err
+	}
+	if len(reply) == 1 {
+		if id, ok := reply[0].(uint32); ok {
+			lastNotification = id
+		}
+	}
+	return nil
+}
+
+// oscText makes s safe inside an OSC 777 sequence, whose fields are split
+// on semicolons
+func oscText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ';' {
+			return ','
+		}
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// defaultNowPlayingFormat is what the now playing file holds without a
+// format of its own
+const defaultNowPlayingFormat = `{{if .Playing}}{{.Title}}{{with .Track}} – {{.}}{{end}}{{end}}`
+
+// writeNowPlaying rewrites the now playing file for a player event, for
+// streaming software like OBS to show as a text source. Stopping empties
+// it, whatever the format.
+func writeNowPlaying(cfg NowPlayingFileConfig, event string, status daemonStatus) {
+	if cfg.Path == "" || event == "error" {
+		return
+	}
+	if status.State == "" {
+		status.State = "playing"
+	}
+
+	var text strings.Builder
+	if event != "stopped" && event != "stream_ended" {
+		tmpl, err := parseNowPlayingFormat(cfg.Format)
+		if err == nil {
+			err = tmpl.Execute(&text, newStatusView(status))
+		}
+		if err != nil {
+			slog.Warn("bad now_playing_file format", "err", err)
+			return
+		}
+	}
+	if err := writeFileAtomic(nowPlayingPath(cfg.Path), []byte(strings.TrimSpace(text.String()))); err != nil {
+		slog.Warn("couldn't write the now playing file", "path", cfg.Path, "err", err)
+	}
+}
+
+// nowPlayingPath expands a leading ~ in the configured path
+func nowPlayingPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// parseNowPlayingFormat parses the now playing file's template
+func parseNowPlayingFormat(format string) (*template.Template, error) {
+	if format == "" {
+		format = defaultNowPlayingFormat
+	}
+	return template.New("now_playing_file").Parse(format)
+}
+
+// writeFileAtomic replaces a file through a temporary one, so readers
+// polling it never see it half written
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// onlineCheck bounds how long deciding whether we're offline may take
+const onlineCheck = 3 * time.Second
+
+// downloadProgress matches yt-dlp's "[download]  42.3% of ..." lines
+var downloadProgress = regexp.MustCompile(`^\[download\]\s+([\d.]+)%`)
+
+// offlineDirectory returns where offline copies go: the configured
+// directory, else lofitui/offline in the user's cache directory
+func offlineDirectory(cfg OfflineConfig) (string, error) {
+	if cfg.Directory != "" {
+		if rest, ok := strings.CutPrefix(cfg.Directory, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(home, rest), nil
+		}
+		return cfg.Directory, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "lofitui", "offline"), nil
+}
+
+// offlineKey names a page's offline copy, whatever its extension
+func offlineKey(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return hex.EncodeToString(sum[:8])
+}
+
+// offlineCopy returns the finished offline copy of a page, if there is one
+func offlineCopy(cfg OfflineConfig, pageURL string) (string, bool) {
+	dir, err := offlineDirectory(cfg)
+	if err != nil {
+		return "", false
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, offlineKey(pageURL)+".*"))
+	for _, path := range matches {
+		if isOfflineCopy(filepath.Base(path)) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// isOfflineCopy reports whether a file name is a finished offline copy:
+// an offlineKey and a single extension. That leaves out yt-dlp's
+// unfinished downloads, such as <key>.mp4.part, and anything else in the
+// directory, which may be shared with the user's own files.
+func isOfflineCopy(name string) bool {
+	key, ext, ok := strings.Cut(name, ".")
+	if !ok || len(key) != len(offlineKey("")) || ext == "" || strings.Contains(ext, ".") {
+		return false
+	}
+	if ext == "part" || ext == "ytdl" {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil && strings.ToLower(key) == key
+}
+
+// isOnline reports whether YouTube can be reached
+func isOnline() bool {
+	conn, err := net.DialTimeout("tcp", "www.youtube.com:443", onlineCheck)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// offlineFallback returns a page's offline copy when there's no network
+// to stream it over. Playing a copy counts as using it, so it's evicted
+// last.
+func offlineFallback(pageURL string) (string, bool) {
+	config, err := loadConfig()
+	if err != nil {
+		return "", false
+	}
+	path, ok := offlineCopy(config.Offline, pageURL)
+	if !ok || isOnline() {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	slog.Info("offline, playing the saved copy", "url", pageURL, "file", path)
+	return path, true
+}
+
+// downloadVOD saves a page's video into the offline directory with
+// yt-dlp, reporting progress from 0 to 1. Live streams are refused, since
+// they never finish.
+func downloadVOD(ctx context.Context, cfg OfflineConfig, pageURL string, progress func(float64)) (string, error) {
+	dir, err := offlineDirectory(cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "yt-dlp", "--newline", "--no-playlist", "-f", "best",
+		"--match-filter", "!is_live", "-o", filepath.Join(dir, offlineKey(pageURL)+".%(ext)s"), "--", pageURL)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	slog.Info("downloading for offline", "url", pageURL)
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if match := downloadProgress.FindStringSubmatch(scanner.Text()); match != nil {
+			if percent, err := strconv.ParseFloat(match[1], 64); err == nil && progress != nil {
+				progress(percent / 100)
+			}
+		}
+	}
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		slog.Error("download failed", "url", pageURL, "err", err, "output", lastLines(stderr.String(), 5))
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("yt-dlp: %w", err)
+	}
+	// yt-dlp skips what the filter refuses without failing
+	path, ok := offlineCopy(cfg, pageURL)
+	if !ok {
+		return "", errors.New("live streams can't be saved for offline")
+	}
+	slog.Info("downloaded for offline", "url", pageURL, "file", path)
+	evictOffline(cfg, path)
+	return path, nil
+}
+
+// evictOffline removes the least recently used offline copies until they
+// fit in the configured size, keeping the one just saved. Only files
+// lofitui saved count, so other files in the directory and downloads still
+// going are left alone.
+func evictOffline(cfg OfflineConfig, keep string) {
+	dir, err := offlineDirectory(cfg)
+	if err != nil || cfg.MaxMB <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var files []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !isOfflineCopy(info.Name()) {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, info := range files {
+		if total <= int64(cfg.MaxMB)<<20 {
+			return
+		}
+		path := filepath.Join(dir, info.Name())
+		if path == keep {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			slog.Info("evicted offline copy", "file", path)
+			total -= info.Size()
+		}
+	}
+}
+
+// download is one page in the download queue
+type download struct {
+	title    string
+	url      string
+	state    string  // "queued", "downloading", "paused", "done", "failed" or "cancelled"
+	progress float64 // 0 to 1
+	path     string  // The offline copy, once done
+	err      error
+	cancel   context.CancelFunc // Stops the download in progress
+}
+
+// downloadQueue saves pages for offline one at a time, in the background
+type downloadQueue struct {
+	mu      sync.Mutex
+	cfg     OfflineConfig
+	jobs    []*download
+	running bool
+}
+
+// newDownloadQueue returns an empty queue saving into cfg's directory
+func newDownloadQueue(cfg OfflineConfig) *downloadQueue {
+	return &downloadQueue{cfg: cfg}
+}
+
+// add queues a page, returning false if it's already queued or saved
+func (q *downloadQueue) add(title, pageURL string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := offlineCopy(q.cfg, pageURL); ok {
+		return false
+	}
+	if _, job := q.find(pageURL); job != nil {
+		// Only a failed download is worth another try
+		if job.state != "failed" {
+			return false
+		}
+		job.state = "queued"
+	} else {
+		q.jobs = append(q.jobs, &download{title: title, url: pageURL, state: "queued"})
+	}
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+	return true
+}
+
+// next returns the first queued job, marking it as downloading, or nil
+// when there's nothing left, in which case the worker stops
+func (q *downloadQueue) next() (*download, context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.jobs {
+		if job.state == "queued" {
+			ctx, cancel := context.WithCancel(context.Background())
+			job.state, job.cancel, job.err = "downloading", cancel, nil
+			return job, ctx
+		}
+	}
+	q.running = false
+	return nil, nil
+}
+
+// run downloads the queued pages until there are none left
+func (q *downloadQueue) run() {
+	for job, ctx := q.next(); job != nil; job, ctx = q.next() {
+		path, err := downloadVOD(ctx, q.cfg, job.url, func(progress float64) {
+			q.mu.Lock()
+			job.progress = progress
+			q.mu.Unlock()
+		})
+		q.mu.Lock()
+		job.cancel()
+		switch {
+		case job.state != "downloading":
+			// Paused or cancelled while it ran
+		case err != nil:
+			job.state, job.err = "failed", err
+		default:
+			job.state, job.path, job.progress = "done", path, 1
+		}
+		q.mu.Unlock()
+	}
+}
+
+// snapshot returns a copy of the jobs, safe to read while they run
+func (q *downloadQueue) snapshot() []download {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]download, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+// find returns the job for a page. q.mu must be held.
+func (q *downloadQueue) find(pageURL string) (int, *download) {
+	for i, job := range q.jobs {
+		if job.url == pageURL {
+			return i, job
+		}
+	}
+	return -1, nil
+}
+
+// togglePause pauses a queued or running download, or queues a paused or
+// failed one again. yt-dlp carries on from where a paused one stopped.
+func (q *downloadQueue) togglePause(pageURL string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, job := q.find(pageURL)
+	if job == nil {
+		return
+	}
+	switch job.state {
+	case "downloading":
+		job.state = "paused"
+		job.cancel()
+	case "queued":
+		job.state = "paused"
+	case "paused", "failed":
+		job.state = "queued"
+		if !q.running {
+			q.running = true
+			go q.run()
+		}
+	}
+}
+
+// cancel drops a download from the queue, along with what it had saved so
+// far
+func (q *downloadQueue) cancel(pageURL string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i, job := q.find(pageURL)
+	if job == nil || job.state == "done" {
+		return
+	}
+	if job.state == "downloading" {
+		job.cancel()
+	}
+	job.state = "cancelled"
+	q.jobs = append(q.jobs[:i:i], q.jobs[i+1:]...)
+	// yt-dlp may still be letting go of its files
+	go func() {
+		time.Sleep(time.Second)
+		removePartialDownload(q.cfg, pageURL)
+	}()
+}
+
+// removePartialDownload deletes the unfinished files of a page's download
+func removePartialDownload(cfg OfflineConfig, pageURL string) {
+	dir, err := offlineDirectory(cfg)
+	if err != nil {
+		return
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, offlineKey(pageURL)+".*"))
+	for _, path := range matches {
+		if ext := filepath.Ext(path); ext == ".part" || ext == ".ytdl" {
+			os.Remove(path)
+		}
+	}
+}
+
+// runDownload saves presets or URLs for offline listening, printing
+// progress as it goes
+func runDownload(args []string) int {
+	flags := newFlagSet("download")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) == 0 {
+		return usageError(flags, "download needs a preset name or URL")
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+
+	failed := false
+	for _, target := range positional {
+		url, title := target, target
+		if !strings.Contains(target, "://") {
+			index, err := findPreset(config.Presets, target)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+				failed = true
+				continue
+			}
+			url, title = config.Presets[index].URL, config.Presets[index].Name
+		}
+		if path, ok := offlineCopy(config.Offline, url); ok {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "%s is already saved as %s\n", title, path)
+			}
+			continue
+		}
+		path, err := downloadVOD(context.Background(), config.Offline, url, func(progress float64) {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "\r%s: %3.0f%%", title, progress*100)
+			}
+		})
+		if !quiet {
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lofitui: %s: %v\n", title, err)
+			failed = true
+			continue
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "%s saved as %s\n", title, path)
+		}
+	}
+	if failed {
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsOfflineCopy(t *testing.T) {
+	key := offlineKey("https://www.youtube.com/watch?v=abc")
+	for name, want := range map[string]bool{
+		key + ".mp4":           true,
+		key + ".webm":          true,
+		key + ".mp4.part":      false,
+		key + ".part":          false,
+		key + ".ytdl":          false,
+		key + ".f137.mp4":      false,
+		key:                    false,
+		"notes.txt":            false,
+		"0123456789abcdeg.mp4": false,
+		"0123456789ABCDEF.mp4": false,
+	} {
+		if got := isOfflineCopy(name); got != want {
+			t.Errorf("isOfflineCopy(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestEvictOfflineLeavesOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := OfflineConfig{Directory: dir, MaxMB: 1}
+	old := filepath.Join(dir, offlineKey("https://example.com/old")+".mp4")
+	kept := filepath.Join(dir, offlineKey("https://example.com/new")+".mp4")
+	partial := filepath.Join(dir, offlineKey("https://example.com/next")+".mp4.part")
+	mine := filepath.Join(dir, "holiday.mp3")
+
+	// Oldest first, so all but the newest copy would go if they counted
+	for i, path := range []string{mine, partial, old, kept} {
+		if err := os.WriteFile(path, make([]byte, 600<<10), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		when := time.Now().Add(time.Duration(i-4) * time.Hour)
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evictOffline(cfg, kept)
+	for _, path := range []string{mine, partial, kept} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed", filepath.Base(path))
+		}
+	}
+	if _, err := os.Stat(old); err == nil {
+		t.Error("the old offline copy wasn't evicted")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultPacksIndex is the community preset pack index kept in the repo;
+// send a pull request to add a pack
+const defaultPacksIndex = "https://raw.githubusercontent.com/willyv3/lofitui/main/packs/index.json"
+
+// packsTimeout bounds fetching the pack index
+const packsTimeout = 15 * time.Second
+
+// presetPack is a curated set of presets from the pack index
+type presetPack struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Presets     []Preset `json:"presets"`
+}
+
+// fetchPacks downloads the pack index
+func fetchPacks(indexURL string) ([]presetPack, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), packsTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", indexURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var index struct {
+		Packs []presetPack `json:"packs"`
+	}
+	if err := doJSON(req, &index); err != nil {
+		return nil, fmt.Errorf("failed to fetch the pack index: %w", err)
+	}
+	return index.Packs, nil
+}
+
+// runPacks lists the packs in the index or installs one
+func runPacks(args []string) int {
+	flags := newFlagSet("packs")
+	args, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	valid := len(args) == 1 && args[0] == "list" || len(args) == 2 && args[0] == "install"
+	if !valid {
+		return usageError(flags, "packs needs list or install <name>")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fail(exitConfig, err)
+	}
+	packs, err := fetchPacks(config.PacksIndex)
+	if err != nil {
+		return fail(exitError, err)
+	}
+
+	if args[0] == "list" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPRESETS\tDESCRIPTION")
+		for _, pack := range packs {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", pack.Name, len(pack.Presets), pack.Description)
+		}
+		w.Flush()
+		return exitOK
+	}
+
+	for _, pack := range packs {
+		if !strings.EqualFold(pack.Name, args[1]) {
+			continue
+		}
+		added := mergePresets(config, pack.Presets)
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Added %d of %d presets from %s\n", added, len(pack.Presets), pack.Name)
+		}
+		return saveOrFail(config)
+	}
+	return fail(exitError, fmt.Errorf("no pack named %q; see lofitui packs list", args[1]))
+}
@@ -0,0 +1,171 @@
+// Package lofitui is the stream resolution and player control behind the
+// lofitui TUI, for other Go programs to embed: ResolveStream turns a
+// YouTube (or any yt-dlp supported) page into a playable stream URL, Start
+// runs mpv in the background, and Player controls a running mpv over its
+// JSON IPC socket.
+package lofitui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultTimeout bounds a single IPC round trip when Player.Timeout is zero
+const DefaultTimeout = 2 * time.Second
+
+// Player controls an mpv started with --input-ipc-server=Socket
+type Player struct {
+	Socket  string
+	Timeout time.Duration // Per command; DefaultTimeout if zero
+}
+
+// request is a single command sent over mpv's JSON IPC protocol
+type request struct {
+	Command   []interface{} `json:"command"`
+	RequestID int           `json:"request_id"`
+}
+
+// response is a reply (or event) read from mpv's JSON IPC socket
+type response struct {
+	Error     string      `json:"error"`
+	Data      interface{} `json:"data"`
+	RequestID int         `json:"request_id"`
+	Event     string      `json:"event"`
+}
+
+// Command runs an mpv command, e.g. "cycle", "pause", and returns its data
+func (p Player) Command(args ...interface{}) (interface{}, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return p.CommandWithin(timeout, args...)
+}
+
+// CommandWithin is Command for commands that may take longer than a round
+// trip, such as writing out the cache
+func (p Player) CommandWithin(timeout time.Duration, args ...interface{}) (interface{}, error) {
+	conn, err := net.DialTimeout("unix", p.Socket, min(timeout, DefaultTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mpv: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	// Any non-zero id works since each command uses its own connection
+	req := request{Command: args, RequestID: 1}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mpv command: %w", err)
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send mpv command: %w", err)
+	}
+
+	// Skip over any events until we see our reply
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var resp response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		if resp.Event != "" || resp.RequestID != req.RequestID {
+			continue
+		}
+		if resp.Error != "success" {
+			return nil, fmt.Errorf("mpv: %s", resp.Error)
+		}
+		return resp.Data, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mpv reply: %w", err)
+	}
+	return nil, fmt.Errorf("mpv closed the connection")
+}
+
+// SetProperty sets an mpv property, e.g. "volume" to 50
+func (p Player) SetProperty(name string, value interface{}) error {
+	_, err := p.Command("set_property", name, value)
+	return err
+}
+
+// Float reads a numeric property, e.g. "time-pos"
+func (p Player) Float(name string) (float64, error) {
+	data, err := p.Command("get_property", name)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := data.(float64)
+	if !ok {
+		return 0, fmt.Errorf("mpv: property %s is not a number", name)
+	}
+	return value, nil
+}
+
+// String reads a string property, e.g. "media-title"
+func (p Player) String(name string) (string, error) {
+	data, err := p.Command("get_property", name)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data.(string)
+	if !ok {
+		return "", fmt.Errorf("mpv: property %s is not a string", name)
+	}
+	return value, nil
+}
+
+// Bool reads a yes/no property, e.g. "pause"
+func (p Player) Bool(name string) (bool, error) {
+	data, err := p.Command("get_property", name)
+	if err != nil {
+		return false, err
+	}
+	value, ok := data.(bool)
+	if !ok {
+		return false, fmt.Errorf("mpv: property %s is not a flag", name)
+	}
+	return value, nil
+}
+
+// Event is something mpv reports by itself, e.g. "file-loaded", or a
+// "property-change" of an observed property with its Name and new Data
+type Event struct {
+	Event string      `json:"event"`
+	Name  string      `json:"name"`
+	Data  interface{} `json:"data"`
+}
+
+// Watch observes properties and calls handle with every event mpv sends,
+// until mpv goes away. mpv reports each property's value once when it's
+// first observed.
+func (p Player) Watch(properties []string, handle func(Event)) error {
+	conn, err := net.Dial("unix", p.Socket)
+	if err != nil {
+		return fmt.Errorf("failed to connect to mpv: %w", err)
+	}
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	for i, name := range properties {
+		req := request{Command: []interface{}{"observe_property", i + 1, name}, RequestID: 100 + i}
+		if err := encoder.Encode(req); err != nil {
+			return fmt.Errorf("failed to send mpv command: %w", err)
+		}
+	}
+
+	scanner := bufio.NewScanner(conn)
+	// Metadata can make for long lines
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event Event
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.Event == "" {
+			continue
+		}
+		handle(event)
+	}
+	return scanner.Err()
+}
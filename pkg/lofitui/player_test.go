@@ -0,0 +1,148 @@
+package lofitui
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMPV listens like mpv's JSON IPC, handing each command it's sent to
+// reply, which writes whatever lines mpv would
+func fakeMPV(t *testing.T, reply func(command []interface{}, id int) []string) Player {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "mpv.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					var req request
+					if json.Unmarshal(scanner.Bytes(), &req) != nil {
+						continue
+					}
+					for _, line := range reply(req.Command, req.RequestID) {
+						if _, err := conn.Write([]byte(line + "\n")); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+	return Player{Socket: socket, Timeout: time.Second}
+}
+
+func TestPlayerProperties(t *testing.T) {
+	properties := map[string]string{"volume": "62.5", "media-title": `"Lofi Girl"`, "pause": "true"}
+	p := fakeMPV(t, func(command []interface{}, id int) []string {
+		value, ok := properties[command[1].(string)]
+		if command[0] != "get_property" || !ok {
+			return []string{`{"error":"property unavailable","request_id":1}`}
+		}
+		// Events and replies to others come first, and are skipped
+		return []string{
+			`{"event":"playback-restart"}`,
+			`{"error":"success","data":0,"request_id":7}`,
+			`{"error":"success","data":` + value + `,"request_id":1}`,
+		}
+	})
+
+	if volume, err := p.Float("volume"); err != nil || volume != 62.5 {
+		t.Errorf("Float(volume) = %v, %v", volume, err)
+	}
+	if title, err := p.String("media-title"); err != nil || title != "Lofi Girl" {
+		t.Errorf("String(media-title) = %q, %v", title, err)
+	}
+	if paused, err := p.Bool("pause"); err != nil || !paused {
+		t.Errorf("Bool(pause) = %v, %v", paused, err)
+	}
+	if _, err := p.Float("media-title"); err == nil {
+		t.Error("Float read a string")
+	}
+	if _, err := p.Float("time-pos"); err == nil || !strings.Contains(err.Error(), "property unavailable") {
+		t.Errorf("Float(time-pos) err = %v, want mpv's error", err)
+	}
+}
+
+func TestPlayerSendsCommands(t *testing.T) {
+	sent := make(chan []interface{}, 1)
+	p := fakeMPV(t, func(command []interface{}, id int) []string {
+		sent <- command
+		return []string{`{"error":"success","request_id":1}`}
+	})
+	if err := p.SetProperty("volume", 40); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(<-sent)
+	if string(got) != `["set_property","volume",40]` {
+		t.Errorf("sent %s", got)
+	}
+}
+
+func TestPlayerTimesOut(t *testing.T) {
+	p := fakeMPV(t, func(command []interface{}, id int) []string { return nil })
+	p.Timeout = 100 * time.Millisecond
+	started := time.Now()
+	if _, err := p.Command("get_property", "volume"); err == nil {
+		t.Fatal("no error from an mpv that never replies")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("gave up after %v", elapsed)
+	}
+}
+
+func TestPlayerWithoutMPV(t *testing.T) {
+	p := Player{Socket: filepath.Join(t.TempDir(), "missing.sock")}
+	if _, err := p.Command("get_property", "volume"); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPlayerWatch(t *testing.T) {
+	observed := make(chan string, 2)
+	p := fakeMPV(t, func(command []interface{}, id int) []string {
+		if command[0] != "observe_property" {
+			return nil
+		}
+		name := command[2].(string)
+		observed <- name
+		if name != "pause" {
+			return nil
+		}
+		// The last event; the fake closes the connection after it
+		return []string{
+			`{"event":"property-change","id":1,"name":"pause","data":false}`,
+			`{"request_id":101,"error":"success"}`,
+			`{"event":"end-file"}`,
+		}
+	})
+
+	events := make(chan Event, 10)
+	go p.Watch([]string{"media-title", "pause"}, func(event Event) { events <- event })
+	for _, want := range []string{"media-title", "pause"} {
+		if got := <-observed; got != want {
+			t.Errorf("observed %q, want %q", got, want)
+		}
+	}
+	first, second := <-events, <-events
+	if first.Event != "property-change" || first.Name != "pause" || first.Data != false {
+		t.Errorf("first event %+v", first)
+	}
+	if second.Event != "end-file" {
+		t.Errorf("second event %+v; replies shouldn't pass as events", second)
+	}
+}
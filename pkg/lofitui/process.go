@@ -0,0 +1,75 @@
+package lofitui
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// startTimeout is how long a started mpv gets to open its IPC socket
+const startTimeout = 5 * time.Second
+
+// Process is an mpv run by Start, controlled over its IPC socket while it
+// plays, rather than given the terminal
+type Process struct {
+	Player
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+// Start runs mpv with args, listening on socket, and waits for the socket
+// to answer. Give it --no-terminal, or it will read the keyboard.
+func Start(socket string, args ...string) (*Process, error) {
+	cmd := exec.Command("mpv", append([]string{"--input-ipc-server=" + socket}, args...)...)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &Process{Player: Player{Socket: socket}, cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+
+	deadline := time.After(startTimeout)
+	for {
+		if _, err := p.Command("get_property", "pid"); err == nil {
+			return p, nil
+		}
+		select {
+		case <-p.exited:
+			if p.err != nil {
+				return nil, fmt.Errorf("mpv exited: %w", p.err)
+			}
+			return nil, fmt.Errorf("mpv exited")
+		case <-deadline:
+			_ = cmd.Process.Kill()
+			<-p.exited
+			return nil, fmt.Errorf("mpv didn't open its IPC socket")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Exited is closed once mpv exits
+func (p *Process) Exited() <-chan struct{} {
+	return p.exited
+}
+
+// Wait blocks until mpv exits and returns why it did, nil if it exited
+// normally
+func (p *Process) Wait() error {
+	<-p.exited
+	return p.err
+}
+
+// Stop asks mpv to quit, killing it if it hasn't within timeout
+func (p *Process) Stop(timeout time.Duration) {
+	_, _ = p.Command("quit")
+	select {
+	case <-p.exited:
+	case <-time.After(timeout):
+		_ = p.cmd.Process.Kill()
+		<-p.exited
+	}
+}
@@ -0,0 +1,14 @@
+package lofitui
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartMPVExiting(t *testing.T) {
+	fakeProgram(t, "mpv", `exit 2`)
+	if _, err := Start(filepath.Join(t.TempDir(), "mpv.sock"), "--idle"); err == nil || !strings.Contains(err.Error(), "mpv exited") {
+		t.Errorf("err = %v, want mpv exited", err)
+	}
+}
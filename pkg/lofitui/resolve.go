@@ -0,0 +1,28 @@
+package lofitui
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// BestFormat is the yt-dlp format of the best single file with both audio
+// and video
+const BestFormat = "best"
+
+// ResolveStream asks yt-dlp for the direct stream URL of a page in the
+// given format, e.g. BestFormat or "bestaudio". yt-dlp's progress is
+// written to stderr if given. Stream URLs expire, so resolve again rather
+// than keeping them.
+func ResolveStream(ctx context.Context, pageURL, format string, stderr io.Writer) (string, error) {
+	// --verbose makes yt-dlp report its progress even though -g implies
+	// --quiet; -- keeps a page URL starting with - from passing as an option
+	cmd := exec.CommandContext(ctx, "yt-dlp", "--verbose", "-f", format, "-g", "--", pageURL)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,62 @@
+package lofitui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeProgram puts a shell script on PATH under name
+func fakeProgram(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestResolveStream(t *testing.T) {
+	// Prints its arguments, one per line, as the stream URL
+	fakeProgram(t, "yt-dlp", `echo "extracting" >&2; printf '%s\n' "$@"`)
+
+	var stderr strings.Builder
+	got, err := ResolveStream(context.Background(), "-https://example.com/watch", "bestaudio", &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Split(got, "\n")
+	want := []string{"--verbose", "-f", "bestaudio", "-g", "--", "-https://example.com/watch"}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("yt-dlp ran with %q, want %q", args, want)
+	}
+	if stderr.String() != "extracting\n" {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
+
+func TestResolveStreamTrimsOutput(t *testing.T) {
+	fakeProgram(t, "yt-dlp", `printf '  https://stream.example/live.m3u8\n\n'`)
+	got, err := ResolveStream(context.Background(), "https://example.com/watch", BestFormat, nil)
+	if err != nil || got != "https://stream.example/live.m3u8" {
+		t.Errorf("ResolveStream = %q, %v", got, err)
+	}
+}
+
+func TestResolveStreamFails(t *testing.T) {
+	fakeProgram(t, "yt-dlp", `echo "ERROR: Video unavailable" >&2; exit 1`)
+	if _, err := ResolveStream(context.Background(), "https://example.com/watch", BestFormat, nil); err == nil {
+		t.Error("no error from a failed yt-dlp")
+	}
+}
+
+func TestResolveStreamCancelled(t *testing.T) {
+	fakeProgram(t, "yt-dlp", `sleep 10`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ResolveStream(ctx, "https://example.com/watch", BestFormat, nil); err == nil {
+		t.Error("no error with the context cancelled")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManagedPlaybackByDefault(t *testing.T) {
+	for name, config := range map[string]string{
+		"new config":     "",
+		"older config":   `{"background_playback": false}`,
+		"terminal optin": `{"terminal_playback": true}`,
+	} {
+		m := testModel(t)
+		if config != "" {
+			path, _ := getConfigPath()
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.config = loaded
+		}
+		m.config.Presets = getDefaultConfig().Presets
+
+		m.pushView(loadingView)
+		m.startLoading(m.config.Presets[0].URL, m.config.Presets[0].Name, 0)
+		updated, _ := m.Update(streamURLMsg{id: m.loadID, url: "https://example.com/stream", title: m.loadingTitle})
+		m = updated.(model)
+
+		managed := m.state == nowPlayingView
+		if want := name != "terminal optin"; managed != want {
+			t.Errorf("%s: played in the background = %v, want %v", name, managed, want)
+		}
+	}
+}
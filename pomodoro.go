@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pomodoroTickMsg refreshes the countdown while a Pomodoro cycle is running
+type pomodoroTickMsg struct {
+	started time.Time // Identifies the cycle that scheduled the tick
+}
+
+// pomodoro tracks a running work/break cycle
+type pomodoro struct {
+	started   time.Time
+	work      time.Duration
+	breakTime time.Duration
+}
+
+// newPomodoro starts a new cycle using the configured durations
+func newPomodoro(cfg PomodoroConfig) *pomodoro {
+	return &pomodoro{
+		started:   time.Now(),
+		work:      time.Duration(cfg.WorkMinutes) * time.Minute,
+		breakTime: time.Duration(cfg.BreakMinutes) * time.Minute,
+	}
+}
+
+// phaseAt reports whether t falls in a break, how long is left in that
+// phase, and which round (starting at 1) it belongs to
+func (p *pomodoro) phaseAt(t time.Time) (onBreak bool, remaining time.Duration, round int) {
+	cycle := p.work + p.breakTime
+	elapsed := t.Sub(p.started)
+	round = int(elapsed/cycle) + 1
+	into := elapsed % cycle
+	if into < p.work {
+		return false, p.work - into, round
+	}
+	return true, cycle - into, round
+}
+
+// status returns the countdown line shown in the TUI
+func (p *pomodoro) status() string {
+	onBreak, remaining, round := p.phaseAt(time.Now())
+	phase := "Focus"
+	if onBreak {
+		phase = "Break"
+	}
+	return fmt.Sprintf("🍅 %s #%d • %s left", phase, round, formatDuration(remaining))
+}
+
+// formatDuration renders a duration as mm:ss (or h:mm:ss when long enough)
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	mins := int(d/time.Minute) % 60
+	secs := int(d/time.Second) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, mins, secs)
+	}
+	return fmt.Sprintf("%02d:%02d", mins, secs)
+}
+
+// pomodoroTick schedules the next countdown refresh
+func pomodoroTick(started time.Time) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return pomodoroTickMsg{started: started}
+	})
+}
+
+// watchPomodoro applies the configured break action to mpv on every phase
+// change until ctx is cancelled. It runs alongside mpv, while the TUI is
+// suspended, so it talks to the player directly over IPC.
+func watchPomodoro(ctx context.Context, p *pomodoro, cfg PomodoroConfig) {
+	// Give mpv a moment to create its IPC socket
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(2 * time.Second):
+	}
+
+	savedVolume := -1.0
+	wasBreak := false
+	for {
+		onBreak, remaining, round := p.phaseAt(time.Now())
+		if onBreak && !wasBreak {
+			switch cfg.BreakAction {
+			case "duck":
+				if volume, err := mpvGetFloat("volume"); err == nil {
+					savedVolume = volume
+				}
+				_ = mpvSetProperty("volume", cfg.BreakVolume)
+			case "pause":
+				_ = mpvSetProperty("pause", true)
+			}
+			_ = mpvShowText(fmt.Sprintf("🍅 Break time! Back in %s", formatDuration(remaining)))
+		} else if !onBreak && wasBreak {
+			switch cfg.BreakAction {
+			case "duck":
+				if savedVolume >= 0 {
+					_ = mpvSetProperty("volume", savedVolume)
+				}
+			case "pause":
+				_ = mpvSetProperty("pause", false)
+			}
+			_ = mpvShowText(fmt.Sprintf("🍅 Focus round #%d", round))
+		}
+		wasBreak = onBreak
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(remaining):
+		}
+	}
+}
+
+// startPomodoroWatch starts applying the Pomodoro's breaks to the stream
+// playing, in place of any watcher of an earlier cycle, or just stops that
+// one if the Pomodoro is off or nothing is playing
+func (m *model) startPomodoroWatch() {
+	if m.stopPomodoroWatch != nil {
+		m.stopPomodoroWatch()
+		m.stopPomodoroWatch = nil
+	}
+	if title, _ := m.stream.current(); title == "" || m.pomodoro == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopPomodoroWatch = cancel
+	go watchPomodoro(ctx, m.pomodoro, m.config.Pomodoro)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// presetChange describes an edit to a preset; nil fields are left alone
+type presetChange struct {
+	Name *string
+	URL  *string
+	Tags *[]string
+}
+
+// skipPreset returns the index of the preset step places after current,
+// wrapping around; from a custom URL (current < 0) it starts at the first
+func skipPreset(current, step, count int) int {
+	if current < 0 {
+		return 0
+	}
+	return ((current+step)%count + count) % count
+}
+
+// addPreset appends a preset, refusing empty fields and duplicate names
+func addPreset(config *Config, preset Preset) error {
+	preset.Name, preset.URL = strings.TrimSpace(preset.Name), strings.TrimSpace(preset.URL)
+	if preset.Name == "" || preset.URL == "" {
+		return fmt.Errorf("name and URL can't be empty")
+	}
+	if presetIndex(config.Presets, preset.Name) >= 0 {
+		return fmt.Errorf("preset %q already exists", preset.Name)
+	}
+	config.Presets = append(config.Presets, preset)
+	return nil
+}
+
+// removePreset deletes the preset with exactly this name
+func removePreset(config *Config, name string) error {
+	index := presetIndex(config.Presets, name)
+	if index < 0 {
+		return fmt.Errorf("no preset named %q", name)
+	}
+	config.Presets = append(config.Presets[:index], config.Presets[index+1:]...)
+	return nil
+}
+
+// updatePreset changes the preset with exactly this name, keeping its play
+// stats. Empty names and URLs are ignored; empty tags clear them.
+func updatePreset(config *Config, name string, change presetChange) error {
+	index := presetIndex(config.Presets, name)
+	if index < 0 {
+		return fmt.Errorf("no preset named %q", name)
+	}
+	preset := &config.Presets[index]
+
+	if change.Name != nil {
+		if newName := strings.TrimSpace(*change.Name); newName != "" {
+			if other := presetIndex(config.Presets, newName); other >= 0 && other != index {
+				return fmt.Errorf("preset %q already exists", newName)
+			}
+			preset.Name = newName
+		}
+	}
+	if change.URL != nil {
+		if url := strings.TrimSpace(*change.URL); url != "" {
+			preset.URL = url
+		}
+	}
+	if change.Tags != nil {
+		preset.Tags = *change.Tags
+	}
+	return nil
+}
+
+// presetIndex returns the index of the preset with exactly this name
+// (ignoring case), or -1. Scripts changing presets get no partial matches.
+func presetIndex(presets []Preset, name string) int {
+	for i, preset := range presets {
+		if strings.EqualFold(preset.Name, strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findPreset looks a preset up by name: an exact match (ignoring case)
+// wins, otherwise the name must match exactly one preset partially
+func findPreset(presets []Preset, name string) (int, error) {
+	needle := strings.ToLower(name)
+	var matches []int
+	for i, preset := range presets {
+		if strings.ToLower(preset.Name) == needle {
+			return i, nil
+		}
+		if strings.Contains(strings.ToLower(preset.Name), needle) {
+			matches = append(matches, i)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return -1, fmt.Errorf("no preset named %q", name)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, index := range matches {
+			names[i] = presets[index].Name
+		}
+		return -1, fmt.Errorf("%q matches several presets: %s", name, strings.Join(names, ", "))
+	}
+}
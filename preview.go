@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// How long the cursor must rest on a preset before its preview is fetched,
+// and how long a fetched preview stays fresh
+const (
+	previewDelay   = time.Second
+	previewTTL     = 10 * time.Minute
+	previewTimeout = 30 * time.Second
+)
+
+// streamPreview is what we know about a preset's stream right now
+type streamPreview struct {
+	title      string
+	liveStatus string // yt-dlp's live_status: is_live, not_live, is_upcoming, was_live...
+	err        error
+	fetched    time.Time
+}
+
+// previewHoverMsg fires once the cursor has rested on a preset
+type previewHoverMsg struct {
+	url string
+}
+
+// previewMsg carries a fetched preview
+type previewMsg struct {
+	url     string
+	preview streamPreview
+}
+
+// previewHover waits for the cursor to rest on url
+func previewHover(url string) tea.Cmd {
+	return tea.Tick(previewDelay, func(time.Time) tea.Msg {
+		return previewHoverMsg{url: url}
+	})
+}
+
+// fetchPreview asks yt-dlp for a stream's current title and live status
+func fetchPreview(url string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "yt-dlp", "--skip-download", "--no-warnings", "--no-playlist",
+			"--print", "%(live_status)s|%(title)s", "--", url)
+		output, err := cmd.Output()
+		preview := streamPreview{fetched: time.Now(), err: err}
+		if err == nil {
+			status, title, _ := strings.Cut(strings.TrimSpace(string(output)), "|")
+			preview.liveStatus = status
+			preview.title = title
+		}
+		return previewMsg{url: url, preview: preview}
+	}
+}
+
+// selectedURL returns the URL of the preset under the cursor
+func (m model) selectedURL() string {
+	if preset, ok := m.list.SelectedItem().(presetItem); ok {
+		return preset.URL
+	}
+	return ""
+}
+
+// previewLine renders the preview of the preset under the cursor
+func (m model) previewLine() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	url := m.selectedURL()
+	if url == "" {
+		return ""
+	}
+
+	preview, ok := m.previews[url]
+	if !ok {
+		if m.previewPending[url] {
+			return muted.Render("  checking stream…")
+		}
+		return ""
+	}
+	if preview.err != nil {
+		return lipgloss.NewStyle().Foreground(theme.Danger).Render("  ✕ unavailable")
+	}
+
+	var badge string
+	switch preview.liveStatus {
+	case "is_live":
+		badge = lipgloss.NewStyle().Foreground(theme.Danger).Render("● LIVE")
+	case "is_upcoming":
+		badge = lipgloss.NewStyle().Foreground(theme.Warning).Render("◷ UPCOMING")
+	case "was_live", "post_live":
+		badge = muted.Render("○ OFFLINE")
+	default:
+		badge = muted.Render("▶ VIDEO")
+	}
+	return "  " + badge + " " + muted.Render(preview.title)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// streamQuality is a yt-dlp format to fall back to on a slow connection
+type streamQuality struct {
+	format string // yt-dlp's -f
+	label  string // Shown in the Now Playing view, empty at full quality
+}
+
+// qualityLevels are tried in turn while the connection can't keep up.
+// Live streams have no audio-only format, so the last falls back to the
+// smallest video.
+var qualityLevels = []streamQuality{
+	{format: "best"},
+	{format: "best[height<=480]/worst", label: "480p"},
+	{format: audioOnlyFormat, label: "audio only"},
+}
+
+// audioOnlyFormat fetches just the audio where there's an audio-only
+// format, and the smallest video where there isn't
+const audioOnlyFormat = "bestaudio/worst"
+
+// qualityTier is a quality to play at, chosen for a preset or just before
+// playing it
+type qualityTier struct {
+	id     string // As saved in a preset's "quality"
+	label  string
+	format string // yt-dlp's -f
+}
+
+// qualityTiers are the qualities to choose from, best first. The smaller
+// ones fall back to the smallest video there is.
+var qualityTiers = []qualityTier{
+	{"best", "Best", "best"},
+	{"720p", "720p", "best[height<=720]/worst"},
+	{"480p", "480p", "best[height<=480]/worst"},
+	{"360p", "360p", "best[height<=360]/worst"},
+	{"144p", "144p", "best[height<=144]/worst"},
+	{"audio", "Audio only", audioOnlyFormat},
+}
+
+// findQualityTier looks a tier up by id; "" is the best
+func findQualityTier(id string) (qualityTier, bool) {
+	if id == "" {
+		return qualityTiers[0], true
+	}
+	for _, tier := range qualityTiers {
+		if strings.EqualFold(tier.id, id) {
+			return tier, true
+		}
+	}
+	return qualityTier{}, false
+}
+
+// streamFormat is the yt-dlp format to extract: audio only in audio-only
+// mode, so no video is downloaded just to be thrown away, else the tier
+// chosen until the connection forces a lower one
+func (m model) streamFormat() string {
+	if m.audioOnly {
+		return audioOnlyFormat
+	}
+	if tier, ok := findQualityTier(m.tier); ok && m.quality == 0 {
+		return tier.format
+	}
+	return qualityLevels[m.quality].format
+}
+
+// openQualityPicker shows the qualities for the preset under the cursor,
+// starting on its own
+func (m *model) openQualityPicker() {
+	preset, ok := m.list.SelectedItem().(presetItem)
+	if !ok || preset.index >= len(m.config.Presets) {
+		return
+	}
+	m.pushView(qualityView)
+	m.selectedIndex = preset.index
+	m.qualityCursor = 0
+	for i, tier := range qualityTiers {
+		if tier.id == m.config.Presets[preset.index].Quality {
+			m.qualityCursor = i
+		}
+	}
+}
+
+// qualityContent renders the quality picker
+func (m model) qualityContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	// The preset may have gone in a config merge meanwhile
+	if m.selectedIndex >= len(m.config.Presets) {
+		return "This preset no longer exists\n\n" + muted.Render("ESC=back")
+	}
+	preset := m.config.Presets[m.selectedIndex]
+	saved, _ := findQualityTier(preset.Quality)
+
+	var rows []string
+	for i, tier := range qualityTiers {
+		row := tier.label
+		if tier.id == saved.id {
+			row += muted.Render(" (default)")
+		}
+		if i == m.qualityCursor {
+			row = lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• ") + row
+		} else {
+			row = "  " + row
+		}
+		rows = append(rows, row)
+	}
+	help := "Enter=play • d=make default • ESC=back"
+	return "Quality for " + preset.Name + "\n\n" + strings.Join(rows, "\n") + "\n\n" + muted.Render(help)
+}
+
+const (
+	// qualityStalls is how many times mpv may run out of cache within
+	// qualityWindow before the stream drops to a lower quality
+	qualityStalls = 3
+	qualityWindow = 2 * time.Minute
+)
+
+// noteStall records mpv running out of cache and reports whether that's
+// happened often enough to try a lower quality
+func (m *model) noteStall() bool {
+	now := time.Now()
+	recent := m.stalls[:0]
+	for _, at := range m.stalls {
+		if now.Sub(at) < qualityWindow {
+			recent = append(recent, at)
+		}
+	}
+	m.stalls = append(recent, now)
+	// A quality chosen by hand is kept, even if it stalls
+	if tier, ok := findQualityTier(m.tier); ok && tier.id != "best" {
+		return false
+	}
+	return len(m.stalls) >= qualityStalls && m.quality < len(qualityLevels)-1
+}
+
+// lowerQuality quits mpv so the stream is loaded again at the next
+// quality down, which streamEndedMsg picks up
+func (m *model) lowerQuality() tea.Cmd {
+	m.quality++
+	m.stalls = nil
+	m.switchingQuality = true
+	label := qualityLevels[m.quality].label
+	slog.Warn("connection can't keep up, lowering quality", "title", m.nowPlaying, "quality", label)
+	m.qualityNotice = fmt.Sprintf("Slow connection, switched to %s", label)
+	return func() tea.Msg {
+		_ = quitMPV()
+		return nil
+	}
+}
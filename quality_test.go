@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestQualityPickerWithPresetGone(t *testing.T) {
+	for _, key := range []string{"d", "enter"} {
+		m := testModel(t)
+		updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+		m = updated.(model)
+		m.openQualityPicker()
+		if m.state != qualityView {
+			t.Fatal("quality picker didn't open")
+		}
+		// A config merge or reload took the presets away
+		m.config.Presets = nil
+
+		_ = m.View()
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		if key == "enter" {
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+		updated, _ = m.Update(msg)
+		m = updated.(model)
+		_ = m.View()
+	}
+}
+
+func TestQualityPickerSavesDefault(t *testing.T) {
+	m := testModel(t)
+	m.openQualityPicker()
+	m.qualityCursor = 2
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	m = updated.(model)
+	if got := m.config.Presets[m.selectedIndex].Quality; got != qualityTiers[2].id {
+		t.Errorf("saved quality %q, want %q", got, qualityTiers[2].id)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// reconnectBaseDelay is the wait before the first reconnect, doubling
+	// for each one after it up to reconnectMaxDelay
+	reconnectBaseDelay = 2 * time.Second
+	reconnectMaxDelay  = time.Minute
+	// reconnectSettled is how long a stream has to play before a drop
+	// starts the count of attempts over
+	reconnectSettled = time.Minute
+	// stallTimeout is how long mpv may wait for its cache to fill before
+	// the connection is taken for lost
+	stallTimeout = 30 * time.Second
+)
+
+// reconnectMsg is sent when it's time to try a dropped stream again
+type reconnectMsg struct {
+	id int // Matches model.loadID while the reconnect is still wanted
+}
+
+// reconnectDelay returns how long to wait before reconnect attempt n,
+// counting from 1
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectBaseDelay
+	for i := 1; i < attempt && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > reconnectMaxDelay {
+		delay = reconnectMaxDelay
+	}
+	return delay
+}
+
+// shouldReconnect reports whether a stream that ended by itself most
+// likely dropped and has attempts left. A VOD that played to the end
+// just finished.
+func (m *model) shouldReconnect() bool {
+	if m.loadingURL == "" {
+		return false
+	}
+	if m.playback.duration > 0 && m.playback.position >= m.playback.duration-5 {
+		return false
+	}
+	if !m.playingSince.IsZero() && time.Since(m.playingSince) > reconnectSettled {
+		m.reconnects = 0
+	}
+	return m.reconnects < m.config.MaxReconnects
+}
+
+// scheduleReconnect shows the loading view as reconnecting and reloads
+// the stream after the next backoff delay. VODs pick up where they were.
+func (m *model) scheduleReconnect() tea.Cmd {
+	m.reconnects++
+	if m.playback.duration > 0 && m.playback.position > 0 {
+		m.resumeAt = m.playback.position
+	}
+	delay := reconnectDelay(m.reconnects)
+	slog.Warn("stream dropped, reconnecting", "title", m.loadingTitle, "url", m.loadingURL,
+		"attempt", m.reconnects, "delay", delay)
+
+	m.zen = false
+	m.replaceView(loadingView)
+	m.loadingSince = time.Now()
+	m.extractLog = nil
+	m.stream.set("", "")
+
+	// ESC while waiting cancels the load, which drops the pending reconnect
+	m.loadID++
+	id := m.loadID
+	return tea.Batch(spinner.Tick, tea.Tick(delay, func(time.Time) tea.Msg {
+		return reconnectMsg{id: id}
+	}))
+}
+
+// reload loads the stream that was playing again, e.g. after it dropped,
+// carrying on the same play at the same quality
+func (m *model) reload() tea.Cmd {
+	m.resuming = true
+	return m.extract()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// recordingCheck is how often a recording is checked against its caps
+const recordingCheck = 5 * time.Second
+
+// recordingDirectory returns where recordings go: the configured
+// directory, else ~/Music/lofitui
+func recordingDirectory(cfg RecordingConfig) (string, error) {
+	if cfg.Directory != "" {
+		if rest, ok := strings.CutPrefix(cfg.Directory, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(home, rest), nil
+		}
+		return cfg.Directory, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Music", "lofitui"), nil
+}
+
+// recordingFile names a recording after the stream and when it started,
+// e.g. "Lofi Girl - Study 2024-05-01 21.30.00.mkv"
+func recordingFile(dir, title string, started time.Time) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = "lofitui"
+	}
+	// Matroska holds whatever the stream carries
+	return filepath.Join(dir, fmt.Sprintf("%s %s.mkv", name, started.Format("2006-01-02 15.04.05")))
+}
+
+// startRecording has mpv copy the stream it's playing to a new file in the
+// recordings directory, returning the file's path
+func startRecording(cfg RecordingConfig, title string) (string, error) {
+	dir, err := recordingDirectory(cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := recordingFile(dir, title, time.Now())
+	if err := mpvSetProperty("stream-record", path); err != nil {
+		return "", err
+	}
+	slog.Info("recording", "title", title, "file", path)
+	return path, nil
+}
+
+// stopRecording has mpv stop copying the stream
+func stopRecording() error {
+	return mpvSetProperty("stream-record", "")
+}
+
+// isRecording reports whether mpv is still recording to path
+func isRecording(path string) bool {
+	current, err := mpvGetString("stream-record")
+	return err == nil && current == path
+}
+
+// watchRecording stops a recording once it reaches the configured length
+// or size, until ctx is cancelled or the recording stops some other way
+func watchRecording(ctx context.Context, path string, cfg RecordingConfig) {
+	if cfg.MaxMinutes <= 0 && cfg.MaxMB <= 0 {
+		return
+	}
+	started := time.Now()
+	ticker := time.NewTicker(recordingCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !isRecording(path) {
+			return
+		}
+		reason := ""
+		if cfg.MaxMinutes > 0 && time.Since(started) >= time.Duration(cfg.MaxMinutes)*time.Minute {
+			reason = "time"
+		}
+		if info, err := os.Stat(path); err == nil && cfg.MaxMB > 0 && info.Size() >= int64(cfg.MaxMB)<<20 {
+			reason = "size"
+		}
+		if reason != "" {
+			slog.Info("recording reached its cap", "file", path, "cap", reason)
+			_ = stopRecording()
+			return
+		}
+	}
+}
+
+// recordingMsg reports a recording started by the TUI
+type recordingMsg struct {
+	path string
+	err  error
+}
+
+// toggleRecording starts recording the stream playing in the background,
+// or stops the recording in progress
+func (m *model) toggleRecording() tea.Cmd {
+	if m.recording != "" {
+		m.recordNotice = "Saved recording to " + m.recording
+		m.endRecording()
+		return func() tea.Msg {
+			_ = stopRecording()
+			return nil
+		}
+	}
+	cfg, title := m.config.Recording, m.nowPlaying
+	return func() tea.Msg {
+		path, err := startRecording(cfg, title)
+		return recordingMsg{path: path, err: err}
+	}
+}
+
+// startedRecording notes a recording has started and enforces its caps
+func (m *model) startedRecording(path string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.recording, m.recordingSince, m.stopRecordingWatch = path, time.Now(), cancel
+	m.recordNotice = ""
+	go watchRecording(ctx, path, m.config.Recording)
+}
+
+// endRecording forgets the recording in progress, if any
+func (m *model) endRecording() {
+	if m.stopRecordingWatch != nil {
+		m.stopRecordingWatch()
+		m.stopRecordingWatch = nil
+	}
+	m.recording = ""
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// startRemote starts the HTTP remote control if it's enabled in the config.
+// Every request must carry the token, as "Authorization: Bearer <token>" or
+// "?token=<token>" for browsers and Stream Deck buttons.
+func (d *daemon) startRemote() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if !config.Remote.Enabled {
+		return nil
+	}
+	if config.Remote.Token == "" {
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		config.Remote.Token = hex.EncodeToString(token)
+		if err := saveConfig(config); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Generated a remote control token (saved in the config): %s\n", config.Remote.Token)
+	}
+
+	listener, err := net.Listen("tcp", config.Remote.Address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	for _, command := range []string{"play", "pause", "stop", "next", "prev", "volume"} {
+		mux.HandleFunc("/"+command, d.commandHandler(command))
+	}
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		status := d.currentStatus()
+		d.mu.Unlock()
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("/events", d.serveEvents)
+	mux.HandleFunc("/presets", func(w http.ResponseWriter, r *http.Request) {
+		config, err := loadConfig()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, daemonResponse{Message: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, presetEntries(config.Presets))
+	})
+
+	go http.Serve(listener, requireToken(config.Remote.Token, mux))
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Remote control listening on http://%s\n", listener.Addr())
+	}
+	return nil
+}
+
+// commandHandler runs a daemon command. /play takes ?preset=<name> or
+// ?url=<url>, /volume takes ?value=<N|+N|-N>.
+func (d *daemon) commandHandler(command string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args []string
+		query := r.URL.Query()
+		for _, param := range []string{"preset", "url", "value"} {
+			if value := strings.TrimSpace(query.Get(param)); value != "" {
+				args = append(args, value)
+			}
+		}
+
+		message, err := d.handle(daemonRequest{Command: command, Args: args})
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, daemonResponse{Message: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, daemonResponse{OK: true, Message: message})
+	}
+}
+
+// requireToken rejects requests without the right token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.URL.Query().Get("token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			given = bearer
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, daemonResponse{Message: "missing or wrong token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON sends v as a JSON reply
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
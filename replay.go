@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// clipTimeout bounds how long mpv may take to write a clip out of its cache
+const clipTimeout = 30 * time.Second
+
+// replayArgs has mpv keep the configured amount of the stream behind the
+// playback position, which is what clips are cut from. Without it mpv
+// keeps its default, usually a minute or two.
+func replayArgs(cfg ReplayConfig) []string {
+	if !cfg.Enabled {
+		return nil
+	}
+	return []string{"--cache=yes", fmt.Sprintf("--demuxer-max-back-bytes=%dMiB", cfg.BufferMB)}
+}
+
+// saveClip writes the last few minutes mpv has cached to a new file in the
+// recordings directory, returning the file's path
+func saveClip(replay ReplayConfig, recording RecordingConfig, title string) (string, error) {
+	pos, err := mpvGetFloat("time-pos")
+	if err != nil {
+		return "", err
+	}
+	dir, err := recordingDirectory(recording)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := recordingFile(dir, title+" clip", time.Now())
+
+	// mpv starts the clip wherever its cache does if that's later
+	var start interface{} = pos - float64(replay.Minutes*60)
+	if pos <= float64(replay.Minutes*60) {
+		start = "no"
+	}
+	if _, err := mpvCommandWithin(clipTimeout, "dump-cache", start, pos, path); err != nil {
+		return "", err
+	}
+	slog.Info("saved clip", "title", title, "file", path)
+	return path, nil
+}
+
+// clipMsg reports a clip saved by the TUI
+type clipMsg struct {
+	path string
+	err  error
+}
+
+// clip saves the last few minutes of the stream playing in the background
+func (m *model) clip() tea.Cmd {
+	replay, recording, title := m.config.Replay, m.config.Recording, m.nowPlaying
+	m.recordNotice = "Saving the last few minutes…"
+	return func() tea.Msg {
+		path, err := saveClip(replay, recording, title)
+		return clipMsg{path: path, err: err}
+	}
+}
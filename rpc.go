@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// rpcRequest is a JSON-RPC 2.0 call on the daemon socket. Requests
+// without an id are notifications and get no reply.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is the reply to a JSON-RPC call
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcMethods maps method names to their handlers. Player methods reply
+// with the daemon's status after the call; preset methods with the presets.
+var rpcMethods = map[string]func(d *daemon, params json.RawMessage) (interface{}, error){
+	"player.play":    (*daemon).rpcPlay,
+	"player.pause":   func(d *daemon, _ json.RawMessage) (interface{}, error) { return d.rpcPause(true) },
+	"player.resume":  func(d *daemon, _ json.RawMessage) (interface{}, error) { return d.rpcPause(false) },
+	"player.stop":    func(d *daemon, _ json.RawMessage) (interface{}, error) { return d.rpcCommand("stop") },
+	"player.next":    func(d *daemon, _ json.RawMessage) (interface{}, error) { return d.rpcCommand("next") },
+	"player.prev":    func(d *daemon, _ json.RawMessage) (interface{}, error) { return d.rpcCommand("prev") },
+	"player.volume":  (*daemon).rpcVolume,
+	"player.status":  func(d *daemon, _ json.RawMessage) (interface{}, error) { return d.rpcStatus() },
+	"presets.list":   func(d *daemon, _ json.RawMessage) (interface{}, error) { return d.rpcPresets(nil) },
+	"presets.add":    (*daemon).rpcAddPreset,
+	"presets.remove": (*daemon).rpcRemovePreset,
+	"presets.update": (*daemon).rpcUpdatePreset,
+}
+
+// handleRPC answers one JSON-RPC request line, returning nil for
+// notifications
+func (d *daemon) handleRPC(line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return rpcFailure(nil, &rpcError{rpcParseError, "parse error: " + err.Error()})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(req.ID, &rpcError{rpcInvalidRequest, "invalid request"})
+	}
+
+	var result interface{}
+	method, ok := rpcMethods[req.Method]
+	err := error(&rpcError{rpcMethodNotFound, "method not found: " + req.Method})
+	if ok {
+		result, err = method(d, req.Params)
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		rpcErr, ok := err.(*rpcError)
+		if !ok {
+			rpcErr = &rpcError{rpcServerError, err.Error()}
+		}
+		return rpcFailure(req.ID, rpcErr)
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// rpcFailure builds an error reply; a missing id is sent as null
+func rpcFailure(id json.RawMessage, err *rpcError) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+// rpcParams decodes named params, which may be left out entirely
+func rpcParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{rpcInvalidParams, "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+// rpcCommand runs a ctl command and replies with the resulting status
+func (d *daemon) rpcCommand(command string, args ...string) (interface{}, error) {
+	if _, err := d.handle(daemonRequest{Command: command, Args: args}); err != nil {
+		return nil, err
+	}
+	return d.rpcStatus()
+}
+
+// rpcStatus replies with what the daemon is doing
+func (d *daemon) rpcStatus() (interface{}, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.currentStatus(), nil
+}
+
+// rpcPlay starts a preset by name or a URL: {"preset": "..."} or {"url": "..."}
+func (d *daemon) rpcPlay(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Preset string `json:"preset"`
+		URL    string `json:"url"`
+	}
+	if err := rpcParams(params, &p); err != nil {
+		return nil, err
+	}
+	target := p.Preset
+	if p.URL != "" {
+		target = p.URL
+	}
+	if target == "" {
+		return nil, &rpcError{rpcInvalidParams, "player.play needs a preset or url"}
+	}
+	return d.rpcCommand("play", target)
+}
+
+// rpcPause pauses or resumes playback
+func (d *daemon) rpcPause(pause bool) (interface{}, error) {
+	d.mu.Lock()
+	err := mpvSetProperty("pause", pause)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.rpcStatus()
+}
+
+// rpcVolume sets the volume: {"value": 60}, or {"value": 5, "relative": true}
+func (d *daemon) rpcVolume(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Value    *float64 `json:"value"`
+		Relative bool     `json:"relative"`
+	}
+	if err := rpcParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Value == nil {
+		return nil, &rpcError{rpcInvalidParams, "player.volume needs a value"}
+	}
+	arg := strconv.FormatFloat(*p.Value, 'f', -1, 64)
+	if p.Relative && *p.Value >= 0 {
+		arg = "+" + arg
+	} else if !p.Relative && *p.Value < 0 {
+		return nil, &rpcError{rpcInvalidParams, "volume can't be negative"}
+	}
+	return d.rpcCommand("volume", arg)
+}
+
+// rpcPresets loads the config, applies change to it (saving if there was
+// one) and replies with the presets
+func (d *daemon) rpcPresets(change func(*Config) error) (interface{}, error) {
+	// Playing a preset saves its stats, so config writes go under d.mu
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		if err := change(config); err != nil {
+			return nil, err
+		}
+		if err := saveConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	return presetEntries(config.Presets), nil
+}
+
+// rpcAddPreset adds a preset: {"name", "url", "tags"}
+func (d *daemon) rpcAddPreset(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name string   `json:"name"`
+		URL  string   `json:"url"`
+		Tags []string `json:"tags"`
+	}
+	if err := rpcParams(params, &p); err != nil {
+		return nil, err
+	}
+	return d.rpcPresets(func(config *Config) error {
+		return addPreset(config, Preset{Name: p.Name, URL: p.URL, Tags: p.Tags})
+	})
+}
+
+// rpcRemovePreset removes a preset: {"name"}
+func (d *daemon) rpcRemovePreset(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if err := rpcParams(params, &p); err != nil {
+		return nil, err
+	}
+	return d.rpcPresets(func(config *Config) error {
+		return removePreset(config, p.Name)
+	})
+}
+
+// rpcUpdatePreset changes a preset: {"name", "new_name"?, "url"?, "tags"?}.
+// Fields left out are kept; "tags": [] clears the tags.
+func (d *daemon) rpcUpdatePreset(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name    string    `json:"name"`
+		NewName *string   `json:"new_name"`
+		URL     *string   `json:"url"`
+		Tags    *[]string `json:"tags"`
+	}
+	if err := rpcParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		return nil, &rpcError{rpcInvalidParams, "presets.update needs a name"}
+	}
+	return d.rpcPresets(func(config *Config) error {
+		return updatePreset(config, p.Name, presetChange{Name: p.NewName, URL: p.URL, Tags: p.Tags})
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// scheduleCheck is how often the scheduler looks for recordings that are due
+const scheduleCheck = 15 * time.Second
+
+// weekdays are the day names schedules take, by their first three letters
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseWeekday reads a day name such as "sat" or "Saturday"
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) < 3 {
+		return 0, false
+	}
+	day, ok := weekdays[name[:3]]
+	return day, ok
+}
+
+// nextRecordingTime returns when a scheduled recording next starts after
+// now: daily at its time, or weekly on its weekday
+func nextRecordingTime(rec ScheduledRecording, now time.Time) (time.Time, error) {
+	next, err := nextAlarmTime(rec.Time, now)
+	if err != nil || rec.Weekday == "" {
+		return next, err
+	}
+	day, ok := parseWeekday(rec.Weekday)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown weekday %q", rec.Weekday)
+	}
+	for next.Weekday() != day {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// parseSchedule reads the recordings view's input, "[weekday] HH:MM
+// minutes", e.g. "sat 21:00 60" for a weekly hour or "07:30 15" daily
+func parseSchedule(input string) (ScheduledRecording, error) {
+	var rec ScheduledRecording
+	fields := strings.Fields(input)
+	if len(fields) == 3 {
+		if _, ok := parseWeekday(fields[0]); !ok {
+			return rec, fmt.Errorf("unknown weekday %q", fields[0])
+		}
+		rec.Weekday = strings.ToLower(fields[0][:3])
+		fields = fields[1:]
+	}
+	if len(fields) != 2 {
+		return rec, fmt.Errorf("use [weekday] HH:MM minutes, e.g. sat 21:00 60")
+	}
+	clock, err := time.Parse("15:04", fields[0])
+	if err != nil {
+		return rec, fmt.Errorf("invalid time %q, use HH:MM", fields[0])
+	}
+	rec.Time = clock.Format("15:04")
+	rec.Minutes, err = strconv.Atoi(fields[1])
+	if err != nil || rec.Minutes <= 0 {
+		return rec, fmt.Errorf("invalid length %q, use minutes", fields[1])
+	}
+	return rec, nil
+}
+
+// describeSchedule renders a scheduled recording for the recordings view
+func describeSchedule(rec ScheduledRecording) string {
+	when := "Daily"
+	if day, ok := parseWeekday(rec.Weekday); ok {
+		when = day.String()[:3]
+	}
+	return fmt.Sprintf("%s %s  %d min  %s", when, rec.Time, rec.Minutes, rec.Preset)
+}
+
+// runRecordingScheduler starts the scheduled recordings as they come due,
+// until ctx is cancelled. The config is read again on each check so
+// changes from the TUI are picked up. Outside the daemon it leaves
+// recordings to the daemon when one is running, so they aren't made twice.
+func runRecordingScheduler(ctx context.Context, inDaemon bool) {
+	next := map[ScheduledRecording]time.Time{}
+	ticker := time.NewTicker(scheduleCheck)
+	defer ticker.Stop()
+	for {
+		config, err := loadConfig()
+		if err == nil {
+			now := time.Now()
+			for _, rec := range config.ScheduledRecordings {
+				at, ok := next[rec]
+				if !ok {
+					if at, err = nextRecordingTime(rec, now); err == nil {
+						next[rec] = at
+					}
+					continue
+				}
+				if now.Before(at) {
+					continue
+				}
+				next[rec], _ = nextRecordingTime(rec, now)
+				if !inDaemon && daemonRunning() {
+					continue
+				}
+				go recordScheduled(config, rec)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// daemonRunning reports whether the daemon answers on its socket
+func daemonRunning() bool {
+	conn, err := net.Dial("unix", daemonSocketPath())
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// recordScheduled records a preset for the scheduled length with a muted
+// mpv of its own, leaving whatever is playing alone
+func recordScheduled(config *Config, rec ScheduledRecording) {
+	index, err := findPreset(config.Presets, rec.Preset)
+	if err != nil {
+		slog.Error("scheduled recording failed", "preset", rec.Preset, "err", err)
+		return
+	}
+	preset := config.Presets[index]
+	dir, err := recordingDirectory(config.Recording)
+	if err == nil {
+		err = os.MkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		slog.Error("scheduled recording failed", "preset", preset.Name, "err", err)
+		return
+	}
+
+	length := time.Duration(rec.Minutes) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), length+time.Minute)
+	defer cancel()
+	streamURL, err := resolveStreamURL(ctx, preset.URL, nil)
+	if err != nil {
+		slog.Error("scheduled recording failed", "preset", preset.Name, "err", err)
+		return
+	}
+
+	path := recordingFile(dir, preset.Name, time.Now())
+	slog.Info("scheduled recording started", "preset", preset.Name, "file", path, "minutes", rec.Minutes)
+	// --length ends it even if we're gone by then; the context is a backstop
+	cmd := exec.CommandContext(ctx, "mpv", "--no-video", "--ao=null", "--no-terminal",
+		"--stream-record="+path, fmt.Sprintf("--length=%d", int(length.Seconds())), streamURL)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = 10 * time.Second
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		slog.Error("scheduled recording failed", "preset", preset.Name, "file", path, "err", err)
+		return
+	}
+	slog.Info("scheduled recording finished", "preset", preset.Name, "file", path)
+}
+
+// recordingsContent renders the recordings view
+func (m model) recordingsContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	var rows []string
+	if len(m.config.ScheduledRecordings) == 0 {
+		rows = append(rows, muted.Render("Nothing scheduled"))
+	}
+	now := time.Now()
+	for i, rec := range m.config.ScheduledRecordings {
+		label := describeSchedule(rec)
+		if next, err := nextRecordingTime(rec, now); err == nil {
+			label += muted.Render("  next " + next.Format("Mon Jan 2 15:04"))
+		}
+		if i == m.scheduleCursor {
+			rows = append(rows, lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render("• ")+label)
+		} else {
+			rows = append(rows, "  "+label)
+		}
+	}
+
+	presetName := ""
+	if m.selectedIndex < len(m.config.Presets) {
+		presetName = m.config.Presets[m.selectedIndex].Name
+	}
+	content := fmt.Sprintf("Scheduled Recordings\n\n%s\n\nRecord '%s' at:\n%s", strings.Join(rows, "\n"), presetName, m.scheduleInput.View())
+	if m.scheduleError != "" {
+		content += "\n" + lipgloss.NewStyle().Foreground(theme.Danger).Render(m.scheduleError)
+	}
+	return content + "\n\n" + muted.Render("[weekday] HH:MM minutes, e.g. sat 21:00 60 • Enter to add • ↑/↓ and ctrl+d to delete • ESC to go back")
+}
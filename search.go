@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// matchStyle highlights the part of a preset that matched the filter
+var matchStyle = lipgloss.NewStyle().Underline(true)
+
+// FilterValue makes name, tags and URL all searchable. Fields are joined by
+// single spaces so the delegate can map match positions back to each field.
+func (p Preset) FilterValue() string {
+	return p.Name + " " + strings.Join(p.Tags, " ") + " " + p.URL
+}
+
+// presetFilter is a case-insensitive substring match over each preset's
+// FilterValue. The list's default fuzzy filter finds a scattered match in
+// almost any long URL, which makes searching URLs useless.
+func presetFilter(term string, targets []string) []list.Rank {
+	needle := []rune(strings.ToLower(term))
+	var ranks []list.Rank
+	for i, target := range targets {
+		haystack := []rune(strings.ToLower(target))
+		start := indexRunes(haystack, needle)
+		if start < 0 {
+			continue
+		}
+		matched := make([]int, len(needle))
+		for j := range needle {
+			matched[j] = start + j
+		}
+		ranks = append(ranks, list.Rank{Index: i, MatchedIndexes: matched})
+	}
+	return ranks
+}
+
+// indexRunes returns the position of needle in haystack, or -1
+func indexRunes(haystack, needle []rune) int {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if string(haystack[i:i+len(needle)]) == string(needle) {
+			return i
+		}
+	}
+	return -1
+}
+
+// highlightField renders one field of a FilterValue, highlighting the
+// matches that fall inside it. start is the field's rune offset.
+func highlightField(field string, start int, matches []int, base lipgloss.Style) string {
+	var local []int
+	length := len([]rune(field))
+	for _, i := range matches {
+		if i >= start && i < start+length {
+			local = append(local, i-start)
+		}
+	}
+	if len(local) == 0 {
+		return base.Render(field)
+	}
+	return lipgloss.StyleRunes(field, local, base.Inherit(matchStyle), base)
+}
+
+// matchesIn reports whether any match falls in [start, start+length)
+func matchesIn(matches []int, start, length int) bool {
+	for _, i := range matches {
+		if i >= start && i < start+length {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTags splits a comma separated tag list, dropping empty entries
+func parseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
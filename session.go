@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sessionSaveInterval is how often the TUI saves its session, and so how
+// much a crash can lose
+const sessionSaveInterval = 10 * time.Second
+
+// savedSession is what the TUI was doing, saved as it runs and removed
+// when it exits cleanly. Finding one on startup means the last run crashed
+// or was killed.
+type savedSession struct {
+	PID      int       `json:"pid"`
+	View     string    `json:"view"` // "menu", "manage" or "playing"
+	Title    string    `json:"title,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Index    int       `json:"index"`              // The preset playing, -1 for custom URLs
+	Volume   float64   `json:"volume,omitempty"`   // mpv's volume, 0-100
+	Position float64   `json:"position,omitempty"` // Seconds into a VOD
+	SavedAt  time.Time `json:"saved_at"`
+}
+
+// getSessionPath returns where the running TUI's session is saved
+func getSessionPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "session.json"), nil
+}
+
+// writeSession saves the session, replacing the file in one go so a crash
+// mid-write can't leave half of it. Errors are dropped, like the history's.
+func writeSession(session savedSession) {
+	path, err := getSessionPath()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
+
+// clearSession removes the saved session, on a clean exit or once the
+// user has decided about restoring it
+func clearSession() {
+	if path, err := getSessionPath(); err == nil {
+		os.Remove(path)
+	}
+}
+
+// loadCrashedSession returns the session of a TUI that didn't exit
+// cleanly, if it was playing something. A session still being saved by
+// another running TUI is left alone.
+func loadCrashedSession() (savedSession, bool) {
+	var session savedSession
+	path, err := getSessionPath()
+	if err != nil {
+		return session, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return session, false
+	}
+	if json.Unmarshal(data, &session) != nil {
+		clearSession()
+		return session, false
+	}
+	if session.PID != os.Getpid() && processAlive(session.PID) {
+		return session, false
+	}
+	// Nothing was playing, so there's nothing worth offering
+	if session.View != "playing" || session.URL == "" {
+		clearSession()
+		return session, false
+	}
+	return session, true
+}
+
+// processAlive reports whether a process is running
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// sessionTickMsg saves the session
+type sessionTickMsg struct{}
+
+// sessionTick schedules the next save of the session
+func sessionTick() tea.Cmd {
+	return tea.Tick(sessionSaveInterval, func(time.Time) tea.Msg {
+		return sessionTickMsg{}
+	})
+}
+
+// saveSession saves what the TUI is doing; mpv is asked for the volume and
+// position off the update loop
+func (m model) saveSession() tea.Cmd {
+	session := savedSession{PID: os.Getpid(), View: "menu", Index: -1}
+	if m.state == managePresetsView || slices.Contains(m.navStack, managePresetsView) {
+		session.View = "manage"
+	}
+	if title, url := m.stream.current(); url != "" {
+		session.View, session.Title, session.URL, session.Index = "playing", title, url, m.playingIndex
+	}
+	return func() tea.Msg {
+		if session.View == "playing" {
+			session.Volume, _ = mpvGetFloat("volume")
+			// Live streams have no position worth going back to
+			if duration, err := mpvGetFloat("duration"); err == nil && duration > 0 {
+				session.Position, _ = mpvGetFloat("time-pos")
+			}
+		}
+		session.SavedAt = time.Now()
+		writeSession(session)
+		return nil
+	}
+}
+
+// restoreSession plays the crashed session's stream again, from where it
+// was and at the volume it had
+func (m *model) restoreSession(session savedSession) tea.Cmd {
+	m.navStack, m.state = nil, mainMenuView
+	if session.View == "manage" {
+		m.pushView(managePresetsView)
+	}
+	// The presets may have changed since
+	index := session.Index
+	if index < 0 || index >= len(m.config.Presets) || m.config.Presets[index].URL != session.URL {
+		index = -1
+	}
+	m.pushView(loadingView)
+	cmd := m.startLoading(session.URL, session.Title, index)
+	m.resumeAt = session.Position
+	m.startVolume = session.Volume
+	return cmd
+}
+
+// restoreSessionContent renders the offer to restore a crashed session
+func (m model) restoreSessionContent() string {
+	session := m.crashedSession
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	detail := fmt.Sprintf("'%s'", session.Title)
+	if session.Position > 0 {
+		detail += " at " + formatDuration(time.Duration(session.Position)*time.Second)
+	}
+	if session.Volume > 0 {
+		detail += fmt.Sprintf(", volume %.0f%%", session.Volume)
+	}
+	return fmt.Sprintf(
+		"Restore Your Session?\n\n%s\n\n%s\n\n%s",
+		muted.Render("LofiTUI didn't close properly last time ("+session.SavedAt.Local().Format("Mon 15:04")+")."),
+		"Pick up "+detail+" where you left off?",
+		muted.Render("Press Y to restore • N to start fresh"),
+	)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sleepTimerOptions are the durations offered by the sleep timer dialog
+var sleepTimerOptions = []time.Duration{30 * time.Minute, 60 * time.Minute, 90 * time.Minute}
+
+// sleepTickMsg refreshes the sleep timer countdown
+type sleepTickMsg struct {
+	deadline time.Time // Identifies the timer that scheduled the tick
+}
+
+// sleepTick schedules the next sleep timer refresh
+func sleepTick(deadline time.Time) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return sleepTickMsg{deadline: deadline}
+	})
+}
+
+// sleepStatus returns the countdown line shown in the TUI
+func sleepStatus(deadline time.Time) string {
+	return fmt.Sprintf("💤 %s", formatDuration(time.Until(deadline)))
+}
+
+// watchSleepTimer fades mpv's volume out over the final fade window and
+// then stops playback once the deadline passes
+func watchSleepTimer(ctx context.Context, deadline time.Time, fade time.Duration) {
+	fadeStart := deadline.Add(-fade)
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(time.Until(fadeStart)):
+	}
+
+	if fade > 0 {
+		startVolume, err := mpvGetFloat("volume")
+		if err == nil {
+			_ = mpvShowText("💤 Sleep timer: fading out")
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for time.Now().Before(deadline) {
+				left := float64(time.Until(deadline)) / float64(fade)
+				if left < 0 {
+					left = 0
+				}
+				_ = mpvSetProperty("volume", startVolume*left)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(time.Until(deadline)):
+	}
+	_, _ = mpvCommand("quit")
+}
+
+// startSleepWatch starts watching the stream playing for the sleep timer,
+// in place of any watcher of an earlier timer, or just stops that one if
+// the timer is off or nothing is playing
+func (m *model) startSleepWatch() {
+	if m.stopSleepWatch != nil {
+		m.stopSleepWatch()
+		m.stopSleepWatch = nil
+	}
+	if title, _ := m.stream.current(); title == "" || m.sleepDeadline.IsZero() {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopSleepWatch = cancel
+	fade := time.Duration(m.config.SleepFadeSeconds) * time.Second
+	go watchSleepTimer(ctx, m.sleepDeadline, fade)
+}
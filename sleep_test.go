@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSleepTimerArmedWhileDetached(t *testing.T) {
+	mpv := newFakeMPV(t)
+	m := testModel(t)
+	m.config.SleepFadeSeconds = 0
+	// A stream left playing in the background while browsing the menu
+	m.stream.set("Lofi Girl - Study", "https://www.youtube.com/watch?v=jfKfPfyJRdk")
+	m.detached = true
+	m.pushView(sleepTimerView)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1")})
+	m = updated.(model)
+	if m.sleepDeadline.IsZero() {
+		t.Fatal("sleep timer wasn't armed")
+	}
+	if m.stopSleepWatch == nil {
+		t.Fatal("no watcher started for the stream playing")
+	}
+
+	// Bring the deadline in, as if the half hour had gone by
+	m.sleepDeadline = time.Now().Add(100 * time.Millisecond)
+	m.startSleepWatch()
+	defer m.stopStreamWatchers()
+	deadline := time.Now().Add(2 * time.Second)
+	for !mpv.sent("quit") {
+		if time.Now().After(deadline) {
+			t.Fatal("the sleep timer didn't stop the stream")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSleepTimerNotWatchedWithoutStream(t *testing.T) {
+	m := testModel(t)
+	m.pushView(sleepTimerView)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")})
+	m = updated.(model)
+	if m.stopSleepWatch != nil {
+		t.Fatal("watcher started with nothing playing")
+	}
+}
+
+func TestPomodoroToggledWhileDetached(t *testing.T) {
+	newFakeMPV(t)
+	m := testModel(t)
+	m.stream.set("Lofi Girl - Study", "https://www.youtube.com/watch?v=jfKfPfyJRdk")
+	m.detached = true
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(m.config.key("pomodoro"))})
+	m = updated.(model)
+	if m.pomodoro == nil || m.stopPomodoroWatch == nil {
+		t.Fatal("Pomodoro started without watching the stream playing")
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(m.config.key("pomodoro"))})
+	m = updated.(model)
+	if m.pomodoro != nil || m.stopPomodoroWatch != nil {
+		t.Fatal("Pomodoro's watcher left running after turning it off")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Snapcast's defaults for a pipe source, as in snapserver.conf's
+// "source = pipe:///tmp/snapfifo?name=default"
+const (
+	defaultSnapcastFifo   = "/tmp/snapfifo"
+	defaultSnapcastFormat = "48000:16:2"
+)
+
+// snapcast is set by -snapcast to play to the Snapcast server for this
+// run, whatever the config says
+var snapcast bool
+
+// parseSampleFormat splits a Snapcast "rate:bits:channels" sample format
+func parseSampleFormat(format string) (rate, bits, channels int, err error) {
+	parts := strings.Split(format, ":")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("sample format %q isn't rate:bits:channels", format)
+	}
+	values := make([]int, 3)
+	for i, part := range parts {
+		if values[i], err = strconv.Atoi(part); err != nil || values[i] <= 0 {
+			return 0, 0, 0, fmt.Errorf("sample format %q isn't rate:bits:channels", format)
+		}
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// snapcastArgs makes mpv write raw PCM in the pipe source's sample format
+// to its FIFO instead of playing to a sound card. Snapserver reads the
+// FIFO in real time, which paces mpv.
+func snapcastArgs(cfg SnapcastConfig) ([]string, error) {
+	rate, bits, channels, err := parseSampleFormat(cfg.SampleFormat)
+	if err != nil {
+		return nil, err
+	}
+	format, ok := map[int]string{16: "s16", 32: "s32"}[bits]
+	if !ok {
+		return nil, fmt.Errorf("mpv can't write %d-bit audio; use 16 or 32 bits in the sample format", bits)
+	}
+	return []string{
+		"--ao=pcm",
+		"--ao-pcm-file=" + cfg.Fifo,
+		"--ao-pcm-waveheader=no",
+		"--audio-format=" + format,
+		fmt.Sprintf("--audio-samplerate=%d", rate),
+		fmt.Sprintf("--audio-channels=%d", channels),
+	}, nil
+}
+
+// outputArgs picks where mpv plays: the Snapcast server when that's on,
+// else the configured audio device
+func outputArgs(config *Config) []string {
+	if config.Snapcast.Enabled || snapcast {
+		args, err := snapcastArgs(config.Snapcast)
+		if err == nil {
+			return args
+		}
+		slog.Warn("bad snapcast settings, playing locally", "err", err)
+	}
+	return audioDeviceArgs(config.AudioDevice)
+}
+
+// checkSnapcast makes sure there's a Snapcast pipe source to play to
+func checkSnapcast(cfg SnapcastConfig) doctorCheck {
+	check := doctorCheck{name: "snapcast"}
+	if _, err := snapcastArgs(cfg); err != nil {
+		check.status, check.detail = "warn", err.Error()
+		check.fix = "set snapcast.sample_format to the pipe source's sampleformat, e.g. " + defaultSnapcastFormat
+		return check
+	}
+	info, err := os.Stat(cfg.Fifo)
+	switch {
+	case err != nil:
+		check.status, check.detail = "warn", cfg.Fifo+" doesn't exist"
+	case info.Mode()&os.ModeNamedPipe == 0:
+		check.status, check.detail = "warn", cfg.Fifo+" isn't a pipe"
+	default:
+		check.status, check.detail = "ok", "playing to "+cfg.Fifo+" as "+cfg.SampleFormat
+		return check
+	}
+	check.fix = "start snapserver with a pipe source (source = pipe://" + cfg.Fifo + "?name=lofitui) or set snapcast.fifo"
+	return check
+}
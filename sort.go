@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// Sort modes for the preset list, in the order the toggle cycles through
+var sortModes = []string{"manual", "name", "plays", "recent"}
+
+// sortLabels are the short names shown in the help line
+var sortLabels = map[string]string{
+	"manual": "manual",
+	"name":   "A-Z",
+	"plays":  "most played",
+	"recent": "recently played",
+}
+
+// presetItem is a preset shown in the list, remembering its position in the
+// config so edits still land on the right preset when the list is sorted
+type presetItem struct {
+	Preset
+	index int
+}
+
+// nextSortMode returns the mode after the given one, wrapping around
+func nextSortMode(mode string) string {
+	for i, m := range sortModes {
+		if m == mode {
+			return sortModes[(i+1)%len(sortModes)]
+		}
+	}
+	return sortModes[0]
+}
+
+// sortedPresetItems builds list items for the presets in the given sort mode.
+// The config keeps the manual order; sorting only affects what is shown.
+func sortedPresetItems(presets []Preset, mode string) []list.Item {
+	order := make([]int, len(presets))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := presets[order[a]], presets[order[b]]
+		switch mode {
+		case "name":
+			return strings.ToLower(pa.Name) < strings.ToLower(pb.Name)
+		case "plays":
+			return pa.PlayCount > pb.PlayCount
+		case "recent":
+			return lastPlayed(pa).After(lastPlayed(pb))
+		}
+		return false
+	})
+
+	items := make([]list.Item, len(order))
+	for i, index := range order {
+		items[i] = presetItem{Preset: presets[index], index: index}
+	}
+	return items
+}
+
+// lastPlayed returns when a preset was last played, zero if never
+func lastPlayed(p Preset) time.Time {
+	if p.LastPlayed == nil {
+		return time.Time{}
+	}
+	return *p.LastPlayed
+}
+
+// recordPlay bumps a preset's play count and last played time
+func recordPlay(config *Config, index int) {
+	if index < 0 || index >= len(config.Presets) {
+		return
+	}
+	now := time.Now()
+	config.Presets[index].PlayCount++
+	config.Presets[index].LastPlayed = &now
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// playRecord is a stream that was played, from the TUI, play or the
+// daemon. The latest is kept so it can be resumed after a reboot.
+type playRecord struct {
+	Title string    `json:"title"`
+	URL   string    `json:"url"` // The page URL; stream URLs expire
+	At    time.Time `json:"at"`
+}
+
+// getStatePath returns where the last played stream is remembered
+func getStatePath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "state.json"), nil
+}
+
+// rememberPlay records a stream that just started as the last played and
+// in the history. Failing to is not worth interrupting playback over, so
+// errors are dropped.
+func rememberPlay(title, url string) {
+	record := playRecord{Title: title, URL: url, At: time.Now()}
+	appendHistory(record)
+
+	path, err := getStatePath()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	_ = os.WriteFile(path, data, 0644)
+}
+
+// loadLastPlayed returns the stream that played last
+func loadLastPlayed() (*playRecord, error) {
+	path, err := getStatePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("nothing has been played yet")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+	var last playRecord
+	if err := json.Unmarshal(data, &last); err != nil || last.URL == "" {
+		return nil, fmt.Errorf("failed to parse state %s", path)
+	}
+	return &last, nil
+}
+
+// runResume plays whatever played last: through the daemon if it's
+// running, otherwise as "lofitui play" would
+func runResume(args []string) int {
+	flags := newFlagSet("resume")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "resume takes no arguments")
+	}
+	last, err := loadLastPlayed()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+
+	// Go through the preset if it's still there, so the play is counted
+	target := last.URL
+	if config, err := loadConfig(); err == nil {
+		for _, preset := range config.Presets {
+			if preset.URL == last.URL {
+				target = preset.Name
+				break
+			}
+		}
+	}
+
+	if conn, err := net.Dial("unix", daemonSocketPath()); err == nil {
+		conn.Close()
+		return runCtl([]string{"play", target})
+	}
+	return runPlay([]string{target})
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// streamStats is how often one stream was played
+type streamStats struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Plays       int       `json:"plays"`
+	FirstPlayed time.Time `json:"first_played"`
+	LastPlayed  time.Time `json:"last_played"`
+}
+
+// listeningReport sums up the history
+type listeningReport struct {
+	From    time.Time     `json:"from"`
+	To      time.Time     `json:"to"`
+	Plays   int           `json:"plays"`
+	Streams []streamStats `json:"streams"` // Most played first
+	Weekday string        `json:"busiest_weekday,omitempty"`
+	Hour    int           `json:"busiest_hour"` // 0-23, local time
+	Streak  int           `json:"longest_streak_days"`
+	Hours   float64       `json:"hours_listened"` // Estimated; see listenedHours
+
+	// Counted over the whole history, whatever the report's period
+	CurrentStreak int           `json:"current_streak_days"`
+	Achievements  []achievement `json:"achievements"`
+}
+
+// buildReport sums up the plays from since on, all of them if since is zero
+func buildReport(records []playRecord, since time.Time) listeningReport {
+	report := listeningReport{From: since, To: time.Now()}
+	streams := map[string]*streamStats{}
+	var weekdays [7]int
+	var hours [24]int
+	days := map[string]bool{}
+	var counted []playRecord
+	for _, record := range records {
+		if record.At.Before(since) {
+			continue
+		}
+		counted = append(counted, record)
+		at := record.At.Local()
+		report.Plays++
+		weekdays[at.Weekday()]++
+		hours[at.Hour()]++
+		days[at.Format("2006-01-02")] = true
+		if report.From.IsZero() || at.Before(report.From) {
+			report.From = at
+		}
+
+		// The same page under another title is the same stream
+		key := record.URL
+		if key == "" {
+			key = record.Title
+		}
+		stats, ok := streams[key]
+		if !ok {
+			stats = &streamStats{Title: record.Title, URL: record.URL, FirstPlayed: at, LastPlayed: at}
+			streams[key] = stats
+		}
+		stats.Plays++
+		if at.Before(stats.FirstPlayed) {
+			stats.FirstPlayed = at
+		}
+		if at.After(stats.LastPlayed) {
+			stats.LastPlayed, stats.Title = at, record.Title
+		}
+	}
+
+	for _, stats := range streams {
+		report.Streams = append(report.Streams, *stats)
+	}
+	sort.Slice(report.Streams, func(i, j int) bool {
+		a, b := report.Streams[i], report.Streams[j]
+		if a.Plays != b.Plays {
+			return a.Plays > b.Plays
+		}
+		return a.LastPlayed.After(b.LastPlayed)
+	})
+	if report.Plays > 0 {
+		report.Weekday = time.Weekday(busiest(weekdays[:])).String()
+		report.Hour = busiest(hours[:])
+	}
+	report.Streak = longestStreak(days)
+	report.Hours = listenedHours(counted, report.To)
+	return report
+}
+
+// busiest returns the index of the largest count, the first on a tie
+func busiest(counts []int) int {
+	best := 0
+	for i, count := range counts {
+		if count > counts[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// longestStreak returns the most days in a row with a play
+func longestStreak(days map[string]bool) int {
+	longest := 0
+	for day := range days {
+		start, _ := time.Parse("2006-01-02", day)
+		// Only count from the first day of each run
+		if days[start.AddDate(0, 0, -1).Format("2006-01-02")] {
+			continue
+		}
+		length := 1
+		for days[start.AddDate(0, 0, length).Format("2006-01-02")] {
+			length++
+		}
+		longest = max(longest, length)
+	}
+	return longest
+}
+
+// weekSummary describes a week's report the way a friend would
+func weekSummary(report listeningReport) string {
+	if report.Plays == 0 {
+		return "Your week in lofi: nothing played this week. Time for a break?\n"
+	}
+	plural := func(n int, word string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, word)
+		}
+		return fmt.Sprintf("%d %ss", n, word)
+	}
+	top := report.Streams[0]
+	summary := fmt.Sprintf("Your week in lofi\n\n%s across %s.\n", plural(report.Plays, "play"), plural(len(report.Streams), "stream"))
+	summary += fmt.Sprintf("Most played: %s (%s).\n", top.Title, plural(top.Plays, "play"))
+	summary += fmt.Sprintf("You listened most on %ss, usually around %02d:00.\n", report.Weekday, report.Hour)
+	if report.Streak > 1 {
+		summary += fmt.Sprintf("You tuned in %d days in a row.\n", report.Streak)
+	}
+	if report.CurrentStreak > 1 {
+		summary += fmt.Sprintf("You're on a %d-day streak. Keep it going!\n", report.CurrentStreak)
+	}
+	return summary
+}
+
+// runStats sums up the listening history, as a table, a weekly summary or
+// an export
+func runStats(args []string) int {
+	flags := newFlagSet("stats")
+	export := flags.String("export", "", "Print the report as `csv` or json")
+	week := flags.Bool("week", false, "Only count the last 7 days, summed up in words")
+	days := flags.Int("days", 0, "Only count the last `N` days, 0 for all")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "stats takes no arguments")
+	}
+	if *export != "" && *export != "csv" && *export != "json" {
+		return usageError(flags, fmt.Sprintf("unknown export format %q; use csv or json", *export))
+	}
+
+	records, err := loadHistory(0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+		return exitError
+	}
+	if *week {
+		*days = 7
+	}
+	var since time.Time
+	if *days > 0 {
+		since = time.Now().AddDate(0, 0, -*days)
+	}
+	report := buildReport(records, since)
+	report.CurrentStreak = currentStreak(records, time.Now())
+	report.Achievements = buildAchievements(records)
+
+	switch {
+	case *export == "json":
+		if report.Streams == nil {
+			report.Streams = []streamStats{}
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+			return exitError
+		}
+		fmt.Println(string(data))
+	case *export == "csv":
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"title", "url", "plays", "first_played", "last_played"})
+		for _, stats := range report.Streams {
+			_ = w.Write([]string{stats.Title, stats.URL, strconv.Itoa(stats.Plays),
+				stats.FirstPlayed.Format(time.RFC3339), stats.LastPlayed.Format(time.RFC3339)})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+			return exitError
+		}
+	case *week:
+		fmt.Print(weekSummary(report))
+	default:
+		if report.Plays == 0 {
+			fmt.Println("Nothing played yet")
+			return exitOK
+		}
+		fmt.Printf("%d plays of %d streams since %s\n", report.Plays, len(report.Streams), report.From.Format("Mon 2006-01-02"))
+		fmt.Printf("Busiest on %ss, around %02d:00; longest streak %d days\n", report.Weekday, report.Hour, report.Streak)
+		fmt.Printf("About %.0f hours listened; current streak %d days\n", report.Hours, report.CurrentStreak)
+		fmt.Print(achievementsSummary(report.Achievements))
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PLAYS\tTITLE\tLAST PLAYED")
+		for _, stats := range report.Streams {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", stats.Plays, stats.Title, stats.LastPlayed.Format("Mon 2006-01-02 15:04"))
+		}
+		w.Flush()
+	}
+	return exitOK
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultStatusFormat is what "lofitui status" prints without -format
+const defaultStatusFormat = `{{if .Playing}}{{if eq .State "paused"}}⏸{{else}}♪{{end}} {{.Title}}{{with .Track}} – {{.}}{{end}}{{end}}`
+
+// statusView is what -format templates see: the daemon's status plus
+// ready-formatted times
+type statusView struct {
+	daemonStatus
+	Playing bool   // Playing or paused, i.e. there's a stream
+	Elapsed string // Position as "mm:ss", or "h:mm:ss"
+	Length  string // Duration the same way, empty for live streams
+}
+
+// newStatusView prepares a status for templates
+func newStatusView(status daemonStatus) statusView {
+	view := statusView{daemonStatus: status, Playing: status.State != "stopped"}
+	if view.Playing {
+		view.Elapsed = formatDuration(time.Duration(status.Position * float64(time.Second)))
+	}
+	if status.Duration > 0 {
+		view.Length = formatDuration(time.Duration(status.Duration * float64(time.Second)))
+	}
+	return view
+}
+
+// waybarStatus is a line of waybar's custom module JSON
+type waybarStatus struct {
+	Text       string `json:"text"`
+	Tooltip    string `json:"tooltip"`
+	Class      string `json:"class"` // playing, paused, stopped or offline
+	Alt        string `json:"alt"`   // Same as class, for format-icons
+	Percentage int    `json:"percentage"`
+}
+
+// statusLine renders one status line: the template's output, or waybar's
+// JSON around it. err is why the daemon couldn't be asked.
+func statusLine(tmpl *template.Template, status daemonStatus, err error, waybar bool) (string, error) {
+	var text strings.Builder
+	if err == nil {
+		if err := tmpl.Execute(&text, newStatusView(status)); err != nil {
+			return "", err
+		}
+	}
+	if !waybar {
+		return strings.TrimSpace(text.String()), nil
+	}
+
+	// Waybar reads the text and tooltip as Pango markup
+	bar := waybarStatus{Text: html.EscapeString(strings.TrimSpace(text.String())), Class: status.State, Percentage: int(status.Volume)}
+	switch {
+	case err != nil:
+		bar.Class, bar.Tooltip = "offline", "The lofitui daemon isn't running"
+	case status.State == "stopped":
+		bar.Tooltip = "Stopped"
+	default:
+		tooltip := []string{status.Title}
+		if status.Track != "" {
+			tooltip = append(tooltip, "♪ "+status.Track)
+		}
+		view := newStatusView(status)
+		position := view.Elapsed
+		if view.Length != "" {
+			position += " / " + view.Length
+		}
+		tooltip = append(tooltip, fmt.Sprintf("%s • volume %d%%", position, bar.Percentage))
+		bar.Tooltip = html.EscapeString(strings.Join(tooltip, "\n"))
+	}
+	bar.Alt = bar.Class
+	data, err := json.Marshal(bar)
+	return string(data), err
+}
+
+// runStatus prints what the daemon is playing on one line, for status
+// bars such as tmux's status-right. It only asks the running daemon, so
+// it's cheap enough to run every few seconds; -follow keeps printing a
+// line whenever it changes, for bars that read a command's output.
+func runStatus(args []string) int {
+	flags := newFlagSet("status")
+	format := flags.String("format", defaultStatusFormat, "Go `template` for the line, e.g. '{{.Title}} {{.Elapsed}}'")
+	waybar := flags.Bool("waybar", false, "Print JSON for a waybar custom module")
+	follow := flags.Bool("follow", false, "Keep running, printing a new line whenever the status changes")
+	interval := flags.Duration("interval", time.Second, "How often -follow checks the daemon")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "status takes no arguments")
+	}
+	tmpl, err := template.New("status").Parse(*format)
+	if err != nil {
+		return usageError(flags, fmt.Sprintf("bad -format: %v", err))
+	}
+	if *interval <= 0 {
+		return usageError(flags, "-interval must be positive")
+	}
+
+	if !*follow {
+		status, err := fetchDaemonStatus()
+		if err != nil && !*waybar {
+			// Print nothing, so a status bar just goes blank
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "lofitui: %v\n", err)
+			}
+			return exitError
+		}
+		line, err := statusLine(tmpl, status, err, *waybar)
+		if err != nil {
+			return fail(exitUsage, fmt.Errorf("bad -format: %w", err))
+		}
+		fmt.Println(line)
+		return exitOK
+	}
+
+	// Keep going while the daemon comes and goes; the bar shows it offline
+	last := ""
+	for {
+		status, err := fetchDaemonStatus()
+		line, err := statusLine(tmpl, status, err, *waybar)
+		if err != nil {
+			return fail(exitUsage, fmt.Errorf("bad -format: %w", err))
+		}
+		if line != last {
+			if _, err := fmt.Println(line); err != nil {
+				// The bar went away
+				return exitOK
+			}
+			last = line
+		}
+		time.Sleep(*interval)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// streamInfoTimeout is how long yt-dlp gets to describe a stream
+const streamInfoTimeout = 30 * time.Second
+
+// streamInfo is the part of yt-dlp's -J output shown before playing
+type streamInfo struct {
+	Title      string  `json:"title"`
+	Channel    string  `json:"channel"`
+	Uploader   string  `json:"uploader"`
+	LiveStatus string  `json:"live_status"`
+	Duration   float64 `json:"duration"`
+	Watching   int     `json:"concurrent_view_count"`
+}
+
+// streamInfoMsg carries a stream's details, for the load with the same id
+type streamInfoMsg struct {
+	id   int
+	info streamInfo
+	err  error
+}
+
+// fetchStreamInfo asks yt-dlp for the details of the page being loaded.
+// It runs alongside the extraction, so usually it's in by the time the
+// stream is ready.
+func fetchStreamInfo(id int, url string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), streamInfoTimeout)
+		defer cancel()
+
+		output, err := exec.CommandContext(ctx, "yt-dlp", "-J", "--no-warnings", "--no-playlist", "--", url).Output()
+		if err != nil {
+			return streamInfoMsg{id: id, err: err}
+		}
+		var info streamInfo
+		if err := json.Unmarshal(output, &info); err != nil {
+			return streamInfoMsg{id: id, err: err}
+		}
+		return streamInfoMsg{id: id, info: info}
+	}
+}
+
+// streamInfoContent renders the details of the stream about to play
+func (m model) streamInfoContent() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	content := "Ready to Play\n\n" + lipgloss.NewStyle().Bold(true).Render(m.loadingTitle)
+
+	switch {
+	case m.streamInfoErr != nil:
+		content += "\n\n" + muted.Render("No details for this stream, but it's ready to play")
+	case m.streamInfo == nil:
+		content += "\n\n" + muted.Render("Fetching details…")
+	default:
+		info := m.streamInfo
+		var rows []string
+		row := func(label, value string) {
+			if value != "" {
+				rows = append(rows, muted.Render(fmt.Sprintf("%-9s", label))+value)
+			}
+		}
+		row("Title", info.Title)
+		row("Channel", info.Channel)
+		if info.Uploader != info.Channel {
+			row("Uploader", info.Uploader)
+		}
+		switch info.LiveStatus {
+		case "is_live":
+			status := lipgloss.NewStyle().Foreground(theme.Danger).Render("● Live now")
+			if info.Watching > 0 {
+				status += muted.Render(fmt.Sprintf(", %d watching", info.Watching))
+			}
+			row("Status", status)
+		case "is_upcoming":
+			row("Status", lipgloss.NewStyle().Foreground(theme.Warning).Render("◷ Not live yet"))
+		default:
+			status := "▶ Video"
+			if info.LiveStatus == "was_live" || info.LiveStatus == "post_live" {
+				status = "○ Past live stream"
+			}
+			if info.Duration > 0 {
+				status += ", " + formatDuration(time.Duration(info.Duration)*time.Second)
+			}
+			row("Status", status)
+		}
+		content += "\n\n" + strings.Join(rows, "\n")
+	}
+
+	return content + "\n\n" + muted.Render("Enter=play • ESC=back")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxSuggestions is how many presets the main menu suggests at most
+const maxSuggestions = 3
+
+// minSuggestionScore keeps a single play long ago at about this time from
+// counting as a habit
+const minSuggestionScore = 1.5
+
+// suggestPresets returns the presets usually played around this hour on
+// this kind of day, best first, as config indexes. Recent plays count for
+// more than old ones.
+func suggestPresets(presets []Preset, records []playRecord, now time.Time) []int {
+	index := make(map[string]int, len(presets))
+	for i, preset := range presets {
+		if _, ok := index[preset.URL]; !ok {
+			index[preset.URL] = i
+		}
+	}
+
+	now = now.Local()
+	scores := map[int]float64{}
+	for _, record := range records {
+		i, ok := index[record.URL]
+		if !ok {
+			continue
+		}
+		at := record.At.Local()
+		// Within a couple of hours either side, wrapping round midnight
+		distance := math.Abs(float64(at.Hour() - now.Hour()))
+		distance = math.Min(distance, 24-distance)
+		hour := map[float64]float64{0: 1, 1: 0.6, 2: 0.3}[distance]
+		if hour == 0 {
+			continue
+		}
+		day := 0.5
+		switch {
+		case at.Weekday() == now.Weekday():
+			day = 1.5
+		case isWeekend(at) == isWeekend(now):
+			day = 1
+		}
+		// Halve a play's weight every month
+		age := now.Sub(at).Hours() / 24
+		scores[i] += hour * day * math.Pow(0.5, age/30)
+	}
+
+	var suggested []int
+	for i, score := range scores {
+		if score >= minSuggestionScore {
+			suggested = append(suggested, i)
+		}
+	}
+	sort.Slice(suggested, func(a, b int) bool {
+		if scores[suggested[a]] != scores[suggested[b]] {
+			return scores[suggested[a]] > scores[suggested[b]]
+		}
+		return suggested[a] < suggested[b]
+	})
+	if len(suggested) > maxSuggestions {
+		suggested = suggested[:maxSuggestions]
+	}
+	return suggested
+}
+
+// isWeekend reports whether t is on a Saturday or Sunday
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// refreshSuggestions works out the main menu's suggestions again, after
+// the presets or the history changed
+func (m *model) refreshSuggestions() {
+	hadSuggestions := len(m.suggestions) > 0
+	m.suggestions = nil
+	if !m.config.HideSuggestions {
+		if records, err := loadHistory(0); err == nil {
+			m.suggestions = suggestPresets(m.config.Presets, records, time.Now())
+		}
+	}
+	// The row takes a line from the list
+	if m.ready && hadSuggestions != (len(m.suggestions) > 0) {
+		m.list.SetHeight(m.listHeight())
+	}
+}
+
+// suggestionsLine renders the suggestions row at the top of the main menu
+func (m model) suggestionsLine() string {
+	var names []string
+	for n, i := range m.suggestions {
+		if i >= len(m.config.Presets) {
+			continue
+		}
+		names = append(names, fmt.Sprintf("%s %s", lipgloss.NewStyle().Bold(true).Render(fmt.Sprint(n+1)), m.config.Presets[i].Name))
+	}
+	line := lipgloss.NewStyle().Foreground(theme.Accent).Render("  ✦ Suggested for now  ") + strings.Join(names, " · ")
+	return ansi.Truncate(line, m.width, "…")
+}
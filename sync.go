@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultSyncAddress is where "sync host" listens without -address:
+	// this machine only, like the remote control, until asked to share
+	defaultSyncAddress = "127.0.0.1:8766"
+	// syncPoll is how often a listener asks the host what's playing
+	syncPoll = 2 * time.Second
+	// syncDrift is how far a listener's VOD may stray from the host's
+	// before it seeks back in step
+	syncDrift = 3 * time.Second
+	// syncSettle is how long a freshly loaded stream gets to start before
+	// its position is corrected
+	syncSettle = 5 * time.Second
+)
+
+// runSync hosts a listen-along session for the daemon's stream, or joins
+// someone else's
+func runSync(args []string) int {
+	flags := newFlagSet("sync")
+	address := flags.String("address", defaultSyncAddress, "`Address` to host the session on, e.g. :8766 to share it on the network")
+	token := flags.String("token", "", "`Token` listeners must give to join; hosts and listeners pass the same one")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	switch {
+	case len(positional) == 1 && positional[0] == "host":
+		return hostSync(*address, *token)
+	case len(positional) == 2 && positional[0] == "join":
+		return joinSync(positional[1], *token)
+	}
+	return usageError(flags, "sync needs host or join <address>")
+}
+
+// hostSync shares what the daemon is playing, and where it is, with
+// anyone who joins, or only those with token if it's set. Listeners only
+// read; they can't control the daemon.
+func hostSync(address, token string) int {
+	if _, err := fetchDaemonStatus(); err != nil {
+		return fail(exitError, err)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fail(exitError, err)
+	}
+	defer listener.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		status, err := fetchDaemonStatus()
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, daemonResponse{Message: err.Error()})
+			return
+		}
+		// Listeners have no use for the host's volume or track lookups
+		writeJSON(w, http.StatusOK, daemonStatus{
+			State: status.State, Title: status.Title, URL: status.URL,
+			Position: status.Position, Duration: status.Duration,
+		})
+	})
+	var handler http.Handler = mux
+	join := "lofitui sync join"
+	if token != "" {
+		handler = requireToken(token, mux)
+		join += " -token " + token
+	}
+	go http.Serve(listener, handler)
+	if !quiet {
+		addr := listener.Addr().(*net.TCPAddr)
+		if addr.IP.IsLoopback() {
+			fmt.Fprintf(os.Stderr, "Hosting a listen-along session on %s, for this machine only; -address :%d shares it on the network\n", addr, addr.Port)
+		} else {
+			fmt.Fprintf(os.Stderr, "Hosting a listen-along session on port %d; friends join with: %s <your address>:%d\n", addr.Port, join, addr.Port)
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	return exitOK
+}
+
+// joinSync plays along with a host until interrupted, following what it
+// plays, its pauses and, for VODs, its position
+func joinSync(address, token string) int {
+	if err := requirePlayer(); err != nil {
+		return fail(exitCode(err), err)
+	}
+	sessionURL := address
+	if !strings.Contains(sessionURL, "://") {
+		sessionURL = "http://" + sessionURL
+	}
+	sessionURL = strings.TrimSuffix(sessionURL, "/") + "/session"
+	if token != "" {
+		sessionURL += "?token=" + url.QueryEscape(token)
+	}
+	if _, _, err := fetchSyncSession(sessionURL); err != nil {
+		return fail(exitError, err)
+	}
+
+	var extraArgs []string
+	if config, err := loadConfig(); err == nil {
+		extraArgs = configArgs(config)
+	}
+	mpv, err := startIdleMPV(extraArgs...)
+	if err != nil {
+		return fail(exitError, fmt.Errorf("failed to start mpv: %w", err))
+	}
+	defer mpv.Stop(3 * time.Second)
+	say := func(format string, args ...interface{}) {
+		if !quiet {
+			fmt.Printf(format+"\n", args...)
+		}
+	}
+	say("Joined %s", address)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	poll := time.NewTicker(syncPoll)
+	defer poll.Stop()
+
+	var playing string // Page URL of what we're playing, empty when stopped
+	var failed string  // Page URL that couldn't be played, not retried until the host moves on
+	var loaded time.Time
+	lost := false
+	for {
+		select {
+		case <-stop:
+			return exitOK
+		case <-mpv.Exited():
+			return fail(exitError, fmt.Errorf("mpv exited unexpectedly"))
+		case <-poll.C:
+		}
+
+		host, rtt, err := fetchSyncSession(sessionURL)
+		if err != nil {
+			// Keep playing through a blip; the next poll may reach it
+			if !lost {
+				say("Lost the host: %v", err)
+				lost = true
+			}
+			continue
+		}
+		if lost {
+			say("Back in touch with the host")
+			lost = false
+		}
+
+		if host.State == "stopped" || host.URL == "" {
+			failed = ""
+			if playing != "" {
+				_, _ = mpvCommand("stop")
+				playing = ""
+				say("The host stopped")
+			}
+			continue
+		}
+		// Half the round trip is roughly how stale the host's position is
+		position := host.Position + rtt.Seconds()/2
+		if host.URL == failed {
+			continue
+		}
+		if host.URL != playing {
+			say("Loading %s", host.Title)
+			if err := loadSyncStream(host, position); err != nil {
+				say("Couldn't play %s: %v", host.Title, err)
+				failed = host.URL
+				continue
+			}
+			playing, loaded = host.URL, time.Now()
+			say("Playing %s", host.Title)
+		}
+
+		if paused, err := mpvGetBool("pause"); err == nil && paused != (host.State == "paused") {
+			_ = mpvSetProperty("pause", !paused)
+		}
+		// Live streams are as in sync as the network makes them
+		if host.Duration <= 0 || host.State != "playing" || time.Since(loaded) < syncSettle {
+			continue
+		}
+		if local, err := mpvGetFloat("time-pos"); err == nil && math.Abs(local-position) > syncDrift.Seconds() {
+			_, _ = mpvCommand("seek", position, "absolute")
+		}
+	}
+}
+
+// fetchSyncSession asks a host what it's playing, returning how long that
+// took too
+func fetchSyncSession(sessionURL string) (daemonStatus, time.Duration, error) {
+	var status daemonStatus
+	client := http.Client{Timeout: 5 * time.Second}
+	started := time.Now()
+	resp, err := client.Get(sessionURL)
+	if err != nil {
+		return status, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var reply daemonResponse
+		_ = json.NewDecoder(resp.Body).Decode(&reply)
+		return status, 0, fmt.Errorf("the host can't share its session: %s", reply.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, 0, fmt.Errorf("bad reply from the host: %w", err)
+	}
+	return status, time.Since(started), nil
+}
+
+// loadSyncStream extracts the host's stream and plays it, from position
+// for VODs
+func loadSyncStream(host daemonStatus, position float64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	streamURL, err := resolveStreamURL(ctx, host.URL, nil)
+	if err != nil {
+		return err
+	}
+	// The seeks that keep VODs in step make up for the time extraction took
+	start := "none"
+	if host.Duration > 0 {
+		start = fmt.Sprintf("%.0f", position)
+	}
+	_ = mpvSetProperty("start", start)
+	_ = mpvSetProperty("force-media-title", host.Title)
+	if _, err := mpvCommand("loadfile", streamURL, "replace"); err != nil {
+		return err
+	}
+	return mpvSetProperty("pause", host.State == "paused")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Theme maps each UI role to a terminal color
+type Theme struct {
+	Accent    lipgloss.Color // Selected item and input dialogs
+	Highlight lipgloss.Color // Spinner, loading and Now Playing
+	Muted     lipgloss.Color // Help text and hints
+	Danger    lipgloss.Color // Destructive confirmations and the live badge
+	Warning   lipgloss.Color // Restore defaults confirmation
+	Info      lipgloss.Color // Sleep timer dialog
+	Alarm     lipgloss.Color // Alarm dialog
+	Bold      bool           // Bold the selected item for extra contrast
+}
+
+// themes are the built-in color schemes, selectable with "theme" in the config
+var themes = map[string]Theme{
+	"default": {
+		Accent:    "170",
+		Highlight: "205",
+		Muted:     "240",
+		Danger:    "196",
+		Warning:   "208",
+		Info:      "63",
+		Alarm:     "214",
+	},
+	// Bright colors that stay readable on any background
+	"high-contrast": {
+		Accent:    "226",
+		Highlight: "51",
+		Muted:     "252",
+		Danger:    "196",
+		Warning:   "226",
+		Info:      "51",
+		Alarm:     "226",
+		Bold:      true,
+	},
+	// Blue/orange palette that avoids red-green distinctions
+	"deuteranopia": {
+		Accent:    "33",
+		Highlight: "214",
+		Muted:     "246",
+		Danger:    "202",
+		Warning:   "220",
+		Info:      "39",
+		Alarm:     "220",
+		Bold:      true,
+	},
+}
+
+// theme is the active color scheme
+var theme = themes["default"]
+
+// Set by -theme and -no-color to override the config for a single run
+var (
+	themeOverride string
+	noColor       bool
+)
+
+// themeNames lists the built-in themes, for error messages
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyTheme switches the active theme and the styles derived from it.
+// Unknown names fall back to the default theme, -theme wins over name, and
+// NO_COLOR or -no-color disables color output entirely.
+func applyTheme(name string) {
+	if themeOverride != "" {
+		name = themeOverride
+	}
+	t, ok := themes[name]
+	if !ok {
+		t = themes["default"]
+	}
+	theme = t
+
+	// https://no-color.org: any non-empty value turns color off
+	if os.Getenv("NO_COLOR") != "" || noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(theme.Accent).Bold(theme.Bold)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Limits on tone presets: carriers outside them are hard to hear or
+// unpleasant, and beats outside them aren't heard as beats
+const (
+	minCarrier, maxCarrier = 40.0, 1500.0
+	minBeat, maxBeat       = 0.5, 40.0
+)
+
+// defaultTones are the tone presets a config starts with: beta for focus,
+// alpha for relaxing and delta for sleep
+func defaultTones() []TonePreset {
+	return []TonePreset{
+		{Name: "Focus", Kind: "binaural", Carrier: 200, Beat: 14},
+		{Name: "Relax", Kind: "binaural", Carrier: 180, Beat: 10},
+		{Name: "Sleep", Kind: "binaural", Carrier: 150, Beat: 3},
+		{Name: "Focus (speakers)", Kind: "isochronic", Carrier: 220, Beat: 14},
+	}
+}
+
+// toneSounds turns the configured tone presets into ambient sounds,
+// leaving out any that don't make sense
+func toneSounds(tones []TonePreset) []ambientSound {
+	var sounds []ambientSound
+	for i, tone := range tones {
+		if tone.Carrier < minCarrier || tone.Carrier > maxCarrier || tone.Beat < minBeat || tone.Beat > maxBeat {
+			continue
+		}
+		sound := ambientSound{
+			id:     fmt.Sprintf("tone:%d:%s", i, tone.Name),
+			name:   tone.Name,
+			icon:   "∿",
+			detail: fmt.Sprintf("%s %g Hz", tone.Kind, tone.Beat),
+		}
+		switch tone.Kind {
+		case "binaural":
+			sound.generator = func(*rand.Rand) sampleGenerator { return &binauralBeat{tone: tone} }
+		case "isochronic":
+			sound.generator = func(*rand.Rand) sampleGenerator { return &isochronicTone{tone: tone} }
+		default:
+			continue
+		}
+		sounds = append(sounds, sound)
+	}
+	return sounds
+}
+
+// toneLevel keeps the tones about as loud as the noises
+const toneLevel = 0.15
+
+// binauralBeat plays the carrier in one ear and the carrier plus the beat
+// in the other; the brain hears the difference as a beat. It only works
+// in headphones.
+type binauralBeat struct {
+	tone        TonePreset
+	left, right float64 // Phases
+}
+
+func (b *binauralBeat) next() (float64, float64) {
+	b.left = math.Mod(b.left+2*math.Pi*b.tone.Carrier/ambientRate, 2*math.Pi)
+	b.right = math.Mod(b.right+2*math.Pi*(b.tone.Carrier+b.tone.Beat)/ambientRate, 2*math.Pi)
+	return toneLevel * math.Sin(b.left), toneLevel * math.Sin(b.right)
+}
+
+// isochronicTone pulses the carrier on and off at the beat rate, which
+// works through speakers too
+type isochronicTone struct {
+	tone         TonePreset
+	phase, pulse float64
+}
+
+func (t *isochronicTone) next() (float64, float64) {
+	t.phase = math.Mod(t.phase+2*math.Pi*t.tone.Carrier/ambientRate, 2*math.Pi)
+	t.pulse = math.Mod(t.pulse+2*math.Pi*t.tone.Beat/ambientRate, 2*math.Pi)
+	// A smoothed pulse: silent half the time, without the clicks of a
+	// square wave
+	envelope := math.Max(0, math.Sin(t.pulse))
+	sample := toneLevel * 1.5 * envelope * math.Sin(t.phase)
+	return sample, sample
+}
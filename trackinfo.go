@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// lyricsSearchURL is LRCLIB's search endpoint, a free lyrics database
+// that needs no API key
+const lyricsSearchURL = "https://lrclib.net/api/search"
+
+// trackInfoTimeout bounds a single lyrics lookup
+const trackInfoTimeout = 10 * time.Second
+
+// trackInfo is what the lyrics lookup found for a track
+type trackInfo struct {
+	pending      bool
+	found        bool
+	artist       string
+	album        string
+	lyrics       string
+	instrumental bool
+	err          error
+}
+
+// trackInfoMsg carries the lookup result for a track title
+type trackInfoMsg struct {
+	track string
+	info  trackInfo
+}
+
+// lrclibTrack is one result from the LRCLIB search API
+type lrclibTrack struct {
+	TrackName    string `json:"trackName"`
+	ArtistName   string `json:"artistName"`
+	AlbumName    string `json:"albumName"`
+	Instrumental bool   `json:"instrumental"`
+	PlainLyrics  string `json:"plainLyrics"`
+}
+
+// fetchTrackInfo looks up credits and lyrics for a track title
+func fetchTrackInfo(track string) tea.Cmd {
+	return func() tea.Msg {
+		client := http.Client{Timeout: trackInfoTimeout}
+		req, err := http.NewRequest("GET", lyricsSearchURL+"?q="+url.QueryEscape(track), nil)
+		if err != nil {
+			return trackInfoMsg{track: track, info: trackInfo{err: err}}
+		}
+		req.Header.Set("User-Agent", "lofitui/"+version+" (https://github.com/willyv3/lofitui)")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return trackInfoMsg{track: track, info: trackInfo{err: err}}
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return trackInfoMsg{track: track, info: trackInfo{err: fmt.Errorf("lyrics lookup: %s", resp.Status)}}
+		}
+
+		var results []lrclibTrack
+		if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+			return trackInfoMsg{track: track, info: trackInfo{err: fmt.Errorf("lyrics lookup: %w", err)}}
+		}
+		if len(results) == 0 {
+			return trackInfoMsg{track: track}
+		}
+		best := results[0]
+		return trackInfoMsg{track: track, info: trackInfo{
+			found:        true,
+			artist:       best.ArtistName,
+			album:        best.AlbumName,
+			lyrics:       strings.TrimSpace(best.PlainLyrics),
+			instrumental: best.Instrumental,
+		}}
+	}
+}
+
+// lookupTrack fetches info for the current track while the pane is open,
+// unless it has been looked up already
+func (m *model) lookupTrack() tea.Cmd {
+	track := m.playback.track
+	if !m.showTrackInfo || track == "" {
+		return nil
+	}
+	if _, ok := m.trackInfos[track]; ok {
+		return nil
+	}
+	m.trackInfos[track] = trackInfo{pending: true}
+	return fetchTrackInfo(track)
+}
+
+// trackInfoPane renders the credits and lyrics of the current track,
+// cut to fit width x height
+func (m model) trackInfoPane(width, height int) string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	track := m.playback.track
+	if track == "" {
+		return "Track Info\n\n" + muted.Render("This stream doesn't report track titles.")
+	}
+
+	lines := []string{"Track Info", "", lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold).Render(track)}
+	info, ok := m.trackInfos[track]
+	switch {
+	case !ok || info.pending:
+		lines = append(lines, "", muted.Render("looking up…"))
+	case info.err != nil:
+		lines = append(lines, "", lipgloss.NewStyle().Foreground(theme.Danger).Render("Lookup failed"))
+	case !info.found:
+		lines = append(lines, "", muted.Render("No lyrics or credits found."))
+	default:
+		credits := info.artist
+		if info.album != "" {
+			credits += " — " + info.album
+		}
+		lines = append(lines, muted.Render(credits), "")
+		if info.instrumental || info.lyrics == "" {
+			lines = append(lines, muted.Render("♪ Instrumental"))
+		} else {
+			lines = append(lines, strings.Split(info.lyrics, "\n")...)
+		}
+	}
+
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, width, "…")
+	}
+	if height > 0 && len(lines) > height {
+		lines = append(lines[:height-1], muted.Render("…"))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,416 @@
+//go:build tray
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The tray icon is a StatusNotifierItem with a DBusMenu, which KDE, GNOME
+// (with the AppIndicator extension), Waybar and most other trays show
+const (
+	trayItemPath   = dbusObjectPath("/StatusNotifierItem")
+	trayMenuPath   = dbusObjectPath("/MenuBar")
+	sniInterface   = "org.kde.StatusNotifierItem"
+	sniWatcher     = "org.kde.StatusNotifierWatcher"
+	dbusMenu       = "com.canonical.dbusmenu"
+	trayPoll       = 2 * time.Second
+	trayVolumeStep = 5
+)
+
+// Menu item ids; preset i is trayPresetBase+i
+const (
+	trayRoot = iota
+	trayNowPlaying
+	trayPlayPause
+	trayStop
+	trayPresets
+	trayQuit
+	traySeparator
+	trayPresetBase = 100
+)
+
+// trayItem is one entry in the tray's menu
+type trayItem struct {
+	id       int32
+	props    map[string]interface{}
+	children []trayItem
+}
+
+// tray is the tray icon, showing and controlling the daemon
+type tray struct {
+	bus      *dbusConn
+	mu       sync.Mutex
+	running  bool // The daemon answered the last poll
+	status   daemonStatus
+	presets  []string
+	revision uint32
+	quit     chan struct{}
+}
+
+// runTray shows a tray icon until the daemon is quit from it, or the tray
+// is interrupted
+func runTray(args []string) int {
+	flags := newFlagSet("tray")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if len(positional) > 0 {
+		return usageError(flags, "tray takes no arguments")
+	}
+
+	bus, err := dbusSessionBus()
+	if err != nil {
+		return fail(exitError, fmt.Errorf("can't show a tray icon: %w", err))
+	}
+	defer bus.conn.Close()
+	name := fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid())
+	if _, err := bus.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RequestName", name, uint32(4)); err != nil {
+		return fail(exitError, err)
+	}
+
+	t := &tray{bus: bus, quit: make(chan struct{}, 1)}
+	t.refresh()
+	// The watcher asks for our properties as soon as we register, so its
+	// reply is picked up by serve rather than waited for
+	register, err := bus.send(&dbusMessage{
+		kind: dbusMethodCall, destination: sniWatcher, path: "/StatusNotifierWatcher",
+		iface: sniWatcher, member: "RegisterStatusNotifierItem", body: []interface{}{name},
+	})
+	if err != nil {
+		return fail(exitError, err)
+	}
+	failed := make(chan error, 1)
+	go t.serve(register, failed)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	poll := time.NewTicker(trayPoll)
+	defer poll.Stop()
+	for {
+		select {
+		case <-stop:
+			return exitOK
+		case <-t.quit:
+			return exitOK
+		case err := <-failed:
+			return fail(exitError, err)
+		case <-poll.C:
+			t.refresh()
+		}
+	}
+}
+
+// serve answers the tray host until the bus goes away. Failing to
+// register, i.e. there's no tray, is sent to failed.
+func (t *tray) serve(register uint32, failed chan<- error) {
+	for {
+		msg, err := t.bus.read()
+		if err != nil {
+			failed <- fmt.Errorf("lost the session bus: %w", err)
+			return
+		}
+		switch {
+		case msg.kind == dbusError && msg.replySerial == register:
+			failed <- fmt.Errorf("no system tray to show the icon in (%s)", msg.errorName)
+			return
+		case msg.kind == dbusMethodCall:
+			go t.handle(msg)
+		}
+	}
+}
+
+// refresh asks the daemon what it's doing, updating the icon and menu if
+// anything changed
+func (t *tray) refresh() {
+	status, err := fetchDaemonStatus()
+	var presets []string
+	if config, err := loadConfig(); err == nil {
+		for _, preset := range config.Presets {
+			presets = append(presets, preset.Name)
+		}
+	}
+
+	t.mu.Lock()
+	changed := t.running != (err == nil) || t.status.State != status.State ||
+		t.status.Title != status.Title || t.status.Track != status.Track ||
+		fmt.Sprint(t.presets) != fmt.Sprint(presets)
+	t.running, t.status, t.presets = err == nil, status, presets
+	if changed {
+		t.revision++
+	}
+	revision := t.revision
+	t.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	_ = t.bus.emit(trayMenuPath, dbusMenu, "LayoutUpdated", revision, int32(trayRoot))
+	_ = t.bus.emit(trayItemPath, sniInterface, "NewIcon")
+	_ = t.bus.emit(trayItemPath, sniInterface, "NewToolTip")
+}
+
+// act runs a menu action against the daemon
+func (t *tray) act(id int32) {
+	t.mu.Lock()
+	presets := t.presets
+	t.mu.Unlock()
+
+	var err error
+	switch {
+	case id == trayPlayPause:
+		_, err = askDaemon(daemonRequest{Command: "pause"})
+	case id == trayStop:
+		_, err = askDaemon(daemonRequest{Command: "stop"})
+	case id == trayQuit:
+		if _, err := askDaemon(daemonRequest{Command: "quit"}); err != nil && err != errNoDaemon {
+			slog.Warn("failed to quit the daemon", "err", err)
+		}
+		select {
+		case t.quit <- struct{}{}:
+		default:
+		}
+		return
+	case id >= trayPresetBase && int(id-trayPresetBase) < len(presets):
+		_, err = askDaemon(daemonRequest{Command: "play", Args: []string{presets[id-trayPresetBase]}})
+	default:
+		return
+	}
+	if err != nil {
+		slog.Warn("tray action failed", "item", id, "err", err)
+	}
+	t.refresh()
+}
+
+// handle answers one call from the tray host
+func (t *tray) handle(call *dbusMessage) {
+	var body []interface{}
+	switch call.iface + "." + call.member {
+	case dbusProperties + ".Get":
+		iface, _ := argString(call, 0)
+		name, _ := argString(call, 1)
+		value, ok := t.properties(iface)[name]
+		if !ok {
+			_ = t.bus.replyError(call, dbusInvalidArgs, "no property "+name+" on "+iface)
+			return
+		}
+		body = []interface{}{dbusVariant{value}}
+	case dbusProperties + ".GetAll":
+		iface, _ := argString(call, 0)
+		body = []interface{}{t.properties(iface)}
+	case dbusPeer + ".Ping":
+
+	// The icon: clicking toggles pause, scrolling changes the volume
+	case sniInterface + ".Activate", sniInterface + ".SecondaryActivate":
+		go t.act(trayPlayPause)
+	case sniInterface + ".ContextMenu":
+	case sniInterface + ".Scroll":
+		delta, _ := argInt(call, 0)
+		orientation, _ := argString(call, 1)
+		if orientation == "vertical" && delta != 0 {
+			step := fmt.Sprintf("%+d", trayVolumeStep)
+			if delta < 0 {
+				step = fmt.Sprintf("%+d", -trayVolumeStep)
+			}
+			go askDaemon(daemonRequest{Command: "volume", Args: []string{step}})
+		}
+
+	// The menu
+	case dbusMenu + ".GetLayout":
+		parent, _ := argInt(call, 0)
+		depth, _ := argInt(call, 1)
+		t.mu.Lock()
+		revision := t.revision
+		t.mu.Unlock()
+		item, ok := findTrayItem(t.menu(), parent)
+		if !ok {
+			_ = t.bus.replyError(call, dbusInvalidArgs, fmt.Sprintf("no menu item %d", parent))
+			return
+		}
+		body = []interface{}{revision, item.layout(depth)}
+	case dbusMenu + ".GetGroupProperties":
+		menu := t.menu()
+		var items []interface{}
+		for _, id := range argList(call, 0) {
+			id, _ := id.(int32)
+			if item, ok := findTrayItem(menu, id); ok {
+				items = append(items, dbusStruct{item.id, item.props})
+			}
+		}
+		body = []interface{}{dbusArray{"(ia{sv})", items}}
+	case dbusMenu + ".GetProperty":
+		id, _ := argInt(call, 0)
+		name, _ := argString(call, 1)
+		item, ok := findTrayItem(t.menu(), id)
+		value, found := item.props[name]
+		if !ok || !found {
+			_ = t.bus.replyError(call, dbusInvalidArgs, fmt.Sprintf("no property %s on menu item %d", name, id))
+			return
+		}
+		body = []interface{}{dbusVariant{value}}
+	case dbusMenu + ".Event":
+		id, _ := argInt(call, 0)
+		if event, _ := argString(call, 1); event == "clicked" {
+			go t.act(id)
+		}
+	case dbusMenu + ".EventGroup":
+		for _, event := range argList(call, 0) {
+			fields, _ := event.([]interface{})
+			if len(fields) == 4 && fields[1] == "clicked" {
+				id, _ := fields[0].(int32)
+				go t.act(id)
+			}
+		}
+		body = []interface{}{dbusArray{"i", nil}}
+	case dbusMenu + ".AboutToShow":
+		body = []interface{}{false}
+	case dbusMenu + ".AboutToShowGroup":
+		body = []interface{}{dbusArray{"i", nil}, dbusArray{"i", nil}}
+	default:
+		_ = t.bus.replyError(call, dbusUnknownMethod, "unknown method "+call.iface+"."+call.member)
+		return
+	}
+	_ = t.bus.reply(call, body...)
+}
+
+// argInt returns a call's i-th argument as an int32
+func argInt(call *dbusMessage, i int) (int32, bool) {
+	if i >= len(call.body) {
+		return 0, false
+	}
+	n, ok := call.body[i].(int32)
+	return n, ok
+}
+
+// argList returns a call's i-th argument as an array
+func argList(call *dbusMessage, i int) []interface{} {
+	if i >= len(call.body) {
+		return nil
+	}
+	list, _ := call.body[i].([]interface{})
+	return list
+}
+
+// properties returns the icon's or the menu's properties
+func (t *tray) properties(iface string) map[string]interface{} {
+	switch iface {
+	case sniInterface:
+		t.mu.Lock()
+		running, status := t.running, t.status
+		t.mu.Unlock()
+
+		icon, description := "audio-x-generic", "Stopped"
+		switch {
+		case !running:
+			description = "The daemon isn't running"
+		case status.State == "playing":
+			icon, description = "media-playback-start", status.Title
+		case status.State == "paused":
+			icon, description = "media-playback-pause", "Paused: "+status.Title
+		}
+		if running && status.Track != "" {
+			description += "\n♪ " + status.Track
+		}
+		noPixmaps := dbusArray{"(iiay)", nil}
+		return map[string]interface{}{
+			"Category":            "ApplicationStatus",
+			"Id":                  "lofitui",
+			"Title":               "lofitui",
+			"Status":              "Active",
+			"WindowId":            int32(0),
+			"IconName":            icon,
+			"IconPixmap":          noPixmaps,
+			"OverlayIconName":     "",
+			"OverlayIconPixmap":   noPixmaps,
+			"AttentionIconName":   "",
+			"AttentionIconPixmap": noPixmaps,
+			"ToolTip":             dbusStruct{icon, noPixmaps, "lofitui", description},
+			"ItemIsMenu":          false,
+			"Menu":                trayMenuPath,
+		}
+	case dbusMenu:
+		return map[string]interface{}{
+			"Version":       uint32(3),
+			"TextDirection": "ltr",
+			"Status":        "normal",
+			"IconThemePath": []string{},
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// menu builds the menu as it should look now
+func (t *tray) menu() trayItem {
+	t.mu.Lock()
+	running, status, presets := t.running, t.status, t.presets
+	t.mu.Unlock()
+
+	item := func(id int32, label string, enabled bool) trayItem {
+		return trayItem{id: id, props: map[string]interface{}{"label": label, "enabled": enabled}}
+	}
+	separator := func(id int32) trayItem {
+		return trayItem{id: id, props: map[string]interface{}{"type": "separator"}}
+	}
+
+	nowPlaying := "Stopped"
+	switch {
+	case !running:
+		nowPlaying = "The daemon isn't running"
+	case status.State != "stopped":
+		nowPlaying = status.Title
+	}
+	playPause := "Pause"
+	if status.State == "paused" {
+		playPause = "Play"
+	}
+
+	picker := item(trayPresets, "Presets", running && len(presets) > 0)
+	picker.props["children-display"] = "submenu"
+	for i, name := range presets {
+		picker.children = append(picker.children, item(trayPresetBase+int32(i), name, true))
+	}
+
+	root := trayItem{id: trayRoot, props: map[string]interface{}{"children-display": "submenu"}}
+	root.children = []trayItem{
+		item(trayNowPlaying, nowPlaying, false),
+		separator(traySeparator),
+		item(trayPlayPause, playPause, running && status.State != "stopped"),
+		item(trayStop, "Stop", running && status.State != "stopped"),
+		picker,
+		separator(traySeparator + 1),
+		item(trayQuit, "Quit lofitui", true),
+	}
+	return root
+}
+
+// findTrayItem finds the menu item with an id
+func findTrayItem(item trayItem, id int32) (trayItem, bool) {
+	if item.id == id {
+		return item, true
+	}
+	for _, child := range item.children {
+		if found, ok := findTrayItem(child, id); ok {
+			return found, true
+		}
+	}
+	return trayItem{}, false
+}
+
+// layout encodes an item and depth levels of its children (all of them
+// for -1) as DBusMenu's (ia{sv}av)
+func (item trayItem) layout(depth int32) dbusStruct {
+	children := dbusArray{elem: "v"}
+	if depth != 0 {
+		for _, child := range item.children {
+			children.items = append(children.items, dbusVariant{child.layout(depth - 1)})
+		}
+	}
+	return dbusStruct{item.id, item.props, children}
+}
@@ -0,0 +1,14 @@
+//go:build !tray
+
+package main
+
+import "errors"
+
+// runTray explains that the tray icon isn't built in by default
+func runTray(args []string) int {
+	flags := newFlagSet("tray")
+	if _, err := parseCommand(flags, args); err != nil {
+		return parseFailed(err)
+	}
+	return fail(exitError, errors.New("this lofitui was built without the tray icon; rebuild it with go build -tags tray"))
+}
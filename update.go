@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// latestReleaseURL is where GitHub says which release is newest
+const latestReleaseURL = "https://api.github.com/repos/willyv3/lofitui/releases/latest"
+
+// updateCheckTimeout bounds the update check; it only ever runs in the
+// background, so a slow network just means no notice
+const updateCheckTimeout = 10 * time.Second
+
+// updateMsg carries the newest release's version, when it's newer than
+// this one
+type updateMsg struct {
+	version string
+}
+
+// checkForUpdate asks GitHub for the newest release in the background.
+// Development builds don't know their version, so they're never told.
+func checkForUpdate(cfg *Config) tea.Cmd {
+	if !cfg.CheckForUpdates || version == "dev" {
+		return nil
+	}
+	dismissed := cfg.DismissedUpdate
+	return func() tea.Msg {
+		client := http.Client{Timeout: updateCheckTimeout}
+		req, err := http.NewRequest("GET", latestReleaseURL, nil)
+		if err != nil {
+			return nil
+		}
+		req.Header.Set("User-Agent", "lofitui/"+version+" (https://github.com/willyv3/lofitui)")
+		req.Header.Set("Accept", "application/vnd.github+json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			slog.Debug("update check failed", "err", err)
+			return nil
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			slog.Debug("update check failed", "status", resp.Status)
+			return nil
+		}
+		var release struct {
+			TagName string `json:"tag_name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+			return nil
+		}
+		latest := strings.TrimPrefix(release.TagName, "v")
+		if latest == dismissed || !newerVersion(latest, version) {
+			return nil
+		}
+		slog.Info("update available", "version", latest, "running", version)
+		return updateMsg{version: latest}
+	}
+}
+
+// newerVersion reports whether version a is newer than b, comparing
+// "1.2.3" style versions number by number
+func newerVersion(a, b string) bool {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(strings.SplitN(as[i], "-", 2)[0])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(strings.SplitN(bs[i], "-", 2)[0])
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+// updateLine renders the update notice under the presets
+func (m model) updateLine() string {
+	return lipgloss.NewStyle().Foreground(theme.Info).Render(fmt.Sprintf(
+		"  ⬆ lofitui %s is out (you have %s) • %s to dismiss", m.updateVersion, version, m.config.key("dismiss")))
+}
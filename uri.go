@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// uriScheme is the scheme of links lofitui opens, e.g.
+// lofitui://play/Lofi%20Girl
+const uriScheme = "lofitui"
+
+// uriCommands are the daemon commands a link may run; like MQTT, quit is
+// left out so a web page can't take the daemon down
+var uriCommands = map[string]bool{
+	"play": true, "pause": true, "stop": true, "next": true, "prev": true, "volume": true,
+}
+
+// parseURI splits a lofitui:// link into a daemon command and its
+// argument, e.g. lofitui://play/Lofi%20Girl is play "Lofi Girl"
+func parseURI(uri string) (command, arg string, err error) {
+	rest, ok := strings.CutPrefix(uri, uriScheme+"://")
+	if !ok {
+		return "", "", fmt.Errorf("not a %s:// link: %q", uriScheme, uri)
+	}
+	command, arg, _ = strings.Cut(rest, "/")
+	command = strings.ToLower(command)
+	if !uriCommands[command] {
+		return "", "", fmt.Errorf("unknown command %q in %q", command, uri)
+	}
+	// Browsers may add a trailing slash
+	arg, err = url.PathUnescape(strings.TrimSuffix(arg, "/"))
+	if err != nil {
+		return "", "", fmt.Errorf("bad link %q: %w", uri, err)
+	}
+	arg = strings.TrimSpace(arg)
+	// Anything with a scheme is handed to yt-dlp, so a web page only gets
+	// to play web pages
+	if command == "play" && strings.Contains(arg, "://") {
+		target, err := url.Parse(arg)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return "", "", fmt.Errorf("can only play presets and http(s) URLs, not %q", arg)
+		}
+	}
+	return command, arg, nil
+}
+
+// runOpen runs a lofitui:// link in the running daemon, or plays it here
+// when there's no daemon. -register makes lofitui the desktop's handler
+// for such links.
+func runOpen(args []string) int {
+	flags := newFlagSet("open")
+	register := flags.Bool("register", false, "Register lofitui as the handler of "+uriScheme+":// links")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	if *register {
+		if len(positional) > 0 {
+			return usageError(flags, "open -register takes no arguments")
+		}
+		if err := registerURIHandler(); err != nil {
+			return fail(exitError, err)
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "lofitui now opens %s:// links\n", uriScheme)
+		}
+		return exitOK
+	}
+	if len(positional) != 1 {
+		return usageError(flags, "open needs a "+uriScheme+":// link")
+	}
+
+	command, arg, err := parseURI(positional[0])
+	if err != nil {
+		return fail(exitUsage, err)
+	}
+	ctlArgs := []string{command}
+	if arg != "" {
+		ctlArgs = append(ctlArgs, arg)
+	}
+	if conn, err := net.Dial("unix", daemonSocketPath()); err == nil {
+		conn.Close()
+		return runCtl(ctlArgs)
+	}
+	// Without a daemon only playing makes sense
+	if command == "play" && arg != "" {
+		return runPlay([]string{arg})
+	}
+	return fail(exitError, errNoDaemon)
+}
+
+// registerURIHandler installs a desktop entry handling lofitui:// links
+// and makes it the default, the freedesktop way. macOS only takes URL
+// handlers from app bundles, so there it's left to launchers like Raycast
+// to run "lofitui open".
+func registerURIHandler() error {
+	if runtime.GOOS != "linux" && !strings.HasSuffix(runtime.GOOS, "bsd") {
+		return fmt.Errorf("registering a link handler isn't supported on %s; have your launcher run lofitui open <link>", runtime.GOOS)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(dataHome, "applications")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	const name = "lofitui-open.desktop"
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=lofitui
+Comment=Open %s:// links
+Exec="%s" open %%u
+Terminal=false
+NoDisplay=true
+MimeType=x-scheme-handler/%s;
+`, uriScheme, executable, uriScheme)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(entry), 0o644); err != nil {
+		return err
+	}
+	if output, err := exec.Command("xdg-mime", "default", name, "x-scheme-handler/"+uriScheme).CombinedOutput(); err != nil {
+		return fmt.Errorf("xdg-mime: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		command string
+		arg     string
+		wantErr bool
+	}{
+		{uri: "lofitui://play/Lofi%20Girl", command: "play", arg: "Lofi Girl"},
+		{uri: "lofitui://PAUSE/", command: "pause"},
+		{uri: "lofitui://volume/40", command: "volume", arg: "40"},
+		{uri: "lofitui://play/https%3A%2F%2Fwww.youtube.com%2Fwatch%3Fv%3DjfKfPfyJRdk", command: "play", arg: "https://www.youtube.com/watch?v=jfKfPfyJRdk"},
+		{uri: "lofitui://quit", wantErr: true},
+		{uri: "https://example.com", wantErr: true},
+		// yt-dlp options smuggled in as the URL
+		{uri: "lofitui://play/--exec%20touch%20x%20ytsearch:a://b", wantErr: true},
+		{uri: "lofitui://play/file:///etc/passwd", wantErr: true},
+		{uri: "lofitui://play/https:///nohost", wantErr: true},
+	}
+	for _, test := range tests {
+		command, arg, err := parseURI(test.uri)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseURI(%q) = %q, %q, want an error", test.uri, command, arg)
+			}
+			continue
+		}
+		if err != nil || command != test.command || arg != test.arg {
+			t.Errorf("parseURI(%q) = %q, %q, %v, want %q, %q", test.uri, command, arg, err, test.command, test.arg)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// volumeStep is how much one press of a volume key changes the volume
+const volumeStep = 5
+
+// volumeMsg reports mpv's volume after a change
+type volumeMsg struct {
+	volume float64 // 0-100
+	muted  bool
+}
+
+// readVolume reads the volume and mute state from mpv
+func readVolume() volumeMsg {
+	var msg volumeMsg
+	msg.volume, _ = mpvGetFloat("volume")
+	msg.muted, _ = mpvGetBool("mute")
+	return msg
+}
+
+// changeVolume raises or lowers the volume by delta, staying within 0-100
+func changeVolume(current, delta float64) tea.Cmd {
+	return func() tea.Msg {
+		volume := current + delta
+		if volume < 0 {
+			volume = 0
+		}
+		if volume > 100 {
+			volume = 100
+		}
+		_ = mpvSetProperty("volume", volume)
+		return readVolume()
+	}
+}
+
+// toggleMute mutes or unmutes mpv
+func toggleMute() tea.Cmd {
+	return func() tea.Msg {
+		_, _ = mpvCommand("cycle", "mute")
+		return readVolume()
+	}
+}
+
+// pausedMsg reports whether mpv is paused after a toggle
+type pausedMsg bool
+
+// togglePause pauses or resumes mpv
+func togglePause() tea.Cmd {
+	return func() tea.Msg {
+		_, _ = mpvCommand("cycle", "pause")
+		paused, _ := mpvGetBool("pause")
+		return pausedMsg(paused)
+	}
+}
+
+// setVolume records mpv's volume and animates the meter towards it
+func (m *model) setVolume(volume float64, muted bool) tea.Cmd {
+	m.muted = muted
+	if volume == m.volume && m.volumeBar.Percent() == volume/100 {
+		return nil
+	}
+	m.volume = volume
+	return m.volumeBar.SetPercent(volume / 100)
+}
+
+// volumeLine renders the volume meter for the Now Playing status line
+func (m model) volumeLine() string {
+	if m.muted {
+		// Grey the bar out so muted is obvious at a glance
+		bar := m.volumeBar
+		bar.FullColor = string(theme.Muted)
+		return "🔇 " + bar.View() + " " + lipgloss.NewStyle().Foreground(theme.Danger).Bold(true).Render("MUTED")
+	}
+	return "🔊 " + m.volumeBar.View() + fmt.Sprintf(" %3.0f%%", m.volume)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// websocketGUID is the fixed key suffix from RFC 6455
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsMaxMessage is the largest message we take from a client, put together
+// from its fragments. Clients only need to send us control frames, which
+// are far smaller.
+const wsMaxMessage = 1 << 16
+
+// wsFrame is a frame waiting to be written
+type wsFrame struct {
+	opcode  byte
+	payload []byte
+}
+
+// upgradeWebSocket completes the WebSocket handshake and takes over the
+// connection. Only what an event stream needs is supported: the server
+// sends text frames and answers pings and closes.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, nil, fmt.Errorf("not a websocket request")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, nil, fmt.Errorf("connection can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		websocketAccept(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept answering a client's
+// Sec-WebSocket-Key
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeFrame writes one unmasked, unfragmented frame
+func writeFrame(w *bufio.Writer, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// readFrame reads one frame sent by a client, unmasking its payload. fin
+// is set on the last frame of a message.
+func readFrame(r *bufio.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	// Clients must mask everything they send
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, fmt.Errorf("websocket frame from the client isn't masked")
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessage {
+		return false, 0, nil, fmt.Errorf("websocket frame too large")
+	}
+	// Control frames can't be fragmented or carry more than 125 bytes
+	if opcode >= wsClose && (!fin || length > 125) {
+		return false, 0, nil, fmt.Errorf("bad websocket control frame")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// wsReader reads whole messages from a client, putting fragmented ones
+// back together. Control frames may come between the fragments, and are
+// returned as they arrive.
+type wsReader struct {
+	r       *bufio.Reader
+	opcode  byte   // Of the fragmented message being read, 0 if none
+	partial []byte // Its fragments so far
+}
+
+// next returns the next control frame or complete message
+func (wr *wsReader) next() (opcode byte, payload []byte, err error) {
+	for {
+		fin, opcode, payload, err := readFrame(wr.r)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch {
+		case opcode >= wsClose:
+			return opcode, payload, nil
+		case opcode == wsContinuation:
+			if wr.opcode == 0 {
+				return 0, nil, fmt.Errorf("websocket continuation frame without a message")
+			}
+		case wr.opcode != 0:
+			return 0, nil, fmt.Errorf("websocket message started before the last one ended")
+		default:
+			wr.opcode = opcode
+		}
+		if len(wr.partial)+len(payload) > wsMaxMessage {
+			return 0, nil, fmt.Errorf("websocket message too large")
+		}
+		wr.partial = append(wr.partial, payload...)
+		if fin {
+			opcode, payload := wr.opcode, wr.partial
+			wr.opcode, wr.partial = 0, nil
+			return opcode, payload, nil
+		}
+	}
+}
+
+// serveEvents streams player events to a WebSocket client as JSON
+func (d *daemon) serveEvents(w http.ResponseWriter, r *http.Request) {
+	conn, rw, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	events := d.events.subscribe()
+	defer d.events.unsubscribe(events)
+
+	// Frames are written from here and the reader below, one at a time
+	writes := make(chan wsFrame, 4)
+	go func() {
+		defer close(writes)
+		reader := &wsReader{r: rw.Reader}
+		for {
+			opcode, payload, err := reader.next()
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case wsPing:
+				writes <- wsFrame{wsPong, payload}
+			case wsClose:
+				writes <- wsFrame{wsClose, payload}
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(event)
+			if writeFrame(rw.Writer, wsText, data) != nil {
+				return
+			}
+		case frame, ok := <-writes:
+			if !ok {
+				return
+			}
+			if writeFrame(rw.Writer, frame.opcode, frame.payload) != nil || frame.opcode == wsClose {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// clientFrame builds a frame as a client sends it, masked with mask
+func clientFrame(fin bool, opcode byte, payload []byte, mask [4]byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func wsReaderOf(frames ...[]byte) *wsReader {
+	return &wsReader{r: bufio.NewReader(bytes.NewReader(bytes.Join(frames, nil)))}
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	// The example from RFC 6455, section 1.3
+	if got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("websocketAccept = %q", got)
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = writeFrame(rw.Writer, wsText, []byte(`{"event":"hello"}`))
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("GET /events HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake answered %s, accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	// The server's frames are unmasked
+	frame := make([]byte, 2+len(`{"event":"hello"}`))
+	if _, err := r.Read(frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame[0] != 0x80|wsText || frame[1] != byte(len(frame)-2) || string(frame[2:]) != `{"event":"hello"}` {
+		t.Errorf("got frame % x", frame)
+	}
+}
+
+func TestWebSocketHandshakeRejectsPlainRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, _ = upgradeWebSocket(w, r)
+	}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET answered %s", resp.Status)
+	}
+}
+
+func TestWebSocketWriteFrameLengths(t *testing.T) {
+	for _, test := range []struct {
+		n      int
+		header []byte
+	}{
+		{5, []byte{0x81, 5}},
+		{126, []byte{0x81, 126, 0, 126}},
+		{0x10000, []byte{0x81, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	} {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		if err := writeFrame(w, wsText, make([]byte, test.n)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), test.header) || buf.Len() != len(test.header)+test.n {
+			t.Errorf("%d bytes: frame starts % x, %d long", test.n, buf.Bytes()[:len(test.header)], buf.Len())
+		}
+	}
+}
+
+func TestWebSocketUnmasks(t *testing.T) {
+	reader := wsReaderOf(clientFrame(true, wsPing, []byte("ping!"), [4]byte{0x37, 0xfa, 0x21, 0x3d}))
+	opcode, payload, err := reader.next()
+	if err != nil || opcode != wsPing || string(payload) != "ping!" {
+		t.Fatalf("next = %#x %q %v", opcode, payload, err)
+	}
+}
+
+func TestWebSocketFragmentedMessage(t *testing.T) {
+	mask := [4]byte{1, 2, 3, 4}
+	reader := wsReaderOf(
+		clientFrame(false, wsText, []byte("Hel"), mask),
+		// A control frame may come between the fragments
+		clientFrame(true, wsPing, []byte("p"), mask),
+		clientFrame(false, wsContinuation, []byte("lo, "), mask),
+		clientFrame(true, wsContinuation, []byte("world"), mask),
+		clientFrame(true, wsClose, nil, mask),
+	)
+	want := []struct {
+		opcode  byte
+		payload string
+	}{{wsPing, "p"}, {wsText, "Hello, world"}, {wsClose, ""}}
+	for _, w := range want {
+		opcode, payload, err := reader.next()
+		if err != nil || opcode != w.opcode || string(payload) != w.payload {
+			t.Fatalf("next = %#x %q %v, want %#x %q", opcode, payload, err, w.opcode, w.payload)
+		}
+	}
+}
+
+func TestWebSocketBadFrames(t *testing.T) {
+	mask := [4]byte{9, 9, 9, 9}
+	big := make([]byte, wsMaxMessage/2+1)
+	tests := map[string]*wsReader{
+		"unmasked":                  {r: bufio.NewReader(bytes.NewReader([]byte{0x89, 0}))},
+		"oversize frame":            wsReaderOf(clientFrame(true, wsText, make([]byte, wsMaxMessage+1), mask)),
+		"oversize message":          wsReaderOf(clientFrame(false, wsText, big, mask), clientFrame(true, wsContinuation, big, mask)),
+		"fragmented control":        wsReaderOf(clientFrame(false, wsPing, []byte("p"), mask)),
+		"long control":              wsReaderOf(clientFrame(true, wsPing, make([]byte, 126), mask)),
+		"stray continuation":        wsReaderOf(clientFrame(true, wsContinuation, []byte("x"), mask)),
+		"message inside a message":  wsReaderOf(clientFrame(false, wsText, []byte("a"), mask), clientFrame(true, wsText, []byte("b"), mask)),
+		"cut short":                 wsReaderOf(clientFrame(true, wsText, []byte("hello"), mask)[:6]),
+		"huge 64-bit length header": {r: bufio.NewReader(bytes.NewReader([]byte{0x81, 0xFF, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}))},
+	}
+	for name, reader := range tests {
+		if opcode, payload, err := reader.next(); err == nil {
+			t.Errorf("%s: read %#x %q", name, opcode, payload)
+		}
+	}
+}
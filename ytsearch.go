@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// searchTimeout bounds a YouTube search
+const searchTimeout = time.Minute
+
+// liveSearchURL is YouTube's search with the "Live" filter applied
+const liveSearchURL = "https://www.youtube.com/results?sp=EgJAAQ%3D%3D&search_query="
+
+// searchResult is a live stream found by "lofitui search"
+type searchResult struct {
+	Title   string `json:"title"`
+	Channel string `json:"channel"`
+	Viewers int    `json:"viewers"` // Zero when YouTube doesn't say
+	URL     string `json:"url"`
+}
+
+// searchLive asks yt-dlp for live streams matching query, without
+// resolving each one so it stays quick
+func searchLive(ctx context.Context, query string, limit int) ([]searchResult, error) {
+	cmd := exec.CommandContext(ctx, "yt-dlp", "--flat-playlist", "--dump-json", "--no-warnings",
+		"--playlist-end", strconv.Itoa(limit), "--", liveSearchURL+url.QueryEscape(query))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("search failed: %s", msg)
+		}
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	var results []searchResult
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Title               string `json:"title"`
+			Channel             string `json:"channel"`
+			Uploader            string `json:"uploader"`
+			URL                 string `json:"url"`
+			LiveStatus          string `json:"live_status"`
+			ConcurrentViewCount int    `json:"concurrent_view_count"`
+			ViewCount           int    `json:"view_count"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.URL == "" {
+			continue
+		}
+		// Channels and playlists can sneak into the results
+		if entry.LiveStatus != "" && entry.LiveStatus != "is_live" {
+			continue
+		}
+		result := searchResult{Title: entry.Title, Channel: entry.Channel, Viewers: entry.ConcurrentViewCount, URL: entry.URL}
+		if result.Channel == "" {
+			result.Channel = entry.Uploader
+		}
+		if result.Viewers == 0 {
+			// Flat search results give a live stream's watchers as its views
+			result.Viewers = entry.ViewCount
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runSearch prints live streams on YouTube matching a query
+func runSearch(args []string) int {
+	flags := newFlagSet("search")
+	limit := flags.Int("limit", 10, "Show up to `N` streams")
+	asJSON := flags.Bool("json", false, "Print JSON instead of a table")
+	positional, err := parseCommand(flags, args)
+	if err != nil {
+		return parseFailed(err)
+	}
+	query := strings.TrimSpace(strings.Join(positional, " "))
+	if query == "" {
+		return usageError(flags, "search needs a query")
+	}
+	if *limit <= 0 {
+		fmt.Fprintln(os.Stderr, "lofitui: -limit must be positive")
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+	results, err := searchLive(ctx, query, *limit)
+	if err != nil {
+		return fail(exitExtraction, err)
+	}
+
+	if *asJSON {
+		if results == nil {
+			results = []searchResult{}
+		}
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fail(exitError, err)
+		}
+		fmt.Println(string(data))
+		return exitOK
+	}
+
+	if len(results) == 0 {
+		if !quiet {
+			fmt.Fprintln(os.Stderr, "No live streams found")
+		}
+		return exitOK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TITLE\tCHANNEL\tVIEWERS\tURL")
+	for _, result := range results {
+		viewers := "-"
+		if result.Viewers > 0 {
+			viewers = strconv.Itoa(result.Viewers)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Title, result.Channel, viewers, result.URL)
+	}
+	w.Flush()
+	return exitOK
+}
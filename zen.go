@@ -0,0 +1,164 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// How fast the zen marquee scrolls, and the gap between repeats of the title
+const (
+	marqueeStep = 120 * time.Millisecond
+	marqueeGap  = 8
+)
+
+// bigFont is a 5 row block font for the zen title; '#' is a filled cell
+var bigFont = map[rune][5]string{
+	'A':  {" ### ", "#   #", "#####", "#   #", "#   #"},
+	'B':  {"#### ", "#   #", "#### ", "#   #", "#### "},
+	'C':  {" ####", "#    ", "#    ", "#    ", " ####"},
+	'D':  {"#### ", "#   #", "#   #", "#   #", "#### "},
+	'E':  {"#####", "#    ", "#### ", "#    ", "#####"},
+	'F':  {"#####", "#    ", "#### ", "#    ", "#    "},
+	'G':  {" ####", "#    ", "#  ##", "#   #", " ####"},
+	'H':  {"#   #", "#   #", "#####", "#   #", "#   #"},
+	'I':  {"###", " # ", " # ", " # ", "###"},
+	'J':  {"  ###", "   # ", "   # ", "#  # ", " ##  "},
+	'K':  {"#   #", "#  # ", "###  ", "#  # ", "#   #"},
+	'L':  {"#    ", "#    ", "#    ", "#    ", "#####"},
+	'M':  {"#   #", "## ##", "# # #", "#   #", "#   #"},
+	'N':  {"#   #", "##  #", "# # #", "#  ##", "#   #"},
+	'O':  {" ### ", "#   #", "#   #", "#   #", " ### "},
+	'P':  {"#### ", "#   #", "#### ", "#    ", "#    "},
+	'Q':  {" ### ", "#   #", "# # #", "#  # ", " ## #"},
+	'R':  {"#### ", "#   #", "#### ", "#  # ", "#   #"},
+	'S':  {" ####", "#    ", " ### ", "    #", "#### "},
+	'T':  {"#####", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U':  {"#   #", "#   #", "#   #", "#   #", " ### "},
+	'V':  {"#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W':  {"#   #", "#   #", "# # #", "## ##", "#   #"},
+	'X':  {"#   #", " # # ", "  #  ", " # # ", "#   #"},
+	'Y':  {"#   #", " # # ", "  #  ", "  #  ", "  #  "},
+	'Z':  {"#####", "   # ", "  #  ", " #   ", "#####"},
+	'0':  {" ### ", "#  ##", "# # #", "##  #", " ### "},
+	'1':  {" # ", "## ", " # ", " # ", "###"},
+	'2':  {" ### ", "#   #", "  ## ", " #   ", "#####"},
+	'3':  {"#### ", "    #", " ### ", "    #", "#### "},
+	'4':  {"#   #", "#   #", "#####", "    #", "    #"},
+	'5':  {"#####", "#    ", "#### ", "    #", "#### "},
+	'6':  {" ### ", "#    ", "#### ", "#   #", " ### "},
+	'7':  {"#####", "    #", "   # ", "  #  ", "  #  "},
+	'8':  {" ### ", "#   #", " ### ", "#   #", " ### "},
+	'9':  {" ### ", "#   #", " ####", "    #", " ### "},
+	' ':  {"   ", "   ", "   ", "   ", "   "},
+	'-':  {"   ", "   ", "###", "   ", "   "},
+	'.':  {" ", " ", " ", " ", "#"},
+	',':  {"  ", "  ", "  ", " #", "# "},
+	'!':  {"#", "#", "#", " ", "#"},
+	'?':  {"### ", "   #", " ## ", "    ", " #  "},
+	'\'': {"#", "#", " ", " ", " "},
+	':':  {" ", "#", " ", "#", " "},
+	'&':  {" ##  ", "#  # ", " ## #", "#  # ", " ## #"},
+	'/':  {"    #", "   # ", "  #  ", " #   ", "#    "},
+	'|':  {"#", "#", "#", "#", "#"},
+	'(':  {" #", "# ", "# ", "# ", " #"},
+	')':  {"# ", " #", " #", " #", "# "},
+}
+
+// zenTickMsg advances the marquee of the zen session that scheduled it
+type zenTickMsg struct {
+	started time.Time
+}
+
+// zenTick schedules the next marquee step
+func zenTick(started time.Time) tea.Cmd {
+	return tea.Tick(marqueeStep, func(time.Time) tea.Msg {
+		return zenTickMsg{started: started}
+	})
+}
+
+// bigText renders s in the block font. ok is false when s has letters the
+// font can't draw (e.g. a title in Japanese), so the caller can fall back
+// to plain text; other symbols such as emoji are just left out.
+func bigText(s string) (lines [5]string, ok bool) {
+	for _, r := range strings.ToUpper(s) {
+		glyph, found := bigFont[r]
+		if !found {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) {
+				return lines, false
+			}
+			continue
+		}
+		for i := range lines {
+			lines[i] += strings.ReplaceAll(glyph[i], "#", "█") + " "
+		}
+	}
+	return lines, strings.TrimSpace(lines[0]+lines[4]) != ""
+}
+
+// marquee returns the width-cell window of line scrolled by offset,
+// wrapping around with a gap. Lines that fit are returned as is.
+func marquee(line []rune, width, offset int) string {
+	if len(line) <= width {
+		return string(line)
+	}
+	loop := append(append([]rune{}, line...), []rune(strings.Repeat(" ", marqueeGap))...)
+	start := offset % len(loop)
+	window := make([]rune, width)
+	for i := range window {
+		window[i] = loop[(start+i)%len(loop)]
+	}
+	return string(window)
+}
+
+// zenView renders the full screen now playing mode
+func (m model) zenView() string {
+	offset := int(time.Since(m.zenStarted) / marqueeStep)
+	accent := lipgloss.NewStyle().Foreground(theme.Accent).Bold(theme.Bold)
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+
+	// Big letters when they fit vertically and the title is drawable
+	var title string
+	lines, ok := bigText(m.nowPlaying)
+	if ok && m.height >= compactHeight {
+		rows := make([]string, len(lines))
+		for i, line := range lines {
+			rows[i] = accent.Render(marquee([]rune(line), m.width, offset))
+		}
+		title = strings.Join(rows, "\n")
+	} else {
+		// Wide characters take two cells, so cut the window to fit
+		title = accent.Render(ansi.Truncate(marquee([]rune(m.nowPlaying), m.width, offset), m.width, ""))
+	}
+
+	status := lipgloss.NewStyle().Foreground(theme.Danger).Render("● LIVE")
+	if m.playback.duration > 0 {
+		status = formatDuration(time.Duration(m.playback.position)*time.Second) +
+			" / " + formatDuration(time.Duration(m.playback.duration)*time.Second)
+	}
+	if m.playback.track != "" {
+		status = "♪ " + m.playback.track + "  " + status
+	}
+	if m.playback.paused {
+		status += "  " + lipgloss.NewStyle().Foreground(theme.Warning).Render("⏸ Paused")
+	}
+	if m.muted {
+		status += "  " + lipgloss.NewStyle().Foreground(theme.Danger).Bold(true).Render("🔇 MUTED")
+	}
+	if m.pomodoro != nil {
+		status += "  " + m.pomodoro.status()
+	}
+	if !m.sleepDeadline.IsZero() {
+		status += "  " + sleepStatus(m.sleepDeadline)
+	}
+
+	status = ansi.Truncate(status, m.width, "…")
+
+	help := muted.Render(m.config.key("zen") + "/ESC=leave zen • " + m.config.key("stop") + "=stop")
+	content := lipgloss.JoinVertical(lipgloss.Center, title, "", status, "", help)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
+}